@@ -0,0 +1,111 @@
+package nsigii
+
+// ============================================================================
+// Streaming Statistics
+// ============================================================================
+
+// StatsAccumulator computes TokenStats one token at a time, for token
+// streams never held in one slice: TokenizeReader callbacks, chunks
+// tokenized in parallel, or every file of a tree. Accumulators of separate
+// chunks combine with Merge. The zero value is empty and ready to use, with
+// default histogram options; an accumulator is not safe for concurrent use.
+//
+// Example:
+//   var total nsigii.StatsAccumulator
+//   for _, source := range sources {
+//       var acc nsigii.StatsAccumulator
+//       err := ctx.TokenizeReader(strings.NewReader(source), func(t nsigii.Token) error {
+//           acc.Add(t)
+//           return nil
+//       })
+//       if err != nil {
+//           log.Fatal(err)
+//       }
+//       total.Merge(&acc)
+//   }
+//   fmt.Println(total.Stats().AverageLength)
+type StatsAccumulator struct {
+	opts    HistogramOptions
+	minMem  uint32
+	maxMem  uint32
+	length  uint64 // sum of Value
+	lengths lengthCounts
+	types   map[TokenType]*lengthCounts
+}
+
+// NewStatsAccumulator creates an empty accumulator whose histograms are
+// configured by opts
+func NewStatsAccumulator(opts HistogramOptions) *StatsAccumulator {
+	return &StatsAccumulator{opts: opts}
+}
+
+// Add counts t
+func (a *StatsAccumulator) Add(t Token) {
+	if a.types == nil {
+		a.types = make(map[TokenType]*lengthCounts)
+	}
+	if a.lengths.total == 0 || t.Memory < a.minMem {
+		a.minMem = t.Memory
+	}
+	if a.lengths.total == 0 || t.Memory > a.maxMem {
+		a.maxMem = t.Memory
+	}
+	a.length += uint64(t.Value)
+	a.lengths.add(t.Value, 1)
+	a.typeCounts(t.Type).add(t.Value, 1)
+}
+
+// typeCounts returns the length counts of typ's tokens
+func (a *StatsAccumulator) typeCounts(typ TokenType) *lengthCounts {
+	l, ok := a.types[typ]
+	if !ok {
+		l = new(lengthCounts)
+		a.types[typ] = l
+	}
+	return l
+}
+
+// Merge adds everything other has counted, as if its tokens had been added
+// to a. other is left unchanged, and a keeps its own histogram options.
+func (a *StatsAccumulator) Merge(other *StatsAccumulator) {
+	if other.lengths.total == 0 {
+		return
+	}
+	if a.types == nil {
+		a.types = make(map[TokenType]*lengthCounts, len(other.types))
+	}
+	if a.lengths.total == 0 || other.minMem < a.minMem {
+		a.minMem = other.minMem
+	}
+	if a.lengths.total == 0 || other.maxMem > a.maxMem {
+		a.maxMem = other.maxMem
+	}
+	a.length += other.length
+	a.lengths.merge(&other.lengths)
+	for typ, l := range other.types {
+		a.typeCounts(typ).merge(l)
+	}
+}
+
+// Stats returns the statistics of the tokens counted so far, as
+// AnalyzeTokens would compute them over the same tokens
+func (a *StatsAccumulator) Stats() TokenStats {
+	opts := a.opts.normalized()
+	stats := TokenStats{
+		TotalTokens:      a.lengths.total,
+		TypeDistribution: make(map[TokenType]int, len(a.types)),
+		LengthHistogram:  a.lengths.histogram(opts),
+		TypeLengths:      make(map[TokenType]Histogram, len(a.types)),
+	}
+	for typ, l := range a.types {
+		stats.TypeDistribution[typ] = l.total
+		stats.TypeLengths[typ] = l.histogram(opts)
+	}
+
+	if a.lengths.total == 0 {
+		return stats
+	}
+	stats.MemoryRange = [2]uint32{a.minMem, a.maxMem}
+	stats.AverageLength = float64(a.length) / float64(a.lengths.total)
+	return stats
+}
@@ -0,0 +1,152 @@
+package nsigii
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ============================================================================
+// Critical Alerts
+// ============================================================================
+
+// alertAuditTail is the number of audit entries attached to an alert
+const alertAuditTail = 16
+
+// Alert describes a context entering MAGENTA
+type Alert struct {
+	Schema    string       `json:"schema"`               // obinexus.[operation].[service]
+	PhantomID *PhantomID   `json:"phantom_id,omitempty"` // Identity of the context, if available
+	From      ColorChannel `json:"from"`                 // State the context left
+	Reason    string       `json:"reason,omitempty"`     // Reason given for the transition
+	Time      time.Time    `json:"time"`
+	Audit     []AuditEntry `json:"audit"` // Most recent audit entries, oldest first
+}
+
+// AlertSink delivers critical alerts to humans or other systems
+type AlertSink interface {
+	SendAlert(ctx context.Context, alert Alert) error
+}
+
+// AlertSinkFunc adapts an ordinary function to an AlertSink
+type AlertSinkFunc func(ctx context.Context, alert Alert) error
+
+// SendAlert calls f(ctx, alert)
+func (f AlertSinkFunc) SendAlert(ctx context.Context, alert Alert) error {
+	return f(ctx, alert)
+}
+
+// SetAlertSink makes the context send an Alert to sink whenever it enters
+// MAGENTA. Alerts are delivered on their own goroutine, so a slow sink never
+// delays the transition; each delivery and its outcome is recorded in the
+// color audit trail. A nil sink disables alerts.
+//
+// Example:
+//   ctx.SetAlertSink(&nsigii.WebhookSink{URL: "https://pager.example.com/hook"})
+func (c *Context) SetAlertSink(sink AlertSink) error {
+	if err := c.require(RoleAdmin, "SetAlertSink"); err != nil {
+		return err
+	}
+	c.color.mu.Lock()
+	defer c.color.mu.Unlock()
+	c.alertSink = sink
+	return nil
+}
+
+// alert sends an Alert for a transition into MAGENTA
+func (c *Context) alert(sink AlertSink, t ColorTransition) {
+	a := Alert{
+		From:   t.From,
+		Reason: t.Reason,
+		Time:   t.Time,
+		Audit:  c.auditTail(alertAuditTail),
+	}
+	if !c.closed() {
+		a.Schema, _ = c.Schema()
+		if id, err := c.PhantomID(); err == nil {
+			a.PhantomID = &id
+		}
+	}
+
+	go func() {
+		err := sink.SendAlert(context.Background(), a)
+
+		e := AuditEntry{
+			Time:   time.Now(),
+			Kind:   AuditAlert,
+			From:   ColorMagenta,
+			To:     ColorMagenta,
+			Passed: err == nil,
+		}
+		if err != nil {
+			e.Reason = err.Error()
+		}
+		c.audit.record(e)
+	}()
+}
+
+// ChannelSink delivers alerts to a channel without blocking, failing if the
+// channel is not ready to receive
+type ChannelSink chan<- Alert
+
+// SendAlert sends alert on the channel
+func (s ChannelSink) SendAlert(ctx context.Context, alert Alert) error {
+	select {
+	case s <- alert:
+		return nil
+	default:
+		return errors.New("alert channel full")
+	}
+}
+
+// WebhookSink POSTs alerts as JSON to a URL, in the style of paging
+// services' event APIs
+type WebhookSink struct {
+	URL    string          // Endpoint receiving the alert
+	Header http.Header     // Extra request headers (e.g. authorization)
+	Client *http.Client    // nil for a client with a 10 second timeout
+	Encode func(Alert) any // Maps the alert to the request body; nil sends the Alert itself
+}
+
+// defaultWebhookClient is used by WebhookSinks without a client
+var defaultWebhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// SendAlert posts alert to s.URL. Non-2xx responses are errors.
+func (s *WebhookSink) SendAlert(ctx context.Context, alert Alert) error {
+	var body any = alert
+	if s.Encode != nil {
+		body = s.Encode(alert)
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for k, v := range s.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := s.Client
+	if client == nil {
+		client = defaultWebhookClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("alert webhook returned %s", resp.Status)
+	}
+	return nil
+}
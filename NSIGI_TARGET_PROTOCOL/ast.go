@@ -0,0 +1,372 @@
+package nsigii
+
+// ============================================================================
+// Abstract Syntax Tree (RIFT Stage 222)
+// ============================================================================
+
+// Position is the location of a node's first byte in the source
+type Position struct {
+	Offset uint32 // Byte offset
+	Line   int    // 1-based line (0 if not tracked)
+	Column int    // 1-based byte column (0 if not tracked)
+}
+
+// tokenPos returns the position of t
+func tokenPos(t Token) Position {
+	return Position{Offset: t.Memory, Line: t.Line, Column: t.Column}
+}
+
+// Node is an element of a RIFT syntax tree
+type Node interface {
+	Pos() Position    // Position of the node's first token
+	End() uint32      // Byte offset just past the node's last token
+	Children() []Node // Direct children in source order
+}
+
+// Stmt is a statement node
+type Stmt interface {
+	Node
+	stmtNode()
+}
+
+// Expr is an expression node
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// children collects the non-nil nodes among ns
+func children(ns ...Node) []Node {
+	out := make([]Node, 0, len(ns))
+	for _, n := range ns {
+		if n != nil {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// semiEnd returns the end of a statement closed by semi, or of its last
+// node if it has no semicolon (a for-loop post statement)
+func semiEnd(semi Token, last Node) uint32 {
+	if semi.Lexeme() == ";" {
+		return semi.EndOffset
+	}
+	return last.End()
+}
+
+// ============================================================================
+// Program and Statements
+// ============================================================================
+
+// Program is the root of a syntax tree: the statements of a source
+type Program struct {
+	Stmts []Stmt
+	EOF   Token
+}
+
+func (n *Program) Pos() Position {
+	if len(n.Stmts) > 0 {
+		return n.Stmts[0].Pos()
+	}
+	return tokenPos(n.EOF)
+}
+func (n *Program) End() uint32 { return n.EOF.EndOffset }
+func (n *Program) Children() []Node {
+	out := make([]Node, len(n.Stmts))
+	for i, s := range n.Stmts {
+		out[i] = s
+	}
+	return out
+}
+
+// Block is a brace-delimited list of statements
+type Block struct {
+	Lbrace Token
+	Stmts  []Stmt
+	Rbrace Token
+}
+
+func (n *Block) Pos() Position { return tokenPos(n.Lbrace) }
+func (n *Block) End() uint32   { return n.Rbrace.EndOffset }
+func (n *Block) Children() []Node {
+	out := make([]Node, len(n.Stmts))
+	for i, s := range n.Stmts {
+		out[i] = s
+	}
+	return out
+}
+
+// LetStmt declares a variable: let, const or var
+type LetStmt struct {
+	Keyword Token
+	Name    *Ident
+	Value   Expr // nil if the declaration has no initializer
+	Semi    Token
+}
+
+func (n *LetStmt) Pos() Position    { return tokenPos(n.Keyword) }
+func (n *LetStmt) End() uint32      { return n.Semi.EndOffset }
+func (n *LetStmt) Children() []Node { return children(n.Name, n.Value) }
+
+// FuncDecl declares a function: func, fn or function
+type FuncDecl struct {
+	Keyword Token
+	Name    *Ident
+	Params  []*Ident
+	Body    *Block
+}
+
+func (n *FuncDecl) Pos() Position { return tokenPos(n.Keyword) }
+func (n *FuncDecl) End() uint32   { return n.Body.End() }
+func (n *FuncDecl) Children() []Node {
+	out := make([]Node, 0, len(n.Params)+2)
+	out = append(out, n.Name)
+	for _, p := range n.Params {
+		out = append(out, p)
+	}
+	return append(out, n.Body)
+}
+
+// ReturnStmt returns from a function
+type ReturnStmt struct {
+	Keyword Token
+	Value   Expr // nil for a bare return
+	Semi    Token
+}
+
+func (n *ReturnStmt) Pos() Position    { return tokenPos(n.Keyword) }
+func (n *ReturnStmt) End() uint32      { return n.Semi.EndOffset }
+func (n *ReturnStmt) Children() []Node { return children(n.Value) }
+
+// IfStmt is a conditional; Else is nil, a *Block or an *IfStmt
+type IfStmt struct {
+	Keyword Token
+	Cond    Expr
+	Then    *Block
+	Else    Stmt
+}
+
+func (n *IfStmt) Pos() Position { return tokenPos(n.Keyword) }
+func (n *IfStmt) End() uint32 {
+	if n.Else != nil {
+		return n.Else.End()
+	}
+	return n.Then.End()
+}
+func (n *IfStmt) Children() []Node { return children(n.Cond, n.Then, n.Else) }
+
+// WhileStmt loops while Cond holds
+type WhileStmt struct {
+	Keyword Token
+	Cond    Expr
+	Body    *Block
+}
+
+func (n *WhileStmt) Pos() Position    { return tokenPos(n.Keyword) }
+func (n *WhileStmt) End() uint32      { return n.Body.End() }
+func (n *WhileStmt) Children() []Node { return children(n.Cond, n.Body) }
+
+// ForStmt is a C-style loop; Init, Cond and Post may each be nil
+type ForStmt struct {
+	Keyword Token
+	Init    Stmt
+	Cond    Expr
+	Post    Stmt
+	Body    *Block
+}
+
+func (n *ForStmt) Pos() Position    { return tokenPos(n.Keyword) }
+func (n *ForStmt) End() uint32      { return n.Body.End() }
+func (n *ForStmt) Children() []Node { return children(n.Init, n.Cond, n.Post, n.Body) }
+
+// BranchStmt is break or continue
+type BranchStmt struct {
+	Keyword Token
+	Semi    Token
+}
+
+func (n *BranchStmt) Pos() Position    { return tokenPos(n.Keyword) }
+func (n *BranchStmt) End() uint32      { return n.Semi.EndOffset }
+func (n *BranchStmt) Children() []Node { return nil }
+
+// ImportStmt imports a module by its string path
+type ImportStmt struct {
+	Keyword Token
+	Path    *Literal
+	Semi    Token
+}
+
+func (n *ImportStmt) Pos() Position    { return tokenPos(n.Keyword) }
+func (n *ImportStmt) End() uint32      { return n.Semi.EndOffset }
+func (n *ImportStmt) Children() []Node { return children(n.Path) }
+
+// ExprStmt is an expression evaluated for its effect
+type ExprStmt struct {
+	X    Expr
+	Semi Token // zero in a for-loop post statement
+}
+
+func (n *ExprStmt) Pos() Position    { return n.X.Pos() }
+func (n *ExprStmt) End() uint32      { return semiEnd(n.Semi, n.X) }
+func (n *ExprStmt) Children() []Node { return children(n.X) }
+
+func (*Block) stmtNode()      {}
+func (*LetStmt) stmtNode()    {}
+func (*FuncDecl) stmtNode()   {}
+func (*ReturnStmt) stmtNode() {}
+func (*IfStmt) stmtNode()     {}
+func (*WhileStmt) stmtNode()  {}
+func (*ForStmt) stmtNode()    {}
+func (*BranchStmt) stmtNode() {}
+func (*ImportStmt) stmtNode() {}
+func (*ExprStmt) stmtNode()   {}
+
+// ============================================================================
+// Expressions
+// ============================================================================
+
+// Ident is an identifier
+type Ident struct {
+	Token Token
+}
+
+// Name returns the identifier's text
+func (n *Ident) Name() string { return n.Token.Lexeme() }
+
+func (n *Ident) Pos() Position    { return tokenPos(n.Token) }
+func (n *Ident) End() uint32      { return n.Token.EndOffset }
+func (n *Ident) Children() []Node { return nil }
+
+// Literal is a number, string, true, false or null
+type Literal struct {
+	Token Token
+}
+
+func (n *Literal) Pos() Position    { return tokenPos(n.Token) }
+func (n *Literal) End() uint32      { return n.Token.EndOffset }
+func (n *Literal) Children() []Node { return nil }
+
+// ParenExpr is a parenthesized expression
+type ParenExpr struct {
+	Lparen Token
+	X      Expr
+	Rparen Token
+}
+
+func (n *ParenExpr) Pos() Position    { return tokenPos(n.Lparen) }
+func (n *ParenExpr) End() uint32      { return n.Rparen.EndOffset }
+func (n *ParenExpr) Children() []Node { return children(n.X) }
+
+// UnaryExpr is a prefix operator (-x, !x, ~x, +x) or a postfix increment
+// or decrement (x++, x--)
+type UnaryExpr struct {
+	Op      Token
+	X       Expr
+	Postfix bool
+}
+
+func (n *UnaryExpr) Pos() Position {
+	if n.Postfix {
+		return n.X.Pos()
+	}
+	return tokenPos(n.Op)
+}
+func (n *UnaryExpr) End() uint32 {
+	if n.Postfix {
+		return n.Op.EndOffset
+	}
+	return n.X.End()
+}
+func (n *UnaryExpr) Children() []Node { return children(n.X) }
+
+// BinaryExpr is X Op Y
+type BinaryExpr struct {
+	X  Expr
+	Op Token
+	Y  Expr
+}
+
+func (n *BinaryExpr) Pos() Position    { return n.X.Pos() }
+func (n *BinaryExpr) End() uint32      { return n.Y.End() }
+func (n *BinaryExpr) Children() []Node { return children(n.X, n.Y) }
+
+// AssignExpr is X = Y or a compound assignment such as X += Y
+type AssignExpr struct {
+	X  Expr
+	Op Token
+	Y  Expr
+}
+
+func (n *AssignExpr) Pos() Position    { return n.X.Pos() }
+func (n *AssignExpr) End() uint32      { return n.Y.End() }
+func (n *AssignExpr) Children() []Node { return children(n.X, n.Y) }
+
+// CallExpr is Fun(Args...)
+type CallExpr struct {
+	Fun    Expr
+	Lparen Token
+	Args   []Expr
+	Rparen Token
+}
+
+func (n *CallExpr) Pos() Position { return n.Fun.Pos() }
+func (n *CallExpr) End() uint32   { return n.Rparen.EndOffset }
+func (n *CallExpr) Children() []Node {
+	out := make([]Node, 0, len(n.Args)+1)
+	out = append(out, n.Fun)
+	for _, a := range n.Args {
+		out = append(out, a)
+	}
+	return out
+}
+
+// IndexExpr is X[Index]
+type IndexExpr struct {
+	X      Expr
+	Lbrack Token
+	Index  Expr
+	Rbrack Token
+}
+
+func (n *IndexExpr) Pos() Position    { return n.X.Pos() }
+func (n *IndexExpr) End() uint32      { return n.Rbrack.EndOffset }
+func (n *IndexExpr) Children() []Node { return children(n.X, n.Index) }
+
+// SelectorExpr is X.Sel
+type SelectorExpr struct {
+	X   Expr
+	Sel *Ident
+}
+
+func (n *SelectorExpr) Pos() Position    { return n.X.Pos() }
+func (n *SelectorExpr) End() uint32      { return n.Sel.End() }
+func (n *SelectorExpr) Children() []Node { return children(n.X, n.Sel) }
+
+// ListExpr is a list literal [a, b, c]
+type ListExpr struct {
+	Lbrack Token
+	Elems  []Expr
+	Rbrack Token
+}
+
+func (n *ListExpr) Pos() Position { return tokenPos(n.Lbrack) }
+func (n *ListExpr) End() uint32   { return n.Rbrack.EndOffset }
+func (n *ListExpr) Children() []Node {
+	out := make([]Node, len(n.Elems))
+	for i, e := range n.Elems {
+		out[i] = e
+	}
+	return out
+}
+
+func (*Ident) exprNode()        {}
+func (*Literal) exprNode()      {}
+func (*ParenExpr) exprNode()    {}
+func (*UnaryExpr) exprNode()    {}
+func (*BinaryExpr) exprNode()   {}
+func (*AssignExpr) exprNode()   {}
+func (*CallExpr) exprNode()     {}
+func (*IndexExpr) exprNode()    {}
+func (*SelectorExpr) exprNode() {}
+func (*ListExpr) exprNode()     {}
@@ -0,0 +1,170 @@
+package nsigii
+
+import (
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Color Audit Trail
+// ============================================================================
+
+// AuditKind classifies audit entries
+type AuditKind int
+
+const (
+	AuditTransition AuditKind = 0 // Color state change
+	AuditConsensus  AuditKind = 1 // RGB consensus check and its result
+	AuditAlert      AuditKind = 2 // Alert delivery and its result
+	AuditContrast   AuditKind = 3 // Negative-path check and its result
+	AuditPolarity   AuditKind = 4 // Polarity inversion and its result
+	AuditTokenize   AuditKind = 5 // Tokenization and its result; recorded only in an AuditLog
+)
+
+var auditKindNames = []string{"TRANSITION", "CONSENSUS", "ALERT", "CONTRAST", "POLARITY", "TOKENIZE"}
+
+func (k AuditKind) String() string {
+	if k >= 0 && int(k) < len(auditKindNames) {
+		return auditKindNames[k]
+	}
+	return "UNKNOWN"
+}
+
+// AuditEntry is one record in a context's color audit trail
+type AuditEntry struct {
+	Seq    uint64       `json:"seq"`              // 1-based position in the context's trail
+	Time   time.Time    `json:"time"`             // When the event happened
+	Kind   AuditKind    `json:"kind"`             // What happened
+	From   ColorChannel `json:"from"`             // State before (transitions) or during (checks)
+	To     ColorChannel `json:"to"`               // State after; equal to From for checks
+	Reason string       `json:"reason,omitempty"` // Transition reason or alert failure, if any
+	Passed bool         `json:"passed"`           // Check result; true for transitions
+}
+
+// AuditSink persists audit entries beyond the in-memory ring. Record is
+// called synchronously, in order, for every entry; it must not call back
+// into the context's color methods.
+type AuditSink interface {
+	Record(entry AuditEntry)
+}
+
+// AuditSinkFunc adapts an ordinary function to an AuditSink
+type AuditSinkFunc func(entry AuditEntry)
+
+// Record calls f(entry)
+func (f AuditSinkFunc) Record(entry AuditEntry) {
+	f(entry)
+}
+
+// defaultAuditCapacity is the number of entries a context keeps in memory
+const defaultAuditCapacity = 256
+
+// auditRing is an append-only ring of the most recent audit entries
+type auditRing struct {
+	mu      sync.Mutex
+	entries []AuditEntry // ring storage, len <= capacity
+	next    int          // index the next entry is written to
+	seq     uint64
+	limit   int // 0 means defaultAuditCapacity
+	sink    AuditSink
+	log     *AuditLog
+	schema  string // schema recorded with entries in log
+}
+
+// SetAuditCapacity sets how many recent entries ColorAudit retains. Older
+// entries are discarded from memory (but were already given to any sink).
+// n <= 0 restores the default of 256.
+func (c *Context) SetAuditCapacity(n int) error {
+	if err := c.require(RoleAdmin, "SetAuditCapacity"); err != nil {
+		return err
+	}
+	c.audit.mu.Lock()
+	defer c.audit.mu.Unlock()
+
+	if n < 0 {
+		n = 0
+	}
+	entries := c.audit.ordered()
+	c.audit.limit = n
+	if capacity := c.audit.capacity(); len(entries) > capacity {
+		entries = entries[len(entries)-capacity:]
+	}
+	c.audit.entries = entries
+	c.audit.next = len(entries) % c.audit.capacity()
+	return nil
+}
+
+// SetAuditSink sends every subsequent audit entry to sink. A nil sink keeps
+// the trail in memory only.
+func (c *Context) SetAuditSink(sink AuditSink) error {
+	if err := c.require(RoleAdmin, "SetAuditSink"); err != nil {
+		return err
+	}
+	c.audit.mu.Lock()
+	defer c.audit.mu.Unlock()
+	c.audit.sink = sink
+	return nil
+}
+
+// auditTail returns the n most recent retained entries, oldest first
+func (c *Context) auditTail(n int) []AuditEntry {
+	entries := c.ColorAudit()
+	if len(entries) > n {
+		entries = entries[len(entries)-n:]
+	}
+	return entries
+}
+
+// ColorAudit returns the retained audit trail, oldest entry first
+//
+// Example:
+//   for _, e := range ctx.ColorAudit() {
+//       fmt.Printf("#%d %s %s -> %s %s\n", e.Seq, e.Kind, e.From, e.To, e.Reason)
+//   }
+func (c *Context) ColorAudit() []AuditEntry {
+	c.audit.mu.Lock()
+	defer c.audit.mu.Unlock()
+	return c.audit.ordered()
+}
+
+// capacity returns the ring's size. The caller holds r.mu.
+func (r *auditRing) capacity() int {
+	if r.limit > 0 {
+		return r.limit
+	}
+	return defaultAuditCapacity
+}
+
+// ordered returns a copy of the ring's entries, oldest first. The caller
+// holds r.mu.
+func (r *auditRing) ordered() []AuditEntry {
+	out := make([]AuditEntry, 0, len(r.entries))
+	if len(r.entries) < r.capacity() {
+		return append(out, r.entries...)
+	}
+	out = append(out, r.entries[r.next:]...)
+	return append(out, r.entries[:r.next]...)
+}
+
+// record appends e to the trail, assigning its sequence number
+func (r *auditRing) record(e AuditEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.seq++
+	e.Seq = r.seq
+
+	if len(r.entries) < r.capacity() {
+		r.entries = append(r.entries, e)
+	} else {
+		r.entries[r.next] = e
+	}
+	r.next = (r.next + 1) % r.capacity()
+
+	if r.sink != nil {
+		r.sink.Record(e)
+	}
+	if r.log != nil {
+		r.log.appendEntry(r.schema, e)
+	}
+}
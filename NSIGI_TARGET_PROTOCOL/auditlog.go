@@ -0,0 +1,339 @@
+package nsigii
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Hash-Chained Audit Log
+// ============================================================================
+
+// ErrAuditTampered reports an audit log whose records were modified,
+// reordered, removed or truncated
+var ErrAuditTampered = errors.New("audit log has been tampered with")
+
+// AuditRecord is one entry of an AuditLog: a context's audit entry, or a
+// tokenization, chained to the record before it
+type AuditRecord struct {
+	Seq    uint64       `json:"seq"` // 1-based position in the log
+	Time   time.Time    `json:"time"`
+	Schema string       `json:"schema"` // obinexus.[operation].[service] of the context
+	Kind   AuditKind    `json:"kind"`
+	From   ColorChannel `json:"from"`
+	To     ColorChannel `json:"to"`
+	Reason string       `json:"reason,omitempty"` // As in AuditEntry; the error of a failed tokenization
+	Passed bool         `json:"passed"`           // As in AuditEntry; true for a successful tokenization
+	Bytes  int          `json:"bytes,omitempty"`  // AuditTokenize: source bytes
+	Tokens int          `json:"tokens,omitempty"` // AuditTokenize: tokens produced
+
+	Prev string `json:"prev"` // Hex SHA-256 of the previous record, zeros for the first
+	Hash string `json:"hash"` // Hex SHA-256 of Prev and this record's fields
+}
+
+// AuditLog is an append-only, tamper-evident log of the audit entries and
+// tokenizations of the contexts attached to it. Each record's hash covers
+// the hash of the one before it, so changing, reordering or removing a
+// record breaks the chain; an exported log ends with a MAC of its length
+// and last hash, so cutting records off the end is detected too.
+//
+// The log is kept in memory; export it with WriteTo to persist it.
+//
+// Example:
+//   log := nsigii.NewAuditLog(key)
+//   ctx.SetAuditLog(log)
+//   ...
+//   f, _ := os.Create("audit.jsonl")
+//   log.WriteTo(f)
+//   f.Close()
+//
+//   f, _ = os.Open("audit.jsonl")
+//   restored, err := nsigii.ReadAuditLog(f, key) // fails if tampered with
+type AuditLog struct {
+	key []byte
+
+	mu      sync.Mutex
+	records []AuditRecord
+	head    [sha256.Size]byte // hash of the last record
+}
+
+// auditSeal is the last line of an exported log
+type auditSeal struct {
+	Count uint64 `json:"count"`
+	Head  string `json:"head"`
+	MAC   []byte `json:"mac"`
+}
+
+// NewAuditLog creates an empty log sealing exports with key. A nil key
+// uses the process phantom key, which only this process can verify.
+func NewAuditLog(key []byte) *AuditLog {
+	if key == nil {
+		key = defaultPhantomKey()
+	}
+	return &AuditLog{key: append([]byte(nil), key...)}
+}
+
+// SetAuditLog appends every subsequent audit entry and tokenization of the
+// context to l. A nil log detaches the context.
+func (c *Context) SetAuditLog(l *AuditLog) error {
+	if err := c.require(RoleAdmin, "SetAuditLog"); err != nil {
+		return err
+	}
+	schema := c.schema()
+
+	c.audit.mu.Lock()
+	defer c.audit.mu.Unlock()
+	c.audit.log = l
+	c.audit.schema = schema
+	return nil
+}
+
+// WithAuditLog attaches the context to an audit log; see SetAuditLog
+func WithAuditLog(l *AuditLog) Option {
+	return func(c *Context) error {
+		return c.SetAuditLog(l)
+	}
+}
+
+// logTokenize appends a finished tokenization to the context's audit log
+func (c *Context) logTokenize(e Event) {
+	c.audit.mu.Lock()
+	l, schema := c.audit.log, c.audit.schema
+	c.audit.mu.Unlock()
+	if l == nil {
+		return
+	}
+
+	r := AuditRecord{
+		Time:   e.Start.Add(e.Duration),
+		Schema: schema,
+		Kind:   AuditTokenize,
+		From:   c.ColorState(),
+		Passed: e.Err == nil,
+		Bytes:  e.Bytes,
+		Tokens: e.Tokens,
+	}
+	r.To = r.From
+	if e.Err != nil {
+		r.Reason = e.Err.Error()
+	}
+	l.append(r)
+}
+
+// appendEntry appends a context's audit entry
+func (l *AuditLog) appendEntry(schema string, e AuditEntry) {
+	l.append(AuditRecord{
+		Time:   e.Time,
+		Schema: schema,
+		Kind:   e.Kind,
+		From:   e.From,
+		To:     e.To,
+		Reason: e.Reason,
+		Passed: e.Passed,
+	})
+}
+
+// append chains r onto the log
+func (l *AuditLog) append(r AuditRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r.Seq = uint64(len(l.records)) + 1
+	r.Prev = hex.EncodeToString(l.head[:])
+	l.head = r.hash(l.head)
+	r.Hash = hex.EncodeToString(l.head[:])
+	l.records = append(l.records, r)
+}
+
+// Records returns a copy of the log's records, oldest first
+func (l *AuditLog) Records() []AuditRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]AuditRecord(nil), l.records...)
+}
+
+// Len returns the number of records in the log
+func (l *AuditLog) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.records)
+}
+
+// Head returns the hex hash of the last record, which commits to the whole
+// log; zeros for an empty log
+func (l *AuditLog) Head() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return hex.EncodeToString(l.head[:])
+}
+
+// Verify recomputes the hash chain and checks it ends at the log's head,
+// returning ErrAuditTampered if it does not
+func (l *AuditLog) Verify() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	head, err := verifyAuditChain(l.records)
+	if err != nil {
+		return err
+	}
+	if head != l.head {
+		return fmt.Errorf("%w: chain ends at %x, log head is %x", ErrAuditTampered, head, l.head)
+	}
+	return nil
+}
+
+// verifyAuditChain checks that each record follows the one before it and
+// returns the last hash
+func verifyAuditChain(records []AuditRecord) ([sha256.Size]byte, error) {
+	var head [sha256.Size]byte
+	for i, r := range records {
+		if r.Seq != uint64(i)+1 {
+			return head, fmt.Errorf("%w: record %d has sequence number %d", ErrAuditTampered, i+1, r.Seq)
+		}
+		if r.Prev != hex.EncodeToString(head[:]) {
+			return head, fmt.Errorf("%w: record %d does not follow record %d", ErrAuditTampered, r.Seq, i)
+		}
+		head = r.hash(head)
+		if r.Hash != hex.EncodeToString(head[:]) {
+			return head, fmt.Errorf("%w: record %d was modified", ErrAuditTampered, r.Seq)
+		}
+	}
+	return head, nil
+}
+
+// hash returns the hash of the record chained to prev. Prev and Hash
+// themselves are not encoded.
+func (r AuditRecord) hash(prev [sha256.Size]byte) [sha256.Size]byte {
+	b := make([]byte, 0, 128+len(r.Schema)+len(r.Reason))
+	field := func(v string) {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(v)))
+		b = append(b, v...)
+	}
+
+	b = append(b, prev[:]...)
+	b = binary.BigEndian.AppendUint64(b, r.Seq)
+	b = binary.BigEndian.AppendUint64(b, uint64(r.Time.UnixNano()))
+	field(r.Schema)
+	b = binary.BigEndian.AppendUint32(b, uint32(r.Kind))
+	b = binary.BigEndian.AppendUint32(b, uint32(r.From))
+	b = binary.BigEndian.AppendUint32(b, uint32(r.To))
+	field(r.Reason)
+	if r.Passed {
+		b = append(b, 1)
+	} else {
+		b = append(b, 0)
+	}
+	b = binary.BigEndian.AppendUint64(b, uint64(r.Bytes))
+	b = binary.BigEndian.AppendUint64(b, uint64(r.Tokens))
+	return sha256.Sum256(b)
+}
+
+// seal returns the MAC over a log's length and head
+func (l *AuditLog) seal(count uint64, head [sha256.Size]byte) []byte {
+	mac := hmac.New(sha256.New, l.key)
+	mac.Write([]byte("nsigii audit log\x00"))
+	mac.Write(binary.BigEndian.AppendUint64(nil, count))
+	mac.Write(head[:])
+	return mac.Sum(nil)
+}
+
+// ============================================================================
+// Export
+// ============================================================================
+
+// WriteTo writes the log as JSON lines, one record per line, followed by a
+// line {"seal": {"count", "head", "mac"}} that ReadAuditLog checks
+func (l *AuditLog) WriteTo(w io.Writer) (int64, error) {
+	l.mu.Lock()
+	records := append([]AuditRecord(nil), l.records...)
+	head := l.head
+	l.mu.Unlock()
+
+	cw := &countWriter{w: w}
+	enc := json.NewEncoder(cw)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return cw.n, err
+		}
+	}
+	err := enc.Encode(struct {
+		Seal auditSeal `json:"seal"`
+	}{auditSeal{
+		Count: uint64(len(records)),
+		Head:  hex.EncodeToString(head[:]),
+		MAC:   l.seal(uint64(len(records)), head),
+	}})
+	return cw.n, err
+}
+
+// ReadAuditLog reads a log written by WriteTo and verifies it against key,
+// returning ErrAuditTampered if any record was changed or removed or the
+// seal is missing. Records appended to the returned log continue the chain.
+func ReadAuditLog(r io.Reader, key []byte) (*AuditLog, error) {
+	l := NewAuditLog(key)
+
+	var seal *auditSeal
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		if seal != nil {
+			return nil, fmt.Errorf("%w: records after the seal", ErrAuditTampered)
+		}
+		var line struct {
+			AuditRecord
+			Seal *auditSeal `json:"seal"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			return nil, err
+		}
+		if line.Seal != nil {
+			seal = line.Seal
+			continue
+		}
+		l.records = append(l.records, line.AuditRecord)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if seal == nil {
+		return nil, fmt.Errorf("%w: no seal; the log is truncated", ErrAuditTampered)
+	}
+
+	head, err := verifyAuditChain(l.records)
+	if err != nil {
+		return nil, err
+	}
+	count := uint64(len(l.records))
+	if seal.Count != count || seal.Head != hex.EncodeToString(head[:]) ||
+		!hmac.Equal(seal.MAC, l.seal(count, head)) {
+		return nil, fmt.Errorf("%w: seal does not match the records", ErrAuditTampered)
+	}
+
+	l.head = head
+	return l, nil
+}
+
+// countWriter counts the bytes written through it
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
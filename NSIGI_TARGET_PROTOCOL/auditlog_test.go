@@ -0,0 +1,119 @@
+package nsigii
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// auditLogLines returns the exported lines of a log of a few tokenizations,
+// the seal last
+func auditLogLines(t *testing.T, key []byte) []string {
+	t.Helper()
+	log := NewAuditLog(key)
+	ctx, err := NewContext("tokenize", "lexer", WithAuditLog(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+	for _, source := range []string{"let a = 1;", "let b = 2;", "let c = 3;"} {
+		if _, err := ctx.Tokenize(source); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := log.Verify(); err != nil {
+		t.Fatalf("Verify(untouched log) = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := log.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) < 4 {
+		t.Fatalf("exported %d lines, want at least 3 records and the seal", len(lines))
+	}
+	return lines
+}
+
+func TestAuditLogRoundTrip(t *testing.T) {
+	key := []byte("audit key")
+	lines := auditLogLines(t, key)
+
+	l, err := ReadAuditLog(strings.NewReader(strings.Join(lines, "\n")+"\n"), key)
+	if err != nil {
+		t.Fatalf("ReadAuditLog(untouched log) = %v", err)
+	}
+	if l.Len() != len(lines)-1 {
+		t.Errorf("read %d records, want %d", l.Len(), len(lines)-1)
+	}
+
+	// Records appended after reading continue the chain
+	l.appendEntry("obinexus.tokenize.lexer", AuditEntry{Kind: AuditConsensus, Passed: true})
+	if err := l.Verify(); err != nil {
+		t.Errorf("Verify after appending to a read log = %v", err)
+	}
+}
+
+func TestAuditLogTampered(t *testing.T) {
+	key := []byte("audit key")
+	lines := auditLogLines(t, key)
+	last := len(lines) - 1 // the seal
+
+	edit := func(f func(lines []string) []string) string {
+		return strings.Join(f(append([]string(nil), lines...)), "\n") + "\n"
+	}
+	tests := []struct {
+		name string
+		log  string
+		key  []byte
+	}{
+		{"modified record", edit(func(l []string) []string {
+			l[1] = strings.Replace(l[1], `"passed":true`, `"passed":false`, 1)
+			return l
+		}), key},
+		{"reordered records", edit(func(l []string) []string {
+			l[0], l[1] = l[1], l[0]
+			return l
+		}), key},
+		{"removed record", edit(func(l []string) []string {
+			return append(l[:1], l[2:]...)
+		}), key},
+		{"truncated before the seal", edit(func(l []string) []string {
+			return append(l[:last-1], l[last])
+		}), key},
+		{"truncated with the seal", edit(func(l []string) []string {
+			return l[:last-1]
+		}), key},
+		{"seal dropped", edit(func(l []string) []string {
+			return l[:last]
+		}), key},
+		{"records after the seal", edit(func(l []string) []string {
+			return append(l, l[0])
+		}), key},
+		{"other key", edit(func(l []string) []string { return l }), []byte("other key")},
+	}
+	for _, tt := range tests {
+		if _, err := ReadAuditLog(strings.NewReader(tt.log), tt.key); !errors.Is(err, ErrAuditTampered) {
+			t.Errorf("%s: ReadAuditLog = %v, want ErrAuditTampered", tt.name, err)
+		}
+	}
+}
+
+func TestAuditLogVerifyInMemory(t *testing.T) {
+	log := NewAuditLog(nil)
+	for range 3 {
+		log.appendEntry("obinexus.tokenize.lexer", AuditEntry{Kind: AuditConsensus, Passed: true})
+	}
+	log.records[1].Passed = false
+	if err := log.Verify(); !errors.Is(err, ErrAuditTampered) {
+		t.Errorf("Verify(modified record) = %v, want ErrAuditTampered", err)
+	}
+
+	log.records[1].Passed = true
+	log.records = log.records[:2]
+	if err := log.Verify(); !errors.Is(err, ErrAuditTampered) {
+		t.Errorf("Verify(truncated log) = %v, want ErrAuditTampered", err)
+	}
+}
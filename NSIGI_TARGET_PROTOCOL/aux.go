@@ -0,0 +1,244 @@
+package nsigii
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// AUX Sequences
+// ============================================================================
+
+// AuxOp identifies an AUX instruction
+type AuxOp int
+
+const (
+	AuxNoise  AuxOp = 0 // Start (or restart) noise injection with a profile
+	AuxDelay  AuxOp = 1 // Wait before the next instruction
+	AuxMarker AuxOp = 2 // Label a point in the sequence; no native effect
+	AuxSync   AuxOp = 3 // Stop noise injection, leaving AUX idle
+)
+
+var auxOpNames = []string{"NOISE", "DELAY", "MARKER", "SYNC"}
+
+func (op AuxOp) String() string {
+	if op >= 0 && int(op) < len(auxOpNames) {
+		return auxOpNames[op]
+	}
+	return "UNKNOWN"
+}
+
+// maxAuxDelay bounds a single AuxDelay instruction
+const maxAuxDelay = time.Minute
+
+// AuxInstruction is one step of an AUX sequence. Only the field matching
+// Op is used.
+type AuxInstruction struct {
+	Op    AuxOp         `json:"op"`
+	Noise NoiseProfile  `json:"noise,omitzero"`  // AuxNoise
+	Delay time.Duration `json:"delay,omitempty"` // AuxDelay
+	Label string        `json:"label,omitempty"` // AuxMarker
+}
+
+// AuxSequence builds a list of AUX instructions to submit in one go
+//
+// Example:
+//   seq := nsigii.NewAuxSequence().
+//       Marker("handshake").
+//       Noise(nsigii.NoiseHigh).
+//       Delay(50 * time.Millisecond).
+//       Sync()
+//   if err := ctx.SubmitAux(seq); err != nil {
+//       log.Fatal(err)
+//   }
+type AuxSequence struct {
+	instructions []AuxInstruction
+}
+
+// NewAuxSequence creates an empty sequence
+func NewAuxSequence() *AuxSequence {
+	return &AuxSequence{}
+}
+
+// Noise appends an instruction starting noise injection with profile
+func (s *AuxSequence) Noise(profile NoiseProfile) *AuxSequence {
+	return s.Append(AuxInstruction{Op: AuxNoise, Noise: profile})
+}
+
+// Delay appends a pause of d
+func (s *AuxSequence) Delay(d time.Duration) *AuxSequence {
+	return s.Append(AuxInstruction{Op: AuxDelay, Delay: d})
+}
+
+// Marker appends a labelled marker
+func (s *AuxSequence) Marker(label string) *AuxSequence {
+	return s.Append(AuxInstruction{Op: AuxMarker, Label: label})
+}
+
+// Sync appends an instruction stopping noise injection
+func (s *AuxSequence) Sync() *AuxSequence {
+	return s.Append(AuxInstruction{Op: AuxSync})
+}
+
+// Append appends raw instructions
+func (s *AuxSequence) Append(instructions ...AuxInstruction) *AuxSequence {
+	s.instructions = append(s.instructions, instructions...)
+	return s
+}
+
+// Instructions returns a copy of the sequence's instructions
+func (s *AuxSequence) Instructions() []AuxInstruction {
+	return append([]AuxInstruction(nil), s.instructions...)
+}
+
+// Len returns the number of instructions in the sequence
+func (s *AuxSequence) Len() int {
+	return len(s.instructions)
+}
+
+// Validate checks every instruction and their order: a SYNC must follow a
+// NOISE it can stop
+func (s *AuxSequence) Validate() error {
+	if len(s.instructions) == 0 {
+		return errors.New("empty AUX sequence")
+	}
+
+	running := false
+	for i, in := range s.instructions {
+		var err error
+		switch in.Op {
+		case AuxNoise:
+			err = in.Noise.Validate()
+			running = true
+		case AuxDelay:
+			if in.Delay < 0 || in.Delay > maxAuxDelay {
+				err = fmt.Errorf("delay %v out of range", in.Delay)
+			}
+		case AuxMarker:
+			if in.Label == "" {
+				err = errors.New("marker without label")
+			}
+		case AuxSync:
+			if !running {
+				err = errors.New("sync without running noise")
+			}
+			running = false
+		default:
+			err = fmt.Errorf("unknown op %d", int(in.Op))
+		}
+		if err != nil {
+			return fmt.Errorf("AUX instruction %d (%s): %w", i, in.Op, err)
+		}
+	}
+
+	return nil
+}
+
+// auxState serializes a context's AUX calls
+type auxState struct {
+	mu       sync.Mutex
+	recorder *auxRecorder  // nil unless RecordAux is active
+	entropy  EntropySource // nil for crypto/rand
+	noise    []byte        // entropy injected for the running noise segment
+	profile  *NoiseProfile // profile of the running noise, nil when stopped
+	gen      uint64        // counts noise segments, ending burst schedules
+	session  *AuxSession   // open session, if any
+	counters auxCounters
+}
+
+// SubmitAux validates seq and then runs it on the context. No other AUX call
+// on the context interleaves with it. If an instruction fails part way, AUX
+// is stopped before the error is returned, so the sequence leaves either its
+// own end state or an idle AUX.
+func (c *Context) SubmitAux(seq *AuxSequence) error {
+	if err := c.require(RoleOperator, "SubmitAux"); err != nil {
+		return err
+	}
+	if err := seq.Validate(); err != nil {
+		return err
+	}
+	if err := c.usable(); err != nil {
+		return err
+	}
+	if err := c.authorize(OpAuxStart); err != nil {
+		return err
+	}
+
+	c.aux.mu.Lock()
+	defer c.aux.mu.Unlock()
+
+	for i, in := range seq.instructions {
+		if err := c.runAux(in); err != nil {
+			c.runAux(AuxInstruction{Op: AuxSync})
+			return fmt.Errorf("AUX instruction %d (%s): %w", i, in.Op, err)
+		}
+	}
+
+	return nil
+}
+
+// runAux performs a single instruction and records it if the context is
+// recording. The caller holds c.aux.mu.
+func (c *Context) runAux(in AuxInstruction) (err error) {
+	if in.Op == AuxNoise || in.Op == AuxSync {
+		start := time.Now()
+		defer func() {
+			op := OpAuxStart
+			if in.Op == AuxSync {
+				op = OpAuxStop
+			}
+			c.observe(Event{Op: op, Start: start, Err: err})
+		}()
+	}
+
+	switch in.Op {
+	case AuxNoise:
+		noise, err := c.drawNoise(in.Noise)
+		if err != nil {
+			return err
+		}
+		var result int
+		err = c.withNative(func(ctx *nativeContext) {
+			result = nativeAuxStart(ctx, in.Noise.nativeLevel())
+		})
+		if err != nil {
+			return err
+		}
+		if result != 0 {
+			c.usage.countError()
+			return &CError{Op: "AUX start", Code: result}
+		}
+		c.aux.gen++
+		c.aux.noise = noise
+		c.aux.profile = &in.Noise
+		c.aux.counters.start(time.Now())
+		if in.Noise.Period > 0 && in.Noise.Level > 0 {
+			c.scheduleBursts(in.Noise, c.aux.gen)
+		}
+	case AuxDelay:
+		time.Sleep(in.Delay)
+	case AuxSync:
+		var result int
+		err := c.withNative(func(ctx *nativeContext) {
+			result = nativeAuxStop(ctx)
+		})
+		if err != nil {
+			return err
+		}
+		if result != 0 {
+			c.usage.countError()
+			return &CError{Op: "AUX stop", Code: result}
+		}
+		c.aux.gen++
+		c.aux.noise = nil
+		c.aux.profile = nil
+		c.aux.counters.stop(time.Now())
+	}
+
+	if c.aux.recorder != nil {
+		c.aux.recorder.record(in)
+	}
+	return nil
+}
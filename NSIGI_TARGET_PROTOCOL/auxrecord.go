@@ -0,0 +1,186 @@
+package nsigii
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ============================================================================
+// AUX Recording and Replay
+// ============================================================================
+
+// AuxRecordingVersion is the current AUX recording format version
+const AuxRecordingVersion = 1
+
+// auxRecordingHeader is the first line of a recording
+type auxRecordingHeader struct {
+	Version int       `json:"version"`
+	Schema  string    `json:"schema"`
+	Started time.Time `json:"started"`
+}
+
+// AuxRecord is one instruction in a recording, with its offset from the
+// start of the recording
+type AuxRecord struct {
+	At          time.Duration  `json:"at"`
+	Instruction AuxInstruction `json:"instruction"`
+}
+
+// AuxRecording is a decoded AUX recording
+type AuxRecording struct {
+	Schema  string      // Schema of the recorded context
+	Started time.Time   // When recording started
+	Records []AuxRecord // Instructions in the order they ran
+}
+
+// auxRecorder writes a context's AUX instructions as JSON lines
+type auxRecorder struct {
+	enc     *json.Encoder
+	started time.Time
+	err     error
+}
+
+// record writes in, keeping only the first write error
+func (r *auxRecorder) record(in AuxInstruction) {
+	// Timing is captured by At, so explicit delays are not recorded
+	if in.Op == AuxDelay || r.err != nil {
+		return
+	}
+	r.err = r.enc.Encode(AuxRecord{At: time.Since(r.started), Instruction: in})
+}
+
+// RecordAux writes every AUX instruction the context runs from now on to w,
+// as a portable JSON-lines recording that ReplayAux can play back against
+// another context. The returned stop function ends the recording and
+// reports the first write error, if any.
+//
+// Example:
+//   f, _ := os.Create("aux.jsonl")
+//   stop, err := ctx.RecordAux(f)
+//   // ... production traffic ...
+//   stop()
+func (c *Context) RecordAux(w io.Writer) (stop func() error, err error) {
+	if err := c.require(RoleOperator, "RecordAux"); err != nil {
+		return nil, err
+	}
+	schema, err := c.Schema()
+	if err != nil {
+		return nil, err
+	}
+
+	c.aux.mu.Lock()
+	defer c.aux.mu.Unlock()
+
+	if c.aux.recorder != nil {
+		return nil, errors.New("AUX recording already active")
+	}
+
+	r := &auxRecorder{enc: json.NewEncoder(w), started: time.Now()}
+	if err := r.enc.Encode(auxRecordingHeader{
+		Version: AuxRecordingVersion,
+		Schema:  schema,
+		Started: r.started,
+	}); err != nil {
+		return nil, err
+	}
+	c.aux.recorder = r
+
+	stop = func() error {
+		c.aux.mu.Lock()
+		defer c.aux.mu.Unlock()
+		if c.aux.recorder == r {
+			c.aux.recorder = nil
+		}
+		return r.err
+	}
+	return stop, nil
+}
+
+// ReadAuxRecording decodes a recording written by RecordAux
+func ReadAuxRecording(r io.Reader) (*AuxRecording, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+
+	if !sc.Scan() {
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("empty AUX recording")
+	}
+	var h auxRecordingHeader
+	if err := json.Unmarshal(sc.Bytes(), &h); err != nil {
+		return nil, fmt.Errorf("invalid AUX recording header: %w", err)
+	}
+	if h.Version != AuxRecordingVersion {
+		return nil, fmt.Errorf("unsupported AUX recording version: %d", h.Version)
+	}
+
+	rec := &AuxRecording{Schema: h.Schema, Started: h.Started}
+	for line := 2; sc.Scan(); line++ {
+		var r AuxRecord
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("invalid AUX record on line %d: %w", line, err)
+		}
+		rec.Records = append(rec.Records, r)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return rec, nil
+}
+
+// Sequence converts the recording into an AuxSequence. With timing set,
+// delays are inserted so instructions run at their recorded offsets.
+// SYNCs recorded while no noise was running stop nothing and are dropped.
+func (rec *AuxRecording) Sequence(timing bool) *AuxSequence {
+	seq := NewAuxSequence()
+
+	var last time.Duration
+	running := false
+	for _, r := range rec.Records {
+		in := r.Instruction
+		if in.Op == AuxSync && !running {
+			continue
+		}
+
+		if timing {
+			for gap := r.At - last; gap > 0; gap -= maxAuxDelay {
+				seq.Delay(min(gap, maxAuxDelay))
+			}
+			last = max(last, r.At)
+		}
+
+		switch in.Op {
+		case AuxNoise:
+			running = true
+		case AuxSync:
+			running = false
+		}
+		seq.Append(in)
+	}
+
+	return seq
+}
+
+// ReplayAux plays a recording from r back on the context, reproducing the
+// recorded timing if timing is set
+func (c *Context) ReplayAux(r io.Reader, timing bool) error {
+	if err := c.require(RoleOperator, "ReplayAux"); err != nil {
+		return err
+	}
+	rec, err := ReadAuxRecording(r)
+	if err != nil {
+		return err
+	}
+
+	seq := rec.Sequence(timing)
+	if seq.Len() == 0 {
+		return nil
+	}
+	return c.SubmitAux(seq)
+}
@@ -0,0 +1,89 @@
+package nsigii
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// AUX Sessions
+// ============================================================================
+
+// AuxSession is a running AUX noise sequence that is stopped by Close.
+// Deferring Close right after opening the session guarantees AuxStop runs
+// even if the caller panics.
+type AuxSession struct {
+	c       *Context
+	profile NoiseProfile
+	started time.Time
+	once    sync.Once
+	err     error
+}
+
+// AuxSession starts AUX noise with profile and returns a session whose Close
+// stops it. Only one session may be open on a context at a time.
+//
+// Example:
+//   s, err := ctx.AuxSession(nsigii.NoiseHigh)
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   defer s.Close()
+func (c *Context) AuxSession(profile NoiseProfile) (*AuxSession, error) {
+	if err := c.require(RoleOperator, "AuxSession"); err != nil {
+		return nil, err
+	}
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+	if err := c.usable(); err != nil {
+		return nil, err
+	}
+	if err := c.authorize(OpAuxStart); err != nil {
+		return nil, err
+	}
+
+	c.aux.mu.Lock()
+	defer c.aux.mu.Unlock()
+
+	if c.aux.session != nil {
+		return nil, errors.New("AUX session already open")
+	}
+	if err := c.runAux(AuxInstruction{Op: AuxNoise, Noise: profile}); err != nil {
+		return nil, err
+	}
+
+	s := &AuxSession{c: c, profile: profile, started: time.Now()}
+	c.aux.session = s
+	return s, nil
+}
+
+// Profile returns the session's noise profile
+func (s *AuxSession) Profile() NoiseProfile {
+	return s.profile
+}
+
+// Started returns when the session was opened
+func (s *AuxSession) Started() time.Time {
+	return s.started
+}
+
+// Close stops AUX noise and ends the session. It is safe to call more than
+// once; later calls return the first call's result.
+func (s *AuxSession) Close() error {
+	s.once.Do(func() {
+		c := s.c
+		c.aux.mu.Lock()
+		defer c.aux.mu.Unlock()
+
+		if c.aux.session == s {
+			c.aux.session = nil
+		}
+		if c.closed() {
+			return // Close on the context already released AUX
+		}
+		s.err = c.runAux(AuxInstruction{Op: AuxSync})
+	})
+	return s.err
+}
@@ -0,0 +1,68 @@
+package nsigii
+
+import "time"
+
+// ============================================================================
+// AUX Telemetry
+// ============================================================================
+
+// AuxStats summarizes a context's AUX activity. A session is a run of noise
+// from a NOISE instruction (AuxStart, AuxSession, a sequence) to the SYNC
+// or NOISE that ends it.
+type AuxStats struct {
+	SessionsStarted int           // Noise sessions started
+	SessionsStopped int           // Noise sessions ended
+	EntropyInjected int           // Total entropy bytes drawn, including bursts
+	AverageSession  time.Duration // Mean duration of ended sessions
+	Active          bool          // Whether a session is running now
+}
+
+// auxCounters accumulates AuxStats. It is guarded by auxState.mu.
+type auxCounters struct {
+	started int
+	stopped int
+	entropy int
+	total   time.Duration // summed duration of ended sessions
+	since   time.Time     // start of the running session, zero if idle
+}
+
+// start records a session starting at t, ending any running one
+func (a *auxCounters) start(t time.Time) {
+	a.stop(t)
+	a.started++
+	a.since = t
+}
+
+// stop records the running session, if any, ending at t
+func (a *auxCounters) stop(t time.Time) {
+	if a.since.IsZero() {
+		return
+	}
+	a.stopped++
+	a.total += t.Sub(a.since)
+	a.since = time.Time{}
+}
+
+// AuxStats returns the context's AUX telemetry, so operators can confirm
+// noise injection is really happening
+//
+// Example:
+//   s := ctx.AuxStats()
+//   fmt.Printf("%d sessions, %d bytes injected, avg %v\n",
+//       s.SessionsStarted, s.EntropyInjected, s.AverageSession)
+func (c *Context) AuxStats() AuxStats {
+	c.aux.mu.Lock()
+	defer c.aux.mu.Unlock()
+
+	a := c.aux.counters
+	s := AuxStats{
+		SessionsStarted: a.started,
+		SessionsStopped: a.stopped,
+		EntropyInjected: a.entropy,
+		Active:          !a.since.IsZero(),
+	}
+	if a.stopped > 0 {
+		s.AverageSession = a.total / time.Duration(a.stopped)
+	}
+	return s
+}
@@ -0,0 +1,96 @@
+package nsigii
+
+import (
+	"fmt"
+	"time"
+)
+
+// ============================================================================
+// Batch Tokenization
+// ============================================================================
+
+// TokenizeBatch tokenizes every source, returning their tokens in the same
+// order. The native lexer is entered once for the whole batch instead of
+// once per source, which dominates the cost of tokenizing many small
+// snippets. Each source is still authorized, counted and observed as its
+// own tokenization.
+//
+// The first source that fails fails the batch, with an error naming its
+// index.
+//
+// Example:
+//   batch, err := ctx.TokenizeBatch(snippets)
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   for i, tokens := range batch {
+//       fmt.Println(i, len(tokens))
+//   }
+func (c *Context) TokenizeBatch(sources []string) ([][]Token, error) {
+	if err := c.usable(); err != nil {
+		return nil, err
+	}
+
+	batch := make([][]Token, len(sources))
+
+	// A Go lexer never crosses into C, so there is nothing to amortize
+	if c.lexer != nil {
+		for i, source := range sources {
+			tokens, err := c.tokenize(source)
+			if err != nil {
+				return nil, fmt.Errorf("source %d: %w", i, err)
+			}
+			batch[i] = tokens
+		}
+		return batch, nil
+	}
+
+	start := time.Now()
+	done := func(source string, tokens []Token, err error) {
+		c.usage.countTokenize(len(source), tokens, err)
+		c.observe(Event{Op: OpTokenize, Start: start, Err: err, Bytes: len(source), Tokens: len(tokens)})
+	}
+
+	for i, source := range sources {
+		if err := c.authorize(OpTokenize); err != nil {
+			done(source, nil, err)
+			return nil, fmt.Errorf("source %d: %w", i, err)
+		}
+		if c.strictUTF8 {
+			if err := validateUTF8(source); err != nil {
+				done(source, nil, err)
+				return nil, fmt.Errorf("source %d: %w", i, err)
+			}
+		}
+	}
+
+	var (
+		triplets [][]triplet
+		results  []int
+		result   int
+	)
+	err := c.withNative(func(ctx *nativeContext) {
+		triplets, results, result = nativeTokenizeBatch(ctx, sources)
+	})
+	if err == nil && result != 0 {
+		err = &CError{Op: "tokenization", Code: result}
+	}
+	if err != nil {
+		for _, source := range sources {
+			done(source, nil, err)
+		}
+		return nil, err
+	}
+
+	for i, source := range sources {
+		full := c.maxTokens > 0 && len(triplets[i]) > c.maxTokens
+		tokens, err := c.tokens(nil, source, triplets[i], full, results[i])
+		done(source, tokens, err)
+		if err != nil {
+			return nil, fmt.Errorf("source %d: %w", i, err)
+		}
+		batch[i] = tokens
+	}
+
+	return batch, nil
+}
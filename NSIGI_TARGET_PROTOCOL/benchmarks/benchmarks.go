@@ -0,0 +1,242 @@
+// Package benchmarks measures the NSIGII bindings and the native library
+// behind them over representative RIFT corpora. The benchmarks are ordinary
+// functions over *testing.B, so they run both under go test -bench, through
+// the wrappers in benchmarks_test.go, and from the nsigii-bench command,
+// which prints results in the same format, the one benchstat reads:
+//   nsigii-bench -count 10 > old.txt
+//   # rebuild against the new bindings or libnsigii_rift
+//   nsigii-bench -count 10 > new.txt
+//   benchstat old.txt new.txt
+//
+// The corpora are generated deterministically, so results from different
+// builds and machines are comparable.
+package benchmarks
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/obinexus/nsigii-rift/nsigii"
+)
+
+// Corpus is a named RIFT source to benchmark against
+type Corpus struct {
+	Name   string
+	Source string
+}
+
+// Corpora returns the standard corpora, smallest first:
+//   tiny     one statement, dominated by per-call overhead
+//   small    one function of about 400 bytes
+//   medium   about 64 KiB of functions
+//   large    about 1 MiB of functions
+//   unicode  about 64 KiB of functions with non-ASCII identifiers
+func Corpora() []Corpus {
+	return []Corpus{
+		{"tiny", "let x = 42;\n"},
+		{"small", generate(1, false)},
+		{"medium", generate(64<<10, false)},
+		{"large", generate(1<<20, false)},
+		{"unicode", generate(64<<10, true)},
+	}
+}
+
+// Snippets returns n tiny sources, as a service tokenizing many small
+// requests would see
+func Snippets(n int) []string {
+	snippets := make([]string, n)
+	for i := range snippets {
+		snippets[i] = fmt.Sprintf("let v%d = %d * (w%d + 1);\n", i, i%97, i%13)
+	}
+	return snippets
+}
+
+// generate writes functions until the source is at least size bytes. The
+// n-th function is the same in every corpus, and uses every token type.
+func generate(size int, unicode bool) string {
+	acc, step := "total", "step"
+	if unicode {
+		acc, step = "größe", "schritt_é"
+	}
+
+	var b strings.Builder
+	for n := 0; b.Len() < size; n++ {
+		fmt.Fprintf(&b, "// worker%d folds its inputs into a checksum\n", n)
+		fmt.Fprintf(&b, "fn worker%d(limit, %s) {\n", n, step)
+		fmt.Fprintf(&b, "    let %s = %d;\n", acc, n%251)
+		fmt.Fprintf(&b, "    for (let i = 0; i < limit; i = i + 1) {\n")
+		fmt.Fprintf(&b, "        if (i %% %d == 0 && %s != 0) {\n", n%7+2, step)
+		fmt.Fprintf(&b, "            %s = %s + %s * i;\n", acc, acc, step)
+		fmt.Fprintf(&b, "        } else {\n")
+		fmt.Fprintf(&b, "            %s = (%s << 1) ^ %d;\n", acc, acc, n)
+		fmt.Fprintf(&b, "        }\n")
+		fmt.Fprintf(&b, "    }\n")
+		fmt.Fprintf(&b, "    /* labels are interned by the caller */\n")
+		fmt.Fprintf(&b, "    log(\"worker%d\", %s);\n", n, acc)
+		fmt.Fprintf(&b, "    return %s;\n", acc)
+		fmt.Fprintf(&b, "}\n\n")
+	}
+	return b.String()
+}
+
+// Benchmark is a named benchmark function
+type Benchmark struct {
+	Name string // Without the "Benchmark" prefix, e.g. "Tokenize/small"
+	F    func(b *testing.B)
+}
+
+// All returns every benchmark nsigii-bench runs: tokenization of each
+// corpus by Tokenize, TokenizeInto and the Go lexer, a batch of snippets by TokenizeBatch
+// and by one Tokenize call each, an RGB consensus check, and the full RIFT
+// stage pipeline over the small and medium corpora.
+func All() []Benchmark {
+	var all []Benchmark
+	corpora := Corpora()
+	for _, c := range corpora {
+		all = append(all, Benchmark{"Tokenize/" + c.Name, func(b *testing.B) { BenchmarkTokenize(b, c) }})
+	}
+	for _, c := range corpora {
+		all = append(all, Benchmark{"TokenizeInto/" + c.Name, func(b *testing.B) { BenchmarkTokenizeInto(b, c) }})
+	}
+	for _, c := range corpora {
+		all = append(all, Benchmark{"GoLexer/" + c.Name, func(b *testing.B) { BenchmarkGoLexer(b, c) }})
+	}
+
+	snippets := Snippets(1000)
+	all = append(all,
+		Benchmark{"TokenizeBatch/1000", func(b *testing.B) { BenchmarkTokenizeBatch(b, snippets) }},
+		Benchmark{"TokenizeEach/1000", func(b *testing.B) { BenchmarkTokenizeEach(b, snippets) }},
+		Benchmark{"Consensus", BenchmarkConsensus},
+	)
+
+	for _, c := range corpora {
+		if c.Name == "small" || c.Name == "medium" {
+			all = append(all, Benchmark{"Pipeline/" + c.Name, func(b *testing.B) { BenchmarkPipeline(b, c) }})
+		}
+	}
+	return all
+}
+
+// newContext creates the context a benchmark runs on
+func newContext(b *testing.B, opts ...nsigii.Option) *nsigii.Context {
+	ctx, err := nsigii.NewContext("benchmark", "bench", opts...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { ctx.Close() })
+	return ctx
+}
+
+// BenchmarkTokenize measures Context.Tokenize over c
+func BenchmarkTokenize(b *testing.B, c Corpus) {
+	ctx := newContext(b)
+	b.SetBytes(int64(len(c.Source)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := ctx.Tokenize(c.Source); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkTokenizeInto measures Context.TokenizeInto over c, reusing one
+// token slice, which shows the garbage tokenization itself produces
+func BenchmarkTokenizeInto(b *testing.B, c Corpus) {
+	ctx := newContext(b)
+	b.SetBytes(int64(len(c.Source)))
+	b.ReportAllocs()
+
+	var tokens []nsigii.Token
+	for i := 0; i < b.N; i++ {
+		var err error
+		if tokens, err = ctx.TokenizeInto(tokens, c.Source); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkGoLexer measures Context.Tokenize over c with the pure-Go lexer,
+// which a language profile selects even in cgo builds. Compared with
+// BenchmarkTokenize in a cgo build, it tracks the Go lexer's distance from
+// the C one.
+func BenchmarkGoLexer(b *testing.B, c Corpus) {
+	ctx := newContext(b, nsigii.WithProfile(nsigii.RIFTProfile))
+	b.SetBytes(int64(len(c.Source)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := ctx.Tokenize(c.Source); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkTokenizeBatch measures Context.TokenizeBatch over sources
+func BenchmarkTokenizeBatch(b *testing.B, sources []string) {
+	ctx := newContext(b)
+	b.SetBytes(totalBytes(sources))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := ctx.TokenizeBatch(sources); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkTokenizeEach measures one Context.Tokenize call per source, the
+// baseline for BenchmarkTokenizeBatch
+func BenchmarkTokenizeEach(b *testing.B, sources []string) {
+	ctx := newContext(b)
+	b.SetBytes(totalBytes(sources))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		for _, source := range sources {
+			if _, err := ctx.Tokenize(source); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkConsensus measures Context.VerifyRGBConsensus
+func BenchmarkConsensus(b *testing.B) {
+	ctx := newContext(b)
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := ctx.VerifyRGBConsensus(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkPipeline measures the RIFT stage pipeline from tokenization to
+// IR emission over c
+func BenchmarkPipeline(b *testing.B, c Corpus) {
+	ctx := newContext(b)
+	p, err := nsigii.NewStagePipeline(ctx,
+		nsigii.StageTokenize, nsigii.StageParse, nsigii.StageValidate, nsigii.StageEmit)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(c.Source)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, _, err := p.Run(c.Source); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func totalBytes(sources []string) int64 {
+	var n int64
+	for _, source := range sources {
+		n += int64(len(source))
+	}
+	return n
+}
@@ -0,0 +1,52 @@
+package benchmarks_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/obinexus/nsigii-rift/nsigii/benchmarks"
+)
+
+// Each wrapper runs one family of All under the name nsigii-bench reports
+// it by, so results from go test -bench and nsigii-bench compare directly
+
+func BenchmarkTokenize(b *testing.B)      { run(b, "Tokenize") }
+func BenchmarkTokenizeInto(b *testing.B)  { run(b, "TokenizeInto") }
+func BenchmarkGoLexer(b *testing.B)       { run(b, "GoLexer") }
+func BenchmarkTokenizeBatch(b *testing.B) { run(b, "TokenizeBatch") }
+func BenchmarkTokenizeEach(b *testing.B)  { run(b, "TokenizeEach") }
+func BenchmarkConsensus(b *testing.B)     { run(b, "Consensus") }
+func BenchmarkPipeline(b *testing.B)      { run(b, "Pipeline") }
+
+// run runs the benchmarks of All named family or family/<sub>
+func run(b *testing.B, family string) {
+	ran := false
+	for _, bm := range benchmarks.All() {
+		switch {
+		case bm.Name == family:
+			bm.F(b)
+		case strings.HasPrefix(bm.Name, family+"/"):
+			b.Run(strings.TrimPrefix(bm.Name, family+"/"), bm.F)
+		default:
+			continue
+		}
+		ran = true
+	}
+	if !ran {
+		b.Fatalf("no benchmarks named %s", family)
+	}
+}
+
+func TestAllNamesCovered(t *testing.T) {
+	families := []string{"Tokenize", "TokenizeInto", "GoLexer", "TokenizeBatch", "TokenizeEach", "Consensus", "Pipeline"}
+	for _, bm := range benchmarks.All() {
+		family, _, _ := strings.Cut(bm.Name, "/")
+		found := false
+		for _, f := range families {
+			found = found || f == family
+		}
+		if !found {
+			t.Errorf("benchmark %s has no Benchmark wrapper", bm.Name)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package benchmarks
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"runtime"
+	"testing"
+)
+
+// Options configures Run
+type Options struct {
+	Match *regexp.Regexp // Benchmarks to run by name; nil runs all
+	Count int            // Runs of each benchmark; 0 means 1
+}
+
+// Run runs the benchmarks of All that opts selects and writes their results
+// to w in the format of go test -bench, which benchstat reads: a header of
+// configuration lines, then one line per run. Each run lasts about the
+// benchmark time of the testing package's -test.benchtime flag, 1s unless
+// set after testing.Init.
+//
+// Example:
+//   testing.Init()
+//   flag.Set("test.benchtime", "2s")
+//   err := benchmarks.Run(os.Stdout, benchmarks.Options{
+//       Match: regexp.MustCompile("^Tokenize/"),
+//       Count: 10,
+//   })
+func Run(w io.Writer, opts Options) error {
+	count := max(opts.Count, 1)
+
+	fmt.Fprintf(w, "goos: %s\n", runtime.GOOS)
+	fmt.Fprintf(w, "goarch: %s\n", runtime.GOARCH)
+	fmt.Fprintf(w, "pkg: github.com/obinexus/nsigii-rift/nsigii/benchmarks\n")
+
+	procs := runtime.GOMAXPROCS(0)
+	for _, bm := range All() {
+		if opts.Match != nil && !opts.Match.MatchString(bm.Name) {
+			continue
+		}
+		for i := 0; i < count; i++ {
+			r := testing.Benchmark(bm.F)
+			if r.N == 0 {
+				return fmt.Errorf("benchmark %s failed", bm.Name)
+			}
+			_, err := fmt.Fprintf(w, "Benchmark%s-%d\t%s\t%s\n", bm.Name, procs, r.String(), r.MemString())
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
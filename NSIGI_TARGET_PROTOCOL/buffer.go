@@ -0,0 +1,63 @@
+package nsigii
+
+import "sync"
+
+// ============================================================================
+// Token Buffers
+// ============================================================================
+
+// maxPooledTriplets bounds the buffers kept for reuse, so one huge source
+// does not pin its buffer for the life of the process
+const maxPooledTriplets = 1 << 20
+
+// tripletBuffer is a reusable triplet slice, boxed so that returning it to
+// tripletPool does not allocate
+type tripletBuffer struct {
+	triplets []triplet
+}
+
+var tripletPool = sync.Pool{New: func() any { return new(tripletBuffer) }}
+
+// getTriplets takes a buffer from the pool
+func getTriplets() *tripletBuffer {
+	return tripletPool.Get().(*tripletBuffer)
+}
+
+// keep adopts triplets, which the lexer filled in b's storage or in storage
+// it grew, as b's storage
+func (b *tripletBuffer) keep(triplets []triplet) {
+	if cap(triplets) > cap(b.triplets) {
+		b.triplets = triplets
+	}
+}
+
+// putTriplets returns b to the pool, dropping buffers too large to keep
+func putTriplets(b *tripletBuffer) {
+	if cap(b.triplets) > maxPooledTriplets {
+		b.triplets = nil
+	}
+	b.triplets = b.triplets[:0]
+	tripletPool.Put(b)
+}
+
+// TokenizeInto tokenizes source like Tokenize, reusing dst's storage for the
+// result, so a caller tokenizing in a loop produces next to no garbage.
+// dst's previous contents are overwritten. The lexer's intermediate buffers
+// are pooled for every tokenization, with or without TokenizeInto.
+//
+// Example:
+//   var tokens []nsigii.Token
+//   for _, source := range sources {
+//       tokens, err = ctx.TokenizeInto(tokens, source)
+//       if err != nil {
+//           log.Fatal(err)
+//       }
+//       process(tokens)
+//   }
+func (c *Context) TokenizeInto(dst []Token, source string) ([]Token, error) {
+	if err := c.usable(); err != nil {
+		return nil, err
+	}
+
+	return c.lex(dst, source, nil)
+}
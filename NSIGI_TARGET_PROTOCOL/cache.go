@@ -0,0 +1,163 @@
+package nsigii
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+// ============================================================================
+// Stage Result Caching
+// ============================================================================
+
+// CacheKey identifies the output of a pipeline run up to one of its stages:
+// a SHA-256 hash of the source, the stages run so far and the context
+// configuration they depend on
+type CacheKey [sha256.Size]byte
+
+// StageCache stores stage outputs for StagePipeline. Units passed to Put
+// and returned by Get are shared snapshots and must not be modified.
+// Implementations must be safe for concurrent use.
+type StageCache interface {
+	Get(key CacheKey) (*Unit, bool)
+	Put(key CacheKey, u *Unit)
+}
+
+// SetCache makes the pipeline look up each run in cache before doing any
+// work, resuming from the last stage whose output is cached, and store the
+// outputs of the stages it does run. A nil cache disables caching.
+//
+// Outputs served from the cache are shared between runs, so callers must
+// copy a unit before changing it; note that Rewrite works in place.
+// Re-registering a stage does not invalidate cached outputs. Validation
+// warnings are reported to the context again on every run, and a hit is
+// refused if the context's policy would refuse the tokenization it saves.
+//
+// Example:
+//   p.SetCache(nsigii.NewLRUCache(256))
+//   unit, results, err := p.Run(source) // cgo work only on a miss
+func (p *StagePipeline) SetCache(cache StageCache) {
+	p.cache = cache
+}
+
+// cacheKeys returns the key of each stage's output for source
+func (p *StagePipeline) cacheKeys(source string) []CacheKey {
+	h := sha256.New()
+	var n [8]byte
+	binary.LittleEndian.PutUint64(n[:], uint64(len(source)))
+	h.Write(n[:])
+	io.WriteString(h, source)
+	p.ctx.writeStageConfig(h)
+
+	keys := make([]CacheKey, len(p.stages))
+	for i, s := range p.stages {
+		h.Write([]byte{byte(s.ID())})
+		h.Sum(keys[i][:0])
+	}
+	return keys
+}
+
+// writeStageConfig writes the settings that shape stage outputs to w
+func (c *Context) writeStageConfig(w io.Writer) {
+	keywords := make([]string, 0, len(c.keywords))
+	for kw := range c.keywords {
+		keywords = append(keywords, kw)
+	}
+	slices.Sort(keywords)
+
+	fmt.Fprintf(w, "\x00schema=%q limit=%d pos=%t runes=%t utf8=%t kw=%t%q ops=%q delims=%q",
+		c.schema(), c.maxTokens, !c.noPos, c.runes, c.strictUTF8,
+		c.keywords != nil, keywords, c.operators, c.delimiters)
+	if c.profile != nil {
+		fmt.Fprintf(w, " profile=%#v", *c.profile)
+	}
+	if c.grammar != nil {
+		// Compiled grammars are immutable; identity is enough
+		fmt.Fprintf(w, " grammar=%p", c.grammar)
+	}
+	for _, v := range c.Validators() {
+		fmt.Fprintf(w, " validator=%T/%q", v, v.Name())
+	}
+}
+
+// ============================================================================
+// LRU Cache
+// ============================================================================
+
+// LRUCache is an in-memory StageCache holding a fixed number of outputs,
+// evicting the least recently used
+type LRUCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // front is most recent
+	entries  map[CacheKey]*list.Element
+}
+
+type lruEntry struct {
+	key  CacheKey
+	unit *Unit
+}
+
+// NewLRUCache returns a cache holding up to capacity stage outputs
+func NewLRUCache(capacity int) *LRUCache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &LRUCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[CacheKey]*list.Element, capacity),
+	}
+}
+
+// Get returns the output stored under key
+func (l *LRUCache) Get(key CacheKey) (*Unit, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[key]
+	if !ok {
+		return nil, false
+	}
+	l.order.MoveToFront(e)
+	return e.Value.(*lruEntry).unit, true
+}
+
+// Put stores u under key, evicting the least recently used output if the
+// cache is full
+func (l *LRUCache) Put(key CacheKey, u *Unit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e, ok := l.entries[key]; ok {
+		e.Value.(*lruEntry).unit = u
+		l.order.MoveToFront(e)
+		return
+	}
+
+	l.entries[key] = l.order.PushFront(&lruEntry{key: key, unit: u})
+	if l.order.Len() > l.capacity {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// Len returns the number of cached outputs
+func (l *LRUCache) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+// Purge empties the cache
+func (l *LRUCache) Purge() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.order.Init()
+	clear(l.entries)
+}
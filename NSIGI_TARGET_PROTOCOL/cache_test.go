@@ -0,0 +1,70 @@
+package nsigii
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStageCacheHitAuthorized(t *testing.T) {
+	ctx, err := NewContext("tokenize", "lexer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+
+	p, err := NewStagePipeline(ctx, StageTokenize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetCache(NewLRUCache(8))
+	if _, _, err := p.Run("let x = 1;"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ctx.SetPolicy(&Policy{Default: PolicyDeny}); err != nil {
+		t.Fatal(err)
+	}
+	var perr *PolicyError
+	if _, _, err := p.Run("let x = 1;"); !errors.As(err, &perr) {
+		t.Fatalf("Run on a cache hit under a denying policy = %v, want *PolicyError", err)
+	}
+
+	if err := ctx.SetPolicy(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.setColor(ctx.ColorState(), ColorBlack, "test", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := p.Run("let x = 1;"); !errors.Is(err, ErrTerminated) {
+		t.Fatalf("Run on a terminated context = %v, want ErrTerminated", err)
+	}
+}
+
+func TestStageCacheKeyedBySchema(t *testing.T) {
+	cache := NewLRUCache(8)
+	run := func(operation, service string) StageResult {
+		ctx, err := NewContext(operation, service)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ctx.Close()
+		p, err := NewStagePipeline(ctx, StageTokenize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.SetCache(cache)
+		_, results, err := p.Run("let x = 1;")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return results[0]
+	}
+
+	run("tokenize", "lexer")
+	if r := run("tokenize", "other"); r.Cached {
+		t.Error("output cached for one schema served to another")
+	}
+	if r := run("tokenize", "lexer"); !r.Cached {
+		t.Error("output not served from the cache for the same schema")
+	}
+}
@@ -0,0 +1,112 @@
+package nsigii
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync/atomic"
+)
+
+// ============================================================================
+// Cancellation (context.Context)
+// ============================================================================
+
+// TokenizeContext tokenizes source like Tokenize, but stops as soon as ctx
+// is cancelled or its deadline passes, returning ctx.Err()
+//
+// The source is fed to the lexer in line-aligned chunks (see
+// TokenizeReader) and ctx is checked between chunks, so a cancelled run
+// stops within one chunk rather than finishing the whole input. Strings,
+// comments and lexer modes open at a chunk boundary are carried into the
+// next chunk, so the tokens are those Tokenize returns.
+func (c *Context) TokenizeContext(ctx context.Context, source string) ([]Token, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var tokens []Token
+	r := contextReader{ctx: ctx, r: strings.NewReader(source)}
+	err := c.TokenizeReader(r, func(t Token) error {
+		tokens = append(tokens, t)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// AuxStartContext starts AUX noise like AuxStart, but gives up with
+// ctx.Err() if ctx is done before the context's AUX state is free, e.g.
+// while a long SubmitAux sequence holds it. AUX is then left as it was.
+func (c *Context) AuxStartContext(ctx context.Context, profile NoiseProfile) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return abandonable(ctx, func(claim func() bool) error {
+		return c.auxStart(profile, claim)
+	})
+}
+
+// VerifyRGBConsensusContext verifies RGB consensus like VerifyRGBConsensus,
+// but gives up with ctx.Err() if ctx is done before the native handle is
+// free, e.g. while a long tokenization holds it. No consensus is then
+// taken or audited.
+func (c *Context) VerifyRGBConsensusContext(ctx context.Context) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	var ok bool
+	err := abandonable(ctx, func(claim func() bool) (err error) {
+		ok, err = c.verifyRGBConsensus(claim)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return ok, nil
+}
+
+// abandonable runs fn on its own goroutine so the caller can stop waiting
+// once ctx is done. fn calls claim when it holds what it was waiting for
+// and is about to act: claim reports false if the caller has already given
+// up, and fn must then return without acting. Once fn has claimed, the
+// caller waits for it, so ctx.Err() is only returned for work not done.
+func abandonable(ctx context.Context, fn func(claim func() bool) error) error {
+	const (
+		pending int32 = iota
+		claimed
+		abandoned
+	)
+	var state atomic.Int32
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(func() bool { return state.CompareAndSwap(pending, claimed) })
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if state.CompareAndSwap(pending, abandoned) {
+			return ctx.Err()
+		}
+		return <-done
+	}
+}
+
+// contextReader fails reads once ctx is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,122 @@
+package nsigii
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenizeContextMatchesTokenize(t *testing.T) {
+	ctx, err := NewContext("tokenize", "lexer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+
+	// A block comment and a string spanning many lines, past any chunk size
+	source := "let a = 1;\n/*" + strings.Repeat(" comment line\n", 8192) + "*/\nlet s = \"" +
+		strings.Repeat("text\n", 8192) + "\";\n"
+
+	want, err := ctx.Tokenize(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ctx.TokenizeContext(context.Background(), source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("TokenizeContext returned %d tokens, Tokenize %d", len(got), len(want))
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ctx.TokenizeContext(cancelled, source); !errors.Is(err, context.Canceled) {
+		t.Fatalf("TokenizeContext(cancelled) = %v, want context.Canceled", err)
+	}
+}
+
+// countdownContext reports itself cancelled from its n-th Err call on, so
+// a test can cancel at a fixed point in a run
+type countdownContext struct {
+	context.Context
+	n atomic.Int32
+}
+
+func (c *countdownContext) Err() error {
+	if c.n.Add(-1) <= 0 {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestTokenizeContextCancelledMidRun(t *testing.T) {
+	ctx, err := NewContext("tokenize", "lexer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+
+	source := strings.Repeat("let x = y + 1; // filler\n", 1<<16)
+	cancelling := &countdownContext{Context: context.Background()}
+	cancelling.n.Store(3) // the up-front check, the first read, then cancelled
+	if _, err := ctx.TokenizeContext(cancelling, source); !errors.Is(err, context.Canceled) {
+		t.Fatalf("TokenizeContext cancelled mid-run = %v, want context.Canceled", err)
+	}
+	if n := cancelling.n.Load(); n < -2 {
+		t.Errorf("TokenizeContext checked ctx %d more times after it was cancelled", -n)
+	}
+}
+
+func TestContextCallsGiveUpWaiting(t *testing.T) {
+	ctx, err := NewContext("tokenize", "lexer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+
+	// A long AUX sequence holds the AUX state
+	ctx.aux.mu.Lock()
+	timeout, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	err = ctx.AuxStartContext(timeout, NoiseLow)
+	cancel()
+	ctx.aux.mu.Unlock()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("AuxStartContext while AUX is held = %v, want context.DeadlineExceeded", err)
+	}
+
+	// A long tokenization holds the native handle
+	ctx.handle.Lock()
+	timeout, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	_, err = ctx.VerifyRGBConsensusContext(timeout)
+	cancel()
+	ctx.handle.Unlock()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("VerifyRGBConsensusContext while the handle is held = %v, want context.DeadlineExceeded", err)
+	}
+
+	// The abandoned calls did nothing once they got through
+	time.Sleep(10 * time.Millisecond)
+	ctx.aux.mu.Lock()
+	running := ctx.aux.profile != nil
+	ctx.aux.mu.Unlock()
+	if running {
+		t.Error("abandoned AuxStartContext started AUX")
+	}
+	for _, e := range ctx.ColorAudit() {
+		if e.Kind == AuditConsensus {
+			t.Error("abandoned VerifyRGBConsensusContext took a consensus")
+		}
+	}
+
+	if err := ctx.AuxStartContext(context.Background(), NoiseLow); err != nil {
+		t.Fatalf("AuxStartContext = %v", err)
+	}
+	if _, err := ctx.VerifyRGBConsensusContext(context.Background()); err != nil {
+		t.Fatalf("VerifyRGBConsensusContext = %v", err)
+	}
+}
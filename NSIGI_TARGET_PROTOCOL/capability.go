@@ -0,0 +1,463 @@
+package nsigii
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// Capability Tokens
+// ============================================================================
+
+// capabilityPrefix starts the string form of every capability
+const capabilityPrefix = "cap."
+
+const capabilityVersion = 2 // Caveats with chained MACs
+
+// ErrCapabilityDenied is wrapped by every error VerifyCapability returns for
+// a capability that does not grant the operation
+var ErrCapabilityDenied = errors.New("capability does not grant the operation")
+
+// Capability is a bearer grant to perform some operations on contexts of
+// one schema until an expiry time, such as "may call tokenize on
+// obinexus.tokenize.lexer until 15:00". A context mints it with its phantom
+// key; any holder can narrow it further with Attenuate before handing it
+// on, but nobody can widen it. A receiving context checks it with
+// VerifyCapability.
+//
+// Capabilities are macaroons: the minted grant and each attenuation are
+// caveats, each authenticated with the MAC over the caveats before it as
+// the key, and only the final MAC is encoded. A holder can therefore add
+// caveats but not remove any.
+//
+// The zero Capability is invalid.
+type Capability struct {
+	issuer  PhantomID // phantom ID of the minting context
+	schema  string
+	nonce   [phantomNonceSize]byte
+	caveats []capabilityCaveat // the minted grant, then one per attenuation
+	mac     [sha256.Size]byte
+}
+
+// capabilityCaveat is one grant in a capability's chain, each at most as
+// wide as the one before it
+type capabilityCaveat struct {
+	holder  string // phantom ID namespace of the intended holder; "" for any
+	ops     []Operation
+	expires int64 // Unix nanoseconds
+}
+
+// MintCapability grants ops on contexts of schema until the given time.
+// holder, if not "", names the phantom ID namespace the capability is meant
+// for (see PhantomID.Within); it is informational unless the verifier
+// checks it. schema must serve the same operation as c.
+//
+// Example:
+//   capability, err := ctx.MintCapability("obinexus.tokenize.lexer", "",
+//       time.Now().Add(time.Hour), nsigii.OpTokenize)
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   send(worker, capability.String())
+//
+//   // In the worker:
+//   capability, _ := nsigii.ParseCapability(received)
+//   if err := workerCtx.UseCapability(capability); err != nil {
+//       log.Fatal(err)
+//   }
+//   workerCtx.SetPolicy(nsigii.CapabilityPolicy)
+func (c *Context) MintCapability(schema, holder string, until time.Time, ops ...Operation) (Capability, error) {
+	if err := c.require(RoleOperator, "MintCapability"); err != nil {
+		return Capability{}, err
+	}
+	if len(ops) == 0 {
+		return Capability{}, errors.New("capability grants no operations")
+	}
+	own, err := c.Schema()
+	if err != nil {
+		return Capability{}, err
+	}
+	if !compatibleSchemas(own, schema) {
+		return Capability{}, fmt.Errorf("%s cannot grant capabilities on %s", own, schema)
+	}
+	if len(holder) > phantomMaxField {
+		return Capability{}, errors.New("capability holder too long")
+	}
+	issuer, err := c.PhantomID()
+	if err != nil {
+		return Capability{}, err
+	}
+
+	cp := Capability{
+		issuer: issuer,
+		schema: schema,
+		caveats: []capabilityCaveat{{
+			holder:  holder,
+			ops:     slices.Clone(ops),
+			expires: until.UnixNano(),
+		}},
+	}
+	if _, err := rand.Read(cp.nonce[:]); err != nil {
+		return Capability{}, fmt.Errorf("failed to generate capability: %w", err)
+	}
+	key, err := c.originKey(issuer)
+	if err != nil {
+		return Capability{}, err
+	}
+	cp.mac = cp.sign(key)
+	return cp, nil
+}
+
+// Attenuate derives a capability from parent granting at most the same:
+// ops must be a subset of parent's, until is capped at parent's expiry,
+// and holder must lie within parent's holder. The child is authenticated
+// with parent's MAC as the key, so no key is needed, and does not contain
+// that MAC, so parent cannot be recovered from it.
+//
+// Example:
+//   narrow, err := nsigii.Attenuate(capability, "obinexus/tokenize/lexer/worker-3",
+//       time.Now().Add(5*time.Minute), nsigii.OpTokenize)
+func Attenuate(parent Capability, holder string, until time.Time, ops ...Operation) (Capability, error) {
+	if parent.IsZero() {
+		return Capability{}, errors.New("zero capability")
+	}
+	if parent.Depth() >= phantomMaxDepth {
+		return Capability{}, errors.New("capability delegation chain too deep")
+	}
+	if len(ops) == 0 {
+		return Capability{}, errors.New("capability grants no operations")
+	}
+	grant := parent.grant()
+	for _, op := range ops {
+		if !slices.Contains(grant.ops, op) {
+			return Capability{}, fmt.Errorf("cannot attenuate to %s: not granted by parent", op)
+		}
+	}
+	if !holderWithin(holder, grant.holder) {
+		return Capability{}, fmt.Errorf("holder %q is not within %q", holder, grant.holder)
+	}
+
+	caveat := capabilityCaveat{
+		holder:  holder,
+		ops:     slices.Clone(ops),
+		expires: min(until.UnixNano(), grant.expires),
+	}
+	cp := Capability{
+		issuer:  parent.issuer,
+		schema:  parent.schema,
+		nonce:   parent.nonce,
+		caveats: append(slices.Clip(parent.caveats), caveat),
+		mac:     chainMAC(parent.mac[:], caveat.encode()),
+	}
+	return cp, nil
+}
+
+// grant returns the last caveat, which the others only widen
+func (cp Capability) grant() capabilityCaveat {
+	if len(cp.caveats) == 0 {
+		return capabilityCaveat{}
+	}
+	return cp.caveats[len(cp.caveats)-1]
+}
+
+// Issuer returns the phantom ID of the context that minted the capability
+func (cp Capability) Issuer() PhantomID {
+	return cp.issuer
+}
+
+// Holder returns the namespace of the intended holder; "" for any
+func (cp Capability) Holder() string {
+	return cp.grant().holder
+}
+
+// Schema returns the schema of the contexts the capability applies to
+func (cp Capability) Schema() string {
+	return cp.schema
+}
+
+// Operations returns the operations the capability grants
+func (cp Capability) Operations() []Operation {
+	return slices.Clone(cp.grant().ops)
+}
+
+// Expires returns when the capability lapses
+func (cp Capability) Expires() time.Time {
+	return time.Unix(0, cp.grant().expires)
+}
+
+// IsZero reports whether cp is the zero Capability
+func (cp Capability) IsZero() bool {
+	return cp.mac == [sha256.Size]byte{} && cp.schema == "" && len(cp.caveats) == 0
+}
+
+// Depth returns the number of attenuations between cp and the minted
+// capability; 0 for a minted one
+func (cp Capability) Depth() int {
+	return max(len(cp.caveats)-1, 0)
+}
+
+// VerifyCapability checks that cp grants op on c: it was minted under c's
+// phantom key or key ring by a context c accepts, it names c's schema, it has not
+// expired, every attenuation only narrowed it, and op is among its
+// operations. Errors for capabilities that fail wrap ErrCapabilityDenied.
+func (c *Context) VerifyCapability(cp Capability, op Operation) error {
+	if cp.IsZero() {
+		return fmt.Errorf("%w: zero capability", ErrCapabilityDenied)
+	}
+	schema, err := c.Schema()
+	if err != nil {
+		return err
+	}
+
+	if ok, err := c.VerifyPhantomID(cp.issuer); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("%w: issuer is not trusted", ErrCapabilityDenied)
+	}
+	if key, err := c.originKey(cp.issuer); err != nil || !cp.authentic(key) {
+		return fmt.Errorf("%w: capability is forged or altered", ErrCapabilityDenied)
+	}
+	if cp.schema != schema {
+		return fmt.Errorf("%w: capability is for %s, not %s", ErrCapabilityDenied, cp.schema, schema)
+	}
+	grant := cp.grant()
+	if time.Now().UnixNano() >= grant.expires {
+		return fmt.Errorf("%w: capability expired at %s", ErrCapabilityDenied, cp.Expires().Format(time.RFC3339))
+	}
+	if !slices.Contains(grant.ops, op) {
+		return fmt.Errorf("%w: %s not granted", ErrCapabilityDenied, op)
+	}
+	return nil
+}
+
+// authentic reports whether cp's MAC, chained through every caveat, checks
+// out against the minting key and each caveat only narrows the one before
+func (cp Capability) authentic(key []byte) bool {
+	if len(cp.caveats) == 0 {
+		return false
+	}
+	for i := 1; i < len(cp.caveats); i++ {
+		p, c := cp.caveats[i-1], cp.caveats[i]
+		if c.expires > p.expires || !holderWithin(c.holder, p.holder) {
+			return false
+		}
+		for _, op := range c.ops {
+			if !slices.Contains(p.ops, op) {
+				return false
+			}
+		}
+	}
+
+	mac := cp.sign(key)
+	return hmac.Equal(mac[:], cp.mac[:])
+}
+
+// holderWithin reports whether a holder namespace lies within parent's;
+// every holder lies within ""
+func holderWithin(holder, parent string) bool {
+	parent = strings.TrimSuffix(parent, "/")
+	return parent == "" || holder == parent || strings.HasPrefix(holder, parent+"/")
+}
+
+// ============================================================================
+// Capability Encoding
+// ============================================================================
+
+// identifier encodes the fields the first MAC of the chain covers
+func (cp Capability) identifier() []byte {
+	issuer := cp.issuer.encode()
+
+	b := make([]byte, 0, 1+4+len(issuer)+2+len(cp.schema)+phantomNonceSize)
+	b = append(b, capabilityVersion)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(issuer)))
+	b = append(b, issuer...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(cp.schema)))
+	b = append(b, cp.schema...)
+	return append(b, cp.nonce[:]...)
+}
+
+// encode returns the caveat's binary form
+func (cv capabilityCaveat) encode() []byte {
+	b := make([]byte, 0, 2+len(cv.holder)+1+len(cv.ops)+8)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(cv.holder)))
+	b = append(b, cv.holder...)
+	b = append(b, byte(len(cv.ops)))
+	for _, op := range cv.ops {
+		b = append(b, byte(op))
+	}
+	return binary.BigEndian.AppendUint64(b, uint64(cv.expires))
+}
+
+// encode returns the capability's binary form: its identifier, the number
+// of caveats, the caveats and the final MAC
+func (cp Capability) encode() []byte {
+	b := append(cp.identifier(), byte(len(cp.caveats)))
+	for _, cv := range cp.caveats {
+		b = append(b, cv.encode()...)
+	}
+	return append(b, cp.mac[:]...)
+}
+
+// sign computes the capability's MAC under the minting key: a MAC over the
+// identifier, then over each caveat in turn, keyed by the MAC before it
+func (cp Capability) sign(key []byte) [sha256.Size]byte {
+	mac := chainMAC(key, cp.identifier())
+	for _, cv := range cp.caveats {
+		mac = chainMAC(mac[:], cv.encode())
+	}
+	return mac
+}
+
+// String returns the capability in its printable form, cap.<base64url>
+func (cp Capability) String() string {
+	return capabilityPrefix + base64.RawURLEncoding.EncodeToString(cp.encode())
+}
+
+// ParseCapability parses the printable form produced by Capability.String.
+// It checks the encoding only; use Context.VerifyCapability to authenticate
+// the result.
+func ParseCapability(s string) (Capability, error) {
+	enc, ok := strings.CutPrefix(s, capabilityPrefix)
+	if !ok {
+		return Capability{}, errors.New("invalid capability: missing prefix")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(enc)
+	if err != nil {
+		return Capability{}, fmt.Errorf("invalid capability: %w", err)
+	}
+
+	return decodeCapability(b)
+}
+
+// decodeCapability decodes the binary form of a capability
+func decodeCapability(b []byte) (Capability, error) {
+	if len(b) == 0 || b[0] != capabilityVersion {
+		return Capability{}, errors.New("invalid capability: unsupported version")
+	}
+	b = b[1:]
+
+	truncated := errors.New("invalid capability: truncated")
+	field := func() (string, bool) {
+		if len(b) < 2 {
+			return "", false
+		}
+		n := int(binary.BigEndian.Uint16(b))
+		if len(b) < 2+n {
+			return "", false
+		}
+		s := string(b[2 : 2+n])
+		b = b[2+n:]
+		return s, true
+	}
+
+	var cp Capability
+	if len(b) < 4 {
+		return Capability{}, truncated
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint64(len(b)) < 4+uint64(n) {
+		return Capability{}, truncated
+	}
+	issuer, err := decodePhantomID(b[4 : 4+n])
+	if err != nil {
+		return Capability{}, fmt.Errorf("invalid capability issuer: %w", err)
+	}
+	cp.issuer = issuer
+	b = b[4+n:]
+
+	var ok bool
+	if cp.schema, ok = field(); !ok || len(b) < phantomNonceSize+1 {
+		return Capability{}, truncated
+	}
+	copy(cp.nonce[:], b)
+	count := int(b[phantomNonceSize])
+	b = b[phantomNonceSize+1:]
+	if count == 0 || count > phantomMaxDepth+1 {
+		return Capability{}, errors.New("invalid capability: bad caveat count")
+	}
+
+	for range count {
+		var cv capabilityCaveat
+		if cv.holder, ok = field(); !ok || len(b) < 1 || len(b) < 1+int(b[0])+8 {
+			return Capability{}, truncated
+		}
+		for _, op := range b[1 : 1+int(b[0])] {
+			cv.ops = append(cv.ops, Operation(op))
+		}
+		b = b[1+int(b[0]):]
+		cv.expires = int64(binary.BigEndian.Uint64(b))
+		b = b[8:]
+		cp.caveats = append(cp.caveats, cv)
+	}
+
+	if len(b) != sha256.Size {
+		return Capability{}, truncated
+	}
+	copy(cp.mac[:], b)
+
+	return cp, nil
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (cp Capability) MarshalText() ([]byte, error) {
+	if cp.IsZero() {
+		return nil, errors.New("cannot marshal zero capability")
+	}
+	return []byte(cp.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (cp *Capability) UnmarshalText(text []byte) error {
+	parsed, err := ParseCapability(string(text))
+	if err != nil {
+		return err
+	}
+	*cp = parsed
+	return nil
+}
+
+// ============================================================================
+// Capability Policy
+// ============================================================================
+
+// UseCapability verifies that cp applies to c and has not expired, and
+// keeps it as the capability CapabilityPolicy checks the context's
+// operations against. The zero Capability clears it.
+func (c *Context) UseCapability(cp Capability) error {
+	if err := c.require(RoleAdmin, "UseCapability"); err != nil {
+		return err
+	}
+	if cp.IsZero() {
+		c.capability = nil
+		return nil
+	}
+	ops := cp.grant().ops
+	if len(ops) == 0 {
+		return fmt.Errorf("%w: capability grants no operations", ErrCapabilityDenied)
+	}
+	if err := c.VerifyCapability(cp, ops[0]); err != nil {
+		return err
+	}
+	c.capability = &cp
+	return nil
+}
+
+// CapabilityPolicy allows an operation only if the capability given to
+// UseCapability grants it at the time of the call
+var CapabilityPolicy PolicyEngine = PolicyFunc(func(c *Context, req PolicyRequest) (PolicyDecision, error) {
+	if c.capability == nil {
+		return PolicyDecision{Reason: "no capability presented"}, nil
+	}
+	if err := c.VerifyCapability(*c.capability, req.Op); err != nil {
+		return PolicyDecision{Reason: err.Error()}, nil
+	}
+	return PolicyDecision{Allow: true, Reason: "capability grants " + req.Op.String()}, nil
+})
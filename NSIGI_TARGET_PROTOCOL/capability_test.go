@@ -0,0 +1,58 @@
+package nsigii
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAttenuateCannotWiden(t *testing.T) {
+	ctx, err := NewContext("tokenize", "lexer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+
+	parent, err := ctx.MintCapability("obinexus.tokenize.lexer", "", time.Now().Add(24*time.Hour),
+		OpTokenize, OpAuxStart, OpConsensus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := Attenuate(parent, "obinexus/tokenize/lexer/worker-3", time.Now().Add(time.Minute), OpTokenize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseCapability(child.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.VerifyCapability(parsed, OpTokenize); err != nil {
+		t.Fatalf("VerifyCapability(child, tokenize) = %v", err)
+	}
+	if err := ctx.VerifyCapability(parsed, OpAuxStart); !errors.Is(err, ErrCapabilityDenied) {
+		t.Fatalf("VerifyCapability(child, aux_start) = %v, want ErrCapabilityDenied", err)
+	}
+
+	// The child's encoding must not carry the key the parent is checked with
+	if bytes.Contains(child.encode(), parent.mac[:]) {
+		t.Fatal("attenuated capability contains its parent's MAC")
+	}
+
+	// Cutting the attenuation off leaves the parent's caveats with the
+	// child's MAC, which does not verify
+	widened := parsed
+	widened.caveats = widened.caveats[:1]
+	if err := ctx.VerifyCapability(widened, OpAuxStart); !errors.Is(err, ErrCapabilityDenied) {
+		t.Fatalf("VerifyCapability(widened, aux_start) = %v, want ErrCapabilityDenied", err)
+	}
+
+	// So does widening the last caveat in place
+	widened = parsed
+	widened.caveats = append([]capabilityCaveat(nil), parsed.caveats...)
+	widened.caveats[1].ops = []Operation{OpTokenize, OpAuxStart}
+	if err := ctx.VerifyCapability(widened, OpAuxStart); !errors.Is(err, ErrCapabilityDenied) {
+		t.Fatalf("VerifyCapability(widened in place, aux_start) = %v, want ErrCapabilityDenied", err)
+	}
+}
@@ -0,0 +1,51 @@
+package nsigii
+
+// ============================================================================
+// Cloning
+// ============================================================================
+
+// Clone creates a new context with its own native handle that inherits c's
+// schema, settings, polarity, color state and running AUX noise profile, so
+// workers fanned out from an established context need not repeat its trust
+// establishment sequence. The clone starts with an empty audit trail and no
+// hooks or subscribers; an open AuxSession is not shared, the clone's noise
+// is stopped with AuxStop.
+//
+// Example:
+//   worker, err := ctx.Clone()
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   defer worker.Close()
+func (c *Context) Clone() (*Context, error) {
+	if err := c.usable(); err != nil {
+		return nil, err
+	}
+
+	clone, err := c.fork()
+	if err != nil {
+		return nil, err
+	}
+
+	if state := c.ColorState(); state != ColorRed {
+		if err := clone.setColor(ColorRed, state, "cloned", true); err != nil {
+			clone.Close()
+			return nil, err
+		}
+	}
+
+	c.aux.mu.Lock()
+	profile := c.aux.profile
+	c.aux.mu.Unlock()
+	if profile != nil {
+		clone.aux.mu.Lock()
+		err := clone.runAux(AuxInstruction{Op: AuxNoise, Noise: *profile})
+		clone.aux.mu.Unlock()
+		if err != nil {
+			clone.Close()
+			return nil, err
+		}
+	}
+
+	return clone, nil
+}
@@ -0,0 +1,69 @@
+// Command nsigii-bench runs the NSIGII benchmarks and prints the results
+// in the format of go test -bench, for comparing builds with benchstat
+//
+// Usage:
+//   nsigii-bench [-run regexp] [-count n] [-benchtime d]
+//
+// Example:
+//   nsigii-bench -count 10 > old.txt
+//   # rebuild against the new bindings or libnsigii_rift
+//   nsigii-bench -count 10 > new.txt
+//   benchstat old.txt new.txt
+//
+// The exit status is 0 on success, 1 if a benchmark failed, and 2 for
+// usage errors. See package benchmarks for the benchmarks and corpora.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/obinexus/nsigii-rift/nsigii/benchmarks"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command line args and returns the exit status
+func run(args []string, stdout, stderr io.Writer) int {
+	// Registers -test.benchtime, which testing.Benchmark reads
+	testing.Init()
+
+	fs := flag.NewFlagSet("nsigii-bench", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	match := fs.String("run", "", "run only benchmarks matching `regexp`, e.g. ^Tokenize/")
+	count := fs.Int("count", 1, "run each benchmark `n` times")
+	benchtime := fs.String("benchtime", "1s", "run each benchmark for duration `d`, or Nx iterations")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 || *count < 1 {
+		fs.Usage()
+		return 2
+	}
+
+	opts := benchmarks.Options{Count: *count}
+	if *match != "" {
+		re, err := regexp.Compile(*match)
+		if err != nil {
+			fmt.Fprintf(stderr, "nsigii-bench: -run: %v\n", err)
+			return 2
+		}
+		opts.Match = re
+	}
+	if err := flag.Set("test.benchtime", *benchtime); err != nil {
+		fmt.Fprintf(stderr, "nsigii-bench: -benchtime: %v\n", err)
+		return 2
+	}
+
+	if err := benchmarks.Run(stdout, opts); err != nil {
+		fmt.Fprintf(stderr, "nsigii-bench: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,185 @@
+//go:build js && wasm
+
+// Command nsigii-wasm exposes NSIGII tokenization to JavaScript, so browser
+// tooling and playgrounds can tokenize RIFT sources without a server. It
+// always uses the pure-Go backend.
+//
+// Build it and load it with the wasm_exec.js shipped with Go:
+//   GOOS=js GOARCH=wasm go build -o nsigii.wasm ./cmd/nsigii-wasm
+//   cp "$(go env GOROOT)/lib/wasm/wasm_exec.js" .
+//
+// Once started, the module defines globalThis.nsigii:
+//   nsigii.tokenize(source, opts)  {schema, tokens}
+//   nsigii.analyze(source, opts)   {schema, stats}
+//   nsigii.validate(source, opts)  {schema, diagnostics}
+//   nsigii.schema(opts)            {schema}
+//
+// opts is optional and may set operation, service and profile (a built-in
+// language profile such as "go"). Results are plain objects in the JSON form
+// of the Go types; failures return {error: message} instead of throwing.
+//
+// Example:
+//   const go = new Go();
+//   const { instance } = await WebAssembly.instantiateStreaming(fetch("nsigii.wasm"), go.importObject);
+//   go.run(instance);
+//   const { tokens } = nsigii.tokenize("let x = 1;", { profile: "rift" });
+//
+// The same package also compiles for GOOS=wasip1, where cmd/nsigii serves as
+// the WASI entry point instead.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"syscall/js"
+
+	"github.com/obinexus/nsigii-rift/nsigii"
+)
+
+// pool keeps contexts alive between calls; JavaScript calls in on a single
+// goroutine, so one context per schema is all it ever holds
+var pool = nsigii.NewContextPool(nsigii.PoolOptions{MaxIdle: 1})
+
+// options are the fields read from a call's opts argument
+type options struct {
+	operation string
+	service   string
+	profile   string
+}
+
+func main() {
+	api := map[string]any{
+		"tokenize": export(1, func(source string, c *nsigii.Context) (map[string]any, error) {
+			tokens, err := c.Tokenize(source)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{"tokens": tokens}, nil
+		}),
+		"analyze": export(1, func(source string, c *nsigii.Context) (map[string]any, error) {
+			tokens, err := c.Tokenize(source)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{"stats": nsigii.AnalyzeTokens(tokens)}, nil
+		}),
+		"validate": export(1, func(source string, c *nsigii.Context) (map[string]any, error) {
+			// Syntax errors are reported as diagnostics too, so editors
+			// can show them the same way
+			diags, err := c.Validate(source)
+			var (
+				verr *nsigii.ValidationError
+				serr *nsigii.SyntaxError
+			)
+			switch {
+			case errors.As(err, &serr):
+				diags = []nsigii.Diagnostic{serr.Diagnostic}
+			case err != nil && !errors.As(err, &verr):
+				return nil, err
+			}
+			if diags == nil {
+				diags = []nsigii.Diagnostic{}
+			}
+			return map[string]any{"diagnostics": diags}, nil
+		}),
+		"schema": export(0, func(string, *nsigii.Context) (map[string]any, error) {
+			return map[string]any{}, nil
+		}),
+	}
+	js.Global().Set("nsigii", js.ValueOf(api))
+
+	select {} // Keep the exported functions callable
+}
+
+// export wraps fn as a JavaScript function. With sources set to 1 the first
+// argument is the source; the argument after it is the opts object.
+func export(sources int, fn func(source string, c *nsigii.Context) (map[string]any, error)) js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		var source string
+		if sources > 0 {
+			if len(args) == 0 || args[0].Type() != js.TypeString {
+				return failure(errors.New("source must be a string"))
+			}
+			source = args[0].String()
+		}
+		var opts js.Value
+		if len(args) > sources {
+			opts = args[sources]
+		}
+
+		result, err := call(parseOptions(opts), source, fn)
+		if err != nil {
+			return failure(err)
+		}
+		return result
+	})
+}
+
+// call runs fn on a pooled context and converts its result to JavaScript
+func call(opts options, source string, fn func(string, *nsigii.Context) (map[string]any, error)) (js.Value, error) {
+	var lp nsigii.LanguageProfile
+	if opts.profile != "" {
+		var ok bool
+		if lp, ok = nsigii.LookupProfile(opts.profile); !ok {
+			return js.Value{}, fmt.Errorf("unknown language profile %q", opts.profile)
+		}
+	}
+
+	c, err := pool.Get(context.Background(), opts.operation, opts.service)
+	if err != nil {
+		return js.Value{}, err
+	}
+	defer pool.Put(c) // Put restores the pool's settings
+
+	if opts.profile != "" {
+		if err := c.SetProfile(lp); err != nil {
+			return js.Value{}, err
+		}
+	}
+	schema, err := c.Schema()
+	if err != nil {
+		return js.Value{}, err
+	}
+	result, err := fn(source, c)
+	if err != nil {
+		return js.Value{}, err
+	}
+	result["schema"] = schema
+	return toJS(result)
+}
+
+// parseOptions reads the opts argument, defaulting the schema to
+// obinexus.tokenize.wasm
+func parseOptions(v js.Value) options {
+	opts := options{operation: "tokenize", service: "wasm"}
+	if v.Type() != js.TypeObject {
+		return opts
+	}
+	if s := v.Get("operation"); s.Type() == js.TypeString && s.String() != "" {
+		opts.operation = s.String()
+	}
+	if s := v.Get("service"); s.Type() == js.TypeString && s.String() != "" {
+		opts.service = s.String()
+	}
+	if s := v.Get("profile"); s.Type() == js.TypeString {
+		opts.profile = s.String()
+	}
+	return opts
+}
+
+// toJS converts v to a JavaScript value through its JSON form, so results
+// match what the JSON encoders of the package produce
+func toJS(v any) (js.Value, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return js.Value{}, err
+	}
+	return js.Global().Get("JSON").Call("parse", string(data)), nil
+}
+
+// failure is the result of a call that failed
+func failure(err error) any {
+	return map[string]any{"error": err.Error()}
+}
@@ -0,0 +1,410 @@
+// Command nsigii runs NSIGII RIFT operations from the shell
+//
+// Usage:
+//   nsigii tokenize  [-format json|text|binary] [-profile name] [file...]
+//   nsigii analyze   [-format json|text] [-profile name] [file...]
+//   nsigii consensus [-format json|text]
+//   nsigii schema
+//   nsigii aux       [-level n] [-burst n] [-period d] [-for d] [-replay file]
+//   nsigii worker    [-nats url] [-subject s] [-queue q] [-encoding json|msgpack] [-concurrency n]
+//
+// Every subcommand accepts -operation and -service to choose the context
+// schema obinexus.[operation].[service]. Sources are read from the named
+// files, or from standard input if there are none or a name is "-". The
+// worker serves tokenization requests over NATS until interrupted; see
+// package natsworker.
+//
+// The exit status is 0 on success, 1 if an operation failed or consensus
+// was not reached, and 2 for usage errors.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/obinexus/nsigii-rift/nsigii"
+	"github.com/obinexus/nsigii-rift/nsigii/encoding"
+	"github.com/obinexus/nsigii-rift/nsigii/natsworker"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// errUsage marks errors caused by the command line rather than the work
+var errUsage = errors.New("usage")
+
+// command is a subcommand: its flags are registered on fs, then it runs
+// with the remaining arguments
+type command struct {
+	name    string
+	summary string
+	run     func(cmd *cli, args []string) error
+}
+
+var commands = []command{
+	{"tokenize", "tokenize sources and write their tokens", (*cli).tokenize},
+	{"analyze", "tokenize sources and write token statistics", (*cli).analyze},
+	{"consensus", "run an RGB consensus check", (*cli).consensus},
+	{"schema", "print the context schema", (*cli).schema},
+	{"aux", "run AUX noise or replay an AUX recording", (*cli).aux},
+	{"worker", "serve tokenization requests from NATS", (*cli).worker},
+}
+
+// cli holds the state of one invocation
+type cli struct {
+	fs      *flag.FlagSet
+	stdin   io.Reader
+	stdout  io.Writer
+	stderr  io.Writer
+	op      string
+	service string
+	format  string
+	profile string
+}
+
+// run executes the command line args and returns the exit status
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		usage(stderr)
+		return 2
+	}
+
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == args[0] {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(stderr, "nsigii: unknown command %q\n", args[0])
+		usage(stderr)
+		return 2
+	}
+
+	c := &cli{
+		fs:     flag.NewFlagSet("nsigii "+cmd.name, flag.ContinueOnError),
+		stdin:  stdin,
+		stdout: stdout,
+		stderr: stderr,
+	}
+	c.fs.SetOutput(stderr)
+	c.fs.StringVar(&c.op, "operation", "tokenize", "schema operation")
+	c.fs.StringVar(&c.service, "service", "cli", "schema service")
+
+	err := cmd.run(c, args[1:])
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp):
+		return 2
+	case errors.Is(err, errUsage):
+		fmt.Fprintf(stderr, "nsigii %s: %v\n", cmd.name, err)
+		return 2
+	}
+	fmt.Fprintf(stderr, "nsigii %s: %v\n", cmd.name, err)
+	return 1
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: nsigii <command> [flags] [file...]")
+	fmt.Fprintln(w)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "nsigii <command> -h" for the flags of a command.`)
+}
+
+// parse parses the subcommand's flags, checking -format against formats
+func (c *cli) parse(args []string, formats ...string) error {
+	if len(formats) > 0 {
+		c.fs.StringVar(&c.format, "format", formats[0], "output format: "+fmt.Sprint(formats))
+	}
+	if err := c.fs.Parse(args); err != nil {
+		return err
+	}
+
+	if len(formats) > 0 {
+		for _, f := range formats {
+			if c.format == f {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: unknown format %q", errUsage, c.format)
+	}
+	return nil
+}
+
+// context creates the context for the invocation
+func (c *cli) context() (*nsigii.Context, error) {
+	var opts []nsigii.Option
+	if c.profile != "" {
+		p, ok := nsigii.LookupProfile(c.profile)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown language profile %q", errUsage, c.profile)
+		}
+		opts = append(opts, nsigii.WithProfile(p))
+	}
+	return nsigii.NewContext(c.op, c.service, opts...)
+}
+
+// sources calls fn with the name and content of every input
+func (c *cli) sources(fn func(name, source string) error) error {
+	names := c.fs.Args()
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+
+	for _, name := range names {
+		var (
+			data []byte
+			err  error
+		)
+		if name == "-" {
+			data, err = io.ReadAll(c.stdin)
+		} else {
+			data, err = os.ReadFile(name)
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(name, string(data)); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// tokenize implements "nsigii tokenize"
+func (c *cli) tokenize(args []string) error {
+	c.fs.StringVar(&c.profile, "profile", "", "language profile (rift, c, go, python, json)")
+	if err := c.parse(args, "json", "text", "binary"); err != nil {
+		return err
+	}
+	if c.format == "binary" && c.fs.NArg() > 1 {
+		return fmt.Errorf("%w: binary output takes a single input", errUsage)
+	}
+
+	ctx, err := c.context()
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+	schema, err := ctx.Schema()
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(c.stdout)
+	return c.sources(func(name, source string) error {
+		tokens, err := ctx.Tokenize(source)
+		if err != nil {
+			return err
+		}
+
+		switch c.format {
+		case "binary":
+			return encoding.WriteTokens(c.stdout, tokens)
+		case "text":
+			for _, t := range tokens {
+				if _, err := fmt.Fprintf(c.stdout, "%s:%d:%d\t%s\t%q\n", name, t.Line, t.Column, t.Type, t.Lexeme()); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return enc.Encode(nsigii.NewTokenStream(schema, tokens))
+	})
+}
+
+// analyze implements "nsigii analyze"
+func (c *cli) analyze(args []string) error {
+	c.fs.StringVar(&c.profile, "profile", "", "language profile (rift, c, go, python, json)")
+	if err := c.parse(args, "json", "text"); err != nil {
+		return err
+	}
+
+	ctx, err := c.context()
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	enc := json.NewEncoder(c.stdout)
+	return c.sources(func(name, source string) error {
+		tokens, err := ctx.Tokenize(source)
+		if err != nil {
+			return err
+		}
+		stats := nsigii.AnalyzeTokens(tokens)
+
+		if c.format == "json" {
+			return enc.Encode(struct {
+				File  string            `json:"file"`
+				Stats nsigii.TokenStats `json:"stats"`
+			}{name, stats})
+		}
+
+		fmt.Fprintf(c.stdout, "%s: %d tokens, memory %d-%d, average length %.2f\n",
+			name, stats.TotalTokens, stats.MemoryRange[0], stats.MemoryRange[1], stats.AverageLength)
+		types := make([]nsigii.TokenType, 0, len(stats.TypeDistribution))
+		for t := range stats.TypeDistribution {
+			types = append(types, t)
+		}
+		sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+		for _, t := range types {
+			fmt.Fprintf(c.stdout, "  %-12s %d\n", t, stats.TypeDistribution[t])
+		}
+		return nil
+	})
+}
+
+// consensus implements "nsigii consensus"
+func (c *cli) consensus(args []string) error {
+	if err := c.parse(args, "json", "text"); err != nil {
+		return err
+	}
+
+	ctx, err := c.context()
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	passed, err := ctx.VerifyRGBConsensus()
+	if err != nil {
+		return err
+	}
+
+	if c.format == "json" {
+		err = json.NewEncoder(c.stdout).Encode(struct {
+			Passed bool                `json:"passed"`
+			State  nsigii.ColorChannel `json:"state"`
+		}{passed, ctx.ColorState()})
+	} else {
+		_, err = fmt.Fprintf(c.stdout, "consensus passed=%t state=%s\n", passed, ctx.ColorState())
+	}
+	if err != nil {
+		return err
+	}
+	if !passed {
+		return nsigii.ErrConsensusFailed
+	}
+	return nil
+}
+
+// schema implements "nsigii schema"
+func (c *cli) schema(args []string) error {
+	if err := c.parse(args); err != nil {
+		return err
+	}
+
+	ctx, err := c.context()
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	schema, err := ctx.Schema()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(c.stdout, schema)
+	return err
+}
+
+// aux implements "nsigii aux"
+func (c *cli) aux(args []string) error {
+	var (
+		profile  nsigii.NoiseProfile
+		duration time.Duration
+		replay   string
+	)
+	c.fs.IntVar(&profile.Level, "level", 1, "noise level")
+	c.fs.IntVar(&profile.Burst, "burst", 0, "draws per burst")
+	c.fs.DurationVar(&profile.Period, "period", 0, "time between bursts")
+	c.fs.DurationVar(&duration, "for", time.Second, "how long to run noise")
+	c.fs.StringVar(&replay, "replay", "", "AUX recording to replay with its timing")
+	if err := c.parse(args); err != nil {
+		return err
+	}
+	if err := profile.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	ctx, err := c.context()
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	if replay != "" {
+		f, err := os.Open(replay)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := ctx.ReplayAux(f, true); err != nil {
+			return err
+		}
+	} else {
+		seq := nsigii.NewAuxSequence().Noise(profile).Delay(duration).Sync()
+		if err := ctx.SubmitAux(seq); err != nil {
+			return err
+		}
+	}
+
+	return json.NewEncoder(c.stdout).Encode(ctx.AuxStats())
+}
+
+// worker implements "nsigii worker"
+func (c *cli) worker(args []string) error {
+	var (
+		url  string
+		opts natsworker.Options
+	)
+	c.fs.StringVar(&url, "nats", nats.DefaultURL, "NATS server URL")
+	c.fs.StringVar(&opts.Subject, "subject", "nsigii.tokenize", "subject to serve")
+	c.fs.StringVar(&opts.Queue, "queue", "nsigii", "queue group")
+	c.fs.StringVar(&opts.Encoding, "encoding", "json", "default reply encoding: json or msgpack")
+	c.fs.IntVar(&opts.Concurrency, "concurrency", 0, "requests handled at once (0 for GOMAXPROCS)")
+	if err := c.parse(args); err != nil {
+		return err
+	}
+	if opts.Encoding != "json" && opts.Encoding != "msgpack" {
+		return fmt.Errorf("%w: unknown encoding %q", errUsage, opts.Encoding)
+	}
+	opts.Operation, opts.Service = c.op, c.service
+
+	nc, err := nats.Connect(url, nats.Name("nsigii worker"))
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	pool := nsigii.NewContextPool(nsigii.PoolOptions{})
+	defer pool.Close()
+	opts.Pool = pool
+	w, err := natsworker.New(nc, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stderr, "nsigii worker: serving %s (queue %s) on %s\n", opts.Subject, opts.Queue, nc.ConnectedUrl())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	return w.Close()
+}
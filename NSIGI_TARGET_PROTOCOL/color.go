@@ -0,0 +1,207 @@
+package nsigii
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Color State Machine
+// ============================================================================
+
+// colorTransitions lists the legal successors of each color state. Contexts
+// start in RED; BLACK is terminal. CONTRAST is never a state of its own.
+var colorTransitions = map[ColorChannel][]ColorChannel{
+	ColorRed:     {ColorGreen, ColorCyan, ColorYellow, ColorMagenta, ColorBlack},
+	ColorGreen:   {ColorCyan, ColorBlue, ColorYellow, ColorMagenta, ColorBlack},
+	ColorCyan:    {ColorBlue, ColorYellow, ColorMagenta, ColorBlack},
+	ColorBlue:    {ColorRed, ColorYellow, ColorMagenta, ColorBlack},
+	ColorYellow:  {ColorRed, ColorMagenta, ColorBlack},
+	ColorMagenta: {ColorYellow, ColorBlack},
+}
+
+// LegalColorTransition reports whether a context may move from one color
+// state to another
+func LegalColorTransition(from, to ColorChannel) bool {
+	for _, next := range colorTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ColorTransition records a change of a context's color state
+type ColorTransition struct {
+	From   ColorChannel
+	To     ColorChannel
+	Reason string // Why the transition happened, "" if not given
+	Time   time.Time
+}
+
+// colorState is a context's current color channel and its observers
+type colorState struct {
+	mu        sync.Mutex
+	channel   ColorChannel // zero value is ColorRed, the initial state
+	hooks     []func(from, to ColorChannel, reason string)
+	terminate []func(ColorTransition) // called on entering BLACK
+	counters  channelCounters
+	subs      map[*colorSubscription]struct{}
+}
+
+// colorSubscription is a channel registered through Context.Subscribe
+type colorSubscription struct {
+	ch chan ColorTransition
+}
+
+// ColorState returns the context's current color channel
+func (c *Context) ColorState() ColorChannel {
+	c.color.mu.Lock()
+	defer c.color.mu.Unlock()
+	return c.color.channel
+}
+
+// TransitionColor moves the context from color state from to color state to.
+// It fails if the context is not currently in from, or if the transition is
+// not legal:
+//   RED     -> GREEN, CYAN
+//   GREEN   -> CYAN, BLUE
+//   CYAN    -> BLUE
+//   BLUE    -> RED
+//   YELLOW  -> RED, MAGENTA
+//   MAGENTA -> YELLOW
+//
+// and any state but BLACK may escalate to YELLOW, MAGENTA or BLACK.
+//
+// Example:
+//   if err := ctx.TransitionColor(nsigii.ColorRed, nsigii.ColorCyan); err != nil {
+//       log.Fatal(err)
+//   }
+func (c *Context) TransitionColor(from, to ColorChannel) error {
+	if err := c.require(RoleOperator, "TransitionColor"); err != nil {
+		return err
+	}
+	return c.transitionColor(from, to, "")
+}
+
+// transitionColor implements TransitionColor, recording reason for
+// observers
+func (c *Context) transitionColor(from, to ColorChannel, reason string) error {
+	return c.setColor(from, to, reason, LegalColorTransition(from, to))
+}
+
+// setColor moves the context from from to to if legal is set, notifying
+// observers
+func (c *Context) setColor(from, to ColorChannel, reason string, legal bool) error {
+	c.color.mu.Lock()
+
+	if c.color.channel != from {
+		c.color.mu.Unlock()
+		return fmt.Errorf("color state is %s, not %s", c.color.channel, from)
+	}
+	if !legal {
+		c.color.mu.Unlock()
+		return fmt.Errorf("illegal color transition: %s -> %s", from, to)
+	}
+
+	c.color.channel = to
+	t := ColorTransition{From: from, To: to, Reason: reason, Time: time.Now()}
+	c.color.countTransition(from, to, t.Time)
+	c.audit.record(AuditEntry{
+		Time:   t.Time,
+		Kind:   AuditTransition,
+		From:   from,
+		To:     to,
+		Reason: reason,
+		Passed: true,
+	})
+	hooks := c.color.hooks
+	sink := c.alertSink
+	var terminate []func(ColorTransition)
+	if to == ColorBlack {
+		terminate = c.color.terminate
+	}
+	for sub := range c.color.subs {
+		select {
+		case sub.ch <- t:
+		default: // Subscriber is not keeping up; drop rather than block
+		}
+	}
+
+	c.color.mu.Unlock()
+
+	c.logTransition(t)
+	c.enterEscalation(to)
+	if to == ColorMagenta && sink != nil {
+		c.alert(sink, t)
+	}
+
+	// Hooks run unlocked so they may inspect the context
+	for _, fn := range hooks {
+		fn(from, to, reason)
+	}
+	for _, fn := range terminate {
+		fn(t)
+	}
+	return nil
+}
+
+// ============================================================================
+// Color Observers
+// ============================================================================
+
+// OnColorTransition registers fn to be called after every color transition.
+// fn runs synchronously on the goroutine that made the transition.
+//
+// Example:
+//   ctx.OnColorTransition(func(from, to nsigii.ColorChannel, reason string) {
+//       if to == nsigii.ColorMagenta {
+//           log.Printf("%s critical: %s", schema, reason)
+//       }
+//   })
+func (c *Context) OnColorTransition(fn func(from, to ColorChannel, reason string)) {
+	c.color.mu.Lock()
+	defer c.color.mu.Unlock()
+
+	// Copy so transitionColor's unlocked iteration never sees a write
+	hooks := make([]func(from, to ColorChannel, reason string), len(c.color.hooks), len(c.color.hooks)+1)
+	copy(hooks, c.color.hooks)
+	c.color.hooks = append(hooks, fn)
+}
+
+// Subscribe returns a channel receiving every subsequent color transition,
+// and a function that cancels the subscription and closes the channel.
+// Transitions are delivered without blocking: if the channel's buffer of
+// size buffer is full, the transition is dropped for this subscriber.
+//
+// Example:
+//   events, cancel := ctx.Subscribe(16)
+//   defer cancel()
+//   for t := range events {
+//       fmt.Printf("%s -> %s\n", t.From, t.To)
+//   }
+func (c *Context) Subscribe(buffer int) (<-chan ColorTransition, func()) {
+	if buffer < 0 {
+		buffer = 0
+	}
+	sub := &colorSubscription{ch: make(chan ColorTransition, buffer)}
+
+	c.color.mu.Lock()
+	if c.color.subs == nil {
+		c.color.subs = make(map[*colorSubscription]struct{})
+	}
+	c.color.subs[sub] = struct{}{}
+	c.color.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			c.color.mu.Lock()
+			delete(c.color.subs, sub)
+			c.color.mu.Unlock()
+			close(sub.ch)
+		})
+	}
+	return sub.ch, cancel
+}
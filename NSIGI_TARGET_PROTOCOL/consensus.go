@@ -0,0 +1,301 @@
+package nsigii
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+)
+
+// ============================================================================
+// Consensus Policy
+// ============================================================================
+
+// ConsensusPolicy sets how RED and GREEN confirmations combine into CYAN.
+// Consensus is reached when the weights of the confirming channels add up to
+// at least Threshold.
+type ConsensusPolicy struct {
+	RedWeight   float64 // Contribution of a RED confirmation
+	GreenWeight float64 // Contribution of a GREEN confirmation
+	Threshold   float64 // CYAN score required for consensus
+}
+
+// DefaultConsensusPolicy returns the RIFT rule, 1/4 RED + 1/4 GREEN = 1/2
+// CYAN: both channels must confirm
+func DefaultConsensusPolicy() ConsensusPolicy {
+	return ConsensusPolicy{RedWeight: 0.25, GreenWeight: 0.25, Threshold: 0.5}
+}
+
+// consensusEpsilon absorbs rounding when comparing scores with thresholds
+const consensusEpsilon = 1e-9
+
+// Validate checks that the policy's weights are finite and non-negative and
+// that its threshold is positive and reachable
+func (p ConsensusPolicy) Validate() error {
+	for _, w := range []float64{p.RedWeight, p.GreenWeight, p.Threshold} {
+		if math.IsNaN(w) || math.IsInf(w, 0) || w < 0 {
+			return errors.New("consensus weights must be finite and non-negative")
+		}
+	}
+	if p.Threshold == 0 {
+		return errors.New("consensus threshold must be positive")
+	}
+	if p.RedWeight+p.GreenWeight+consensusEpsilon < p.Threshold {
+		return errors.New("consensus threshold is unreachable")
+	}
+	return nil
+}
+
+// Score returns the CYAN score for the given confirmations
+func (p ConsensusPolicy) Score(red, green bool) float64 {
+	var score float64
+	if red {
+		score += p.RedWeight
+	}
+	if green {
+		score += p.GreenWeight
+	}
+	return score
+}
+
+// Reached reports whether the given confirmations meet the threshold
+func (p ConsensusPolicy) Reached(red, green bool) bool {
+	return p.Score(red, green)+consensusEpsilon >= p.Threshold
+}
+
+// SetConsensusPolicy replaces the rule VerifyRGBConsensus applies
+//
+// Example:
+//   // Trust a single confirming channel
+//   err := ctx.SetConsensusPolicy(nsigii.ConsensusPolicy{
+//       RedWeight: 0.25, GreenWeight: 0.25, Threshold: 0.25,
+//   })
+func (c *Context) SetConsensusPolicy(p ConsensusPolicy) error {
+	if err := c.require(RoleAdmin, "SetConsensusPolicy"); err != nil {
+		return err
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	c.consensus = &p
+	return nil
+}
+
+// ConsensusPolicy returns the rule VerifyRGBConsensus applies
+func (c *Context) ConsensusPolicy() ConsensusPolicy {
+	if c.consensus == nil {
+		return DefaultConsensusPolicy()
+	}
+	return *c.consensus
+}
+
+// ============================================================================
+// Quorum Consensus
+// ============================================================================
+
+// ErrVoteRejected is returned by ConsensusGroup.Cast for a vote its
+// verifier cannot authenticate
+var ErrVoteRejected = errors.New("consensus vote rejected")
+
+// Vote is one context's RED and GREEN confirmations. Votes are plain data
+// and can be sent between processes (Voter marshals as text); the seal
+// lets a group's verifier check that the voter's confirmations arrive as
+// cast.
+type Vote struct {
+	Voter PhantomID `json:"voter"`
+	Red   bool      `json:"red"`
+	Green bool      `json:"green"`
+	Seal  []byte    `json:"seal"` // MAC binding the fields above
+}
+
+// ConsensusVote takes the context's RGB consensus under the native rule and
+// returns it as a vote under its phantom ID, sealed with the key that
+// minted the ID, for a ConsensusGroup in this or another process
+//
+// Example:
+//   v, err := ctx.ConsensusVote()
+//   ...
+//   data, _ := json.Marshal(v) // send to the process holding the group
+func (c *Context) ConsensusVote() (Vote, error) {
+	if err := c.require(RoleVerifier, "ConsensusVote"); err != nil {
+		return Vote{}, err
+	}
+	if err := c.authorize(OpConsensus); err != nil {
+		return Vote{}, err
+	}
+	id, err := c.PhantomID()
+	if err != nil {
+		return Vote{}, err
+	}
+	key, err := c.originKey(id)
+	if err != nil {
+		return Vote{}, err
+	}
+
+	var ok bool
+	err = c.withNative(func(h *nativeContext) {
+		ok = nativeVerifyRGBConsensus(h)
+	})
+	if err != nil {
+		return Vote{}, err
+	}
+
+	v := Vote{Voter: id, Red: ok, Green: ok}
+	v.Seal = voteSeal(key, v)
+	return v, nil
+}
+
+// voteSeal computes the MAC over a vote's fields
+func voteSeal(key []byte, v Vote) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte("nsigii consensus vote\x00"))
+
+	voter := v.Voter.String()
+	h.Write(binary.BigEndian.AppendUint32(nil, uint32(len(voter))))
+	h.Write([]byte(voter))
+	var confirmed [2]byte
+	if v.Red {
+		confirmed[0] = 1
+	}
+	if v.Green {
+		confirmed[1] = 1
+	}
+	h.Write(confirmed[:])
+
+	return h.Sum(nil)
+}
+
+// ConsensusResult summarizes the votes cast in a ConsensusGroup
+type ConsensusResult struct {
+	Voters    int  // Distinct voters, by subject
+	Red       int  // Voters confirming RED
+	Green     int  // Voters confirming GREEN
+	Confirmed int  // Voters whose confirmations meet the group's policy
+	Quorum    int  // Confirmed voters required
+	Reached   bool // Confirmed >= Quorum
+}
+
+// ConsensusGroup aggregates votes from several contexts and reports
+// consensus once a quorum of voters has confirmed under the group's policy.
+// It is safe for concurrent use.
+//
+// Example:
+//   g, _ := nsigii.NewConsensusGroup(2, nsigii.DefaultConsensusPolicy())
+//   g.SetVerifier(ctx)
+//   for _, peer := range peers {
+//       g.Vote(peer)
+//   }
+//   if g.Result().Reached {
+//       ctx.TransitionColor(nsigii.ColorRed, nsigii.ColorCyan)
+//   }
+type ConsensusGroup struct {
+	mu       sync.Mutex
+	quorum   int
+	policy   ConsensusPolicy
+	verifier *Context
+	votes    map[string]Vote // by Voter.Subject()
+}
+
+// NewConsensusGroup creates a group requiring quorum confirming voters
+func NewConsensusGroup(quorum int, policy ConsensusPolicy) (*ConsensusGroup, error) {
+	if quorum < 1 {
+		return nil, errors.New("consensus quorum must be at least 1")
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &ConsensusGroup{
+		quorum: quorum,
+		policy: policy,
+		votes:  make(map[string]Vote),
+	}, nil
+}
+
+// SetVerifier makes the group authenticate each vote: its voter with
+// ctx.VerifyPhantomID, and its seal with the key that minted the voter, so
+// a vote cannot be forged or altered by anyone who has merely seen the
+// voter's ID. A nil ctx accepts any well-formed vote unauthenticated, which
+// is only safe when every vote is cast by Vote in this process.
+func (g *ConsensusGroup) SetVerifier(ctx *Context) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.verifier = ctx
+}
+
+// Cast records v. Voters are counted by subject, so a voter holding
+// several IDs, such as one rotating its phantom ID, has one vote: v
+// replaces an earlier vote by the same subject unless that vote was cast
+// under a more recently issued ID.
+func (g *ConsensusGroup) Cast(v Vote) error {
+	if v.Voter.IsZero() {
+		return errors.New("vote has no voter")
+	}
+
+	g.mu.Lock()
+	verifier := g.verifier
+	g.mu.Unlock()
+
+	if verifier != nil {
+		ok, err := verifier.VerifyPhantomID(v.Voter)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w: untrusted voter", ErrVoteRejected)
+		}
+		key, err := verifier.originKey(v.Voter)
+		if err != nil || !hmac.Equal(voteSeal(key, v), v.Seal) {
+			return fmt.Errorf("%w: invalid seal", ErrVoteRejected)
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	subject := v.Voter.Subject()
+	if prev, ok := g.votes[subject]; ok && prev.Voter.IssuedAt().After(v.Voter.IssuedAt()) {
+		return fmt.Errorf("%w: superseded by a vote under a newer ID", ErrVoteRejected)
+	}
+	g.votes[subject] = v
+	return nil
+}
+
+// Vote casts ctx's own vote; see Context.ConsensusVote
+func (g *ConsensusGroup) Vote(ctx *Context) error {
+	v, err := ctx.ConsensusVote()
+	if err != nil {
+		return err
+	}
+	return g.Cast(v)
+}
+
+// Result tallies the votes cast so far
+func (g *ConsensusGroup) Result() ConsensusResult {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	r := ConsensusResult{Voters: len(g.votes), Quorum: g.quorum}
+	for _, v := range g.votes {
+		if v.Red {
+			r.Red++
+		}
+		if v.Green {
+			r.Green++
+		}
+		if g.policy.Reached(v.Red, v.Green) {
+			r.Confirmed++
+		}
+	}
+	r.Reached = r.Confirmed >= r.Quorum
+	return r
+}
+
+// Reset discards all votes
+func (g *ConsensusGroup) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.votes = make(map[string]Vote)
+}
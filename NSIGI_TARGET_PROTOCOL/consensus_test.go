@@ -0,0 +1,98 @@
+package nsigii
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestConsensusGroupAuthenticatesVotes(t *testing.T) {
+	verifier, err := NewContext("tokenize", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer verifier.Close()
+	voter, err := NewContext("tokenize", "lexer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer voter.Close()
+
+	g, err := NewConsensusGroup(2, DefaultConsensusPolicy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.SetVerifier(verifier)
+
+	v, err := voter.ConsensusVote()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A sealed vote survives the trip between processes
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var received Vote
+	if err := json.Unmarshal(data, &received); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Cast(received); err != nil {
+		t.Fatalf("Cast(sealed vote) = %v", err)
+	}
+
+	// Anyone who has seen the voter's ID cannot vote as it
+	forged := []Vote{
+		{Voter: v.Voter, Red: !v.Red, Green: v.Green, Seal: v.Seal},
+		{Voter: v.Voter, Red: true, Green: true},
+	}
+	for i, f := range forged {
+		if err := g.Cast(f); !errors.Is(err, ErrVoteRejected) {
+			t.Errorf("Cast(forged vote %d) = %v, want ErrVoteRejected", i, err)
+		}
+	}
+
+	// A voter rotating its ID keeps one vote, and its old vote cannot be
+	// replayed over the new one
+	if _, err := voter.RotatePhantomID(); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Vote(voter); err != nil {
+		t.Fatal(err)
+	}
+	if r := g.Result(); r.Voters != 1 {
+		t.Errorf("voters after rotation = %d, want 1", r.Voters)
+	}
+	if err := g.Cast(v); !errors.Is(err, ErrVoteRejected) {
+		t.Errorf("Cast(replayed vote) = %v, want ErrVoteRejected", err)
+	}
+}
+
+func TestConsensusVoteAuthorized(t *testing.T) {
+	reader, err := NewContext("tokenize", "lexer", WithRole(RoleReader))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if _, err := reader.ConsensusVote(); !errors.Is(err, ErrForbidden) {
+		t.Errorf("ConsensusVote on a reader = %v, want ErrForbidden", err)
+	}
+
+	ctx, err := NewContext("tokenize", "lexer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+	if err := ctx.SetPolicy(&Policy{Default: PolicyDeny}); err != nil {
+		t.Fatal(err)
+	}
+	g, err := NewConsensusGroup(1, DefaultConsensusPolicy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var perr *PolicyError
+	if err := g.Vote(ctx); !errors.As(err, &perr) {
+		t.Errorf("Vote under a denying policy = %v, want *PolicyError", err)
+	}
+}
@@ -0,0 +1,105 @@
+package nsigii
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ============================================================================
+// Contrast Channel
+// ============================================================================
+
+// colorInverse pairs each channel with its RGB complement. CONTRAST stands
+// in for white as the inverse of BLACK.
+var colorInverse = map[ColorChannel]ColorChannel{
+	ColorRed:      ColorCyan,
+	ColorCyan:     ColorRed,
+	ColorGreen:    ColorMagenta,
+	ColorMagenta:  ColorGreen,
+	ColorBlue:     ColorYellow,
+	ColorYellow:   ColorBlue,
+	ColorBlack:    ColorContrast,
+	ColorContrast: ColorBlack,
+}
+
+// Inverse returns the channel's complement: RED/CYAN, GREEN/MAGENTA,
+// BLUE/YELLOW and BLACK/CONTRAST. Unknown channels are their own inverse.
+func (c ColorChannel) Inverse() ColorChannel {
+	if inv, ok := colorInverse[c]; ok {
+		return inv
+	}
+	return c
+}
+
+// ContrastState returns the inverse of the context's current color state
+func (c *Context) ContrastState() ColorChannel {
+	return c.ColorState().Inverse()
+}
+
+// VerifyContrast runs the context's checks on inputs that must be rejected
+// and returns an error naming any that were accepted instead:
+//
+//   - a phantom ID with a corrupted MAC must not verify
+//   - a transition claiming the context is in its contrast state must fail
+//   - RGB consensus must not also pass with every confirmation inverted
+//
+// It changes no state beyond recording the outcome in the audit trail, and
+// is meant for negative-path testing of a live context.
+func (c *Context) VerifyContrast() error {
+	if err := c.require(RoleVerifier, "VerifyContrast"); err != nil {
+		return err
+	}
+	if c.closed() {
+		return ErrContextClosed
+	}
+
+	var failures []error
+
+	id, err := mintPhantomID(c, "contrast")
+	if err != nil {
+		return err
+	}
+	id.mac[0] ^= 0xFF
+	if ok, err := c.VerifyPhantomID(id); err != nil {
+		return err
+	} else if ok {
+		failures = append(failures, errors.New("forged phantom ID verified"))
+	}
+
+	state := c.ColorState()
+	inverse := state.Inverse()
+	if err := c.TransitionColor(inverse, state); err == nil {
+		failures = append(failures, fmt.Errorf("transition from contrast state %s accepted", inverse))
+	}
+
+	var ok bool
+	err = c.withNative(func(ctx *nativeContext) {
+		ok = nativeVerifyRGBConsensus(ctx)
+	})
+	if err != nil {
+		return err
+	}
+	policy := c.ConsensusPolicy()
+	if policy.Reached(ok, ok) == policy.Reached(!ok, !ok) {
+		failures = append(failures, errors.New("RGB consensus ignores its confirmations"))
+	}
+
+	err = errors.Join(failures...)
+	e := AuditEntry{
+		Time:   time.Now(),
+		Kind:   AuditContrast,
+		From:   state,
+		To:     inverse,
+		Passed: err == nil,
+	}
+	if err != nil {
+		e.Reason = err.Error()
+	}
+	c.audit.record(e)
+
+	if err != nil {
+		return fmt.Errorf("contrast check failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package nsigii
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ============================================================================
+// Chain of Custody
+// ============================================================================
+
+// ErrCustodyBroken reports a stream envelope whose custody records do not
+// match its stream or each other
+var ErrCustodyBroken = errors.New("chain of custody is broken")
+
+// CustodyRecord states that a context handled a stream at a stage. Each
+// record is signed with the context's signing key (see SetSigningKey) over
+// the stream, its own fields and the signature of the record before it, so
+// records cannot be removed, reordered or moved to another stream.
+type CustodyRecord struct {
+	Stage     StageID           `json:"stage"`
+	Schema    string            `json:"schema"`         // obinexus.[operation].[service] of the handler
+	Origin    PhantomID         `json:"origin"`         // Phantom ID of the handler
+	Time      time.Time         `json:"time"`           // When the stage finished with the stream
+	Signer    ed25519.PublicKey `json:"signer"`         // Public key of the handler
+	Signature []byte            `json:"signature"`      // Ed25519 over the stream and the chain so far
+	Note      string            `json:"note,omitempty"` // Free-form detail from the handler
+}
+
+// StreamEnvelope carries a token stream with the custody records of every
+// stage that handled it, oldest first
+//
+// Example:
+//   env := nsigii.NewStreamEnvelope(stream)
+//   if err := env.Append(ctx, nsigii.StageTokenize, ""); err != nil {
+//       log.Fatal(err)
+//   }
+//   // Downstream:
+//   if err := env.Verify(); err != nil {
+//       log.Fatal(err)
+//   }
+//   for _, r := range env.Custody {
+//       fmt.Println(r.Stage, r.Schema, r.Origin, r.Time)
+//   }
+type StreamEnvelope struct {
+	Stream  *TokenStream    `json:"stream"`
+	Custody []CustodyRecord `json:"custody"`
+}
+
+// NewStreamEnvelope wraps s in an envelope with no custody records
+func NewStreamEnvelope(s *TokenStream) *StreamEnvelope {
+	return &StreamEnvelope{Stream: s}
+}
+
+// Append records that c handled the stream at stage, with an optional
+// note. A closed or terminated context refuses to sign.
+func (e *StreamEnvelope) Append(c *Context, stage StageID, note string) error {
+	if err := c.require(RoleOperator, "StreamEnvelope.Append"); err != nil {
+		return err
+	}
+	if err := c.usable(); err != nil {
+		return err
+	}
+	id, err := c.PhantomID()
+	if err != nil {
+		return err
+	}
+
+	key := c.signer()
+	r := CustodyRecord{
+		Stage:  stage,
+		Schema: c.schema(),
+		Origin: id,
+		Time:   time.Now().UTC(),
+		Signer: key.Public().(ed25519.PublicKey),
+		Note:   note,
+	}
+	r.Signature = ed25519.Sign(key, e.custodyBytes(sha256.Sum256(e.Stream.CanonicalBytes()), len(e.Custody), r))
+	e.Custody = append(e.Custody, r)
+	return nil
+}
+
+// Verify checks every custody record's signature against its own signer,
+// returning an error wrapping ErrCustodyBroken at the first that fails.
+// It does not decide whether the signers are trusted; compare each
+// record's Signer with the keys of the handlers you expect.
+func (e *StreamEnvelope) Verify() error {
+	if e.Stream == nil {
+		return errors.New("stream envelope has no stream")
+	}
+
+	sum := sha256.Sum256(e.Stream.CanonicalBytes())
+	for i, r := range e.Custody {
+		if len(r.Signer) != ed25519.PublicKeySize {
+			return fmt.Errorf("%w: record %d has an invalid signer", ErrCustodyBroken, i)
+		}
+		if !ed25519.Verify(r.Signer, e.custodyBytes(sum, i, r), r.Signature) {
+			return fmt.Errorf("%w: record %d (stage %s, %s)", ErrCustodyBroken, i, r.Stage, r.Schema)
+		}
+	}
+	return nil
+}
+
+// Handled reports whether a record names signer as the handler of stage.
+// Call Verify first.
+func (e *StreamEnvelope) Handled(stage StageID, signer ed25519.PublicKey) bool {
+	return slices.ContainsFunc(e.Custody, func(r CustodyRecord) bool {
+		return r.Stage == stage && bytes.Equal(r.Signer, signer)
+	})
+}
+
+// Clone returns a copy of the envelope whose custody can be appended to
+// without affecting e. The stream is shared.
+func (e *StreamEnvelope) Clone() *StreamEnvelope {
+	if e == nil {
+		return nil
+	}
+	return &StreamEnvelope{Stream: e.Stream, Custody: slices.Clone(e.Custody)}
+}
+
+// custodyBytes returns what record i signs: the stream's canonical hash,
+// the previous record's signature and the record's own fields
+func (e *StreamEnvelope) custodyBytes(stream [sha256.Size]byte, i int, r CustodyRecord) []byte {
+	b := make([]byte, 0, 256)
+	field := func(v []byte) {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(v)))
+		b = append(b, v...)
+	}
+
+	b = append(b, "NSIGII-CUSTODY\x00"...)
+	b = append(b, stream[:]...)
+	b = binary.BigEndian.AppendUint32(b, uint32(i))
+	if i > 0 {
+		field(e.Custody[i-1].Signature)
+	} else {
+		field(nil)
+	}
+	b = append(b, byte(r.Stage))
+	field([]byte(r.Schema))
+	field([]byte(r.Origin.String()))
+	b = binary.BigEndian.AppendUint64(b, uint64(r.Time.UnixNano()))
+	field(r.Signer)
+	field([]byte(r.Note))
+	return b
+}
+
+// ============================================================================
+// Pipeline Custody
+// ============================================================================
+
+// SetCustody makes the pipeline keep a StreamEnvelope in each unit's
+// Envelope once tokens exist, appending a custody record signed by the
+// pipeline's context after every stage it runs. Stages served from the
+// cache keep the records made when they ran.
+//
+// Example:
+//   p.SetCustody(true)
+//   unit, _, err := p.Run(source)
+//   // unit.Envelope.Custody has one record per stage
+func (p *StagePipeline) SetCustody(on bool) {
+	p.custody = on
+}
+
+// recordCustody appends the custody record of stage to u's envelope
+func (p *StagePipeline) recordCustody(stage StageID, u *Unit) error {
+	if !p.custody || u.Tokens == nil {
+		return nil
+	}
+	if u.Envelope == nil {
+		u.Envelope = NewStreamEnvelope(NewTokenStream(p.ctx.schema(), slices.Clone(u.Tokens)))
+	}
+	return u.Envelope.Append(p.ctx, stage, "")
+}
@@ -0,0 +1,68 @@
+package nsigii
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStreamEnvelopeCustody(t *testing.T) {
+	lexer, err := NewContext("tokenize", "lexer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lexer.Close()
+	parser, err := NewContext("tokenize", "parser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+
+	envelope := func() *StreamEnvelope {
+		e := NewStreamEnvelope(NewTokenStream(lexer.schema(), merkleTokens(4)))
+		for _, h := range []struct {
+			ctx   *Context
+			stage StageID
+		}{{lexer, StageTokenize}, {parser, StageParse}, {parser, StageValidate}} {
+			if err := e.Append(h.ctx, h.stage, ""); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return e
+	}
+
+	e := envelope()
+	if err := e.Verify(); err != nil {
+		t.Fatalf("Verify(untouched) = %v", err)
+	}
+	if !e.Handled(StageParse, parser.SigningPublicKey()) || e.Handled(StageParse, lexer.SigningPublicKey()) {
+		t.Error("Handled does not match the records")
+	}
+
+	tamper := map[string]func(e *StreamEnvelope){
+		"reordered records": func(e *StreamEnvelope) { e.Custody[1], e.Custody[2] = e.Custody[2], e.Custody[1] },
+		"removed record":    func(e *StreamEnvelope) { e.Custody = e.Custody[1:] },
+		"modified record":   func(e *StreamEnvelope) { e.Custody[1].Note = "skipped" },
+		"changed stage":     func(e *StreamEnvelope) { e.Custody[0].Stage = StageEmit },
+		"changed stream":    func(e *StreamEnvelope) { e.Stream.Tokens[0].Text = "other" },
+		"moved to another stream": func(e *StreamEnvelope) {
+			e.Stream = NewTokenStream(lexer.schema(), merkleTokens(5))
+		},
+	}
+	for name, f := range tamper {
+		e := envelope()
+		f(e)
+		if err := e.Verify(); !errors.Is(err, ErrCustodyBroken) {
+			t.Errorf("Verify with %s = %v, want ErrCustodyBroken", name, err)
+		}
+	}
+
+	// A clone is appended to independently
+	e = envelope()
+	clone := e.Clone()
+	if err := clone.Append(lexer, StageEmit, "clone"); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.Custody) != 3 || clone.Verify() != nil || e.Verify() != nil {
+		t.Error("appending to a clone changed or broke the original")
+	}
+}
@@ -0,0 +1,91 @@
+package nsigii
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ============================================================================
+// Source Reconstruction
+// ============================================================================
+
+// Detokenize reconstructs source text from a token stream
+//
+// Tokens are laid out at their Memory offsets, and the gaps between them
+// (whitespace skipped by the lexer) are refilled with newlines, following
+// Line when positions were tracked, and spaces. The result has the original
+// length and token offsets, so it re-tokenizes to the same stream.
+//
+// The stream must be ordered and non-overlapping and each token's Text must
+// be Value bytes long; anything else is reported as an error.
+func Detokenize(tokens []Token) (string, error) {
+	var b strings.Builder
+	offset := 0
+	line := 1
+
+	for i, t := range tokens {
+		start := int(t.Memory)
+		if start < offset {
+			return "", fmt.Errorf("token %d at offset %d overlaps previous token ending at %d", i, start, offset)
+		}
+
+		if t.Type == TokenEOF {
+			if i != len(tokens)-1 {
+				return "", fmt.Errorf("token %d: EOF before end of stream", i)
+			}
+		} else if n := len(t.Lexeme()); n != int(t.Value) {
+			return "", fmt.Errorf("token %d: text length %d does not match value %d", i, n, t.Value)
+		}
+
+		// Fill the gap: newlines to reach the token's line, spaces for the rest
+		gap := start - offset
+		newlines := 0
+		if t.Line > line {
+			newlines = t.Line - line
+		}
+		if newlines > gap {
+			return "", fmt.Errorf("token %d: %d-byte gap cannot hold %d line breaks", i, gap, newlines)
+		}
+		b.WriteString(strings.Repeat("\n", newlines))
+		b.WriteString(strings.Repeat(" ", gap-newlines))
+		line += newlines
+
+		if t.Type != TokenEOF {
+			text := t.Lexeme()
+			b.WriteString(text)
+			line += strings.Count(text, "\n")
+		}
+		offset = start + int(t.Value)
+	}
+
+	return b.String(), nil
+}
+
+// Canonicalize renders a token stream in canonical layout: tokens on the
+// same line separated by one space, lines separated by a single newline, no
+// indentation. Offsets are not preserved. Without position tracking the
+// whole stream is rendered on one line.
+func Canonicalize(tokens []Token) string {
+	var b strings.Builder
+	line := 0
+
+	for _, t := range tokens {
+		if t.Type == TokenEOF {
+			break
+		}
+
+		switch {
+		case b.Len() == 0:
+		case t.Line > line:
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(' ')
+		}
+
+		text := t.Lexeme()
+		b.WriteString(text)
+		line = t.Line + strings.Count(text, "\n")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,296 @@
+package encoding
+
+import (
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/obinexus/nsigii-rift/nsigii"
+)
+
+// ============================================================================
+// CBOR
+// ============================================================================
+
+// The CBOR form (RFC 8949) uses integer keys and arrays throughout:
+//   tokens   array of token
+//   token    array [type, memory, value, text, line, column,
+//                   end offset, rune offset, rune length]
+//   stats    map {1: total, 2: {type name: count}, 3: [min, max], 4: average}
+//   color    map {1: state, 2: array of transition}
+//   transition  array [from, to, reason, Unix nanoseconds]
+//
+// Encoded in deterministic mode, equal values always encode to the same
+// bytes, on any platform and with any CBOR library that follows the core
+// deterministic encoding rules, so the output can be hashed and signed.
+
+// CBOROptions configures CBOR encoding
+type CBOROptions struct {
+	// Deterministic applies the core deterministic encoding requirements of
+	// RFC 8949 section 4.2.1: map keys sorted bytewise and floats in their
+	// shortest exact form. Without it map keys are in no particular order.
+	Deterministic bool
+}
+
+var (
+	cborEnc    cbor.EncMode
+	cborDetEnc cbor.EncMode
+	cborDec    cbor.DecMode
+)
+
+func init() {
+	var err error
+	if cborEnc, err = (cbor.EncOptions{}).EncMode(); err != nil {
+		panic(err)
+	}
+	if cborDetEnc, err = cbor.CoreDetEncOptions().EncMode(); err != nil {
+		panic(err)
+	}
+	// Duplicate keys would let two encodings carry different values under
+	// one signature
+	if cborDec, err = (cbor.DecOptions{DupMapKey: cbor.DupMapKeyEnforcedAPF}).DecMode(); err != nil {
+		panic(err)
+	}
+}
+
+func (o CBOROptions) marshal(v any) ([]byte, error) {
+	if o.Deterministic {
+		return cborDetEnc.Marshal(v)
+	}
+	return cborEnc.Marshal(v)
+}
+
+// cborToken is the CBOR form of a token
+type cborToken struct {
+	_          struct{} `cbor:",toarray"`
+	Type       int32
+	Memory     uint32
+	Value      uint32
+	Text       string
+	Line       int
+	Column     int
+	EndOffset  uint32
+	RuneOffset uint32
+	RuneLength uint32
+}
+
+// cborStats is the CBOR form of token statistics
+type cborStats struct {
+	TotalTokens      int                      `cbor:"1,keyasint"`
+	TypeDistribution map[string]int           `cbor:"2,keyasint"`
+	MemoryRange      [2]uint32                `cbor:"3,keyasint"`
+	AverageLength    float64                  `cbor:"4,keyasint"`
+	LengthHistogram  cborHistogram            `cbor:"5,keyasint"`
+	TypeLengths      map[string]cborHistogram `cbor:"6,keyasint,omitempty"`
+}
+
+// cborHistogram is the CBOR form of a length histogram
+type cborHistogram struct {
+	Bounds    []uint32       `cbor:"1,keyasint"`
+	Counts    []int          `cbor:"2,keyasint"`
+	Quantiles []cborQuantile `cbor:"3,keyasint,omitempty"`
+}
+
+// cborQuantile is the CBOR form of a quantile
+type cborQuantile struct {
+	_      struct{} `cbor:",toarray"`
+	Q      float64
+	Length uint32
+}
+
+// cborTransition is the CBOR form of a color transition
+type cborTransition struct {
+	_      struct{} `cbor:",toarray"`
+	From   int
+	To     int
+	Reason string
+	Time   int64 // Unix nanoseconds
+}
+
+// cborColorState is the CBOR form of a ColorState
+type cborColorState struct {
+	State   int              `cbor:"1,keyasint"`
+	History []cborTransition `cbor:"2,keyasint"`
+}
+
+// MarshalTokensCBOR encodes tokens as CBOR
+func MarshalTokensCBOR(tokens []nsigii.Token, opts CBOROptions) ([]byte, error) {
+	wire := make([]cborToken, len(tokens))
+	for i, t := range tokens {
+		wire[i] = cborToken{
+			Type:       int32(t.Type),
+			Memory:     t.Memory,
+			Value:      t.Value,
+			Text:       t.Lexeme(),
+			Line:       t.Line,
+			Column:     t.Column,
+			EndOffset:  t.EndOffset,
+			RuneOffset: t.RuneOffset,
+			RuneLength: t.RuneLength,
+		}
+	}
+	return opts.marshal(wire)
+}
+
+// UnmarshalTokensCBOR decodes tokens encoded by MarshalTokensCBOR
+func UnmarshalTokensCBOR(data []byte) ([]nsigii.Token, error) {
+	var wire []cborToken
+	if err := cborDec.Unmarshal(data, &wire); err != nil {
+		return nil, err
+	}
+
+	tokens := make([]nsigii.Token, len(wire))
+	for i, t := range wire {
+		tokens[i] = nsigii.Token{
+			Type:       nsigii.TokenType(t.Type),
+			Memory:     t.Memory,
+			Value:      t.Value,
+			Text:       t.Text,
+			Line:       t.Line,
+			Column:     t.Column,
+			EndOffset:  t.EndOffset,
+			RuneOffset: t.RuneOffset,
+			RuneLength: t.RuneLength,
+		}
+	}
+	return tokens, nil
+}
+
+// MarshalStatsCBOR encodes token statistics as CBOR. Token types are keyed
+// by name, or by decimal value if they have none, as in JSON.
+func MarshalStatsCBOR(s nsigii.TokenStats, opts CBOROptions) ([]byte, error) {
+	wire := cborStats{
+		TotalTokens:      s.TotalTokens,
+		TypeDistribution: make(map[string]int, len(s.TypeDistribution)),
+		MemoryRange:      s.MemoryRange,
+		AverageLength:    s.AverageLength,
+		LengthHistogram:  toCBORHistogram(s.LengthHistogram),
+		TypeLengths:      make(map[string]cborHistogram, len(s.TypeLengths)),
+	}
+	for typ, n := range s.TypeDistribution {
+		name, err := typ.MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		wire.TypeDistribution[string(name)] = n
+	}
+	for typ, h := range s.TypeLengths {
+		name, err := typ.MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		wire.TypeLengths[string(name)] = toCBORHistogram(h)
+	}
+	return opts.marshal(wire)
+}
+
+// toCBORHistogram converts a histogram to its CBOR form
+func toCBORHistogram(h nsigii.Histogram) cborHistogram {
+	wire := cborHistogram{Bounds: h.Bounds, Counts: h.Counts}
+	for _, q := range h.Quantiles {
+		wire.Quantiles = append(wire.Quantiles, cborQuantile{Q: q.Q, Length: q.Length})
+	}
+	return wire
+}
+
+// histogram converts h back from its CBOR form
+func (h cborHistogram) histogram() nsigii.Histogram {
+	native := nsigii.Histogram{Bounds: h.Bounds, Counts: h.Counts}
+	for _, q := range h.Quantiles {
+		native.Quantiles = append(native.Quantiles, nsigii.Quantile{Q: q.Q, Length: q.Length})
+	}
+	return native
+}
+
+// UnmarshalStatsCBOR decodes token statistics encoded by MarshalStatsCBOR
+func UnmarshalStatsCBOR(data []byte) (nsigii.TokenStats, error) {
+	var wire cborStats
+	if err := cborDec.Unmarshal(data, &wire); err != nil {
+		return nsigii.TokenStats{}, err
+	}
+
+	s := nsigii.TokenStats{
+		TotalTokens:      wire.TotalTokens,
+		TypeDistribution: make(map[nsigii.TokenType]int, len(wire.TypeDistribution)),
+		MemoryRange:      wire.MemoryRange,
+		AverageLength:    wire.AverageLength,
+		LengthHistogram:  wire.LengthHistogram.histogram(),
+		TypeLengths:      make(map[nsigii.TokenType]nsigii.Histogram, len(wire.TypeLengths)),
+	}
+	for name, n := range wire.TypeDistribution {
+		var typ nsigii.TokenType
+		if err := typ.UnmarshalText([]byte(name)); err != nil {
+			return nsigii.TokenStats{}, err
+		}
+		s.TypeDistribution[typ] = n
+	}
+	for name, h := range wire.TypeLengths {
+		var typ nsigii.TokenType
+		if err := typ.UnmarshalText([]byte(name)); err != nil {
+			return nsigii.TokenStats{}, err
+		}
+		s.TypeLengths[typ] = h.histogram()
+	}
+	return s, nil
+}
+
+// ColorState is a context's color state with the transitions that led to
+// it, oldest first, as far as its audit trail reaches
+type ColorState struct {
+	State   nsigii.ColorChannel
+	History []nsigii.ColorTransition
+}
+
+// CaptureColorState returns the color state of c
+func CaptureColorState(c *nsigii.Context) ColorState {
+	s := ColorState{State: c.ColorState()}
+	for _, e := range c.ColorAudit() {
+		if e.Kind == nsigii.AuditTransition {
+			s.History = append(s.History, nsigii.ColorTransition{
+				From:   e.From,
+				To:     e.To,
+				Reason: e.Reason,
+				Time:   e.Time,
+			})
+		}
+	}
+	return s
+}
+
+// MarshalColorStateCBOR encodes a color state as CBOR. Transition times
+// keep nanosecond precision but lose their location and monotonic reading.
+func MarshalColorStateCBOR(s ColorState, opts CBOROptions) ([]byte, error) {
+	wire := cborColorState{State: int(s.State), History: make([]cborTransition, len(s.History))}
+	for i, t := range s.History {
+		wire.History[i] = cborTransition{
+			From:   int(t.From),
+			To:     int(t.To),
+			Reason: t.Reason,
+			Time:   t.Time.UnixNano(),
+		}
+	}
+	return opts.marshal(wire)
+}
+
+// UnmarshalColorStateCBOR decodes a color state encoded by
+// MarshalColorStateCBOR
+func UnmarshalColorStateCBOR(data []byte) (ColorState, error) {
+	var wire cborColorState
+	if err := cborDec.Unmarshal(data, &wire); err != nil {
+		return ColorState{}, err
+	}
+
+	s := ColorState{State: nsigii.ColorChannel(wire.State)}
+	if len(wire.History) > 0 {
+		s.History = make([]nsigii.ColorTransition, len(wire.History))
+	}
+	for i, t := range wire.History {
+		s.History[i] = nsigii.ColorTransition{
+			From:   nsigii.ColorChannel(t.From),
+			To:     nsigii.ColorChannel(t.To),
+			Reason: t.Reason,
+			Time:   time.Unix(0, t.Time),
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,612 @@
+package encoding
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/obinexus/nsigii-rift/nsigii"
+)
+
+// ============================================================================
+// MessagePack
+// ============================================================================
+
+// The MessagePack form keeps token text and positions, unlike the triplet
+// format, at a fraction of the size of JSON:
+//   tokens   array of token
+//   token    array [type, memory, value, text, line, column]
+//            with [end offset, rune offset, rune length] appended when the
+//            end offset is not memory + value or rune offsets are set
+//   stream   map {"version", "schema", "tokens", "origin", "seal", "signer", "signature"}
+//
+// Integers are written in their smallest form; decoding accepts any integer
+// width, and unknown stream keys are skipped.
+
+// ErrMsgpack is returned for data that is not a MessagePack token encoding
+var ErrMsgpack = errors.New("malformed MessagePack token data")
+
+// MarshalMsgpack encodes tokens as a MessagePack array
+func MarshalMsgpack(tokens []nsigii.Token) []byte {
+	return appendTokens(make([]byte, 0, 16*len(tokens)+5), tokens)
+}
+
+// UnmarshalMsgpack decodes tokens encoded by MarshalMsgpack
+func UnmarshalMsgpack(data []byte) ([]nsigii.Token, error) {
+	d := msgpackDecoder{data: data}
+	tokens, err := d.tokens()
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(data) {
+		return nil, fmt.Errorf("%w: %d bytes after tokens", ErrMsgpack, len(data)-d.pos)
+	}
+	return tokens, nil
+}
+
+// MarshalStreamMsgpack encodes a token stream as a MessagePack map. Stats
+// are not stored, since they are derived from the tokens.
+func MarshalStreamMsgpack(s *nsigii.TokenStream) ([]byte, error) {
+	n := 3
+	var origin []byte
+	if s.Origin != nil {
+		var err error
+		if origin, err = s.Origin.MarshalText(); err != nil {
+			return nil, err
+		}
+		n++
+	}
+	if s.Seal != nil {
+		n++
+	}
+	if s.Signature != nil {
+		n += 2
+	}
+
+	b := make([]byte, 0, 16*len(s.Tokens)+64)
+	b = appendMapHeader(b, n)
+	b = appendString(b, "version")
+	b = appendInt(b, int64(s.Version))
+	b = appendString(b, "schema")
+	b = appendString(b, s.Schema)
+	b = appendString(b, "tokens")
+	b = appendTokens(b, s.Tokens)
+	if origin != nil {
+		b = appendString(b, "origin")
+		b = appendString(b, string(origin))
+	}
+	if s.Seal != nil {
+		b = appendString(b, "seal")
+		b = appendBinary(b, s.Seal)
+	}
+	if s.Signature != nil {
+		b = appendString(b, "signer")
+		b = appendBinary(b, s.Signer)
+		b = appendString(b, "signature")
+		b = appendBinary(b, s.Signature)
+	}
+	return b, nil
+}
+
+// UnmarshalStreamMsgpack decodes a token stream encoded by
+// MarshalStreamMsgpack, rejecting unsupported versions and recomputing its
+// stats
+func UnmarshalStreamMsgpack(data []byte) (*nsigii.TokenStream, error) {
+	d := msgpackDecoder{data: data}
+	n, err := d.mapHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &nsigii.TokenStream{}
+	for i := 0; i < n; i++ {
+		key, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+
+		switch key {
+		case "version":
+			v, err := d.int()
+			if err != nil {
+				return nil, err
+			}
+			s.Version = int(v)
+		case "schema":
+			if s.Schema, err = d.string(); err != nil {
+				return nil, err
+			}
+		case "tokens":
+			if s.Tokens, err = d.tokens(); err != nil {
+				return nil, err
+			}
+		case "origin":
+			text, err := d.string()
+			if err != nil {
+				return nil, err
+			}
+			var id nsigii.PhantomID
+			if err := id.UnmarshalText([]byte(text)); err != nil {
+				return nil, err
+			}
+			s.Origin = &id
+		case "seal":
+			if s.Seal, err = d.binary(); err != nil {
+				return nil, err
+			}
+		case "signer":
+			if s.Signer, err = d.binary(); err != nil {
+				return nil, err
+			}
+		case "signature":
+			if s.Signature, err = d.binary(); err != nil {
+				return nil, err
+			}
+		default:
+			if err := d.skip(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if d.pos != len(data) {
+		return nil, fmt.Errorf("%w: %d bytes after stream", ErrMsgpack, len(data)-d.pos)
+	}
+	if s.Version != nsigii.TokenStreamVersion {
+		return nil, fmt.Errorf("unsupported token stream version: %d", s.Version)
+	}
+	if s.Tokens == nil {
+		s.Tokens = []nsigii.Token{}
+	}
+	stats := nsigii.AnalyzeTokens(s.Tokens)
+	s.Stats = &stats
+	return s, nil
+}
+
+// appendTokens appends tokens as an array of token arrays
+func appendTokens(b []byte, tokens []nsigii.Token) []byte {
+	b = appendArrayHeader(b, len(tokens))
+	for _, t := range tokens {
+		long := t.EndOffset != t.Memory+t.Value || t.RuneOffset != 0 || t.RuneLength != 0
+		if long {
+			b = appendArrayHeader(b, 9)
+		} else {
+			b = appendArrayHeader(b, 6)
+		}
+		b = appendInt(b, int64(t.Type))
+		b = appendUint(b, uint64(t.Memory))
+		b = appendUint(b, uint64(t.Value))
+		b = appendString(b, t.Lexeme())
+		b = appendInt(b, int64(t.Line))
+		b = appendInt(b, int64(t.Column))
+		if long {
+			b = appendUint(b, uint64(t.EndOffset))
+			b = appendUint(b, uint64(t.RuneOffset))
+			b = appendUint(b, uint64(t.RuneLength))
+		}
+	}
+	return b
+}
+
+// ============================================================================
+// MessagePack Primitives
+// ============================================================================
+
+func appendUint(b []byte, v uint64) []byte {
+	switch {
+	case v <= 0x7f:
+		return append(b, byte(v))
+	case v <= math.MaxUint8:
+		return append(b, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(v))
+	case v <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(v))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xcf), v)
+}
+
+func appendInt(b []byte, v int64) []byte {
+	switch {
+	case v >= 0:
+		return appendUint(b, uint64(v))
+	case v >= -32:
+		return append(b, byte(v))
+	case v >= math.MinInt8:
+		return append(b, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(v))
+	case v >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(v))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(v))
+}
+
+func appendString(b []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n <= 31:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
+
+func appendBinary(b []byte, data []byte) []byte {
+	n := len(data)
+	switch {
+	case n <= math.MaxUint8:
+		b = append(b, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xc5), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xc6), uint32(n))
+	}
+	return append(b, data...)
+}
+
+func appendArrayHeader(b []byte, n int) []byte {
+	switch {
+	case n <= 15:
+		return append(b, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xdc), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(b, 0xdd), uint32(n))
+}
+
+func appendMapHeader(b []byte, n int) []byte {
+	switch {
+	case n <= 15:
+		return append(b, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xde), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(b, 0xdf), uint32(n))
+}
+
+// msgpackDecoder reads MessagePack values from a buffer
+type msgpackDecoder struct {
+	data []byte
+	pos  int
+}
+
+// next returns the next n bytes
+func (d *msgpackDecoder) next(n int) ([]byte, error) {
+	if n < 0 || len(d.data)-d.pos < n {
+		return nil, ErrTruncated
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *msgpackDecoder) byte() (byte, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// length reads a big-endian length of size bytes
+func (d *msgpackDecoder) length(size int) (int, error) {
+	b, err := d.next(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return int(b[0]), nil
+	case 2:
+		return int(binary.BigEndian.Uint16(b)), nil
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint64(n) > uint64(len(d.data)) {
+		// No element takes less than a byte, so this cannot be satisfied
+		return 0, ErrTruncated
+	}
+	return int(n), nil
+}
+
+func (d *msgpackDecoder) int() (int64, error) {
+	c, err := d.byte()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	}
+
+	var b []byte
+	switch c {
+	case 0xcc, 0xd0:
+		b, err = d.next(1)
+	case 0xcd, 0xd1:
+		b, err = d.next(2)
+	case 0xce, 0xd2:
+		b, err = d.next(4)
+	case 0xcf, 0xd3:
+		b, err = d.next(8)
+	default:
+		return 0, fmt.Errorf("%w: expected integer, found 0x%02x", ErrMsgpack, c)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	switch c {
+	case 0xcc:
+		return int64(b[0]), nil
+	case 0xcd:
+		return int64(binary.BigEndian.Uint16(b)), nil
+	case 0xce:
+		return int64(binary.BigEndian.Uint32(b)), nil
+	case 0xcf:
+		v := binary.BigEndian.Uint64(b)
+		if v > math.MaxInt64 {
+			return 0, fmt.Errorf("%w: integer %d out of range", ErrMsgpack, v)
+		}
+		return int64(v), nil
+	case 0xd0:
+		return int64(int8(b[0])), nil
+	case 0xd1:
+		return int64(int16(binary.BigEndian.Uint16(b))), nil
+	case 0xd2:
+		return int64(int32(binary.BigEndian.Uint32(b))), nil
+	}
+	return int64(binary.BigEndian.Uint64(b)), nil
+}
+
+// uint32 reads an integer that must fit a uint32
+func (d *msgpackDecoder) uint32() (uint32, error) {
+	v, err := d.int()
+	if err != nil {
+		return 0, err
+	}
+	if v < 0 || v > math.MaxUint32 {
+		return 0, fmt.Errorf("%w: offset %d out of range", ErrMsgpack, v)
+	}
+	return uint32(v), nil
+}
+
+func (d *msgpackDecoder) string() (string, error) {
+	c, err := d.byte()
+	if err != nil {
+		return "", err
+	}
+
+	var n int
+	switch {
+	case c&0xe0 == 0xa0:
+		n = int(c & 0x1f)
+	case c == 0xd9:
+		n, err = d.length(1)
+	case c == 0xda:
+		n, err = d.length(2)
+	case c == 0xdb:
+		n, err = d.length(4)
+	default:
+		return "", fmt.Errorf("%w: expected string, found 0x%02x", ErrMsgpack, c)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	b, err := d.next(n)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (d *msgpackDecoder) binary() ([]byte, error) {
+	c, err := d.byte()
+	if err != nil {
+		return nil, err
+	}
+
+	var n int
+	switch c {
+	case 0xc4:
+		n, err = d.length(1)
+	case 0xc5:
+		n, err = d.length(2)
+	case 0xc6:
+		n, err = d.length(4)
+	default:
+		return nil, fmt.Errorf("%w: expected binary, found 0x%02x", ErrMsgpack, c)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := d.next(n)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), b...), nil
+}
+
+func (d *msgpackDecoder) arrayHeader() (int, error) {
+	c, err := d.byte()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case c&0xf0 == 0x90:
+		return int(c & 0x0f), nil
+	case c == 0xdc:
+		return d.length(2)
+	case c == 0xdd:
+		return d.length(4)
+	}
+	return 0, fmt.Errorf("%w: expected array, found 0x%02x", ErrMsgpack, c)
+}
+
+func (d *msgpackDecoder) mapHeader() (int, error) {
+	c, err := d.byte()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case c&0xf0 == 0x80:
+		return int(c & 0x0f), nil
+	case c == 0xde:
+		return d.length(2)
+	case c == 0xdf:
+		return d.length(4)
+	}
+	return 0, fmt.Errorf("%w: expected map, found 0x%02x", ErrMsgpack, c)
+}
+
+// tokens reads an array of tokens
+func (d *msgpackDecoder) tokens() ([]nsigii.Token, error) {
+	n, err := d.arrayHeader()
+	if err != nil {
+		return nil, err
+	}
+	if n > len(d.data)-d.pos {
+		return nil, ErrTruncated
+	}
+
+	tokens := make([]nsigii.Token, n)
+	for i := range tokens {
+		if tokens[i], err = d.token(); err != nil {
+			return nil, fmt.Errorf("token %d: %w", i, err)
+		}
+	}
+	return tokens, nil
+}
+
+func (d *msgpackDecoder) token() (nsigii.Token, error) {
+	var t nsigii.Token
+	n, err := d.arrayHeader()
+	if err != nil {
+		return t, err
+	}
+	if n != 6 && n != 9 {
+		return t, fmt.Errorf("%w: token has %d fields", ErrMsgpack, n)
+	}
+
+	typ, err := d.int()
+	if err != nil {
+		return t, err
+	}
+	if typ < math.MinInt32 || typ > math.MaxInt32 {
+		return t, fmt.Errorf("%w: token type %d out of range", ErrMsgpack, typ)
+	}
+	t.Type = nsigii.TokenType(typ)
+	if t.Memory, err = d.uint32(); err != nil {
+		return t, err
+	}
+	if t.Value, err = d.uint32(); err != nil {
+		return t, err
+	}
+	if t.Text, err = d.string(); err != nil {
+		return t, err
+	}
+	line, err := d.int()
+	if err != nil {
+		return t, err
+	}
+	column, err := d.int()
+	if err != nil {
+		return t, err
+	}
+	t.Line, t.Column = int(line), int(column)
+
+	if n == 6 {
+		t.EndOffset = t.Memory + t.Value
+		return t, nil
+	}
+	if t.EndOffset, err = d.uint32(); err != nil {
+		return t, err
+	}
+	if t.RuneOffset, err = d.uint32(); err != nil {
+		return t, err
+	}
+	if t.RuneLength, err = d.uint32(); err != nil {
+		return t, err
+	}
+	return t, nil
+}
+
+// skip reads past one value of any type
+func (d *msgpackDecoder) skip() error {
+	c, err := d.byte()
+	if err != nil {
+		return err
+	}
+
+	var n int
+	switch {
+	case c <= 0x7f, c >= 0xe0, c == 0xc0, c == 0xc2, c == 0xc3:
+		return nil
+	case c&0xe0 == 0xa0:
+		_, err = d.next(int(c & 0x1f))
+		return err
+	case c&0xf0 == 0x90:
+		return d.skipN(int(c & 0x0f))
+	case c&0xf0 == 0x80:
+		return d.skipN(2 * int(c&0x0f))
+	}
+
+	switch c {
+	case 0xcc, 0xd0:
+		_, err = d.next(1)
+	case 0xcd, 0xd1:
+		_, err = d.next(2)
+	case 0xce, 0xd2, 0xca:
+		_, err = d.next(4)
+	case 0xcf, 0xd3, 0xcb:
+		_, err = d.next(8)
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8: // fixext 1, 2, 4, 8, 16
+		_, err = d.next(1 + 1<<(c-0xd4))
+	case 0xc4, 0xd9:
+		if n, err = d.length(1); err == nil {
+			_, err = d.next(n)
+		}
+	case 0xc5, 0xda:
+		if n, err = d.length(2); err == nil {
+			_, err = d.next(n)
+		}
+	case 0xc6, 0xdb:
+		if n, err = d.length(4); err == nil {
+			_, err = d.next(n)
+		}
+	case 0xc7, 0xc8, 0xc9: // ext 8, 16, 32
+		if n, err = d.length(1 << (c - 0xc7)); err == nil {
+			_, err = d.next(1 + n)
+		}
+	case 0xdc, 0xdd:
+		if n, err = d.length(2 << (c - 0xdc)); err == nil {
+			err = d.skipN(n)
+		}
+	case 0xde, 0xdf:
+		if n, err = d.length(2 << (c - 0xde)); err == nil {
+			err = d.skipN(2 * n)
+		}
+	default:
+		return fmt.Errorf("%w: invalid type 0x%02x", ErrMsgpack, c)
+	}
+	return err
+}
+
+func (d *msgpackDecoder) skipN(n int) error {
+	for i := 0; i < n; i++ {
+		if err := d.skip(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,310 @@
+package encoding
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/obinexus/nsigii-rift/nsigii"
+)
+
+// ============================================================================
+// Sparse Format
+// ============================================================================
+
+// The sparse format stores the same triplets as the packed format in a
+// fraction of the space. Consecutive tokens of one type form a run that
+// stores the type once, and each token's Memory is stored as its distance
+// from the end of the token before it, which in source text is nearly
+// always a few bytes of whitespace. With varints, source code typically
+// takes three to four bytes a token instead of twelve.
+//
+// Layout:
+//   header   "NSGS" | version uint16 (little-endian)
+//   run      varint type | uvarint count (> 0) | count × (varint gap | uvarint value)
+//   trailer  varint 0 | uvarint 0 | uvarint token count | CRC-32 (IEEE) of runs, little-endian
+//
+// gap is Memory minus the previous token's Memory+Value, with the first
+// token measured from 0.
+
+const (
+	sparseMagic      = "NSGS"
+	sparseHeaderSize = 6
+
+	// maxSparseRun bounds the tokens a SparseWriter buffers before it must
+	// write a run out
+	maxSparseRun = 4096
+)
+
+// SparseWriter encodes tokens to an io.Writer in the sparse format. Tokens
+// are written out a run at a time; Close must be called to write the last
+// run and the trailer.
+type SparseWriter struct {
+	w      io.Writer
+	crc    uint32
+	count  uint64
+	end    uint32 // Memory+Value of the last token written
+	header bool
+	closed bool
+
+	run     []nsigii.Token // pending run, all of one type
+	scratch []byte
+}
+
+// NewSparseWriter returns a SparseWriter encoding to w
+func NewSparseWriter(w io.Writer) *SparseWriter {
+	return &SparseWriter{w: w}
+}
+
+// Write encodes one token. Only its type, Memory and Value are stored.
+func (w *SparseWriter) Write(t nsigii.Token) error {
+	if w.closed {
+		return ErrWriterDone
+	}
+	if len(w.run) > 0 && (w.run[0].Type != t.Type || len(w.run) == maxSparseRun) {
+		if err := w.flush(); err != nil {
+			return err
+		}
+	}
+	w.run = append(w.run, t)
+	return nil
+}
+
+// Close writes any pending run and the trailer. It does not close the
+// underlying writer.
+func (w *SparseWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	if err := w.flush(); err != nil {
+		return err
+	}
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.closed = true
+
+	b := binary.AppendVarint(w.scratch[:0], 0)
+	b = binary.AppendUvarint(b, 0)
+	b = binary.AppendUvarint(b, w.count)
+	b = binary.LittleEndian.AppendUint32(b, w.crc)
+	_, err := w.w.Write(b)
+	return err
+}
+
+// flush writes the pending run
+func (w *SparseWriter) flush() error {
+	if len(w.run) == 0 {
+		return nil
+	}
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	b := binary.AppendVarint(w.scratch[:0], int64(w.run[0].Type))
+	b = binary.AppendUvarint(b, uint64(len(w.run)))
+	for _, t := range w.run {
+		b = binary.AppendVarint(b, int64(t.Memory)-int64(w.end))
+		b = binary.AppendUvarint(b, uint64(t.Value))
+		w.end = t.Memory + t.Value
+	}
+	w.scratch = b
+	w.crc = crc32.Update(w.crc, crc32.IEEETable, b)
+	w.count += uint64(len(w.run))
+	w.run = w.run[:0]
+
+	_, err := w.w.Write(b)
+	return err
+}
+
+func (w *SparseWriter) writeHeader() error {
+	if w.header {
+		return nil
+	}
+	w.header = true
+
+	var hdr [sparseHeaderSize]byte
+	copy(hdr[:4], sparseMagic)
+	binary.LittleEndian.PutUint16(hdr[4:], Version)
+
+	_, err := w.w.Write(hdr[:])
+	return err
+}
+
+// EncodeSparse encodes a complete token stream in the sparse format
+func EncodeSparse(tokens []nsigii.Token) []byte {
+	var buf bytes.Buffer
+	w := NewSparseWriter(&buf)
+	for _, t := range tokens {
+		w.Write(t) // Writes to a bytes.Buffer cannot fail
+	}
+	w.Close()
+	return buf.Bytes()
+}
+
+// SparseReader decodes tokens in the sparse format from an io.Reader
+type SparseReader struct {
+	r     io.ByteReader
+	crc   uint32
+	count uint64
+	end   uint32
+	typ   nsigii.TokenType
+	left  uint64 // tokens remaining in the current run
+	done  bool
+}
+
+// NewSparseReader reads and validates the stream header from r. If r is not
+// an io.ByteReader it is buffered, and may be read past the end of the
+// stream.
+func NewSparseReader(r io.Reader) (*SparseReader, error) {
+	var hdr [sparseHeaderSize]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrTruncated
+		}
+		return nil, err
+	}
+
+	if string(hdr[:4]) != sparseMagic {
+		return nil, ErrBadMagic
+	}
+	if v := binary.LittleEndian.Uint16(hdr[4:]); v != Version {
+		return nil, fmt.Errorf("%w: %d", ErrVersion, v)
+	}
+
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &SparseReader{r: br}, nil
+}
+
+// Read decodes the next token. It returns io.EOF once the trailer has been
+// read and the count and checksum verified. Decoded tokens carry no Text.
+func (r *SparseReader) Read() (nsigii.Token, error) {
+	if r.done {
+		return nsigii.Token{}, io.EOF
+	}
+
+	if r.left == 0 {
+		sum := r.crc
+		typ, err := r.varint()
+		if err != nil {
+			return nsigii.Token{}, err
+		}
+		n, err := r.uvarint()
+		if err != nil {
+			return nsigii.Token{}, err
+		}
+		if n == 0 {
+			if typ != 0 {
+				r.done = true
+				return nsigii.Token{}, errors.New("sparse run has no tokens")
+			}
+			return nsigii.Token{}, r.trailer(sum)
+		}
+		r.typ, r.left = nsigii.TokenType(typ), n
+	}
+
+	gap, err := r.varint()
+	if err != nil {
+		return nsigii.Token{}, err
+	}
+	value, err := r.uvarint()
+	if err != nil {
+		return nsigii.Token{}, err
+	}
+	memory := int64(r.end) + gap
+	if memory < 0 || memory+int64(value) > 1<<32-1 {
+		return nsigii.Token{}, errors.New("sparse token out of range")
+	}
+	r.left--
+	r.count++
+	r.end = uint32(memory) + uint32(value)
+
+	return nsigii.Token{
+		Type:      r.typ,
+		Memory:    uint32(memory),
+		Value:     uint32(value),
+		EndOffset: r.end,
+	}, nil
+}
+
+// trailer verifies the trailer after its empty run header against the
+// checksum of the runs before it
+func (r *SparseReader) trailer(sum uint32) error {
+	r.done = true
+
+	count, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return truncated(err)
+	}
+	var b [4]byte
+	for i := range b {
+		if b[i], err = r.r.ReadByte(); err != nil {
+			return truncated(err)
+		}
+	}
+	if count != r.count || binary.LittleEndian.Uint32(b[:]) != sum {
+		return ErrChecksum
+	}
+	return io.EOF
+}
+
+// varint reads a signed varint of the run data
+func (r *SparseReader) varint() (int64, error) {
+	v, err := binary.ReadVarint(crcReader{r})
+	return v, truncated(err)
+}
+
+// uvarint reads an unsigned varint of the run data
+func (r *SparseReader) uvarint() (uint64, error) {
+	v, err := binary.ReadUvarint(crcReader{r})
+	return v, truncated(err)
+}
+
+// crcReader adds the bytes read to the reader's checksum
+type crcReader struct {
+	r *SparseReader
+}
+
+func (c crcReader) ReadByte() (byte, error) {
+	b, err := c.r.r.ReadByte()
+	if err == nil {
+		one := [1]byte{b}
+		c.r.crc = crc32.Update(c.r.crc, crc32.IEEETable, one[:])
+	}
+	return b, err
+}
+
+// truncated maps the end of input inside a stream to ErrTruncated
+func truncated(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrTruncated
+	}
+	return err
+}
+
+// DecodeSparse decodes a complete token stream in the sparse format
+func DecodeSparse(data []byte) ([]nsigii.Token, error) {
+	r, err := NewSparseReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens []nsigii.Token
+	for {
+		t, err := r.Read()
+		if err == io.EOF {
+			return tokens, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+}
@@ -0,0 +1,121 @@
+package encoding
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/obinexus/nsigii-rift/nsigii"
+)
+
+// sparseTokens returns tokens with runs of one type, gaps, an overlap and
+// the EOF token, as a lexer might produce them
+func sparseTokens() []nsigii.Token {
+	tokens := []nsigii.Token{
+		{Type: nsigii.TokenKeyword, Memory: 0, Value: 3},
+		{Type: nsigii.TokenIdentifier, Memory: 4, Value: 1},
+		{Type: nsigii.TokenOperator, Memory: 6, Value: 1},
+		{Type: nsigii.TokenNumber, Memory: 8, Value: 1},
+		{Type: nsigii.TokenDelimiter, Memory: 9, Value: 1},
+		{Type: nsigii.TokenComment, Memory: 11, Value: 40},
+		{Type: nsigii.TokenIdentifier, Memory: 52, Value: 5},
+		{Type: nsigii.TokenIdentifier, Memory: 58, Value: 5},
+		{Type: nsigii.TokenIdentifier, Memory: 60, Value: 2}, // overlaps the one before
+		{Type: nsigii.TokenString, Memory: 1 << 20, Value: 70000},
+		{Type: nsigii.TokenEOF, Memory: 1<<20 + 70000},
+	}
+	for i := range tokens {
+		tokens[i].EndOffset = tokens[i].Memory + tokens[i].Value
+	}
+	return tokens
+}
+
+func TestSparseRoundTrip(t *testing.T) {
+	for _, tokens := range [][]nsigii.Token{nil, sparseTokens()} {
+		got, err := DecodeSparse(EncodeSparse(tokens))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(tokens) {
+			t.Fatalf("decoded %d tokens, want %d", len(got), len(tokens))
+		}
+		for i := range tokens {
+			if got[i] != tokens[i] {
+				t.Errorf("token %d = %+v, want %+v", i, got[i], tokens[i])
+			}
+		}
+	}
+
+	// Runs longer than the writer buffers are split and read back whole
+	long := make([]nsigii.Token, 2*maxSparseRun+1)
+	for i := range long {
+		long[i] = nsigii.Token{Type: nsigii.TokenIdentifier, Memory: uint32(2 * i), Value: 1, EndOffset: uint32(2*i + 1)}
+	}
+	got, err := DecodeSparse(EncodeSparse(long))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(long) || got[len(got)-1] != long[len(long)-1] {
+		t.Errorf("long run decoded as %d tokens", len(got))
+	}
+}
+
+func TestSparseStreaming(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewSparseWriter(&buf)
+	for _, tok := range sparseTokens() {
+		if err := w.Write(tok); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewSparseReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for {
+		_, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != len(sparseTokens()) {
+		t.Errorf("read %d tokens, want %d", n, len(sparseTokens()))
+	}
+}
+
+func TestSparseTampered(t *testing.T) {
+	data := EncodeSparse(sparseTokens())
+
+	for cut := 0; cut < len(data); cut++ {
+		if _, err := DecodeSparse(data[:cut]); err == nil {
+			t.Errorf("DecodeSparse of the first %d of %d bytes succeeded", cut, len(data))
+		}
+	}
+	if _, err := DecodeSparse(data[:len(data)-1]); !errors.Is(err, ErrTruncated) {
+		t.Errorf("DecodeSparse(truncated trailer) = %v, want ErrTruncated", err)
+	}
+
+	for i := sparseHeaderSize; i < len(data); i++ {
+		flipped := bytes.Clone(data)
+		flipped[i] ^= 0x01
+		if _, err := DecodeSparse(flipped); err == nil {
+			t.Errorf("DecodeSparse with byte %d flipped succeeded", i)
+		}
+	}
+
+	bad := bytes.Clone(data)
+	copy(bad, "NSGX")
+	if _, err := DecodeSparse(bad); !errors.Is(err, ErrBadMagic) {
+		t.Errorf("DecodeSparse(bad magic) = %v, want ErrBadMagic", err)
+	}
+}
@@ -0,0 +1,232 @@
+// Package encoding reads and writes NSIGII token streams in the packed
+// TokenTriplet wire format shared with the native toolchain
+//
+// Layout (all fields little-endian):
+//   header   "NSGT" | version uint16 | record size uint16
+//   record   type int32 | memory uint32 | value uint32   (C TokenTriplet)
+//   trailer  0xFFFFFFFF | record count uint32 | CRC-32 (IEEE) of records
+//
+// Only the triplet is stored; token text is recovered by slicing the
+// original source with Memory/Value. Streams that must carry their text use
+// the MessagePack form instead (see MarshalMsgpack); large streams can be
+// stored far more compactly in the sparse format (see SparseWriter).
+package encoding
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+
+	"github.com/obinexus/nsigii-rift/nsigii"
+)
+
+// Version is the wire format version written by Writer
+const Version = 1
+
+const (
+	magic      = "NSGT"
+	headerSize = 8
+	recordSize = 12
+
+	// trailerMarker occupies the type slot of the trailer. It reads as -1
+	// when decoded as a type, which RegisterTokenType refuses and Write
+	// rejects, so it can never collide with a record.
+	trailerMarker = 0xFFFFFFFF
+)
+
+var (
+	ErrBadMagic   = errors.New("not an NSIGII token stream")
+	ErrVersion    = errors.New("unsupported token stream version")
+	ErrChecksum   = errors.New("token stream checksum mismatch")
+	ErrTruncated  = errors.New("token stream truncated")
+	ErrWriterDone = errors.New("token stream writer is closed")
+	ErrTokenType  = errors.New("token type out of range")
+)
+
+// ============================================================================
+// Writer
+// ============================================================================
+
+// Writer encodes tokens to an io.Writer. Close must be called to write the
+// trailer; a stream without one fails to decode.
+type Writer struct {
+	w      io.Writer
+	crc    hash.Hash32
+	count  uint32
+	header bool
+	closed bool
+	buf    [recordSize]byte
+}
+
+// NewWriter returns a Writer encoding to w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w, crc: crc32.NewIEEE()}
+}
+
+// Write encodes one token
+func (w *Writer) Write(t nsigii.Token) error {
+	if w.closed {
+		return ErrWriterDone
+	}
+	if t.Type < 0 || t.Type > math.MaxInt32 {
+		return fmt.Errorf("%w: %d", ErrTokenType, t.Type)
+	}
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	binary.LittleEndian.PutUint32(w.buf[0:], uint32(int32(t.Type)))
+	binary.LittleEndian.PutUint32(w.buf[4:], t.Memory)
+	binary.LittleEndian.PutUint32(w.buf[8:], t.Value)
+
+	if _, err := w.w.Write(w.buf[:]); err != nil {
+		return err
+	}
+	w.crc.Write(w.buf[:])
+	w.count++
+
+	return nil
+}
+
+// Close writes the trailer. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.closed = true
+
+	binary.LittleEndian.PutUint32(w.buf[0:], trailerMarker)
+	binary.LittleEndian.PutUint32(w.buf[4:], w.count)
+	binary.LittleEndian.PutUint32(w.buf[8:], w.crc.Sum32())
+
+	_, err := w.w.Write(w.buf[:])
+	return err
+}
+
+func (w *Writer) writeHeader() error {
+	if w.header {
+		return nil
+	}
+	w.header = true
+
+	var hdr [headerSize]byte
+	copy(hdr[:4], magic)
+	binary.LittleEndian.PutUint16(hdr[4:], Version)
+	binary.LittleEndian.PutUint16(hdr[6:], recordSize)
+
+	_, err := w.w.Write(hdr[:])
+	return err
+}
+
+// WriteTokens encodes a complete token stream to w
+func WriteTokens(w io.Writer, tokens []nsigii.Token) error {
+	enc := NewWriter(w)
+	for _, t := range tokens {
+		if err := enc.Write(t); err != nil {
+			return err
+		}
+	}
+	return enc.Close()
+}
+
+// ============================================================================
+// Reader
+// ============================================================================
+
+// Reader decodes tokens from an io.Reader
+type Reader struct {
+	r     io.Reader
+	crc   hash.Hash32
+	count uint32
+	done  bool
+	buf   [recordSize]byte
+}
+
+// NewReader reads and validates the stream header from r
+func NewReader(r io.Reader) (*Reader, error) {
+	var hdr [headerSize]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrTruncated
+		}
+		return nil, err
+	}
+
+	if string(hdr[:4]) != magic {
+		return nil, ErrBadMagic
+	}
+	if v := binary.LittleEndian.Uint16(hdr[4:]); v != Version {
+		return nil, fmt.Errorf("%w: %d", ErrVersion, v)
+	}
+	if size := binary.LittleEndian.Uint16(hdr[6:]); size != recordSize {
+		return nil, fmt.Errorf("unsupported record size: %d", size)
+	}
+
+	return &Reader{r: r, crc: crc32.NewIEEE()}, nil
+}
+
+// Read decodes the next token. It returns io.EOF once the trailer has been
+// read and the count and checksum verified. Decoded tokens carry no Text.
+func (r *Reader) Read() (nsigii.Token, error) {
+	if r.done {
+		return nsigii.Token{}, io.EOF
+	}
+
+	if _, err := io.ReadFull(r.r, r.buf[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nsigii.Token{}, ErrTruncated
+		}
+		return nsigii.Token{}, err
+	}
+
+	typ := binary.LittleEndian.Uint32(r.buf[0:])
+	if typ == trailerMarker {
+		r.done = true
+		count := binary.LittleEndian.Uint32(r.buf[4:])
+		sum := binary.LittleEndian.Uint32(r.buf[8:])
+		if count != r.count || sum != r.crc.Sum32() {
+			return nsigii.Token{}, ErrChecksum
+		}
+		return nsigii.Token{}, io.EOF
+	}
+
+	r.crc.Write(r.buf[:])
+	r.count++
+
+	memory := binary.LittleEndian.Uint32(r.buf[4:])
+	value := binary.LittleEndian.Uint32(r.buf[8:])
+
+	return nsigii.Token{
+		Type:      nsigii.TokenType(int32(typ)),
+		Memory:    memory,
+		Value:     value,
+		EndOffset: memory + value,
+	}, nil
+}
+
+// ReadTokens decodes a complete token stream from r
+func ReadTokens(r io.Reader) ([]nsigii.Token, error) {
+	dec, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens []nsigii.Token
+	for {
+		t, err := dec.Read()
+		if err == io.EOF {
+			return tokens, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+}
@@ -0,0 +1,132 @@
+package nsigii
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	mathrand "math/rand/v2"
+	"os"
+	"sync"
+)
+
+// ============================================================================
+// Entropy Sources
+// ============================================================================
+
+// EntropySource supplies the random bytes a context injects as AUX noise.
+// Read fills p completely or returns an error.
+type EntropySource interface {
+	Read(p []byte) (n int, err error)
+}
+
+// auxNoiseSize is the number of entropy bytes in one draw at noise level 1
+const auxNoiseSize = 32
+
+// CryptoEntropy returns the operating system's secure random source, the
+// default for every context
+func CryptoEntropy() EntropySource {
+	return rand.Reader
+}
+
+// seededEntropy is a deterministic ChaCha8 stream
+type seededEntropy struct {
+	mu  sync.Mutex
+	rng *mathrand.ChaCha8
+}
+
+// NewSeededEntropy returns a deterministic source producing the same bytes
+// for the same seed. It is meant for tests and staging replays and must not
+// be used where the noise has to be unpredictable.
+func NewSeededEntropy(seed uint64) EntropySource {
+	var key [32]byte
+	binary.LittleEndian.PutUint64(key[:], seed)
+	return &seededEntropy{rng: mathrand.NewChaCha8(key)}
+}
+
+func (s *seededEntropy) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rng.Read(p)
+}
+
+// DeviceEntropy reads from a hardware random number generator exposed as a
+// device file, such as /dev/hwrng
+type DeviceEntropy struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenDeviceEntropy opens the entropy device at path
+func OpenDeviceEntropy(path string) (*DeviceEntropy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &DeviceEntropy{f: f}, nil
+}
+
+// Read fills p from the device
+func (d *DeviceEntropy) Read(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return io.ReadFull(d.f, p)
+}
+
+// Close closes the device
+func (d *DeviceEntropy) Close() error {
+	return d.f.Close()
+}
+
+// SetEntropySource sets where the context draws AUX noise from. A nil
+// source restores CryptoEntropy.
+//
+// Example:
+//   ctx.SetEntropySource(nsigii.NewSeededEntropy(42)) // reproducible noise
+func (c *Context) SetEntropySource(src EntropySource) error {
+	if err := c.require(RoleOperator, "SetEntropySource"); err != nil {
+		return err
+	}
+	c.aux.mu.Lock()
+	defer c.aux.mu.Unlock()
+	c.aux.entropy = src
+	return nil
+}
+
+// EntropySource returns the context's entropy source
+func (c *Context) EntropySource() EntropySource {
+	c.aux.mu.Lock()
+	defer c.aux.mu.Unlock()
+	if c.aux.entropy == nil {
+		return CryptoEntropy()
+	}
+	return c.aux.entropy
+}
+
+// AuxNoise returns the entropy currently injected, or nil if AUX is idle,
+// running at level 0, or between bursts of its noise profile
+func (c *Context) AuxNoise() []byte {
+	c.aux.mu.Lock()
+	defer c.aux.mu.Unlock()
+	return append([]byte(nil), c.aux.noise...)
+}
+
+// drawNoise reads the entropy for one burst of profile p. The caller holds
+// c.aux.mu.
+func (c *Context) drawNoise(p NoiseProfile) ([]byte, error) {
+	if p.Level <= 0 {
+		return nil, nil
+	}
+
+	src := c.aux.entropy
+	if src == nil {
+		src = CryptoEntropy()
+	}
+
+	noise := make([]byte, p.burstSize())
+	if _, err := io.ReadFull(src, noise); err != nil {
+		return nil, fmt.Errorf("entropy source failed: %w", err)
+	}
+	c.aux.counters.entropy += len(noise)
+	return noise, nil
+}
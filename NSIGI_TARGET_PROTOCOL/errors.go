@@ -0,0 +1,93 @@
+package nsigii
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ============================================================================
+// Errors
+// ============================================================================
+
+var (
+	// ErrContextClosed is returned for work on a context that has been
+	// closed, including work racing with Close
+	ErrContextClosed = errors.New("context is closed")
+
+	// ErrTerminated is returned for work refused by a context whose color
+	// state is BLACK. Such a context stays refused until it is reinstated.
+	ErrTerminated = errors.New("context is terminated")
+
+	// ErrTokenBufferFull reports that a source needs more tokens than the
+	// context's limit allows (see SetMaxTokens)
+	ErrTokenBufferFull = errors.New("token limit exceeded")
+
+	// ErrConsensusFailed reports that an operation requiring RGB consensus
+	// was refused because consensus was not reached
+	ErrConsensusFailed = errors.New("RGB consensus not reached")
+
+	// ErrInvalidUTF8 reports a source rejected by SetStrictUTF8
+	ErrInvalidUTF8 = errors.New("invalid UTF-8")
+)
+
+// Result codes returned by the native library (nsigii_core.h)
+const (
+	codeNullContext = -1 // NSIGII_ERROR_NULL_CTX
+	codeNullInput   = -2 // NSIGII_ERROR_NULL_INPUT
+	codeNoMemory    = -3 // NSIGII_ERROR_NO_MEMORY
+	codeInvalid     = -4 // NSIGII_ERROR_INVALID
+	codeNoConsensus = -5 // NSIGII_ERROR_NO_CONSENSUS
+	codeColorFail   = -6 // NSIGII_ERROR_COLOR_FAIL
+	codeBalanceFail = -7 // NSIGII_ERROR_BALANCE_FAIL
+)
+
+// cErrorMessages describes the native result codes. The library keeps no
+// error string of its own, so this table is the only source of detail.
+var cErrorMessages = map[int]string{
+	codeNullContext: "no native context",
+	codeNullInput:   "missing input",
+	codeNoMemory:    "out of memory",
+	codeInvalid:     "invalid argument or buffer too small",
+	codeNoConsensus: "RGB consensus not reached",
+	codeColorFail:   "color verification failed",
+	codeBalanceFail: "cisco tree rebalance failed",
+}
+
+// CError is a failure reported by the native library as a nonzero result
+// code
+//
+// Example:
+//   var cerr *nsigii.CError
+//   if errors.As(err, &cerr) {
+//       log.Printf("native %s returned %d", cerr.Op, cerr.Code)
+//   }
+type CError struct {
+	Op   string // Native operation that failed, e.g. "tokenization"
+	Code int    // Result code returned by the native library
+}
+
+func (e *CError) Error() string {
+	if msg, ok := cErrorMessages[e.Code]; ok {
+		return fmt.Sprintf("%s failed: %s (%d)", e.Op, msg, e.Code)
+	}
+	return fmt.Sprintf("%s failed: %d", e.Op, e.Code)
+}
+
+// Message describes the result code, or returns "" for codes the package
+// does not know
+func (e *CError) Message() string {
+	return cErrorMessages[e.Code]
+}
+
+// Is matches the sentinel errors equivalent to the result code, so a
+// native consensus failure is also ErrConsensusFailed and a missing native
+// context ErrContextClosed
+func (e *CError) Is(target error) bool {
+	switch e.Code {
+	case codeNullContext:
+		return target == ErrContextClosed
+	case codeNoConsensus:
+		return target == ErrConsensusFailed
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package nsigii
+
+import (
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Escalation
+// ============================================================================
+
+// EscalationPolicy makes a context fail safe on its own: a context left in
+// YELLOW escalates to MAGENTA, and one left in MAGENTA escalates to BLACK.
+// Zero fields disable the corresponding step.
+type EscalationPolicy struct {
+	YellowTimeout  time.Duration // Time in YELLOW before escalating to MAGENTA
+	MagentaTimeout time.Duration // Time in MAGENTA before escalating to BLACK
+	MaxWarnings    int           // Warnings in YELLOW before escalating to MAGENTA
+
+	// OnEscalate, if set, is called after each automatic escalation
+	OnEscalate func(from, to ColorChannel, reason string)
+}
+
+// escalation tracks the running escalation step of a context
+type escalation struct {
+	mu       sync.Mutex
+	policy   EscalationPolicy
+	timer    *time.Timer
+	gen      uint64 // invalidates timers from earlier states
+	warnings int
+}
+
+// SetEscalationPolicy sets the context's escalation policy. It applies from
+// the next color transition or warning.
+//
+// Example:
+//   ctx.SetEscalationPolicy(nsigii.EscalationPolicy{
+//       YellowTimeout:  30 * time.Second,
+//       MagentaTimeout: 5 * time.Second,
+//       MaxWarnings:    3,
+//   })
+func (c *Context) SetEscalationPolicy(p EscalationPolicy) error {
+	if err := c.require(RoleAdmin, "SetEscalationPolicy"); err != nil {
+		return err
+	}
+	c.escalation.mu.Lock()
+	defer c.escalation.mu.Unlock()
+	c.escalation.policy = p
+	return nil
+}
+
+// EscalationPolicy returns the context's escalation policy
+func (c *Context) EscalationPolicy() EscalationPolicy {
+	c.escalation.mu.Lock()
+	defer c.escalation.mu.Unlock()
+	return c.escalation.policy
+}
+
+// Warn reports a warning condition. A context outside YELLOW enters it;
+// one already in YELLOW counts the warning and escalates to MAGENTA once
+// the policy's MaxWarnings is reached. Warnings in MAGENTA change nothing.
+func (c *Context) Warn(reason string) error {
+	if err := c.require(RoleOperator, "Warn"); err != nil {
+		return err
+	}
+	state := c.ColorState()
+	switch state {
+	case ColorBlack:
+		return ErrTerminated
+	case ColorMagenta:
+		return nil
+	case ColorYellow:
+		c.escalation.mu.Lock()
+		c.escalation.warnings++
+		max := c.escalation.policy.MaxWarnings
+		reached := max > 0 && c.escalation.warnings >= max
+		c.escalation.mu.Unlock()
+
+		if reached {
+			return c.escalate(ColorYellow, ColorMagenta, "warning limit reached: "+reason)
+		}
+		return nil
+	default:
+		return c.transitionColor(state, ColorYellow, reason)
+	}
+}
+
+// escalate makes an automatic transition and notifies the policy's callback
+func (c *Context) escalate(from, to ColorChannel, reason string) error {
+	if err := c.transitionColor(from, to, reason); err != nil {
+		return err
+	}
+
+	if fn := c.EscalationPolicy().OnEscalate; fn != nil {
+		fn(from, to, reason)
+	}
+	return nil
+}
+
+// enterEscalation arms the escalation step for a context that just entered state
+func (c *Context) enterEscalation(state ColorChannel) {
+	e := &c.escalation
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.gen++
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+
+	var timeout time.Duration
+	var next ColorChannel
+	switch state {
+	case ColorYellow:
+		e.warnings = 1
+		timeout, next = e.policy.YellowTimeout, ColorMagenta
+	case ColorMagenta:
+		timeout, next = e.policy.MagentaTimeout, ColorBlack
+	}
+	if timeout <= 0 {
+		return
+	}
+
+	gen := e.gen
+	e.timer = time.AfterFunc(timeout, func() {
+		e.mu.Lock()
+		current := e.gen == gen
+		e.mu.Unlock()
+
+		// A failed transition means the state moved on in the meantime
+		if current {
+			c.escalate(state, next, "escalation timeout in "+state.String())
+		}
+	})
+}
+
+// stopEscalation cancels any pending escalation step
+func (c *Context) stopEscalation() {
+	e := &c.escalation
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.gen++
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+}
@@ -0,0 +1,290 @@
+// Package export writes tokenization output as Apache Arrow record batches
+// and Parquet files, so token streams from many files can be queried with
+// SQL engines such as DuckDB, Spark or BigQuery.
+//
+// Two tables are produced: one row per token (TokenSchema) and one row per
+// file with its statistics (StatsSchema), joined on the file column.
+//
+// Example:
+//   e, err := export.NewParquetExporter(tokensFile, statsFile, export.Options{})
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   for _, path := range paths {
+//       tokens, err := ctx.TokenizeFile(path)
+//       if err != nil {
+//           log.Fatal(err)
+//       }
+//       if err := e.Add(path, tokens); err != nil {
+//           log.Fatal(err)
+//       }
+//   }
+//   err = e.Close()
+package export
+
+import (
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+
+	"github.com/obinexus/nsigii-rift/nsigii"
+)
+
+// ============================================================================
+// Schemas
+// ============================================================================
+
+// TokenSchema is the Arrow schema of the token table
+var TokenSchema = arrow.NewSchema([]arrow.Field{
+	{Name: "file", Type: arrow.BinaryTypes.String},
+	{Name: "index", Type: arrow.PrimitiveTypes.Int64}, // Position in the file's stream
+	{Name: "type", Type: arrow.BinaryTypes.String},    // Token type name, or value if unnamed
+	{Name: "memory", Type: arrow.PrimitiveTypes.Uint32},
+	{Name: "value", Type: arrow.PrimitiveTypes.Uint32},
+	{Name: "text", Type: arrow.BinaryTypes.String},
+	{Name: "line", Type: arrow.PrimitiveTypes.Int32},
+	{Name: "column", Type: arrow.PrimitiveTypes.Int32},
+}, nil)
+
+// StatsSchema is the Arrow schema of the per-file statistics table
+var StatsSchema = arrow.NewSchema([]arrow.Field{
+	{Name: "file", Type: arrow.BinaryTypes.String},
+	{Name: "total_tokens", Type: arrow.PrimitiveTypes.Int64},
+	{Name: "memory_min", Type: arrow.PrimitiveTypes.Uint32},
+	{Name: "memory_max", Type: arrow.PrimitiveTypes.Uint32},
+	{Name: "average_length", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "type_distribution", Type: arrow.MapOf(arrow.BinaryTypes.String, arrow.PrimitiveTypes.Int64)},
+}, nil)
+
+// ============================================================================
+// Record Batches
+// ============================================================================
+
+// RecordBuilder accumulates files into token and statistics record batches.
+// It is not safe for concurrent use.
+type RecordBuilder struct {
+	tokens *array.RecordBuilder
+	stats  *array.RecordBuilder
+	rows   int
+	files  int
+}
+
+// NewRecordBuilder creates a builder allocating from mem; nil means the Go
+// allocator
+func NewRecordBuilder(mem memory.Allocator) *RecordBuilder {
+	if mem == nil {
+		mem = memory.DefaultAllocator
+	}
+	return &RecordBuilder{
+		tokens: array.NewRecordBuilder(mem, TokenSchema),
+		stats:  array.NewRecordBuilder(mem, StatsSchema),
+	}
+}
+
+// Add appends the tokens of one file and a statistics row for it
+func (b *RecordBuilder) Add(file string, tokens []nsigii.Token) {
+	f := b.tokens.Fields()
+	files := f[0].(*array.StringBuilder)
+	indexes := f[1].(*array.Int64Builder)
+	types := f[2].(*array.StringBuilder)
+	memories := f[3].(*array.Uint32Builder)
+	values := f[4].(*array.Uint32Builder)
+	texts := f[5].(*array.StringBuilder)
+	lines := f[6].(*array.Int32Builder)
+	columns := f[7].(*array.Int32Builder)
+
+	for _, field := range f {
+		field.Reserve(len(tokens))
+	}
+	for i, t := range tokens {
+		files.Append(file)
+		indexes.Append(int64(i))
+		types.Append(typeName(t.Type))
+		memories.Append(t.Memory)
+		values.Append(t.Value)
+		texts.Append(t.Lexeme())
+		lines.Append(int32(t.Line))
+		columns.Append(int32(t.Column))
+	}
+	b.rows += len(tokens)
+
+	stats := nsigii.AnalyzeTokens(tokens)
+	s := b.stats.Fields()
+	s[0].(*array.StringBuilder).Append(file)
+	s[1].(*array.Int64Builder).Append(int64(stats.TotalTokens))
+	s[2].(*array.Uint32Builder).Append(stats.MemoryRange[0])
+	s[3].(*array.Uint32Builder).Append(stats.MemoryRange[1])
+	s[4].(*array.Float64Builder).Append(stats.AverageLength)
+
+	// Sorted so equal inputs produce equal files
+	dist := make([]nsigii.TokenType, 0, len(stats.TypeDistribution))
+	for typ := range stats.TypeDistribution {
+		dist = append(dist, typ)
+	}
+	sort.Slice(dist, func(i, j int) bool { return dist[i] < dist[j] })
+	m := s[5].(*array.MapBuilder)
+	m.Append(true)
+	for _, typ := range dist {
+		m.KeyBuilder().(*array.StringBuilder).Append(typeName(typ))
+		m.ItemBuilder().(*array.Int64Builder).Append(int64(stats.TypeDistribution[typ]))
+	}
+	b.files++
+}
+
+// Rows returns the number of token rows added since the last NewRecords
+func (b *RecordBuilder) Rows() int {
+	return b.rows
+}
+
+// Files returns the number of files added since the last NewRecords
+func (b *RecordBuilder) Files() int {
+	return b.files
+}
+
+// NewRecords returns the token and statistics record batches of the files
+// added so far and resets the builder. The caller must release both.
+func (b *RecordBuilder) NewRecords() (tokens, stats arrow.RecordBatch) {
+	b.rows, b.files = 0, 0
+	return b.tokens.NewRecordBatch(), b.stats.NewRecordBatch()
+}
+
+// Release frees the builder's buffers
+func (b *RecordBuilder) Release() {
+	b.tokens.Release()
+	b.stats.Release()
+}
+
+// typeName returns the name a token type is exported under
+func typeName(t nsigii.TokenType) string {
+	name, _ := t.MarshalText()
+	return string(name)
+}
+
+// ============================================================================
+// Parquet
+// ============================================================================
+
+// Options configures a ParquetExporter
+type Options struct {
+	// BatchSize is the number of token rows buffered before they are written
+	// out as a row group; 0 means 65536. Statistics rows are written with
+	// the same batches.
+	BatchSize int
+
+	// Compression is the page compression, e.g. compress.Codecs.Zstd; the
+	// zero value writes uncompressed pages
+	Compression compress.Compression
+
+	Allocator memory.Allocator // nil means the Go allocator
+}
+
+// ParquetExporter writes the token and statistics tables to two Parquet
+// files. It is not safe for concurrent use.
+type ParquetExporter struct {
+	opts    Options
+	builder *RecordBuilder
+	tokens  *pqarrow.FileWriter
+	stats   *pqarrow.FileWriter
+	closed  bool
+}
+
+// NewParquetExporter creates an exporter writing tokens and stats. Close
+// must be called to write the Parquet footers; it does not close the
+// underlying writers.
+func NewParquetExporter(tokens, stats io.Writer, opts Options) (*ParquetExporter, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1 << 16
+	}
+	if opts.Allocator == nil {
+		opts.Allocator = memory.DefaultAllocator
+	}
+
+	props := parquet.NewWriterProperties(
+		parquet.WithAllocator(opts.Allocator),
+		parquet.WithCompression(opts.Compression),
+		parquet.WithMaxRowGroupLength(int64(opts.BatchSize)),
+	)
+	arrowProps := pqarrow.NewArrowWriterProperties(
+		pqarrow.WithAllocator(opts.Allocator),
+		pqarrow.WithStoreSchema(),
+	)
+
+	tw, err := pqarrow.NewFileWriter(TokenSchema, nopCloser{tokens}, props, arrowProps)
+	if err != nil {
+		return nil, err
+	}
+	sw, err := pqarrow.NewFileWriter(StatsSchema, nopCloser{stats}, props, arrowProps)
+	if err != nil {
+		tw.Close()
+		return nil, err
+	}
+
+	return &ParquetExporter{
+		opts:    opts,
+		builder: NewRecordBuilder(opts.Allocator),
+		tokens:  tw,
+		stats:   sw,
+	}, nil
+}
+
+// Add exports the tokens of one file, writing a row group once BatchSize
+// token rows are buffered
+func (e *ParquetExporter) Add(file string, tokens []nsigii.Token) error {
+	if e.closed {
+		return errors.New("parquet exporter is closed")
+	}
+
+	e.builder.Add(file, tokens)
+	if e.builder.Rows() >= e.opts.BatchSize {
+		return e.flush()
+	}
+	return nil
+}
+
+// flush writes the buffered rows
+func (e *ParquetExporter) flush() error {
+	if e.builder.Files() == 0 {
+		return nil
+	}
+
+	tokens, stats := e.builder.NewRecords()
+	defer tokens.Release()
+	defer stats.Release()
+
+	if err := e.tokens.Write(tokens); err != nil {
+		return err
+	}
+	return e.stats.Write(stats)
+}
+
+// Close writes any buffered rows and the Parquet footers
+func (e *ParquetExporter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	defer e.builder.Release()
+
+	err := e.flush()
+	if cerr := e.tokens.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := e.stats.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// nopCloser keeps the Parquet writers from closing the caller's writers
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
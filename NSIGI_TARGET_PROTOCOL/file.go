@@ -0,0 +1,75 @@
+package nsigii
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"unsafe"
+)
+
+// ============================================================================
+// File Tokenization
+// ============================================================================
+
+// TokenizeFile tokenizes the file at path
+//
+// Where the platform allows, the file is memory-mapped and the mapping is
+// handed to the lexer in place, so the contents are never copied into a Go
+// string or a C string; only the text of each token is copied out before the
+// mapping is released (with lazy text, the whole file is copied once
+// instead). Files whose size is an exact multiple of the page size (which
+// leaves no room for the terminating NUL) and platforms without mmap fall
+// back to reading the file.
+//
+// The file must not be modified while it is being tokenized.
+func (c *Context) TokenizeFile(path string) ([]Token, error) {
+	if err := c.usable(); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() >= math.MaxUint32 {
+		return nil, fmt.Errorf("%s: file too large for 32-bit token offsets", path)
+	}
+
+	data, unmap, err := mapFile(f, int(info.Size()))
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return c.tokenize(string(source))
+	}
+	defer unmap()
+
+	source := unsafe.String(&data[0], len(data)-1)
+	tokens, err := c.lex(nil, source, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Token text still points into the mapping. Lazy tokens need only one
+	// copy of the whole file; eager ones copy their own text.
+	if c.lazyText {
+		cloneLazy(tokens, source)
+		return tokens, nil
+	}
+	for i := range tokens {
+		tokens[i].Text = strings.Clone(tokens[i].Text)
+	}
+
+	return tokens, nil
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package nsigii
+
+import "os"
+
+// mapFile reports that memory mapping is unavailable on this platform
+func mapFile(f *os.File, size int) (data []byte, unmap func() error, err error) {
+	return nil, nil, nil
+}
+
+// lockFile reports that advisory file locks are unavailable on this
+// platform; callers fall back to their in-process locking
+func lockFile(path string) (unlock func() error, err error) {
+	return func() error { return nil }, nil
+}
@@ -0,0 +1,39 @@
+//go:build unix
+
+package nsigii
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps the size-byte file f read-only with a trailing NUL byte. It
+// returns nil data if the file cannot be mapped that way.
+func mapFile(f *os.File, size int) (data []byte, unmap func() error, err error) {
+	// The tail of the last page past EOF reads as zeros, which provides the
+	// NUL terminator; a page-aligned file has no such tail
+	if size == 0 || size%os.Getpagesize() == 0 {
+		return nil, nil, nil
+	}
+
+	data, err = syscall.Mmap(int(f.Fd()), 0, size+1, syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
+
+// lockFile takes an exclusive advisory lock on the file at path, creating
+// it if needed, and returns the function releasing it
+func lockFile(path string) (unlock func() error, err error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f.Close, nil
+}
@@ -0,0 +1,127 @@
+package nsigii
+
+import "strings"
+
+// ============================================================================
+// Token Filters
+// ============================================================================
+
+// TokenFilter post-processes a token stream. Filter may reuse the backing
+// array of tokens for its result.
+type TokenFilter interface {
+	Filter(tokens []Token) []Token
+}
+
+// TokenFilterFunc adapts an ordinary function to a TokenFilter
+type TokenFilterFunc func(tokens []Token) []Token
+
+// Filter calls f(tokens)
+func (f TokenFilterFunc) Filter(tokens []Token) []Token {
+	return f(tokens)
+}
+
+// Pipeline applies a sequence of filters in order. A Pipeline is itself a
+// TokenFilter, so pipelines can be nested.
+//
+// Example:
+//   p := nsigii.NewPipeline(nsigii.DropComments(), nsigii.MergeAdjacentStrings())
+//   tokens = p.Filter(tokens)
+type Pipeline struct {
+	filters []TokenFilter
+}
+
+// NewPipeline creates a pipeline running filters in order
+func NewPipeline(filters ...TokenFilter) *Pipeline {
+	return &Pipeline{filters: append([]TokenFilter(nil), filters...)}
+}
+
+// Then appends filters to the pipeline and returns it
+func (p *Pipeline) Then(filters ...TokenFilter) *Pipeline {
+	p.filters = append(p.filters, filters...)
+	return p
+}
+
+// Filter runs tokens through every filter in the pipeline
+func (p *Pipeline) Filter(tokens []Token) []Token {
+	for _, f := range p.filters {
+		tokens = f.Filter(tokens)
+	}
+	return tokens
+}
+
+// TokenizeWith tokenizes source and runs the result through p
+func (c *Context) TokenizeWith(source string, p *Pipeline) ([]Token, error) {
+	tokens, err := c.Tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+	return p.Filter(tokens), nil
+}
+
+// DropTypes returns a filter that removes tokens of the given types
+func DropTypes(types ...TokenType) TokenFilter {
+	return TokenFilterFunc(func(tokens []Token) []Token {
+		kept := tokens[:0]
+		for _, tok := range tokens {
+			drop := false
+			for _, t := range types {
+				if tok.Type == t {
+					drop = true
+					break
+				}
+			}
+			if !drop {
+				kept = append(kept, tok)
+			}
+		}
+		return kept
+	})
+}
+
+// DropComments returns a filter that removes comment tokens
+func DropComments() TokenFilter {
+	return DropTypes(TokenComment)
+}
+
+// MergeAdjacentStrings returns a filter that joins runs of consecutive
+// string tokens, as in C's "a" "b", into a single token spanning the run.
+// The merged token's Text is the concatenation of the originals' Text, so
+// it no longer matches the source between Memory and EndOffset.
+func MergeAdjacentStrings() TokenFilter {
+	return TokenFilterFunc(func(tokens []Token) []Token {
+		merged := tokens[:0]
+		for _, tok := range tokens {
+			n := len(merged)
+			if n == 0 || tok.Type != TokenString || merged[n-1].Type != TokenString {
+				merged = append(merged, tok)
+				continue
+			}
+
+			last := &merged[n-1]
+			last.materialize()
+			last.Text += tok.Lexeme()
+			last.Value = tok.EndOffset - last.Memory
+			last.EndOffset = tok.EndOffset
+			if tok.RuneLength > 0 || last.RuneLength > 0 {
+				last.RuneLength = tok.RuneOffset + tok.RuneLength - last.RuneOffset
+			}
+		}
+		return merged
+	})
+}
+
+// NormalizeWhitespace returns a filter that trims the Text of comment and
+// string tokens and collapses each internal run of whitespace to a single
+// space. Offsets are left unchanged and still describe the original source.
+func NormalizeWhitespace() TokenFilter {
+	return TokenFilterFunc(func(tokens []Token) []Token {
+		for i := range tokens {
+			switch tokens[i].Type {
+			case TokenComment, TokenString:
+				tokens[i].materialize()
+				tokens[i].Text = strings.Join(strings.Fields(tokens[i].Text), " ")
+			}
+		}
+		return tokens
+	})
+}
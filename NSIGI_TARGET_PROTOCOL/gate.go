@@ -0,0 +1,89 @@
+package nsigii
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ============================================================================
+// Stage Gates
+// ============================================================================
+
+// ErrGateBlocked is matched, via errors.Is, by every *GateError
+var ErrGateBlocked = errors.New("stage gate blocked")
+
+// GateError reports a pipeline refused passage from one stage to the next
+type GateError struct {
+	From   StageID
+	To     StageID
+	State  ColorChannel // Color state of the context when blocked
+	Reason string
+}
+
+func (e *GateError) Error() string {
+	return fmt.Sprintf("gate %s -> %s blocked: %s", e.From, e.To, e.Reason)
+}
+
+// Is reports whether target is ErrGateBlocked
+func (e *GateError) Is(target error) bool {
+	return target == ErrGateBlocked
+}
+
+// Gate decides whether a pipeline may advance from stage from to stage to.
+// It returns "" to allow passage, or the reason for blocking it.
+type Gate interface {
+	Allow(c *Context, from, to StageID, u *Unit) (reason string)
+}
+
+// GateFunc adapts a function to a Gate
+type GateFunc func(c *Context, from, to StageID, u *Unit) string
+
+// Allow calls f(c, from, to, u)
+func (f GateFunc) Allow(c *Context, from, to StageID, u *Unit) string {
+	return f(c, from, to, u)
+}
+
+// ZeroTrustGate lets a pipeline advance only while the context is not
+// BLACK and reaches RGB consensus (see VerifyRGBConsensus), re-checked at
+// every stage boundary
+var ZeroTrustGate Gate = GateFunc(func(c *Context, from, to StageID, u *Unit) string {
+	if state := c.ColorState(); state == ColorBlack {
+		return "color state is BLACK"
+	}
+
+	ok, err := c.VerifyRGBConsensus()
+	if err != nil {
+		return "consensus check failed: " + err.Error()
+	}
+	if !ok {
+		return "RGB consensus not reached"
+	}
+	return ""
+})
+
+// SetGate makes the pipeline consult gate before advancing from each stage
+// to the next, failing the run with a *GateError when it refuses. Stages
+// served from the cache pass through the gate too. A nil gate removes it.
+//
+// Example:
+//   p.SetGate(nsigii.ZeroTrustGate)
+//   _, _, err := p.Run(source)
+//   if errors.Is(err, nsigii.ErrGateBlocked) {
+//       log.Printf("pipeline halted: %v", err)
+//   }
+func (p *StagePipeline) SetGate(gate Gate) {
+	p.gate = gate
+}
+
+// checkGate consults the pipeline's gate for the boundary before stage i
+func (p *StagePipeline) checkGate(i int, u *Unit) error {
+	if p.gate == nil || i == 0 {
+		return nil
+	}
+
+	from, to := p.stages[i-1].ID(), p.stages[i].ID()
+	if reason := p.gate.Allow(p.ctx, from, to, u); reason != "" {
+		return &GateError{From: from, To: to, State: p.ctx.ColorState(), Reason: reason}
+	}
+	return nil
+}
@@ -0,0 +1,806 @@
+package nsigii
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ============================================================================
+// Grammar Definitions
+// ============================================================================
+
+// Grammar declares a custom language for RIFT pipelines: its statement
+// rules, expression operators and token classes. Compile turns it into the
+// lexer profile and parser a context uses after SetGrammar.
+//
+// A rule's Pattern is a sequence of elements separated by spaces:
+//   "let" ";"            literal keyword, operator or delimiter
+//   IDENT NUMBER STRING  a token of that class
+//   expr block stmt      an expression, a block of statements, a statement
+//   name                 another rule
+//   ( ... )              a group
+//
+// Any element may be followed by ? (optional), * (zero or more) or + (one
+// or more), and any element but a literal or group may be labelled, as in
+// name:IDENT, to be found again with RuleNode.Field.
+//
+// Rules that are not fragments are statements. A statement rule starting
+// with a literal is chosen by that literal; the others are tried in order.
+//
+// Example:
+//   g, err := nsigii.Grammar{
+//       Name: "config",
+//       Rules: []nsigii.Rule{
+//           {Name: "set", Pattern: `"set" name:IDENT "=" value:expr ";"`},
+//           {Name: "section", Pattern: `"section" name:IDENT body:block`},
+//       },
+//       Precedence: []nsigii.OpLevel{{Ops: []string{"+", "-"}}, {Ops: []string{"*", "/"}}},
+//   }.Compile()
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   ctx.SetGrammar(g)
+//   prog, err := ctx.Parse("section net { set port = 8000 + 80; }")
+type Grammar struct {
+	Name       string
+	Rules      []Rule
+	Precedence []OpLevel // Binary operators, loosest first
+	Prefix     []string  // Unary prefix operators
+	Literals   []string  // Words parsed as literal values, e.g. "true"
+	Calls      bool      // Parse f(a, b) call expressions
+	Block      [2]string // Open and close of a block; default {"{", "}"}
+
+	// Comments, quotes and delimiters; RIFTProfile's if nil. Words and
+	// multi-byte symbols used by the grammar are added to its keywords and
+	// operators.
+	Tokens *LanguageProfile
+}
+
+// Rule is a named pattern of a Grammar
+type Rule struct {
+	Name     string
+	Pattern  string
+	Fragment bool // Only used from other rules, never as a statement
+}
+
+// OpLevel is a set of binary operators sharing one precedence
+type OpLevel struct {
+	Ops   []string
+	Right bool // Right-associative
+}
+
+// CompiledGrammar is a Grammar ready to tokenize and parse with
+type CompiledGrammar struct {
+	name     string
+	profile  LanguageProfile
+	rules    []*grammarRule
+	dispatch map[string]*grammarRule // statement rules by leading literal
+	fallback []*grammarRule          // statement rules tried in order
+	levels   []OpLevel
+	prefix   map[string]bool
+	literals map[string]bool
+	calls    bool
+	block    [2]string
+}
+
+// grammarRule is a compiled Rule
+type grammarRule struct {
+	name     string
+	elems    []grammarElem
+	fragment bool
+}
+
+type grammarKind int
+
+const (
+	elemLiteral grammarKind = iota
+	elemClass
+	elemExpr
+	elemBlock
+	elemStmt
+	elemRule
+	elemGroup
+)
+
+// grammarElem is one element of a compiled pattern
+type grammarElem struct {
+	kind     grammarKind
+	text     string        // elemLiteral: the literal; elemRule: rule name while compiling
+	class    TokenType     // elemClass
+	rule     *grammarRule  // elemRule
+	group    []grammarElem // elemGroup
+	label    string
+	rep      byte // 0, '?', '*' or '+'
+	nullable bool // can match no tokens
+}
+
+// grammarClasses maps pattern class names to token types
+var grammarClasses = map[string]TokenType{
+	"IDENT":      TokenIdentifier,
+	"IDENTIFIER": TokenIdentifier,
+	"NUMBER":     TokenNumber,
+	"STRING":     TokenString,
+}
+
+// Name returns the grammar's name
+func (g *CompiledGrammar) Name() string {
+	return g.name
+}
+
+// Profile returns the lexer profile compiled from the grammar
+func (g *CompiledGrammar) Profile() LanguageProfile {
+	return g.profile
+}
+
+// Compile checks the grammar and builds its lexer profile and parser
+func (g Grammar) Compile() (*CompiledGrammar, error) {
+	cg := &CompiledGrammar{
+		name:     g.Name,
+		dispatch: make(map[string]*grammarRule),
+		levels:   g.Precedence,
+		prefix:   make(map[string]bool, len(g.Prefix)),
+		literals: make(map[string]bool, len(g.Literals)),
+		calls:    g.Calls,
+		block:    g.Block,
+	}
+	if cg.block == [2]string{} {
+		cg.block = [2]string{"{", "}"}
+	}
+	if cg.block[0] == "" || cg.block[1] == "" {
+		return nil, fmt.Errorf("grammar %s: incomplete block delimiters", g.Name)
+	}
+
+	index := make(map[string]*grammarRule, len(g.Rules))
+	for _, r := range g.Rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("grammar %s: unnamed rule", g.Name)
+		}
+		if _, ok := grammarClasses[r.Name]; ok || r.Name == "expr" || r.Name == "block" || r.Name == "stmt" {
+			return nil, fmt.Errorf("grammar %s: rule name %q is reserved", g.Name, r.Name)
+		}
+		if index[r.Name] != nil {
+			return nil, fmt.Errorf("grammar %s: duplicate rule %q", g.Name, r.Name)
+		}
+		gr := &grammarRule{name: r.Name, fragment: r.Fragment}
+		index[r.Name] = gr
+		cg.rules = append(cg.rules, gr)
+	}
+
+	words := make(map[string]bool)
+	symbols := make(map[string]bool)
+	addLiteral := func(s string) {
+		if r, _ := utf8.DecodeRuneInString(s); r == '_' || unicode.IsLetter(r) {
+			words[s] = true
+		} else if len(s) > 1 {
+			symbols[s] = true
+		}
+	}
+
+	for i, r := range g.Rules {
+		gr := cg.rules[i]
+		elems, err := parsePattern(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("grammar %s: rule %s: %w", g.Name, r.Name, err)
+		}
+		if len(elems) == 0 {
+			return nil, fmt.Errorf("grammar %s: rule %s: empty pattern", g.Name, r.Name)
+		}
+		if err := resolvePattern(elems, index, addLiteral); err != nil {
+			return nil, fmt.Errorf("grammar %s: rule %s: %w", g.Name, r.Name, err)
+		}
+		gr.elems = elems
+	}
+
+	// Nullability needs every rule resolved; rules are never nullable, so
+	// a single pass suffices
+	for _, gr := range cg.rules {
+		markNullable(gr.elems)
+		if seqNullable(gr.elems) {
+			return nil, fmt.Errorf("grammar %s: rule %s can match no tokens", g.Name, gr.name)
+		}
+		if err := checkRepeats(gr.elems); err != nil {
+			return nil, fmt.Errorf("grammar %s: rule %s: %w", g.Name, gr.name, err)
+		}
+	}
+
+	for _, gr := range cg.rules {
+		if gr.fragment {
+			continue
+		}
+		first := gr.elems[0]
+		if first.kind == elemLiteral && !first.nullable {
+			if other := cg.dispatch[first.text]; other != nil {
+				return nil, fmt.Errorf("grammar %s: rules %s and %s both start with %q",
+					g.Name, other.name, gr.name, first.text)
+			}
+			cg.dispatch[first.text] = gr
+		} else {
+			cg.fallback = append(cg.fallback, gr)
+		}
+	}
+	if len(cg.dispatch) == 0 && len(cg.fallback) == 0 {
+		return nil, fmt.Errorf("grammar %s: no statement rules", g.Name)
+	}
+	if err := cg.checkLeftRecursion(); err != nil {
+		return nil, err
+	}
+
+	for _, level := range g.Precedence {
+		for _, op := range level.Ops {
+			addLiteral(op)
+		}
+	}
+	for _, op := range g.Prefix {
+		addLiteral(op)
+		cg.prefix[op] = true
+	}
+	for _, lit := range g.Literals {
+		addLiteral(lit)
+		cg.literals[lit] = true
+	}
+	addLiteral(cg.block[0])
+	addLiteral(cg.block[1])
+
+	base := RIFTProfile
+	base.Keywords = nil
+	if g.Tokens != nil {
+		base = *g.Tokens
+	}
+	cg.profile = base
+	cg.profile.Name = g.Name
+	cg.profile.Keywords = mergeWords(base.Keywords, words)
+	for s := range symbols {
+		if slices.Contains(base.Delimiters, s) {
+			delete(symbols, s)
+		}
+	}
+	cg.profile.Operators = mergeWords(base.Operators, symbols)
+	if err := cg.profile.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cg, nil
+}
+
+// mergeWords returns list plus the members of set it lacks, in a stable
+// order
+func mergeWords(list []string, set map[string]bool) []string {
+	out := append([]string(nil), list...)
+	var extra []string
+	for w := range set {
+		if !slices.Contains(list, w) {
+			extra = append(extra, w)
+		}
+	}
+	slices.Sort(extra)
+	return append(out, extra...)
+}
+
+// parsePattern splits a rule pattern into elements. Rule references are
+// left unresolved, with the rule name in text.
+func parsePattern(pattern string) ([]grammarElem, error) {
+	ps := patternScanner{s: pattern}
+	elems, err := ps.seq()
+	if err != nil {
+		return nil, err
+	}
+	if ps.i < len(ps.s) {
+		return nil, fmt.Errorf("unbalanced %q at offset %d", ps.s[ps.i], ps.i)
+	}
+	return elems, nil
+}
+
+// patternScanner reads a rule pattern
+type patternScanner struct {
+	s string
+	i int
+}
+
+func (ps *patternScanner) skipSpace() {
+	for ps.i < len(ps.s) && (ps.s[ps.i] == ' ' || ps.s[ps.i] == '\t' || ps.s[ps.i] == '\n') {
+		ps.i++
+	}
+}
+
+// seq reads elements up to the end of the pattern or a closing ")"
+func (ps *patternScanner) seq() ([]grammarElem, error) {
+	var elems []grammarElem
+	for {
+		ps.skipSpace()
+		if ps.i >= len(ps.s) || ps.s[ps.i] == ')' {
+			return elems, nil
+		}
+		e, err := ps.elem()
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, e)
+	}
+}
+
+func (ps *patternScanner) elem() (grammarElem, error) {
+	var e grammarElem
+	start := ps.i
+
+	switch c := ps.s[ps.i]; {
+	case c == '"' || c == '\'':
+		end := strings.IndexByte(ps.s[ps.i+1:], c)
+		if end < 0 {
+			return e, fmt.Errorf("unterminated literal at offset %d", start)
+		}
+		e.kind, e.text = elemLiteral, ps.s[ps.i+1:ps.i+1+end]
+		if e.text == "" || strings.ContainsAny(e.text, " \t\n") {
+			return e, fmt.Errorf("literal at offset %d must be one non-empty token", start)
+		}
+		ps.i += end + 2
+	case c == '(':
+		ps.i++
+		group, err := ps.seq()
+		if err != nil {
+			return e, err
+		}
+		if ps.i >= len(ps.s) {
+			return e, fmt.Errorf("unclosed group at offset %d", start)
+		}
+		if len(group) == 0 {
+			return e, fmt.Errorf("empty group at offset %d", start)
+		}
+		ps.i++
+		e.kind, e.group = elemGroup, group
+	case isPatternWord(c):
+		name := ps.word()
+		if ps.i < len(ps.s) && ps.s[ps.i] == ':' {
+			ps.i++
+			if ps.i >= len(ps.s) || !isPatternWord(ps.s[ps.i]) {
+				return e, fmt.Errorf("label %q must be followed by a class, builtin or rule", name)
+			}
+			e.label = name
+			name = ps.word()
+		}
+		e.text = name
+		if class, ok := grammarClasses[name]; ok {
+			e.kind, e.class = elemClass, class
+		} else {
+			switch name {
+			case "expr":
+				e.kind = elemExpr
+			case "block":
+				e.kind = elemBlock
+			case "stmt":
+				e.kind = elemStmt
+			default:
+				e.kind = elemRule
+			}
+		}
+	default:
+		return e, fmt.Errorf("unexpected %q at offset %d", c, start)
+	}
+
+	if ps.i < len(ps.s) && strings.IndexByte("?*+", ps.s[ps.i]) >= 0 {
+		e.rep = ps.s[ps.i]
+		ps.i++
+	}
+	return e, nil
+}
+
+func (ps *patternScanner) word() string {
+	start := ps.i
+	for ps.i < len(ps.s) && isPatternWord(ps.s[ps.i]) {
+		ps.i++
+	}
+	return ps.s[start:ps.i]
+}
+
+func isPatternWord(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// resolvePattern links rule references and reports literals to addLiteral
+func resolvePattern(elems []grammarElem, index map[string]*grammarRule, addLiteral func(string)) error {
+	for i := range elems {
+		e := &elems[i]
+		switch e.kind {
+		case elemLiteral:
+			addLiteral(e.text)
+		case elemRule:
+			if e.rule = index[e.text]; e.rule == nil {
+				return fmt.Errorf("undefined rule %q", e.text)
+			}
+		case elemGroup:
+			if err := resolvePattern(e.group, index, addLiteral); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// markNullable sets nullable on every element of elems
+func markNullable(elems []grammarElem) {
+	for i := range elems {
+		e := &elems[i]
+		if e.kind == elemGroup {
+			markNullable(e.group)
+		}
+		switch {
+		case e.rep == '?' || e.rep == '*':
+			e.nullable = true
+		case e.kind == elemGroup:
+			e.nullable = seqNullable(e.group)
+		}
+	}
+}
+
+// seqNullable reports whether every element of elems can match no tokens
+func seqNullable(elems []grammarElem) bool {
+	for _, e := range elems {
+		if !e.nullable {
+			return false
+		}
+	}
+	return true
+}
+
+// checkRepeats rejects repetitions whose body can match no tokens, which
+// would never end
+func checkRepeats(elems []grammarElem) error {
+	for _, e := range elems {
+		if e.kind != elemGroup {
+			continue
+		}
+		if (e.rep == '*' || e.rep == '+') && seqNullable(e.group) {
+			return fmt.Errorf("repeated group can match no tokens")
+		}
+		if err := checkRepeats(e.group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkLeftRecursion rejects rules that can reach themselves without
+// consuming a token, which the parser would follow forever
+func (g *CompiledGrammar) checkLeftRecursion() error {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[*grammarRule]int, len(g.rules))
+
+	var visit func(r *grammarRule) error
+	var leading func(elems []grammarElem) error
+	leading = func(elems []grammarElem) error {
+		for _, e := range elems {
+			var err error
+			switch e.kind {
+			case elemRule:
+				err = visit(e.rule)
+			case elemStmt:
+				for _, s := range g.fallback {
+					if err = visit(s); err != nil {
+						break
+					}
+				}
+			case elemGroup:
+				err = leading(e.group)
+			}
+			if err != nil || !e.nullable {
+				return err
+			}
+		}
+		return nil
+	}
+	visit = func(r *grammarRule) error {
+		switch state[r] {
+		case visiting:
+			return fmt.Errorf("grammar %s: rule %s is left-recursive", g.name, r.name)
+		case done:
+			return nil
+		}
+		state[r] = visiting
+		if err := leading(r.elems); err != nil {
+			return err
+		}
+		state[r] = done
+		return nil
+	}
+
+	for _, r := range g.rules {
+		if err := visit(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ============================================================================
+// Grammar Parse Trees
+// ============================================================================
+
+// RuleNode is the match of a grammar rule
+type RuleNode struct {
+	Rule   string
+	First  Token    // First token of the match
+	Last   Token    // Last token of the match
+	Tokens []Token  // Literal tokens matched
+	Nodes  []Node   // Matched child nodes in source order
+	Labels []string // Label of each child node, "" if unlabelled
+}
+
+// Field returns the child nodes labelled name
+func (n *RuleNode) Field(name string) []Node {
+	var out []Node
+	for i, label := range n.Labels {
+		if label == name {
+			out = append(out, n.Nodes[i])
+		}
+	}
+	return out
+}
+
+func (n *RuleNode) Pos() Position    { return tokenPos(n.First) }
+func (n *RuleNode) End() uint32      { return n.Last.EndOffset }
+func (n *RuleNode) Children() []Node { return n.Nodes }
+
+func (*RuleNode) stmtNode() {}
+
+// ============================================================================
+// Grammar Parser
+// ============================================================================
+
+// Parse parses a token stream with the grammar. A failure is returned as a
+// *SyntaxError.
+func (g *CompiledGrammar) Parse(tokens []Token) (*Program, error) {
+	p := &grammarParser{parser: parser{tokens: tokens, pos: -1}, g: g}
+	return p.program(p.stmt)
+}
+
+// SetGrammar switches the context to the language described by g: its lexer
+// profile is installed and Parse uses its parser. A nil g restores the RIFT
+// language.
+func (c *Context) SetGrammar(g *CompiledGrammar) error {
+	if err := c.require(RoleAdmin, "SetGrammar"); err != nil {
+		return err
+	}
+	if g == nil {
+		c.grammar = nil
+		c.profile = nil
+		c.rebuildLexer()
+		return nil
+	}
+
+	if err := c.SetProfile(g.profile); err != nil {
+		return err
+	}
+	c.grammar = g
+	return nil
+}
+
+// Grammar returns the context's grammar, or nil for the RIFT language
+func (c *Context) Grammar() *CompiledGrammar {
+	return c.grammar
+}
+
+// grammarParser parses with the rules of a compiled grammar
+type grammarParser struct {
+	parser
+	g *CompiledGrammar
+}
+
+func (p *grammarParser) stmt() Stmt {
+	if r := p.g.dispatch[p.tok.Lexeme()]; r != nil && p.is(p.tok.Lexeme()) {
+		return p.rule(r)
+	}
+	for _, r := range p.g.fallback {
+		if p.startsSeq(r.elems) {
+			return p.rule(r)
+		}
+	}
+	p.unexpected("statement")
+	return nil
+}
+
+func (p *grammarParser) rule(r *grammarRule) *RuleNode {
+	n := &RuleNode{Rule: r.name, First: p.tok}
+	p.seq(n, r.elems)
+	n.Last = p.last
+	return n
+}
+
+func (p *grammarParser) seq(n *RuleNode, elems []grammarElem) {
+	for i := range elems {
+		e := &elems[i]
+		switch e.rep {
+		case '?':
+			if p.starts(e) {
+				p.one(n, e)
+			}
+		case '*':
+			for p.starts(e) {
+				p.one(n, e)
+			}
+		case '+':
+			p.one(n, e)
+			for p.starts(e) {
+				p.one(n, e)
+			}
+		default:
+			p.one(n, e)
+		}
+	}
+}
+
+// one matches a single occurrence of e
+func (p *grammarParser) one(n *RuleNode, e *grammarElem) {
+	var child Node
+	switch e.kind {
+	case elemLiteral:
+		n.Tokens = append(n.Tokens, p.expect(e.text))
+		return
+	case elemGroup:
+		p.seq(n, e.group)
+		return
+	case elemClass:
+		if p.tok.Type != e.class {
+			p.unexpected(e.text)
+		}
+		if e.class == TokenIdentifier {
+			child = &Ident{Token: p.tok}
+		} else {
+			child = &Literal{Token: p.tok}
+		}
+		p.next()
+	case elemExpr:
+		child = p.expr()
+	case elemBlock:
+		child = p.block()
+	case elemStmt:
+		child = p.stmt()
+	case elemRule:
+		child = p.rule(e.rule)
+	}
+
+	n.Nodes = append(n.Nodes, child)
+	n.Labels = append(n.Labels, e.label)
+}
+
+// starts reports whether the current token can begin a match of e
+func (p *grammarParser) starts(e *grammarElem) bool {
+	switch e.kind {
+	case elemLiteral:
+		return p.is(e.text)
+	case elemClass:
+		return p.tok.Type == e.class
+	case elemExpr:
+		return p.startsExpr()
+	case elemBlock:
+		return p.is(p.g.block[0])
+	case elemStmt:
+		if r := p.g.dispatch[p.tok.Lexeme()]; r != nil && p.is(p.tok.Lexeme()) {
+			return true
+		}
+		for _, r := range p.g.fallback {
+			if p.startsSeq(r.elems) {
+				return true
+			}
+		}
+		return false
+	case elemRule:
+		return p.startsSeq(e.rule.elems)
+	case elemGroup:
+		return p.startsSeq(e.group)
+	}
+	return false
+}
+
+func (p *grammarParser) startsSeq(elems []grammarElem) bool {
+	for i := range elems {
+		if p.starts(&elems[i]) {
+			return true
+		}
+		if !elems[i].nullable {
+			return false
+		}
+	}
+	return false
+}
+
+func (p *grammarParser) block() *Block {
+	b := &Block{Lbrace: p.expect(p.g.block[0])}
+	for !p.is(p.g.block[1]) {
+		if p.tok.Type == TokenEOF {
+			p.unexpected(fmt.Sprintf("%q", p.g.block[1]))
+		}
+		b.Stmts = append(b.Stmts, p.stmt())
+	}
+	b.Rbrace = p.expect(p.g.block[1])
+	return b
+}
+
+// expr parses an expression with the grammar's operator table
+func (p *grammarParser) expr() Expr {
+	return p.binaryExpr(0)
+}
+
+func (p *grammarParser) binaryExpr(level int) Expr {
+	if level == len(p.g.levels) {
+		return p.unaryExpr()
+	}
+
+	x := p.binaryExpr(level + 1)
+	for p.isOp(p.g.levels[level].Ops) {
+		op := p.tok
+		p.next()
+		if p.g.levels[level].Right {
+			return &BinaryExpr{X: x, Op: op, Y: p.binaryExpr(level)}
+		}
+		x = &BinaryExpr{X: x, Op: op, Y: p.binaryExpr(level + 1)}
+	}
+	return x
+}
+
+func (p *grammarParser) isOp(ops []string) bool {
+	for _, op := range ops {
+		if p.is(op) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *grammarParser) unaryExpr() Expr {
+	if p.g.prefix[p.tok.Lexeme()] && p.is(p.tok.Lexeme()) {
+		op := p.tok
+		p.next()
+		return &UnaryExpr{Op: op, X: p.unaryExpr()}
+	}
+
+	x := p.primaryExpr()
+	for p.g.calls && p.is("(") {
+		call := &CallExpr{Fun: x, Lparen: p.tok}
+		p.next()
+		for !p.is(")") {
+			if len(call.Args) > 0 {
+				p.expect(",")
+			}
+			call.Args = append(call.Args, p.expr())
+		}
+		call.Rparen = p.expect(")")
+		x = call
+	}
+	return x
+}
+
+func (p *grammarParser) primaryExpr() Expr {
+	switch {
+	case p.tok.Type == TokenIdentifier:
+		return p.ident()
+	case p.tok.Type == TokenNumber, p.tok.Type == TokenString,
+		p.g.literals[p.tok.Lexeme()] && p.is(p.tok.Lexeme()):
+		lit := &Literal{Token: p.tok}
+		p.next()
+		return lit
+	case p.is("("):
+		paren := &ParenExpr{Lparen: p.tok}
+		p.next()
+		paren.X = p.expr()
+		paren.Rparen = p.expect(")")
+		return paren
+	}
+
+	p.unexpected("expression")
+	return nil
+}
+
+// startsExpr reports whether the current token can begin an expression
+func (p *grammarParser) startsExpr() bool {
+	switch p.tok.Type {
+	case TokenIdentifier, TokenNumber, TokenString:
+		return true
+	}
+	return p.is("(") || (p.is(p.tok.Lexeme()) && (p.g.prefix[p.tok.Lexeme()] || p.g.literals[p.tok.Lexeme()]))
+}
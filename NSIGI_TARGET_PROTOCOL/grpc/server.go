@@ -0,0 +1,249 @@
+// Package nsigiigrpc serves NSIGII tokenization over gRPC, so services
+// written in other languages can use the framework over the network. The
+// service is defined in tokenizerpb/tokenizer.proto, on the messages of
+// nsigiipb/nsigii.proto in the parent directory; clients generate their
+// stubs from those files.
+//
+// Example:
+//   pool := nsigii.NewContextPool(nsigii.PoolOptions{MaxSize: 16})
+//   defer pool.Close()
+//
+//   g := grpc.NewServer()
+//   nsigiigrpc.NewServer(nsigiigrpc.Options{Pool: pool}).Register(g)
+//   g.Serve(listener)
+package nsigiigrpc
+
+//go:generate protoc -I .. --go_out=.. --go_opt=paths=source_relative --go-grpc_out=.. --go-grpc_opt=paths=source_relative grpc/tokenizerpb/tokenizer.proto
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/obinexus/nsigii-rift/nsigii"
+	"github.com/obinexus/nsigii-rift/nsigii/grpc/tokenizerpb"
+	"github.com/obinexus/nsigii-rift/nsigii/nsigiipb"
+)
+
+// Options configures a Server
+type Options struct {
+	// Pool supplies the contexts requests run on. Nil creates a pool with
+	// default options that the server owns; see Server.Close.
+	Pool *nsigii.ContextPool
+
+	Operation string // Default schema operation; "" means "tokenize"
+	Service   string // Default schema service; "" means "lexer"
+
+	// MaxSourceBytes rejects larger sources with ResourceExhausted; 0 means
+	// no limit
+	MaxSourceBytes int
+
+	// Peers, if set, refuses every call with PermissionDenied unless it
+	// arrives over TLS with a verified client certificate bound to the
+	// requested schema (see nsigii.PeerRegistry). The gRPC server must be
+	// given TLS credentials that require and verify client certificates.
+	// The peer's phantom ID is the caller the context's policy sees.
+	Peers *nsigii.PeerRegistry
+}
+
+// Server implements tokenizerpb.TokenizerServer on top of a context pool
+type Server struct {
+	tokenizerpb.UnimplementedTokenizerServer
+
+	opts    Options
+	ownPool bool
+}
+
+// NewServer creates a server
+func NewServer(opts Options) *Server {
+	s := &Server{opts: opts}
+	if s.opts.Pool == nil {
+		s.opts.Pool = nsigii.NewContextPool(nsigii.PoolOptions{})
+		s.ownPool = true
+	}
+	if s.opts.Operation == "" {
+		s.opts.Operation = "tokenize"
+	}
+	if s.opts.Service == "" {
+		s.opts.Service = "lexer"
+	}
+	return s
+}
+
+// Register registers the Tokenizer service on g
+func (s *Server) Register(g grpc.ServiceRegistrar) {
+	tokenizerpb.RegisterTokenizerServer(g, s)
+}
+
+// Close closes the server's pool if the server created it
+func (s *Server) Close() error {
+	if s.ownPool {
+		return s.opts.Pool.Close()
+	}
+	return nil
+}
+
+// Tokenize implements tokenizerpb.TokenizerServer
+func (s *Server) Tokenize(ctx context.Context, req *tokenizerpb.TokenizeRequest) (*tokenizerpb.TokenizeResponse, error) {
+	resp := &tokenizerpb.TokenizeResponse{}
+	err := s.tokenize(ctx, req.GetOperation(), req.GetService(), req.GetProfile(), req.GetSource(),
+		func(schema string, tokens []nsigii.Token) {
+			resp.Schema = schema
+			resp.Tokens = nsigiipb.FromTokens(tokens)
+		})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Analyze implements tokenizerpb.TokenizerServer
+func (s *Server) Analyze(ctx context.Context, req *tokenizerpb.AnalyzeRequest) (*tokenizerpb.AnalyzeResponse, error) {
+	resp := &tokenizerpb.AnalyzeResponse{}
+	err := s.tokenize(ctx, req.GetOperation(), req.GetService(), req.GetProfile(), req.GetSource(),
+		func(schema string, tokens []nsigii.Token) {
+			resp.Schema = schema
+			resp.Stats = nsigiipb.FromTokenStats(nsigii.AnalyzeTokens(tokens))
+		})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// VerifyConsensus implements tokenizerpb.TokenizerServer
+func (s *Server) VerifyConsensus(ctx context.Context, req *tokenizerpb.VerifyConsensusRequest) (*tokenizerpb.VerifyConsensusResponse, error) {
+	c, err := s.get(ctx, req.GetOperation(), req.GetService())
+	if err != nil {
+		return nil, err
+	}
+	defer s.opts.Pool.Put(c)
+
+	schema, err := c.Schema()
+	if err != nil {
+		return nil, statusError(err)
+	}
+	passed, err := c.VerifyRGBConsensus()
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &tokenizerpb.VerifyConsensusResponse{
+		Schema: schema,
+		Passed: passed,
+		State:  nsigiipb.ColorChannel(c.ColorState()),
+	}, nil
+}
+
+// tokenize runs source through a pooled context and hands the result to
+// done while the context is still held
+func (s *Server) tokenize(ctx context.Context, operation, service, profile, source string,
+	done func(schema string, tokens []nsigii.Token)) error {
+	if s.opts.MaxSourceBytes > 0 && len(source) > s.opts.MaxSourceBytes {
+		return status.Errorf(codes.ResourceExhausted, "source is %d bytes, limit is %d",
+			len(source), s.opts.MaxSourceBytes)
+	}
+
+	var lp nsigii.LanguageProfile
+	if profile != "" {
+		var ok bool
+		if lp, ok = nsigii.LookupProfile(profile); !ok {
+			return status.Errorf(codes.InvalidArgument, "unknown language profile %q", profile)
+		}
+	}
+
+	c, err := s.get(ctx, operation, service)
+	if err != nil {
+		return err
+	}
+	defer s.opts.Pool.Put(c) // Put restores the pool's settings
+
+	if profile != "" {
+		if err := c.SetProfile(lp); err != nil {
+			return statusError(err)
+		}
+	}
+
+	schema, err := c.Schema()
+	if err != nil {
+		return statusError(err)
+	}
+	tokens, err := c.Tokenize(source)
+	if err != nil {
+		return statusError(err)
+	}
+
+	done(schema, tokens)
+	return nil
+}
+
+// get takes a context for the request's schema from the pool
+func (s *Server) get(ctx context.Context, operation, service string) (*nsigii.Context, error) {
+	if operation == "" {
+		operation = s.opts.Operation
+	}
+	if service == "" {
+		service = s.opts.Service
+	}
+
+	c, err := s.opts.Pool.Get(ctx, operation, service)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if s.opts.Peers != nil {
+		b, err := s.opts.Peers.Authorize(c, tlsState(ctx))
+		if err != nil {
+			s.opts.Pool.Put(c)
+			return nil, statusError(err)
+		}
+		if err := c.SetCaller(b.PhantomID); err != nil { // Cleared by Put
+			s.opts.Pool.Put(c)
+			return nil, statusError(err)
+		}
+	}
+	return c, nil
+}
+
+// tlsState returns the TLS state of the call's connection, or nil if it
+// did not arrive over TLS
+func tlsState(ctx context.Context) *tls.ConnectionState {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil
+	}
+	return &info.State
+}
+
+// statusError maps package errors to gRPC status codes
+func statusError(err error) error {
+	var cerr *nsigii.CError
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	case errors.Is(err, nsigii.ErrTokenBufferFull):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, nsigii.ErrInvalidUTF8):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, nsigii.ErrTerminated):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, nsigii.ErrPeerUnauthorized), errors.Is(err, nsigii.ErrPolicyDenied),
+		errors.Is(err, nsigii.ErrForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, nsigii.ErrContextClosed), errors.Is(err, nsigii.ErrPoolClosed):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.As(err, &cerr):
+		return status.Error(codes.Internal, err.Error())
+	}
+	return status.Error(codes.Unknown, fmt.Sprint(err))
+}
@@ -0,0 +1,477 @@
+// NSIGII RIFT tokenization service
+//
+// Regenerate the Go code with `go generate` in the parent directory.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: grpc/tokenizerpb/tokenizer.proto
+
+package tokenizerpb
+
+import (
+	nsigiipb "github.com/obinexus/nsigii-rift/nsigii/nsigiipb"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type TokenizeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Operation     string                 `protobuf:"bytes,1,opt,name=operation,proto3" json:"operation,omitempty"`
+	Service       string                 `protobuf:"bytes,2,opt,name=service,proto3" json:"service,omitempty"`
+	Source        string                 `protobuf:"bytes,3,opt,name=source,proto3" json:"source,omitempty"`
+	Profile       string                 `protobuf:"bytes,4,opt,name=profile,proto3" json:"profile,omitempty"` // Built-in language profile, e.g. "go"; empty for RIFT
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TokenizeRequest) Reset() {
+	*x = TokenizeRequest{}
+	mi := &file_grpc_tokenizerpb_tokenizer_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TokenizeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TokenizeRequest) ProtoMessage() {}
+
+func (x *TokenizeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grpc_tokenizerpb_tokenizer_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TokenizeRequest.ProtoReflect.Descriptor instead.
+func (*TokenizeRequest) Descriptor() ([]byte, []int) {
+	return file_grpc_tokenizerpb_tokenizer_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *TokenizeRequest) GetOperation() string {
+	if x != nil {
+		return x.Operation
+	}
+	return ""
+}
+
+func (x *TokenizeRequest) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+func (x *TokenizeRequest) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *TokenizeRequest) GetProfile() string {
+	if x != nil {
+		return x.Profile
+	}
+	return ""
+}
+
+type TokenizeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Schema        string                 `protobuf:"bytes,1,opt,name=schema,proto3" json:"schema,omitempty"`
+	Tokens        []*nsigiipb.Token      `protobuf:"bytes,2,rep,name=tokens,proto3" json:"tokens,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TokenizeResponse) Reset() {
+	*x = TokenizeResponse{}
+	mi := &file_grpc_tokenizerpb_tokenizer_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TokenizeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TokenizeResponse) ProtoMessage() {}
+
+func (x *TokenizeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_grpc_tokenizerpb_tokenizer_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TokenizeResponse.ProtoReflect.Descriptor instead.
+func (*TokenizeResponse) Descriptor() ([]byte, []int) {
+	return file_grpc_tokenizerpb_tokenizer_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *TokenizeResponse) GetSchema() string {
+	if x != nil {
+		return x.Schema
+	}
+	return ""
+}
+
+func (x *TokenizeResponse) GetTokens() []*nsigiipb.Token {
+	if x != nil {
+		return x.Tokens
+	}
+	return nil
+}
+
+type AnalyzeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Operation     string                 `protobuf:"bytes,1,opt,name=operation,proto3" json:"operation,omitempty"`
+	Service       string                 `protobuf:"bytes,2,opt,name=service,proto3" json:"service,omitempty"`
+	Source        string                 `protobuf:"bytes,3,opt,name=source,proto3" json:"source,omitempty"`
+	Profile       string                 `protobuf:"bytes,4,opt,name=profile,proto3" json:"profile,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnalyzeRequest) Reset() {
+	*x = AnalyzeRequest{}
+	mi := &file_grpc_tokenizerpb_tokenizer_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnalyzeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalyzeRequest) ProtoMessage() {}
+
+func (x *AnalyzeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grpc_tokenizerpb_tokenizer_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalyzeRequest.ProtoReflect.Descriptor instead.
+func (*AnalyzeRequest) Descriptor() ([]byte, []int) {
+	return file_grpc_tokenizerpb_tokenizer_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *AnalyzeRequest) GetOperation() string {
+	if x != nil {
+		return x.Operation
+	}
+	return ""
+}
+
+func (x *AnalyzeRequest) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+func (x *AnalyzeRequest) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *AnalyzeRequest) GetProfile() string {
+	if x != nil {
+		return x.Profile
+	}
+	return ""
+}
+
+type AnalyzeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Schema        string                 `protobuf:"bytes,1,opt,name=schema,proto3" json:"schema,omitempty"`
+	Stats         *nsigiipb.TokenStats   `protobuf:"bytes,2,opt,name=stats,proto3" json:"stats,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnalyzeResponse) Reset() {
+	*x = AnalyzeResponse{}
+	mi := &file_grpc_tokenizerpb_tokenizer_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnalyzeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalyzeResponse) ProtoMessage() {}
+
+func (x *AnalyzeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_grpc_tokenizerpb_tokenizer_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalyzeResponse.ProtoReflect.Descriptor instead.
+func (*AnalyzeResponse) Descriptor() ([]byte, []int) {
+	return file_grpc_tokenizerpb_tokenizer_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *AnalyzeResponse) GetSchema() string {
+	if x != nil {
+		return x.Schema
+	}
+	return ""
+}
+
+func (x *AnalyzeResponse) GetStats() *nsigiipb.TokenStats {
+	if x != nil {
+		return x.Stats
+	}
+	return nil
+}
+
+type VerifyConsensusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Operation     string                 `protobuf:"bytes,1,opt,name=operation,proto3" json:"operation,omitempty"`
+	Service       string                 `protobuf:"bytes,2,opt,name=service,proto3" json:"service,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VerifyConsensusRequest) Reset() {
+	*x = VerifyConsensusRequest{}
+	mi := &file_grpc_tokenizerpb_tokenizer_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifyConsensusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyConsensusRequest) ProtoMessage() {}
+
+func (x *VerifyConsensusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grpc_tokenizerpb_tokenizer_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyConsensusRequest.ProtoReflect.Descriptor instead.
+func (*VerifyConsensusRequest) Descriptor() ([]byte, []int) {
+	return file_grpc_tokenizerpb_tokenizer_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *VerifyConsensusRequest) GetOperation() string {
+	if x != nil {
+		return x.Operation
+	}
+	return ""
+}
+
+func (x *VerifyConsensusRequest) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+type VerifyConsensusResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Schema        string                 `protobuf:"bytes,1,opt,name=schema,proto3" json:"schema,omitempty"`
+	Passed        bool                   `protobuf:"varint,2,opt,name=passed,proto3" json:"passed,omitempty"`
+	State         nsigiipb.ColorChannel  `protobuf:"varint,3,opt,name=state,proto3,enum=nsigii.v1.ColorChannel" json:"state,omitempty"` // Color state of the context after the check
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VerifyConsensusResponse) Reset() {
+	*x = VerifyConsensusResponse{}
+	mi := &file_grpc_tokenizerpb_tokenizer_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifyConsensusResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyConsensusResponse) ProtoMessage() {}
+
+func (x *VerifyConsensusResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_grpc_tokenizerpb_tokenizer_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyConsensusResponse.ProtoReflect.Descriptor instead.
+func (*VerifyConsensusResponse) Descriptor() ([]byte, []int) {
+	return file_grpc_tokenizerpb_tokenizer_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *VerifyConsensusResponse) GetSchema() string {
+	if x != nil {
+		return x.Schema
+	}
+	return ""
+}
+
+func (x *VerifyConsensusResponse) GetPassed() bool {
+	if x != nil {
+		return x.Passed
+	}
+	return false
+}
+
+func (x *VerifyConsensusResponse) GetState() nsigiipb.ColorChannel {
+	if x != nil {
+		return x.State
+	}
+	return nsigiipb.ColorChannel(0)
+}
+
+var File_grpc_tokenizerpb_tokenizer_proto protoreflect.FileDescriptor
+
+const file_grpc_tokenizerpb_tokenizer_proto_rawDesc = "" +
+	"\n" +
+	" grpc/tokenizerpb/tokenizer.proto\x12\tnsigii.v1\x1a\x15nsigiipb/nsigii.proto\"{\n" +
+	"\x0fTokenizeRequest\x12\x1c\n" +
+	"\toperation\x18\x01 \x01(\tR\toperation\x12\x18\n" +
+	"\aservice\x18\x02 \x01(\tR\aservice\x12\x16\n" +
+	"\x06source\x18\x03 \x01(\tR\x06source\x12\x18\n" +
+	"\aprofile\x18\x04 \x01(\tR\aprofile\"T\n" +
+	"\x10TokenizeResponse\x12\x16\n" +
+	"\x06schema\x18\x01 \x01(\tR\x06schema\x12(\n" +
+	"\x06tokens\x18\x02 \x03(\v2\x10.nsigii.v1.TokenR\x06tokens\"z\n" +
+	"\x0eAnalyzeRequest\x12\x1c\n" +
+	"\toperation\x18\x01 \x01(\tR\toperation\x12\x18\n" +
+	"\aservice\x18\x02 \x01(\tR\aservice\x12\x16\n" +
+	"\x06source\x18\x03 \x01(\tR\x06source\x12\x18\n" +
+	"\aprofile\x18\x04 \x01(\tR\aprofile\"V\n" +
+	"\x0fAnalyzeResponse\x12\x16\n" +
+	"\x06schema\x18\x01 \x01(\tR\x06schema\x12+\n" +
+	"\x05stats\x18\x02 \x01(\v2\x15.nsigii.v1.TokenStatsR\x05stats\"P\n" +
+	"\x16VerifyConsensusRequest\x12\x1c\n" +
+	"\toperation\x18\x01 \x01(\tR\toperation\x12\x18\n" +
+	"\aservice\x18\x02 \x01(\tR\aservice\"x\n" +
+	"\x17VerifyConsensusResponse\x12\x16\n" +
+	"\x06schema\x18\x01 \x01(\tR\x06schema\x12\x16\n" +
+	"\x06passed\x18\x02 \x01(\bR\x06passed\x12-\n" +
+	"\x05state\x18\x03 \x01(\x0e2\x17.nsigii.v1.ColorChannelR\x05state2\xec\x01\n" +
+	"\tTokenizer\x12C\n" +
+	"\bTokenize\x12\x1a.nsigii.v1.TokenizeRequest\x1a\x1b.nsigii.v1.TokenizeResponse\x12@\n" +
+	"\aAnalyze\x12\x19.nsigii.v1.AnalyzeRequest\x1a\x1a.nsigii.v1.AnalyzeResponse\x12X\n" +
+	"\x0fVerifyConsensus\x12!.nsigii.v1.VerifyConsensusRequest\x1a\".nsigii.v1.VerifyConsensusResponseB9Z7github.com/obinexus/nsigii-rift/nsigii/grpc/tokenizerpbb\x06proto3"
+
+var (
+	file_grpc_tokenizerpb_tokenizer_proto_rawDescOnce sync.Once
+	file_grpc_tokenizerpb_tokenizer_proto_rawDescData []byte
+)
+
+func file_grpc_tokenizerpb_tokenizer_proto_rawDescGZIP() []byte {
+	file_grpc_tokenizerpb_tokenizer_proto_rawDescOnce.Do(func() {
+		file_grpc_tokenizerpb_tokenizer_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_grpc_tokenizerpb_tokenizer_proto_rawDesc), len(file_grpc_tokenizerpb_tokenizer_proto_rawDesc)))
+	})
+	return file_grpc_tokenizerpb_tokenizer_proto_rawDescData
+}
+
+var file_grpc_tokenizerpb_tokenizer_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_grpc_tokenizerpb_tokenizer_proto_goTypes = []any{
+	(*TokenizeRequest)(nil),         // 0: nsigii.v1.TokenizeRequest
+	(*TokenizeResponse)(nil),        // 1: nsigii.v1.TokenizeResponse
+	(*AnalyzeRequest)(nil),          // 2: nsigii.v1.AnalyzeRequest
+	(*AnalyzeResponse)(nil),         // 3: nsigii.v1.AnalyzeResponse
+	(*VerifyConsensusRequest)(nil),  // 4: nsigii.v1.VerifyConsensusRequest
+	(*VerifyConsensusResponse)(nil), // 5: nsigii.v1.VerifyConsensusResponse
+	(*nsigiipb.Token)(nil),          // 6: nsigii.v1.Token
+	(*nsigiipb.TokenStats)(nil),     // 7: nsigii.v1.TokenStats
+	(nsigiipb.ColorChannel)(0),      // 8: nsigii.v1.ColorChannel
+}
+var file_grpc_tokenizerpb_tokenizer_proto_depIdxs = []int32{
+	6, // 0: nsigii.v1.TokenizeResponse.tokens:type_name -> nsigii.v1.Token
+	7, // 1: nsigii.v1.AnalyzeResponse.stats:type_name -> nsigii.v1.TokenStats
+	8, // 2: nsigii.v1.VerifyConsensusResponse.state:type_name -> nsigii.v1.ColorChannel
+	0, // 3: nsigii.v1.Tokenizer.Tokenize:input_type -> nsigii.v1.TokenizeRequest
+	2, // 4: nsigii.v1.Tokenizer.Analyze:input_type -> nsigii.v1.AnalyzeRequest
+	4, // 5: nsigii.v1.Tokenizer.VerifyConsensus:input_type -> nsigii.v1.VerifyConsensusRequest
+	1, // 6: nsigii.v1.Tokenizer.Tokenize:output_type -> nsigii.v1.TokenizeResponse
+	3, // 7: nsigii.v1.Tokenizer.Analyze:output_type -> nsigii.v1.AnalyzeResponse
+	5, // 8: nsigii.v1.Tokenizer.VerifyConsensus:output_type -> nsigii.v1.VerifyConsensusResponse
+	6, // [6:9] is the sub-list for method output_type
+	3, // [3:6] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_grpc_tokenizerpb_tokenizer_proto_init() }
+func file_grpc_tokenizerpb_tokenizer_proto_init() {
+	if File_grpc_tokenizerpb_tokenizer_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_grpc_tokenizerpb_tokenizer_proto_rawDesc), len(file_grpc_tokenizerpb_tokenizer_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_grpc_tokenizerpb_tokenizer_proto_goTypes,
+		DependencyIndexes: file_grpc_tokenizerpb_tokenizer_proto_depIdxs,
+		MessageInfos:      file_grpc_tokenizerpb_tokenizer_proto_msgTypes,
+	}.Build()
+	File_grpc_tokenizerpb_tokenizer_proto = out.File
+	file_grpc_tokenizerpb_tokenizer_proto_goTypes = nil
+	file_grpc_tokenizerpb_tokenizer_proto_depIdxs = nil
+}
@@ -0,0 +1,39 @@
+package nsigii
+
+// ============================================================================
+// Health Checks
+// ============================================================================
+
+// Ping makes a cheap round trip into the native library and reports why the
+// context cannot take on work, if anything: ErrContextClosed once closed, a
+// *CError if the native handle no longer answers, or ErrTerminated in BLACK.
+//
+// Example:
+//   http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+//       if err := ctx.Ping(); err != nil {
+//           http.Error(w, err.Error(), http.StatusServiceUnavailable)
+//       }
+//   })
+func (c *Context) Ping() error {
+	var result int
+	err := c.withNative(func(ctx *nativeContext) {
+		_, result = nativeGenerateSchema(ctx)
+	})
+	if err != nil {
+		return err
+	}
+	if result != 0 {
+		c.usage.countError()
+		return &CError{Op: "ping", Code: result}
+	}
+
+	if c.ColorState() == ColorBlack {
+		return ErrTerminated
+	}
+	return nil
+}
+
+// Healthy reports whether Ping succeeds
+func (c *Context) Healthy() bool {
+	return c.Ping() == nil
+}
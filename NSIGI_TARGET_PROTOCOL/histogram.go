@@ -0,0 +1,176 @@
+package nsigii
+
+import (
+	"maps"
+	"math"
+	"slices"
+	"sort"
+)
+
+// ============================================================================
+// Length Histograms
+// ============================================================================
+
+// Histogram is a distribution of token lengths (Token.Value) over
+// configurable buckets, with quantiles computed from the exact lengths
+// rather than estimated from the buckets
+type Histogram struct {
+	Bounds    []uint32   `json:"bounds"`    // Inclusive upper bound of each bucket but the last, ascending
+	Counts    []int      `json:"counts"`    // Tokens per bucket; the last holds lengths above every bound
+	Quantiles []Quantile `json:"quantiles"` // In HistogramOptions order; none for an empty histogram
+}
+
+// Quantile is the token length at quantile Q of a distribution: the
+// smallest length that at least a fraction Q of the tokens do not exceed
+type Quantile struct {
+	Q      float64 `json:"q"`
+	Length uint32  `json:"length"`
+}
+
+// Total returns the number of tokens in the histogram
+func (h Histogram) Total() int {
+	n := 0
+	for _, count := range h.Counts {
+		n += count
+	}
+	return n
+}
+
+// HistogramOptions configures the histograms in TokenStats
+type HistogramOptions struct {
+	Bounds    []uint32  // Bucket bounds; nil means DefaultLengthBounds
+	Quantiles []float64 // Quantiles to report, in [0, 1]; nil means DefaultQuantiles
+}
+
+// DefaultLengthBounds returns the default bucket bounds, powers of two from
+// 1 to 256
+func DefaultLengthBounds() []uint32 {
+	return []uint32{1, 2, 4, 8, 16, 32, 64, 128, 256}
+}
+
+// DefaultQuantiles returns the default quantiles: the median, p90 and p99
+func DefaultQuantiles() []float64 {
+	return []float64{0.5, 0.9, 0.99}
+}
+
+// normalized returns opts with defaults filled in, bounds sorted without
+// duplicates and quantiles clamped to [0, 1]
+func (opts HistogramOptions) normalized() HistogramOptions {
+	if opts.Bounds == nil {
+		opts.Bounds = DefaultLengthBounds()
+	} else {
+		opts.Bounds = slices.Compact(slices.Sorted(slices.Values(opts.Bounds)))
+	}
+
+	quantiles := DefaultQuantiles()
+	if opts.Quantiles != nil {
+		quantiles = make([]float64, len(opts.Quantiles))
+		for i, q := range opts.Quantiles {
+			quantiles[i] = min(max(q, 0), 1)
+		}
+	}
+	opts.Quantiles = quantiles
+	return opts
+}
+
+// AnalyzeTokensWith analyzes tokens like AnalyzeTokens, with histograms
+// bucketed and summarized as opts configures
+//
+// Example:
+//   stats := nsigii.AnalyzeTokensWith(tokens, nsigii.HistogramOptions{
+//       Bounds:    []uint32{1, 4, 16, 64},
+//       Quantiles: []float64{0.5, 0.999},
+//   })
+//   for typ, h := range stats.TypeLengths {
+//       fmt.Println(typ, h.Counts, h.Quantiles)
+//   }
+func AnalyzeTokensWith(tokens []Token, opts HistogramOptions) TokenStats {
+	acc := NewStatsAccumulator(opts)
+	for _, token := range tokens {
+		acc.Add(token)
+	}
+	return acc.Stats()
+}
+
+// smallLengths is the number of lengths lengthCounts counts without a map;
+// nearly every token of real source is shorter
+const smallLengths = 64
+
+// lengthCounts counts tokens by exact length, so that histograms can be
+// bucketed and quantiles computed after any number of merges
+type lengthCounts struct {
+	total int
+	small [smallLengths]int
+	large map[uint32]int
+}
+
+// add counts n tokens of the given length
+func (l *lengthCounts) add(length uint32, n int) {
+	l.total += n
+	if length < smallLengths {
+		l.small[length] += n
+		return
+	}
+	if l.large == nil {
+		l.large = make(map[uint32]int)
+	}
+	l.large[length] += n
+}
+
+// merge adds other's counts to l
+func (l *lengthCounts) merge(other *lengthCounts) {
+	for length, n := range other.small {
+		if n > 0 {
+			l.add(uint32(length), n)
+		}
+	}
+	for length, n := range other.large {
+		l.add(length, n)
+	}
+}
+
+// each calls fn for every length counted, in ascending order
+func (l *lengthCounts) each(fn func(length uint32, n int)) {
+	for length, n := range l.small {
+		if n > 0 {
+			fn(uint32(length), n)
+		}
+	}
+	for _, length := range slices.Sorted(maps.Keys(l.large)) {
+		fn(length, l.large[length])
+	}
+}
+
+// histogram buckets the counts and computes quantiles as opts, which must
+// be normalized, configures
+func (l *lengthCounts) histogram(opts HistogramOptions) Histogram {
+	h := Histogram{
+		Bounds: slices.Clone(opts.Bounds),
+		Counts: make([]int, len(opts.Bounds)+1),
+	}
+	if l.total == 0 {
+		return h
+	}
+
+	// Ranks are 1-based: quantile q is the length of the ceil(q*total)-th
+	// shortest token
+	ranks := make([]int, len(opts.Quantiles))
+	for i, q := range opts.Quantiles {
+		ranks[i] = max(int(math.Ceil(q*float64(l.total))), 1)
+		h.Quantiles = append(h.Quantiles, Quantile{Q: q})
+	}
+
+	seen := 0
+	l.each(func(length uint32, n int) {
+		bucket := sort.Search(len(h.Bounds), func(i int) bool { return h.Bounds[i] >= length })
+		h.Counts[bucket] += n
+
+		for i, rank := range ranks {
+			if seen < rank && rank <= seen+n {
+				h.Quantiles[i].Length = length
+			}
+		}
+		seen += n
+	})
+	return h
+}
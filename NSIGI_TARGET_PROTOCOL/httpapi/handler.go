@@ -0,0 +1,357 @@
+// Package httpapi exposes NSIGII over HTTP with JSON bodies, so the
+// framework can be stood up as a microservice in a few lines:
+//   POST /tokenize   tokenize a source
+//   GET  /schema     report the schema of a context
+//   POST /consensus  run an RGB consensus check
+//
+// A /tokenize request with a JSON body gets the whole token stream back as
+// one JSON document. Any other body is taken as the raw source, with the
+// schema and profile in the query string, and the tokens are streamed back
+// as newline-delimited JSON while they are scanned, so large sources are
+// never held in memory whole.
+//
+// Example:
+//   pool := nsigii.NewContextPool(nsigii.PoolOptions{MaxSize: 16})
+//   defer pool.Close()
+//   http.Handle("/nsigii/", http.StripPrefix("/nsigii", httpapi.NewHandler(httpapi.Options{Pool: pool})))
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/obinexus/nsigii-rift/nsigii"
+)
+
+// Options configures a Handler
+type Options struct {
+	// Pool supplies the contexts requests run on. Nil creates a pool with
+	// default options that the handler owns; see Handler.Close.
+	Pool *nsigii.ContextPool
+
+	Operation string // Default schema operation; "" means "tokenize"
+	Service   string // Default schema service; "" means "lexer"
+
+	// MaxSourceBytes rejects larger JSON requests and stops streamed ones
+	// once exceeded; 0 means no limit
+	MaxSourceBytes int64
+
+	// Peers, if set, refuses every request with 403 unless it arrives over
+	// TLS with a verified client certificate bound to the requested schema
+	// (see nsigii.PeerRegistry). The server's tls.Config must require and
+	// verify client certificates. The peer's phantom ID is the caller the
+	// context's policy sees.
+	Peers *nsigii.PeerRegistry
+}
+
+// TokenizeRequest is the JSON body of POST /tokenize
+type TokenizeRequest struct {
+	Operation string `json:"operation,omitempty"`
+	Service   string `json:"service,omitempty"`
+	Source    string `json:"source"`
+	Profile   string `json:"profile,omitempty"` // Built-in language profile, e.g. "go"
+}
+
+// ConsensusRequest is the JSON body of POST /consensus
+type ConsensusRequest struct {
+	Operation string `json:"operation,omitempty"`
+	Service   string `json:"service,omitempty"`
+}
+
+// ConsensusResponse is the JSON response of POST /consensus
+type ConsensusResponse struct {
+	Schema string              `json:"schema"`
+	Passed bool                `json:"passed"`
+	State  nsigii.ColorChannel `json:"state"` // Color state after the check
+}
+
+// SchemaResponse is the JSON response of GET /schema
+type SchemaResponse struct {
+	Schema string `json:"schema"`
+}
+
+// ErrorResponse is the JSON body of every error response, and the last line
+// of a streamed response that failed part way
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// Handler serves the NSIGII HTTP API
+type Handler struct {
+	opts    Options
+	ownPool bool
+	mux     *http.ServeMux
+}
+
+// NewHandler creates a handler
+func NewHandler(opts Options) *Handler {
+	h := &Handler{opts: opts, mux: http.NewServeMux()}
+	if h.opts.Pool == nil {
+		h.opts.Pool = nsigii.NewContextPool(nsigii.PoolOptions{})
+		h.ownPool = true
+	}
+	if h.opts.Operation == "" {
+		h.opts.Operation = "tokenize"
+	}
+	if h.opts.Service == "" {
+		h.opts.Service = "lexer"
+	}
+
+	h.mux.HandleFunc("POST /tokenize", h.tokenize)
+	h.mux.HandleFunc("GET /schema", h.schema)
+	h.mux.HandleFunc("POST /consensus", h.consensus)
+	return h
+}
+
+// Close closes the handler's pool if the handler created it
+func (h *Handler) Close() error {
+	if h.ownPool {
+		return h.opts.Pool.Close()
+	}
+	return nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// tokenize serves POST /tokenize
+func (h *Handler) tokenize(w http.ResponseWriter, r *http.Request) {
+	if !isJSON(r) {
+		h.tokenizeStream(w, r)
+		return
+	}
+
+	var req TokenizeRequest
+	if !h.decode(w, r, &req) {
+		return
+	}
+	if h.opts.MaxSourceBytes > 0 && int64(len(req.Source)) > h.opts.MaxSourceBytes {
+		writeJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse{
+			Error: fmt.Sprintf("source is %d bytes, limit is %d", len(req.Source), h.opts.MaxSourceBytes),
+		})
+		return
+	}
+
+	c, ok := h.context(w, r, req.Operation, req.Service, req.Profile)
+	if !ok {
+		return
+	}
+	defer h.opts.Pool.Put(c)
+
+	schema, err := c.Schema()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	tokens, err := c.Tokenize(req.Source)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, nsigii.NewTokenStream(schema, tokens))
+}
+
+// tokenizeStream serves POST /tokenize with a raw source body, writing one
+// token per line as they are scanned
+func (h *Handler) tokenizeStream(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	c, ok := h.context(w, r, q.Get("operation"), q.Get("service"), q.Get("profile"))
+	if !ok {
+		return
+	}
+	defer h.opts.Pool.Put(c)
+
+	var body io.Reader = r.Body
+	if h.opts.MaxSourceBytes > 0 {
+		body = http.MaxBytesReader(w, r.Body, h.opts.MaxSourceBytes)
+	}
+
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	started := false
+	n := 0
+	err := c.TokenizeReader(body, func(t nsigii.Token) error {
+		if err := r.Context().Err(); err != nil {
+			return err
+		}
+		if !started {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		if err := enc.Encode(t); err != nil {
+			return err
+		}
+		if n++; n%256 == 0 && flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+
+	switch {
+	case err == nil:
+	case started:
+		enc.Encode(ErrorResponse{Error: err.Error()})
+	default:
+		writeError(w, err)
+	}
+}
+
+// schema serves GET /schema
+func (h *Handler) schema(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	c, ok := h.context(w, r, q.Get("operation"), q.Get("service"), "")
+	if !ok {
+		return
+	}
+	defer h.opts.Pool.Put(c)
+
+	schema, err := c.Schema()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, SchemaResponse{Schema: schema})
+}
+
+// consensus serves POST /consensus
+func (h *Handler) consensus(w http.ResponseWriter, r *http.Request) {
+	var req ConsensusRequest
+	if r.ContentLength != 0 && !h.decode(w, r, &req) {
+		return
+	}
+
+	c, ok := h.context(w, r, req.Operation, req.Service, "")
+	if !ok {
+		return
+	}
+	defer h.opts.Pool.Put(c)
+
+	schema, err := c.Schema()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	passed, err := c.VerifyRGBConsensus()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ConsensusResponse{Schema: schema, Passed: passed, State: c.ColorState()})
+}
+
+// decode reads a JSON request body into v, answering the request itself
+// if that fails
+func (h *Handler) decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	var body io.Reader = r.Body
+	if h.opts.MaxSourceBytes > 0 {
+		// Leave room for the rest of the JSON document around the source
+		body = http.MaxBytesReader(w, r.Body, h.opts.MaxSourceBytes+4096)
+	}
+
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse{Error: err.Error()})
+		} else {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid request body: " + err.Error()})
+		}
+		return false
+	}
+	return true
+}
+
+// context takes a context for the schema from the pool and applies the
+// requested profile, answering the request itself if that fails. The
+// caller returns the context to the pool, which restores its settings.
+func (h *Handler) context(w http.ResponseWriter, r *http.Request, operation, service, profile string) (*nsigii.Context, bool) {
+	var lp nsigii.LanguageProfile
+	if profile != "" {
+		var ok bool
+		if lp, ok = nsigii.LookupProfile(profile); !ok {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("unknown language profile %q", profile)})
+			return nil, false
+		}
+	}
+	if operation == "" {
+		operation = h.opts.Operation
+	}
+	if service == "" {
+		service = h.opts.Service
+	}
+
+	c, err := h.opts.Pool.Get(r.Context(), operation, service)
+	if err != nil {
+		writeError(w, err)
+		return nil, false
+	}
+	if h.opts.Peers != nil {
+		b, err := h.opts.Peers.Authorize(c, r.TLS)
+		if err != nil {
+			h.opts.Pool.Put(c)
+			writeError(w, err)
+			return nil, false
+		}
+		if err := c.SetCaller(b.PhantomID); err != nil { // Cleared by Put
+			h.opts.Pool.Put(c)
+			writeError(w, err)
+			return nil, false
+		}
+	}
+	if profile != "" {
+		if err := c.SetProfile(lp); err != nil {
+			h.opts.Pool.Put(c)
+			writeError(w, err)
+			return nil, false
+		}
+	}
+	return c, true
+}
+
+// isJSON reports whether the request body is declared as JSON
+func isJSON(r *http.Request) bool {
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mt == "application/json"
+}
+
+// writeJSON writes v as the response with the given status
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes err with the status matching it
+func writeError(w http.ResponseWriter, err error) {
+	writeJSON(w, statusCode(err), ErrorResponse{Error: err.Error()})
+}
+
+// statusCode maps package errors to HTTP status codes
+func statusCode(err error) int {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, nsigii.ErrTokenBufferFull):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, nsigii.ErrInvalidUTF8):
+		return http.StatusBadRequest
+	case errors.Is(err, nsigii.ErrTerminated):
+		return http.StatusConflict
+	case errors.Is(err, nsigii.ErrPeerUnauthorized), errors.Is(err, nsigii.ErrPolicyDenied),
+		errors.Is(err, nsigii.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, nsigii.ErrContextClosed), errors.Is(err, nsigii.ErrPoolClosed):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
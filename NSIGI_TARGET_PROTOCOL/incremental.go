@@ -0,0 +1,239 @@
+package nsigii
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ============================================================================
+// Incremental Tokenization
+// ============================================================================
+
+// Edit describes a single text change to a tokenized source
+type Edit struct {
+	Offset   int    // Byte offset of the change in the old source
+	Deleted  int    // Number of bytes removed at Offset
+	Inserted string // Text inserted at Offset
+}
+
+// IncrementalTokenizer keeps a token stream in sync with a source that is
+// being edited, re-tokenizing only the lines an edit touches
+//
+// The affected region is widened to whole lines, to any token crossing its
+// edges and, with a profile defining lexer modes, back to where the
+// enclosing modes were entered, then re-scanned. If the re-scanned region
+// ends inside a token or mode (an edit opened a block comment or string),
+// the region grows until the stream resynchronises, so the result always
+// matches a full Tokenize.
+type IncrementalTokenizer struct {
+	ctx    *Context
+	source string
+	tokens []Token
+}
+
+// NewIncrementalTokenizer starts tracking source. tokens is the existing
+// stream for source as produced by ctx; if nil, source is tokenized now.
+func NewIncrementalTokenizer(ctx *Context, source string, tokens []Token) (*IncrementalTokenizer, error) {
+	if tokens == nil {
+		var err error
+		tokens, err = ctx.Tokenize(source)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &IncrementalTokenizer{ctx: ctx, source: source, tokens: tokens}, nil
+}
+
+// Source returns the current source text
+func (it *IncrementalTokenizer) Source() string {
+	return it.source
+}
+
+// Tokens returns the current token stream
+func (it *IncrementalTokenizer) Tokens() []Token {
+	return it.tokens
+}
+
+// Apply applies edit to the source and returns the updated token stream.
+// The previously returned slice is left untouched.
+func (it *IncrementalTokenizer) Apply(edit Edit) ([]Token, error) {
+	if err := it.ctx.usable(); err != nil {
+		return nil, err
+	}
+
+	old := it.source
+	if edit.Offset < 0 || edit.Deleted < 0 || edit.Offset+edit.Deleted > len(old) {
+		return nil, fmt.Errorf("edit out of range: offset=%d deleted=%d len=%d",
+			edit.Offset, edit.Deleted, len(old))
+	}
+
+	source := old[:edit.Offset] + edit.Inserted + old[edit.Offset+edit.Deleted:]
+	delta := len(edit.Inserted) - edit.Deleted
+
+	// Affected region [start, oldEnd) in old coordinates; start is the same
+	// in new coordinates, the end becomes oldEnd+delta
+	start := lineStart(old, edit.Offset)
+	oldEnd := lineEnd(old, edit.Offset+edit.Deleted)
+
+	var region []Token
+	for {
+		start, oldEnd = it.widen(start, oldEnd)
+
+		// The region is scanned from the root mode, so it must start there
+		if entered, open := it.ctx.openMode(it.tokens, start); open {
+			start = lineStart(old, entered)
+			continue
+		}
+		newEnd := oldEnd + delta
+
+		var err error
+		region, err = it.ctx.tokenize(source[start:newEnd])
+		if err != nil {
+			return nil, err
+		}
+
+		// The region is resynchronised unless its last token runs into
+		// the unscanned remainder of the source, or it ends in a different
+		// mode from the one the remainder was scanned in
+		last := len(region) - 1
+		if region[last].Type == TokenEOF {
+			last--
+		}
+		synced := newEnd == len(source) || last < 0 || int(region[last].EndOffset) < newEnd-start
+		if synced && newEnd < len(source) {
+			_, newOpen := it.ctx.openMode(region, newEnd-start)
+			_, oldOpen := it.ctx.openMode(it.tokens, oldEnd)
+			synced = !newOpen && !oldOpen
+		}
+		if synced {
+			break
+		}
+
+		oldEnd = lineEnd(old, oldEnd+(oldEnd-start))
+	}
+
+	newEnd := oldEnd + delta
+	startLine := strings.Count(source[:start], "\n")
+	lineDelta := strings.Count(source[start:newEnd], "\n") - strings.Count(old[start:oldEnd], "\n")
+
+	var startRunes, runeDelta int
+	if it.ctx.runes {
+		startRunes = utf8.RuneCountInString(source[:start])
+		runeDelta = utf8.RuneCountInString(source[start:newEnd]) - utf8.RuneCountInString(old[start:oldEnd])
+	}
+
+	// Lazy tokens all move over to the edited source
+	src := &source
+
+	tokens := make([]Token, 0, len(it.tokens)+len(region))
+	for _, t := range it.tokens {
+		if t.Type != TokenEOF && int(t.EndOffset) <= start {
+			t.rebase(src)
+			tokens = append(tokens, t)
+		}
+	}
+
+	for _, t := range region {
+		if t.Type == TokenEOF {
+			continue
+		}
+		t.Memory += uint32(start)
+		t.EndOffset += uint32(start)
+		t.rebase(src)
+		if t.Line > 0 {
+			t.Line += startLine
+		}
+		if it.ctx.runes {
+			t.RuneOffset += uint32(startRunes)
+		}
+		tokens = append(tokens, t)
+	}
+
+	for _, t := range it.tokens {
+		if t.Type == TokenEOF || int(t.Memory) < oldEnd {
+			continue
+		}
+		t.Memory = uint32(int(t.Memory) + delta)
+		t.EndOffset = uint32(int(t.EndOffset) + delta)
+		t.rebase(src)
+		if t.Line > 0 {
+			t.Line += lineDelta
+		}
+		if it.ctx.runes {
+			t.RuneOffset = uint32(int(t.RuneOffset) + runeDelta)
+		}
+		tokens = append(tokens, t)
+	}
+
+	eof := Token{Type: TokenEOF, Memory: uint32(len(source)), EndOffset: uint32(len(source)), Text: "<EOF>"}
+	if !it.ctx.noPos {
+		eof.Line = strings.Count(source, "\n") + 1
+		eof.Column = len(source) - lineStart(source, len(source)) + 1
+	}
+	if it.ctx.runes {
+		eof.RuneOffset = uint32(utf8.RuneCountInString(source))
+	}
+	tokens = append(tokens, eof)
+
+	it.source = source
+	it.tokens = tokens
+
+	return tokens, nil
+}
+
+// openMode reports whether tokens, scanned by c, leave a lexer mode open at
+// offset, and if so where the outermost one was entered. Only profiles with
+// lexer modes can.
+func (c *Context) openMode(tokens []Token, offset int) (entered int, open bool) {
+	if c.lexer == nil || len(c.lexer.modes) == 0 {
+		return 0, false
+	}
+	depth, entered := c.lexer.modeAt(tokens, offset)
+	return entered, depth > 0
+}
+
+// widen grows [start, end) until no token of the current stream crosses
+// either edge, keeping both edges on line boundaries. A token ending exactly
+// at start swallowed the preceding newline (or ran to end of input), so it
+// may absorb the edit and counts as crossing.
+func (it *IncrementalTokenizer) widen(start, end int) (int, int) {
+	for changed := true; changed; {
+		changed = false
+		for _, t := range it.tokens {
+			if t.Type == TokenEOF {
+				continue
+			}
+			mem, endOff := int(t.Memory), int(t.EndOffset)
+			if mem < start && endOff >= start {
+				start = lineStart(it.source, mem)
+				changed = true
+			}
+			if mem < end && endOff > end {
+				end = lineEnd(it.source, endOff)
+				changed = true
+			}
+		}
+	}
+	return start, end
+}
+
+// lineStart returns the offset of the first byte of the line containing
+// offset
+func lineStart(s string, offset int) int {
+	return strings.LastIndexByte(s[:offset], '\n') + 1
+}
+
+// lineEnd returns the offset just past the newline ending the line that
+// contains offset, or len(s) on the last line
+func lineEnd(s string, offset int) int {
+	if offset >= len(s) {
+		return len(s)
+	}
+	i := strings.IndexByte(s[offset:], '\n')
+	if i < 0 {
+		return len(s)
+	}
+	return offset + i + 1
+}
@@ -0,0 +1,107 @@
+package nsigii
+
+import (
+	"strings"
+	"testing"
+)
+
+// interpolationProfile is RIFT with "${...}" interpolation in double-quoted
+// strings
+func interpolationProfile() LanguageProfile {
+	p := RIFTProfile
+	p.Name = "rift-interp"
+	p.Quotes = []string{`'`, "`"}
+	p.Enter = []ModeSwitch{{Open: `"`, Mode: "string"}}
+	p.Modes = []LexerMode{
+		{Name: "string", Text: true, Escapes: true, Exit: []string{`"`},
+			Enter: []ModeSwitch{{Open: "${", Mode: "code"}}},
+		{Name: "code", Exit: []string{"}"},
+			Enter: []ModeSwitch{{Open: "{", Mode: "code"}, {Open: `"`, Mode: "string"}}},
+	}
+	return p
+}
+
+// sameTokens fails t unless got and want agree on every token's type,
+// position and text
+func sameTokens(t *testing.T, got, want []Token) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d tokens, want %d\ngot:  %v\nwant: %v", len(got), len(want), got, want)
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.Type != w.Type || g.Memory != w.Memory || g.Value != w.Value ||
+			g.Line != w.Line || g.Column != w.Column || g.Lexeme() != w.Lexeme() {
+			t.Fatalf("token %d = %v at %d:%d, want %v at %d:%d", i, g, g.Line, g.Column, w, w.Line, w.Column)
+		}
+	}
+}
+
+func TestIncrementalApplyInsideMode(t *testing.T) {
+	ctx, err := NewContext("tokenize", "lexer", WithProfile(interpolationProfile()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+
+	source := "let s = \"a ${ x +\n y } tail words\";\nlet t = 1;\n"
+	it, err := NewIncrementalTokenizer(ctx, source, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each edit replaces the first occurrence of old in the current source
+	edits := []struct{ old, new string }{
+		{"y", "yy"},                 // inside the interpolation, on its second line
+		{"tail", "end"},             // in the string after it
+		{" }", " + z"},              // removes the exit, leaving the mode open
+		{"let t", "let u = 2; }\""}, // closes it again on a later line
+	}
+	for _, e := range edits {
+		edit := Edit{Offset: strings.Index(it.Source(), e.old), Deleted: len(e.old), Inserted: e.new}
+		got, err := it.Apply(edit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := ctx.Tokenize(it.Source())
+		if err != nil {
+			t.Fatal(err)
+		}
+		sameTokens(t, got, want)
+	}
+}
+
+func TestChunkedTokenizeInsideMode(t *testing.T) {
+	ctx, err := NewContext("tokenize", "lexer", WithProfile(interpolationProfile()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+
+	// The interpolation opens in the first chunk and closes after the
+	// chunk boundary, on a line that would lex as code from the root mode
+	filler := strings.Repeat("let x = 1;\n", parallelMinChunk/11)
+	source := filler + "let s = \"a ${ x +\n" + strings.Repeat(" y +\n", streamChunkSize/5) +
+		" y } tail words // not a comment\";\n" + filler
+
+	want, err := ctx.Tokenize(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var streamed []Token
+	err = ctx.TokenizeReader(strings.NewReader(source), func(tok Token) error {
+		streamed = append(streamed, tok)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameTokens(t, streamed, want)
+
+	parallel, err := ctx.TokenizeParallel(source, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameTokens(t, parallel, want)
+}
@@ -0,0 +1,131 @@
+package nsigii
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ============================================================================
+// Instrumentation
+// ============================================================================
+
+// Operation identifies an instrumented operation
+type Operation int
+
+const (
+	OpTokenize  Operation = 0 // A tokenization, direct or through another operation
+	OpConsensus Operation = 1 // An RGB consensus check
+	OpAuxStart  Operation = 2 // Starting AUX noise
+	OpAuxStop   Operation = 3 // Stopping AUX noise
+	OpStage     Operation = 4 // One stage of a StagePipeline run
+)
+
+var operationNames = []string{"tokenize", "consensus", "aux_start", "aux_stop", "stage"}
+
+func (op Operation) String() string {
+	if op >= 0 && int(op) < len(operationNames) {
+		return operationNames[op]
+	}
+	return "unknown"
+}
+
+// Event describes a finished operation
+type Event struct {
+	Op       Operation
+	Schema   string // obinexus.[operation].[service] of the context
+	Start    time.Time
+	Duration time.Duration
+	Err      error
+
+	Stage  StageID // OpStage: the stage that ran
+	Bytes  int     // OpTokenize: source bytes
+	Tokens int     // OpTokenize: tokens produced
+	Passed bool    // OpConsensus: whether consensus was reached
+}
+
+// Instrument observes the operations of contexts, for metrics, tracing or
+// logging. Observe is called synchronously once each operation finishes,
+// possibly from many goroutines at once. It must not block; it may read the
+// context's state, such as its color, but must not start operations on it.
+type Instrument interface {
+	Observe(c *Context, e Event)
+}
+
+// InstrumentFunc adapts an ordinary function to an Instrument
+type InstrumentFunc func(c *Context, e Event)
+
+// Observe calls f(c, e)
+func (f InstrumentFunc) Observe(c *Context, e Event) {
+	f(c, e)
+}
+
+// Instruments combines instruments into one that calls each in turn
+//
+// Example:
+//   nsigii.SetDefaultInstrument(nsigii.Instruments(collector, tracer))
+func Instruments(list ...Instrument) Instrument {
+	list = append([]Instrument(nil), list...)
+	return InstrumentFunc(func(c *Context, e Event) {
+		for _, in := range list {
+			in.Observe(c, e)
+		}
+	})
+}
+
+// instrumentBox lets a nil Instrument be stored in an atomic.Value
+type instrumentBox struct {
+	in Instrument
+}
+
+var defaultInstrument atomic.Value // instrumentBox
+
+// SetDefaultInstrument sets the instrument of contexts that have none of
+// their own. nil removes it.
+//
+// Example:
+//   collector := metrics.NewCollector(metrics.Options{})
+//   prometheus.MustRegister(collector)
+//   nsigii.SetDefaultInstrument(collector)
+func SetDefaultInstrument(in Instrument) {
+	defaultInstrument.Store(instrumentBox{in})
+}
+
+// SetInstrument sets the instrument observing the context, overriding the
+// default instrument. nil restores the default.
+func (c *Context) SetInstrument(in Instrument) {
+	c.instrument = in
+}
+
+// WithInstrument sets the context's instrument; see SetInstrument
+func WithInstrument(in Instrument) Option {
+	return func(c *Context) error {
+		c.SetInstrument(in)
+		return nil
+	}
+}
+
+// observer returns the instrument observing the context, nil if none
+func (c *Context) observer() Instrument {
+	if c.instrument != nil {
+		return c.instrument
+	}
+	box, _ := defaultInstrument.Load().(instrumentBox)
+	return box.in
+}
+
+// observe reports an operation that started at e.Start to the context's
+// instrument and logger
+func (c *Context) observe(e Event) {
+	e.Duration = time.Since(e.Start)
+	c.logOperation(e)
+	if e.Op == OpTokenize {
+		c.logTokenize(e)
+	}
+
+	in := c.observer()
+	if in == nil {
+		return
+	}
+	e.Schema = c.schema()
+	in.Observe(c, e)
+}
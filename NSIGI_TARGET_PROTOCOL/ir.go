@@ -0,0 +1,650 @@
+package nsigii
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strings"
+)
+
+// ============================================================================
+// Intermediate Representation
+// ============================================================================
+
+// Opcode is an IR instruction. The IR is a stack machine: operands are
+// popped from and results pushed to an evaluation stack.
+type Opcode uint8
+
+const (
+	OpPushNumber Opcode = iota // Push number literal S
+	OpPushString               // Push string literal S, quotes included
+	OpPushTrue                 // Push true
+	OpPushFalse                // Push false
+	OpPushNull                 // Push null
+	OpLoad                     // Push variable S
+	OpStore                    // Assign the top of stack to variable S, leaving it
+	OpDefine                   // Pop a value into new variable S in the current scope
+	OpGetField                 // Pop object, push its field S
+	OpSetField                 // Pop value and object, set field S, push value
+	OpGetIndex                 // Pop index and object, push the element
+	OpSetIndex                 // Pop value, index and object, set the element, push value
+	OpUnary                    // Pop operand, push the result of prefix operator S
+	OpBinary                   // Pop right and left operands, push the result of operator S
+	OpCall                     // Pop A arguments and the callee, push the result
+	OpList                     // Pop A elements, push a list of them
+	OpJump                     // Continue at instruction A
+	OpJumpFalse                // Pop condition, continue at A if false
+	OpJumpTrue                 // Pop condition, continue at A if true
+	OpDup                      // Duplicate the top of stack
+	OpDup2                     // Duplicate the top two stack entries
+	OpPop                      // Discard the top of stack
+	OpEnter                    // Open a block scope
+	OpLeave                    // Close the innermost block scope
+	OpFunc                     // Define function S as IR.Funcs[A]
+	OpReturn                   // Pop the return value and return it
+	OpImport                   // Import module S (string literal)
+)
+
+var opcodeNames = []string{
+	"PUSH_NUMBER", "PUSH_STRING", "PUSH_TRUE", "PUSH_FALSE", "PUSH_NULL",
+	"LOAD", "STORE", "DEFINE", "GET_FIELD", "SET_FIELD", "GET_INDEX",
+	"SET_INDEX", "UNARY", "BINARY", "CALL", "LIST", "JUMP", "JUMP_FALSE",
+	"JUMP_TRUE", "DUP", "DUP2", "POP", "ENTER", "LEAVE", "FUNC", "RETURN",
+	"IMPORT",
+}
+
+func (op Opcode) String() string {
+	if int(op) < len(opcodeNames) {
+		return opcodeNames[op]
+	}
+	return "UNKNOWN"
+}
+
+// Instr is one IR instruction
+type Instr struct {
+	Op  Opcode
+	A   int32  // Count, jump target or function index
+	S   string // Name, operator or literal text
+	Pos uint32 // Byte offset of the source construct
+}
+
+func (in Instr) String() string {
+	switch in.Op {
+	case OpCall, OpList, OpJump, OpJumpFalse, OpJumpTrue:
+		return fmt.Sprintf("%s %d", in.Op, in.A)
+	case OpFunc:
+		return fmt.Sprintf("%s %s %d", in.Op, in.S, in.A)
+	}
+	if in.S != "" {
+		return fmt.Sprintf("%s %s", in.Op, in.S)
+	}
+	return in.Op.String()
+}
+
+// IRFunc is a function body in IR
+type IRFunc struct {
+	Name   string // "" for the top level
+	Params []string
+	Code   []Instr
+}
+
+// IR is a lowered RIFT program. Funcs[0] is the top level; OpFunc refers to
+// the others by index.
+type IR struct {
+	Funcs []IRFunc
+}
+
+// String disassembles the IR
+func (ir *IR) String() string {
+	var b strings.Builder
+	for i, fn := range ir.Funcs {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		name := fn.Name
+		if i == 0 {
+			name = "<main>"
+		}
+		fmt.Fprintf(&b, "func %s(%s):\n", name, strings.Join(fn.Params, ", "))
+		for pc, in := range fn.Code {
+			fmt.Fprintf(&b, "  %04d  %s\n", pc, in)
+		}
+	}
+	return b.String()
+}
+
+// ============================================================================
+// Emission (RIFT Stage 333 -> IR)
+// ============================================================================
+
+// Emit tokenizes, parses, validates and lowers source to IR
+//
+// Example:
+//   ir, err := ctx.Emit("let result = (x + y) * 42;")
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   fmt.Print(ir)
+func (c *Context) Emit(source string) (*IR, error) {
+	u := &Unit{Source: source}
+	if err := runValidateStage(c, u); err != nil {
+		return nil, err
+	}
+	if err := runEmitStage(c, u); err != nil {
+		return nil, err
+	}
+	return u.IR, nil
+}
+
+// EmitIR lowers a RIFT syntax tree to IR. Trees of custom grammars cannot
+// be lowered; register a StageEmit of their own instead.
+func EmitIR(prog *Program) (ir *IR, err error) {
+	e := &emitter{ir: &IR{Funcs: []IRFunc{{}}}}
+	defer func() {
+		if r := recover(); r != nil {
+			f, ok := r.(emitFailure)
+			if !ok {
+				panic(r)
+			}
+			ir, err = nil, f.err
+		}
+	}()
+
+	e.stmts(prog.Stmts)
+	e.emit(OpPushNull, 0, "", prog.End())
+	e.emit(OpReturn, 0, "", prog.End())
+
+	return e.ir, nil
+}
+
+// runEmitStage implements StageEmit, validating first if no earlier stage
+// parsed
+func runEmitStage(c *Context, u *Unit) error {
+	if u.AST == nil {
+		if err := runValidateStage(c, u); err != nil {
+			return err
+		}
+	}
+
+	ir, err := EmitIR(u.AST)
+	if err != nil {
+		return err
+	}
+	u.IR = ir
+	return nil
+}
+
+// emitFailure carries an emission error up the recursion
+type emitFailure struct {
+	err error
+}
+
+// emitter lowers one syntax tree
+type emitter struct {
+	ir    *IR
+	fn    int    // index of the function being emitted
+	depth int    // open block scopes in the current function
+	loops []loop // enclosing loops in the current function
+}
+
+// loop collects the jumps a loop patches once its bounds are known
+type loop struct {
+	depth     int // block scopes open at the loop
+	breaks    []int
+	continues []int
+}
+
+func (e *emitter) fail(n Node, format string, args ...any) {
+	d := nodeDiagnostic(n, SeverityError, fmt.Sprintf(format, args...))
+	panic(emitFailure{fmt.Errorf("emit: %s", d)})
+}
+
+// emit appends an instruction to the current function, returning its index
+func (e *emitter) emit(op Opcode, a int32, s string, pos uint32) int {
+	code := &e.ir.Funcs[e.fn].Code
+	*code = append(*code, Instr{Op: op, A: a, S: s, Pos: pos})
+	return len(*code) - 1
+}
+
+// here returns the index of the next instruction
+func (e *emitter) here() int32 {
+	return int32(len(e.ir.Funcs[e.fn].Code))
+}
+
+// patch points the jump at index i to target
+func (e *emitter) patch(i int, target int32) {
+	e.ir.Funcs[e.fn].Code[i].A = target
+}
+
+// stmts emits a statement list; functions are defined first so they are
+// visible throughout it
+func (e *emitter) stmts(list []Stmt) {
+	for _, s := range list {
+		if fn, ok := s.(*FuncDecl); ok {
+			e.funcDecl(fn)
+		}
+	}
+	for _, s := range list {
+		if _, ok := s.(*FuncDecl); !ok {
+			e.stmt(s)
+		}
+	}
+}
+
+func (e *emitter) block(b *Block) {
+	e.emit(OpEnter, 0, "", b.Pos().Offset)
+	e.depth++
+	e.stmts(b.Stmts)
+	e.depth--
+	e.emit(OpLeave, 0, "", b.Rbrace.Memory)
+}
+
+func (e *emitter) funcDecl(d *FuncDecl) {
+	params := make([]string, len(d.Params))
+	for i, p := range d.Params {
+		params[i] = p.Name()
+	}
+	e.ir.Funcs = append(e.ir.Funcs, IRFunc{Name: d.Name.Name(), Params: params})
+	index := len(e.ir.Funcs) - 1
+	e.emit(OpFunc, int32(index), d.Name.Name(), d.Pos().Offset)
+
+	fn, depth, loops := e.fn, e.depth, e.loops
+	e.fn, e.depth, e.loops = index, 0, nil
+	e.stmts(d.Body.Stmts)
+	e.emit(OpPushNull, 0, "", d.Body.Rbrace.Memory)
+	e.emit(OpReturn, 0, "", d.Body.Rbrace.Memory)
+	e.fn, e.depth, e.loops = fn, depth, loops
+}
+
+func (e *emitter) stmt(s Stmt) {
+	switch s := s.(type) {
+	case *LetStmt:
+		if s.Value != nil {
+			e.expr(s.Value)
+		} else {
+			e.emit(OpPushNull, 0, "", s.Pos().Offset)
+		}
+		e.emit(OpDefine, 0, s.Name.Name(), s.Pos().Offset)
+	case *FuncDecl:
+		e.funcDecl(s)
+	case *ReturnStmt:
+		if s.Value != nil {
+			e.expr(s.Value)
+		} else {
+			e.emit(OpPushNull, 0, "", s.Pos().Offset)
+		}
+		e.emit(OpReturn, 0, "", s.Pos().Offset)
+	case *IfStmt:
+		e.expr(s.Cond)
+		jf := e.emit(OpJumpFalse, 0, "", s.Pos().Offset)
+		e.block(s.Then)
+		if s.Else == nil {
+			e.patch(jf, e.here())
+			return
+		}
+		j := e.emit(OpJump, 0, "", s.Pos().Offset)
+		e.patch(jf, e.here())
+		e.stmt(s.Else)
+		e.patch(j, e.here())
+	case *WhileStmt:
+		top := e.here()
+		e.expr(s.Cond)
+		jf := e.emit(OpJumpFalse, 0, "", s.Pos().Offset)
+		l := e.loop(s.Body)
+		e.emit(OpJump, top, "", s.Pos().Offset)
+		e.endLoop(l, top, e.here())
+		e.patch(jf, e.here())
+	case *ForStmt:
+		e.emit(OpEnter, 0, "", s.Pos().Offset)
+		e.depth++
+		if s.Init != nil {
+			e.stmt(s.Init)
+		}
+		top := e.here()
+		jf := -1
+		if s.Cond != nil {
+			e.expr(s.Cond)
+			jf = e.emit(OpJumpFalse, 0, "", s.Pos().Offset)
+		}
+		l := e.loop(s.Body)
+		next := e.here()
+		if s.Post != nil {
+			e.stmt(s.Post)
+		}
+		e.emit(OpJump, top, "", s.Pos().Offset)
+		end := e.here()
+		e.endLoop(l, next, end)
+		if jf >= 0 {
+			e.patch(jf, end)
+		}
+		e.depth--
+		e.emit(OpLeave, 0, "", s.End())
+	case *BranchStmt:
+		if len(e.loops) == 0 {
+			e.fail(s, "%s outside loop", s.Keyword.Lexeme())
+		}
+		l := &e.loops[len(e.loops)-1]
+		for range e.depth - l.depth {
+			e.emit(OpLeave, 0, "", s.Pos().Offset)
+		}
+		j := e.emit(OpJump, 0, "", s.Pos().Offset)
+		if s.Keyword.Lexeme() == "break" {
+			l.breaks = append(l.breaks, j)
+		} else {
+			l.continues = append(l.continues, j)
+		}
+	case *ImportStmt:
+		e.emit(OpImport, 0, s.Path.Token.Lexeme(), s.Pos().Offset)
+	case *Block:
+		e.block(s)
+	case *ExprStmt:
+		e.expr(s.X)
+		e.emit(OpPop, 0, "", s.Pos().Offset)
+	case *RuleNode:
+		e.fail(s, "cannot lower rule %q of a custom grammar", s.Rule)
+	default:
+		e.fail(s, "cannot lower %T", s)
+	}
+}
+
+// loop emits a loop body, returning the loop's pending jumps
+func (e *emitter) loop(body *Block) loop {
+	e.loops = append(e.loops, loop{depth: e.depth})
+	e.block(body)
+	l := e.loops[len(e.loops)-1]
+	e.loops = e.loops[:len(e.loops)-1]
+	return l
+}
+
+// endLoop patches a loop's continues to next and its breaks to end
+func (e *emitter) endLoop(l loop, next, end int32) {
+	for _, j := range l.continues {
+		e.patch(j, next)
+	}
+	for _, j := range l.breaks {
+		e.patch(j, end)
+	}
+}
+
+func (e *emitter) expr(x Expr) {
+	pos := x.Pos().Offset
+	switch x := x.(type) {
+	case *Ident:
+		e.emit(OpLoad, 0, x.Name(), pos)
+	case *Literal:
+		switch t := x.Token; {
+		case t.Type == TokenNumber:
+			e.emit(OpPushNumber, 0, t.Lexeme(), pos)
+		case t.Type == TokenString:
+			e.emit(OpPushString, 0, t.Lexeme(), pos)
+		case t.Lexeme() == "true":
+			e.emit(OpPushTrue, 0, "", pos)
+		case t.Lexeme() == "false":
+			e.emit(OpPushFalse, 0, "", pos)
+		case t.Lexeme() == "null":
+			e.emit(OpPushNull, 0, "", pos)
+		default:
+			e.fail(x, "cannot lower literal %q", t.Lexeme())
+		}
+	case *ParenExpr:
+		e.expr(x.X)
+	case *UnaryExpr:
+		if op := x.Op.Lexeme(); op == "++" || op == "--" {
+			one := func() { e.emit(OpPushNumber, 0, "1", pos) }
+			e.update(x.X, op[:1], one, pos)
+			if x.Postfix {
+				// The stored value is the new one; step back for the old
+				one()
+				e.emit(OpBinary, 0, map[string]string{"++": "-", "--": "+"}[op], pos)
+			}
+			return
+		}
+		e.expr(x.X)
+		e.emit(OpUnary, 0, x.Op.Lexeme(), pos)
+	case *BinaryExpr:
+		if op := x.Op.Lexeme(); op == "&&" || op == "||" {
+			// Short-circuit: the left operand is the result if it decides
+			e.expr(x.X)
+			e.emit(OpDup, 0, "", pos)
+			jump := OpJumpFalse
+			if op == "||" {
+				jump = OpJumpTrue
+			}
+			j := e.emit(jump, 0, "", x.Op.Memory)
+			e.emit(OpPop, 0, "", pos)
+			e.expr(x.Y)
+			e.patch(j, e.here())
+			return
+		}
+		e.expr(x.X)
+		e.expr(x.Y)
+		e.emit(OpBinary, 0, x.Op.Lexeme(), x.Op.Memory)
+	case *AssignExpr:
+		op := strings.TrimSuffix(x.Op.Lexeme(), "=")
+		if op == ":" {
+			op = ""
+		}
+		e.update(x.X, op, func() { e.expr(x.Y) }, x.Op.Memory)
+	case *CallExpr:
+		e.expr(x.Fun)
+		for _, arg := range x.Args {
+			e.expr(arg)
+		}
+		e.emit(OpCall, int32(len(x.Args)), "", x.Lparen.Memory)
+	case *IndexExpr:
+		e.expr(x.X)
+		e.expr(x.Index)
+		e.emit(OpGetIndex, 0, "", x.Lbrack.Memory)
+	case *SelectorExpr:
+		e.expr(x.X)
+		e.emit(OpGetField, 0, x.Sel.Name(), x.Sel.Pos().Offset)
+	case *ListExpr:
+		for _, el := range x.Elems {
+			e.expr(el)
+		}
+		e.emit(OpList, int32(len(x.Elems)), "", pos)
+	default:
+		e.fail(x, "cannot lower %T", x)
+	}
+}
+
+// update assigns to target the value pushed by rhs, combined with the
+// target's current value by binary operator op unless op is "". The
+// assigned value is left on the stack.
+func (e *emitter) update(target Expr, op string, rhs func(), pos uint32) {
+	switch t := target.(type) {
+	case *ParenExpr:
+		e.update(t.X, op, rhs, pos)
+		return
+	case *Ident:
+		if op != "" {
+			e.emit(OpLoad, 0, t.Name(), pos)
+		}
+	case *SelectorExpr:
+		e.expr(t.X)
+		if op != "" {
+			e.emit(OpDup, 0, "", pos)
+			e.emit(OpGetField, 0, t.Sel.Name(), pos)
+		}
+	case *IndexExpr:
+		e.expr(t.X)
+		e.expr(t.Index)
+		if op != "" {
+			e.emit(OpDup2, 0, "", pos)
+			e.emit(OpGetIndex, 0, "", pos)
+		}
+	default:
+		e.fail(target, "cannot assign to %T", target)
+	}
+
+	rhs()
+	if op != "" {
+		e.emit(OpBinary, 0, op, pos)
+	}
+
+	switch t := target.(type) {
+	case *Ident:
+		e.emit(OpStore, 0, t.Name(), pos)
+	case *SelectorExpr:
+		e.emit(OpSetField, 0, t.Sel.Name(), pos)
+	case *IndexExpr:
+		e.emit(OpSetIndex, 0, "", pos)
+	}
+}
+
+// ============================================================================
+// Binary Encoding
+// ============================================================================
+
+// IRVersion is the IR encoding version written by MarshalBinary
+const IRVersion = 1
+
+// irMagic opens an encoded IR module
+const irMagic = "NSIR"
+
+// MarshalBinary encodes the IR in a stable little-endian layout:
+//   header    "NSIR" | version uint16 | function count uint32
+//   function  name | param count uint32 | params | instr count uint32 | instrs
+//   instr     op uint8 | a int32 | pos uint32 | s
+//   string    length uint32 | bytes
+//   trailer   CRC-32 (IEEE) of everything before it
+func (ir *IR) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, 64)
+	b = append(b, irMagic...)
+	b = binary.LittleEndian.AppendUint16(b, IRVersion)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(ir.Funcs)))
+
+	str := func(s string) {
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(s)))
+		b = append(b, s...)
+	}
+	for _, fn := range ir.Funcs {
+		str(fn.Name)
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(fn.Params)))
+		for _, p := range fn.Params {
+			str(p)
+		}
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(fn.Code)))
+		for _, in := range fn.Code {
+			b = append(b, byte(in.Op))
+			b = binary.LittleEndian.AppendUint32(b, uint32(in.A))
+			b = binary.LittleEndian.AppendUint32(b, in.Pos)
+			str(in.S)
+		}
+	}
+
+	return binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(b)), nil
+}
+
+// errIRTruncated reports an encoded IR module that ends early
+var errIRTruncated = errors.New("IR truncated")
+
+// UnmarshalBinary decodes IR written by MarshalBinary, checking its
+// checksum, opcodes, jump targets and function indices
+func (ir *IR) UnmarshalBinary(data []byte) error {
+	if len(data) < len(irMagic)+2+4+4 || string(data[:len(irMagic)]) != irMagic {
+		return errors.New("not an NSIGII IR module")
+	}
+	body, sum := data[:len(data)-4], binary.LittleEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != sum {
+		return errors.New("IR checksum mismatch")
+	}
+	if v := binary.LittleEndian.Uint16(body[len(irMagic):]); v != IRVersion {
+		return fmt.Errorf("unsupported IR version %d", v)
+	}
+
+	r := irReader{b: body[len(irMagic)+2:]}
+	funcs := make([]IRFunc, r.count(12))
+	for i := range funcs {
+		fn := &funcs[i]
+		fn.Name = r.str()
+		for range r.count(4) {
+			fn.Params = append(fn.Params, r.str())
+		}
+		if n := r.count(13); n > 0 {
+			fn.Code = make([]Instr, n)
+		}
+		for j := range fn.Code {
+			in := &fn.Code[j]
+			in.Op = Opcode(r.u8())
+			in.A = int32(r.u32())
+			in.Pos = r.u32()
+			in.S = r.str()
+		}
+	}
+	if r.err != nil {
+		return r.err
+	}
+	if len(r.b) > 0 {
+		return fmt.Errorf("IR has %d trailing bytes", len(r.b))
+	}
+
+	for i, fn := range funcs {
+		for pc, in := range fn.Code {
+			if int(in.Op) >= len(opcodeNames) {
+				return fmt.Errorf("IR func %d instr %d: unknown opcode %d", i, pc, in.Op)
+			}
+			switch in.Op {
+			case OpJump, OpJumpFalse, OpJumpTrue:
+				if in.A < 0 || int(in.A) > len(fn.Code) {
+					return fmt.Errorf("IR func %d instr %d: jump target %d out of range", i, pc, in.A)
+				}
+			case OpFunc:
+				if in.A <= 0 || int(in.A) >= len(funcs) {
+					return fmt.Errorf("IR func %d instr %d: function index %d out of range", i, pc, in.A)
+				}
+			}
+		}
+	}
+
+	ir.Funcs = funcs
+	return nil
+}
+
+// irReader decodes the fields of an IR module, latching the first error
+type irReader struct {
+	b   []byte
+	err error
+}
+
+func (r *irReader) take(n int) []byte {
+	if r.err != nil || len(r.b) < n {
+		r.err = errIRTruncated
+		return nil
+	}
+	p := r.b[:n]
+	r.b = r.b[n:]
+	return p
+}
+
+func (r *irReader) u8() byte {
+	if p := r.take(1); p != nil {
+		return p[0]
+	}
+	return 0
+}
+
+func (r *irReader) u32() uint32 {
+	if p := r.take(4); p != nil {
+		return binary.LittleEndian.Uint32(p)
+	}
+	return 0
+}
+
+// count reads an element count, rejecting counts that could not fit in the
+// remaining input at minSize bytes per element
+func (r *irReader) count(minSize int) int {
+	n := int(r.u32())
+	if n > len(r.b)/minSize {
+		if r.err == nil {
+			r.err = errIRTruncated
+		}
+		return 0
+	}
+	return n
+}
+
+func (r *irReader) str() string {
+	return string(r.take(int(r.u32())))
+}
@@ -0,0 +1,240 @@
+package nsigii
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// ============================================================================
+// JSON Encoding
+// ============================================================================
+
+// MarshalText encodes the token type by its stable name (e.g. "KEYWORD").
+// Types without a name are encoded as their decimal value.
+func (t TokenType) MarshalText() ([]byte, error) {
+	if name, ok := tokenTypeName(t); ok {
+		return []byte(name), nil
+	}
+	return []byte(strconv.Itoa(int(t))), nil
+}
+
+// UnmarshalText decodes a token type name (built-in or registered) or
+// decimal value
+func (t *TokenType) UnmarshalText(text []byte) error {
+	if id, ok := lookupTokenType(string(text)); ok {
+		*t = id
+		return nil
+	}
+
+	id, err := strconv.Atoi(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid token type %q", text)
+	}
+	*t = TokenType(id)
+	return nil
+}
+
+// MarshalText encodes the color channel by its stable name (e.g. "CYAN").
+// Channels without a name are encoded as their decimal value.
+func (c ColorChannel) MarshalText() ([]byte, error) {
+	if c >= 0 && int(c) < len(colorChannelNames) {
+		return []byte(colorChannelNames[c]), nil
+	}
+	return []byte(strconv.Itoa(int(c))), nil
+}
+
+// UnmarshalText decodes a color channel name or decimal value
+func (c *ColorChannel) UnmarshalText(text []byte) error {
+	id, err := lookupName(colorChannelNames, string(text))
+	if err != nil {
+		return fmt.Errorf("invalid color channel %q", text)
+	}
+	*c = ColorChannel(id)
+	return nil
+}
+
+// MarshalText encodes the audit kind by its stable name (e.g. "TRANSITION")
+func (k AuditKind) MarshalText() ([]byte, error) {
+	if k >= 0 && int(k) < len(auditKindNames) {
+		return []byte(auditKindNames[k]), nil
+	}
+	return []byte(strconv.Itoa(int(k))), nil
+}
+
+// UnmarshalText decodes an audit kind name or decimal value
+func (k *AuditKind) UnmarshalText(text []byte) error {
+	id, err := lookupName(auditKindNames, string(text))
+	if err != nil {
+		return fmt.Errorf("invalid audit kind %q", text)
+	}
+	*k = AuditKind(id)
+	return nil
+}
+
+// MarshalText encodes the AUX op by its stable name (e.g. "NOISE")
+func (op AuxOp) MarshalText() ([]byte, error) {
+	if op >= 0 && int(op) < len(auxOpNames) {
+		return []byte(auxOpNames[op]), nil
+	}
+	return []byte(strconv.Itoa(int(op))), nil
+}
+
+// UnmarshalText decodes an AUX op name or decimal value
+func (op *AuxOp) UnmarshalText(text []byte) error {
+	id, err := lookupName(auxOpNames, string(text))
+	if err != nil {
+		return fmt.Errorf("invalid AUX op %q", text)
+	}
+	*op = AuxOp(id)
+	return nil
+}
+
+// MarshalText encodes the severity by its stable name (e.g. "WARNING")
+func (s Severity) MarshalText() ([]byte, error) {
+	if s >= 0 && int(s) < len(severityNames) {
+		return []byte(severityNames[s]), nil
+	}
+	return []byte(strconv.Itoa(int(s))), nil
+}
+
+// UnmarshalText decodes a severity name or decimal value
+func (s *Severity) UnmarshalText(text []byte) error {
+	id, err := lookupName(severityNames, string(text))
+	if err != nil {
+		return fmt.Errorf("invalid severity %q", text)
+	}
+	*s = Severity(id)
+	return nil
+}
+
+// lookupName returns the index of name in names, accepting a decimal value
+// as a fallback
+func lookupName(names []string, name string) (int, error) {
+	for i, n := range names {
+		if n == name {
+			return i, nil
+		}
+	}
+	return strconv.Atoi(name)
+}
+
+// tokenJSON is the wire form of Token
+type tokenJSON struct {
+	Type      TokenType `json:"type"`
+	Memory    uint32    `json:"memory"`
+	Value     uint32    `json:"value"`
+	Text      string    `json:"text"`
+	Line      int       `json:"line,omitempty"`
+	Column    int       `json:"column,omitempty"`
+	EndOffset uint32    `json:"end_offset"`
+
+	RuneOffset uint32 `json:"rune_offset,omitempty"`
+	RuneLength uint32 `json:"rune_length,omitempty"`
+}
+
+// MarshalJSON encodes the token as an object with a named type. Lazy
+// tokens are encoded with their text.
+func (t Token) MarshalJSON() ([]byte, error) {
+	return json.Marshal(tokenJSON{
+		Type:       t.Type,
+		Memory:     t.Memory,
+		Value:      t.Value,
+		Text:       t.Lexeme(),
+		Line:       t.Line,
+		Column:     t.Column,
+		EndOffset:  t.EndOffset,
+		RuneOffset: t.RuneOffset,
+		RuneLength: t.RuneLength,
+	})
+}
+
+// UnmarshalJSON decodes a token produced by MarshalJSON
+func (t *Token) UnmarshalJSON(data []byte) error {
+	var v tokenJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*t = Token{
+		Type:       v.Type,
+		Memory:     v.Memory,
+		Value:      v.Value,
+		Text:       v.Text,
+		Line:       v.Line,
+		Column:     v.Column,
+		EndOffset:  v.EndOffset,
+		RuneOffset: v.RuneOffset,
+		RuneLength: v.RuneLength,
+	}
+	return nil
+}
+
+// statsJSON is the wire form of TokenStats
+type statsJSON struct {
+	TotalTokens      int                     `json:"total_tokens"`
+	TypeDistribution map[TokenType]int       `json:"type_distribution"`
+	MemoryRange      [2]uint32               `json:"memory_range"`
+	AverageLength    float64                 `json:"average_length"`
+	LengthHistogram  Histogram               `json:"length_histogram"`
+	TypeLengths      map[TokenType]Histogram `json:"type_lengths,omitempty"`
+}
+
+// MarshalJSON encodes the stats with type distribution keyed by type name
+func (s TokenStats) MarshalJSON() ([]byte, error) {
+	return json.Marshal(statsJSON(s))
+}
+
+// UnmarshalJSON decodes stats produced by MarshalJSON
+func (s *TokenStats) UnmarshalJSON(data []byte) error {
+	var v statsJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*s = TokenStats(v)
+	return nil
+}
+
+// TokenStreamVersion is the current TokenStream format version
+const TokenStreamVersion = 1
+
+// TokenStream is the persisted form of a tokenization result
+type TokenStream struct {
+	Version int         `json:"version"`
+	Schema  string      `json:"schema,omitempty"` // obinexus.[operation].[service] of the producer
+	Tokens  []Token     `json:"tokens"`
+	Stats   *TokenStats `json:"stats,omitempty"`
+
+	// Origin header, set by Context.Stamp (see VerifyStreamOrigin)
+	Origin *PhantomID `json:"origin,omitempty"` // phantom ID of the producer
+	Seal   []byte     `json:"seal,omitempty"`   // MAC binding Origin to the tokens
+
+	// Signature, set by SignStream (see VerifyStream)
+	Signer    ed25519.PublicKey `json:"signer,omitempty"`    // public key of the signer
+	Signature []byte            `json:"signature,omitempty"` // Ed25519 over CanonicalBytes
+}
+
+// NewTokenStream wraps tokens with their statistics for persistence
+func NewTokenStream(schema string, tokens []Token) *TokenStream {
+	stats := AnalyzeTokens(tokens)
+	return &TokenStream{
+		Version: TokenStreamVersion,
+		Schema:  schema,
+		Tokens:  tokens,
+		Stats:   &stats,
+	}
+}
+
+// UnmarshalJSON decodes a stream, rejecting unsupported versions
+func (s *TokenStream) UnmarshalJSON(data []byte) error {
+	type plain TokenStream
+	var v plain
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Version != TokenStreamVersion {
+		return fmt.Errorf("unsupported token stream version: %d", v.Version)
+	}
+	*s = TokenStream(v)
+	return nil
+}
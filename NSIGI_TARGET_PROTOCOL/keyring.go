@@ -0,0 +1,690 @@
+package nsigii
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Key Rings
+// ============================================================================
+
+const (
+	keySize      = 32 // Length of every key a ring generates
+	gcmNonceSize = 12 // Standard AES-GCM nonce length
+)
+
+// KeyID names one generation of a key ring. Phantom IDs, origin seals,
+// capabilities and snapshots record the ID of the key they were made with,
+// so they keep verifying after the ring rotates. The empty KeyID is the
+// context's untagged phantom key (see SetPhantomKey).
+type KeyID string
+
+// Key is one generation of a key ring
+type Key struct {
+	ID      KeyID     `json:"id"`
+	Secret  []byte    `json:"secret"`
+	Created time.Time `json:"created"`
+}
+
+// KeyRing holds the generations of a context's phantom key: the current one
+// for minting and sealing, and older ones for verifying what they made.
+// Implementations must be safe for concurrent use.
+type KeyRing interface {
+	// Current returns the key new phantom IDs, seals and envelopes use
+	Current() Key
+
+	// Key returns the generation id names, if the ring still holds it
+	Key(id KeyID) (Key, bool)
+
+	// Rotate makes a new generation current, keeping the old ones
+	Rotate() (Key, error)
+}
+
+// SetKeyRing makes the context mint, seal and verify with the keys of ring
+// instead of its phantom key, which still verifies untagged phantom IDs
+// made before. Contexts sharing a ring accept each other's IDs across
+// rotations. A nil ring restores the phantom key.
+//
+// Signing keys derived from the phantom key (see SetSigningKey) follow the
+// current generation, so verifiers must be given the new public key after
+// a rotation.
+//
+// Example:
+//   ring, err := nsigii.OpenFileKeyRing("/etc/nsigii/keys.json")
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   ctx.SetKeyRing(ring)
+//   ...
+//   ring.Rotate() // IDs minted before still verify
+func (c *Context) SetKeyRing(ring KeyRing) error {
+	if err := c.require(RoleAdmin, "SetKeyRing"); err != nil {
+		return err
+	}
+	c.keyRing = ring
+	return nil
+}
+
+// WithKeyRing sets the context's key ring; see SetKeyRing
+func WithKeyRing(ring KeyRing) Option {
+	return func(c *Context) error {
+		return c.SetKeyRing(ring)
+	}
+}
+
+// currentKey returns the key the context mints and seals with
+func (c *Context) currentKey() (KeyID, []byte) {
+	if c.keyRing != nil {
+		k := c.keyRing.Current()
+		return k.ID, k.Secret
+	}
+	return "", c.key()
+}
+
+// keyByID returns the key generation id names
+func (c *Context) keyByID(id KeyID) ([]byte, bool) {
+	if id == "" {
+		return c.key(), true
+	}
+	if c.keyRing == nil {
+		return nil, false
+	}
+	k, ok := c.keyRing.Key(id)
+	return k.Secret, ok
+}
+
+// originKey returns the key that minted id, which also seals what id
+// stamps or attests, so the seal verifies as long as id does
+func (c *Context) originKey(id PhantomID) ([]byte, error) {
+	key, ok := c.keyByID(id.KeyID())
+	if !ok {
+		return nil, fmt.Errorf("key %s is not in the key ring", id.KeyID())
+	}
+	return key, nil
+}
+
+// newKey generates a random key
+func newKey() (Key, error) {
+	id, err := newKeyID()
+	if err != nil {
+		return Key{}, err
+	}
+	k := Key{ID: id, Secret: make([]byte, keySize), Created: time.Now().UTC()}
+	if _, err := rand.Read(k.Secret); err != nil {
+		return Key{}, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return k, nil
+}
+
+// newKeyID generates a random key ID
+func newKeyID() (KeyID, error) {
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", fmt.Errorf("failed to generate key ID: %w", err)
+	}
+	return KeyID(hex.EncodeToString(id[:])), nil
+}
+
+// MemoryKeyRing is a KeyRing held in process memory
+type MemoryKeyRing struct {
+	mu      sync.RWMutex
+	keys    []Key // oldest first
+	current int
+}
+
+// NewMemoryKeyRing creates a ring holding keys, the last of which is
+// current. With no keys it generates one.
+func NewMemoryKeyRing(keys ...Key) (*MemoryKeyRing, error) {
+	r := &MemoryKeyRing{}
+	for _, k := range keys {
+		if err := r.Add(k); err != nil {
+			return nil, err
+		}
+	}
+	if len(r.keys) == 0 {
+		if _, err := r.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Current implements KeyRing
+func (r *MemoryKeyRing) Current() Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.keys[r.current]
+}
+
+// Key implements KeyRing
+func (r *MemoryKeyRing) Key(id KeyID) (Key, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	i := r.index(id)
+	if i < 0 {
+		return Key{}, false
+	}
+	return r.keys[i], true
+}
+
+// Rotate implements KeyRing
+func (r *MemoryKeyRing) Rotate() (Key, error) {
+	k, err := newKey()
+	if err != nil {
+		return Key{}, err
+	}
+	return k, r.Add(k)
+}
+
+// Add adds k to the ring and makes it current
+func (r *MemoryKeyRing) Add(k Key) error {
+	if k.ID == "" || len(k.ID) > phantomMaxField {
+		return fmt.Errorf("invalid key ID %q", k.ID)
+	}
+	if len(k.Secret) == 0 {
+		return fmt.Errorf("key %s has no secret", k.ID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.index(k.ID) >= 0 {
+		return fmt.Errorf("key %s already in ring", k.ID)
+	}
+	k.Secret = slices.Clone(k.Secret)
+	r.keys = append(r.keys, k)
+	r.current = len(r.keys) - 1
+	return nil
+}
+
+// Retire removes an old generation, after which what it made no longer
+// verifies. The current key cannot be retired.
+func (r *MemoryKeyRing) Retire(id KeyID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.index(id)
+	switch {
+	case i < 0:
+		return fmt.Errorf("key %s not in ring", id)
+	case i == r.current:
+		return fmt.Errorf("key %s is current", id)
+	}
+	r.keys = slices.Delete(r.keys, i, i+1)
+	if i < r.current {
+		r.current--
+	}
+	return nil
+}
+
+// Keys returns every generation in the ring, oldest first
+func (r *MemoryKeyRing) Keys() []Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.keys)
+}
+
+// index returns the position of id in r.keys, or -1. The caller holds r.mu.
+func (r *MemoryKeyRing) index(id KeyID) int {
+	return slices.IndexFunc(r.keys, func(k Key) bool { return k.ID == id })
+}
+
+// FileKeyRing is a KeyRing persisted to a JSON file, so every process
+// opening the file shares its generations. The file holds secrets in the
+// clear and is written with mode 0600.
+//
+// Changes are made under an advisory lock on path + ".lock" by reading the
+// file, applying the change and writing it back, so processes rotating or
+// retiring at once do not lose each other's generations. A key ID the ring
+// does not hold reloads the file if it changed, picking up generations
+// other processes added. On platforms without flock the lock only
+// serializes changes within the process.
+type FileKeyRing struct {
+	*MemoryKeyRing
+	path   string
+	mu     sync.Mutex // serializes changes and reloads
+	loaded fileStamp  // the file as last read or written
+}
+
+// fileStamp identifies a version of a file cheaply
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// keyRingFile is the file format of a FileKeyRing
+type keyRingFile struct {
+	Current KeyID `json:"current"`
+	Keys    []Key `json:"keys"`
+}
+
+// OpenFileKeyRing loads the ring stored at path, creating it with one key
+// if the file does not exist
+func OpenFileKeyRing(path string) (*FileKeyRing, error) {
+	r := &FileKeyRing{MemoryKeyRing: &MemoryKeyRing{}, path: path}
+	err := r.locked(func() error {
+		mem, stamp, err := r.read()
+		if !errors.Is(err, os.ErrNotExist) {
+			if err == nil {
+				r.replace(mem, stamp)
+			}
+			return err
+		}
+
+		if mem, err = NewMemoryKeyRing(); err != nil {
+			return err
+		}
+		return r.write(mem)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Key implements KeyRing, reloading the file when id is missing in case
+// another process added it
+func (r *FileKeyRing) Key(id KeyID) (Key, bool) {
+	if k, ok := r.MemoryKeyRing.Key(id); ok {
+		return k, true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if info, err := os.Stat(r.path); err != nil || stampOf(info).equal(r.loaded) {
+		return Key{}, false
+	}
+	err := r.lockFile(func() error {
+		mem, stamp, err := r.read()
+		if err != nil {
+			return err
+		}
+		r.replace(mem, stamp)
+		return nil
+	})
+	if err != nil {
+		return Key{}, false
+	}
+	return r.MemoryKeyRing.Key(id)
+}
+
+// Rotate implements KeyRing, writing the new generation to the file before
+// it becomes current
+func (r *FileKeyRing) Rotate() (Key, error) {
+	var k Key
+	err := r.update(func(mem *MemoryKeyRing) error {
+		var err error
+		k, err = mem.Rotate()
+		return err
+	})
+	if err != nil {
+		return Key{}, err
+	}
+	return k, nil
+}
+
+// Add adds k to the ring and the file and makes it current
+func (r *FileKeyRing) Add(k Key) error {
+	return r.update(func(mem *MemoryKeyRing) error {
+		return mem.Add(k)
+	})
+}
+
+// Retire removes an old generation from the ring and the file
+func (r *FileKeyRing) Retire(id KeyID) error {
+	return r.update(func(mem *MemoryKeyRing) error {
+		return mem.Retire(id)
+	})
+}
+
+// update applies change to the ring as currently stored and writes the
+// result back, all under the file lock. The ring is left as it was if
+// either step fails.
+func (r *FileKeyRing) update(change func(*MemoryKeyRing) error) error {
+	return r.locked(func() error {
+		mem, _, err := r.read()
+		if errors.Is(err, os.ErrNotExist) {
+			// Recreate a deleted file from what the ring last held
+			mem, err = NewMemoryKeyRing(r.Keys()...)
+			if err == nil {
+				mem.current = mem.index(r.Current().ID)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if err := change(mem); err != nil {
+			return err
+		}
+		return r.write(mem)
+	})
+}
+
+// locked runs fn holding both r.mu and the file lock
+func (r *FileKeyRing) locked(fn func() error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lockFile(fn)
+}
+
+// lockFile runs fn holding the file lock. The caller holds r.mu.
+func (r *FileKeyRing) lockFile(fn func() error) error {
+	unlock, err := lockFile(r.path + ".lock")
+	if err != nil {
+		return fmt.Errorf("locking key ring %s: %w", r.path, err)
+	}
+	defer unlock()
+	return fn()
+}
+
+// read loads the ring stored in the file and the file's stamp. The caller
+// holds the file lock.
+func (r *FileKeyRing) read() (*MemoryKeyRing, fileStamp, error) {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return nil, fileStamp{}, err
+	}
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return nil, fileStamp{}, err
+	}
+
+	var f keyRingFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fileStamp{}, fmt.Errorf("invalid key ring %s: %w", r.path, err)
+	}
+	if len(f.Keys) == 0 {
+		return nil, fileStamp{}, fmt.Errorf("key ring %s holds no keys", r.path)
+	}
+	mem, err := NewMemoryKeyRing(f.Keys...)
+	if err != nil {
+		return nil, fileStamp{}, fmt.Errorf("invalid key ring %s: %w", r.path, err)
+	}
+	if mem.current = mem.index(f.Current); mem.current < 0 {
+		return nil, fileStamp{}, fmt.Errorf("key ring %s: current key %s not in ring", r.path, f.Current)
+	}
+	return mem, stampOf(info), nil
+}
+
+// write stores mem in the file atomically and makes it the ring's contents.
+// The caller holds the file lock.
+func (r *FileKeyRing) write(mem *MemoryKeyRing) error {
+	data, err := json.MarshalIndent(keyRingFile{Current: mem.Current().ID, Keys: mem.Keys()}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), ".keyring-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return err
+	}
+
+	var stamp fileStamp
+	if info, err := os.Stat(r.path); err == nil {
+		stamp = stampOf(info)
+	}
+	r.replace(mem, stamp)
+	return nil
+}
+
+// replace makes the ring hold mem's generations, as read from or written
+// to the file version stamp. The caller holds r.mu.
+func (r *FileKeyRing) replace(mem *MemoryKeyRing, stamp fileStamp) {
+	r.loaded = stamp
+	mem.mu.RLock()
+	keys, current := mem.keys, mem.current
+	mem.mu.RUnlock()
+
+	r.MemoryKeyRing.mu.Lock()
+	defer r.MemoryKeyRing.mu.Unlock()
+	r.keys, r.current = keys, current
+}
+
+// stampOf returns the stamp of the file info describes
+func stampOf(info os.FileInfo) fileStamp {
+	return fileStamp{size: info.Size(), modTime: info.ModTime()}
+}
+
+// equal reports whether s and t stamp the same version
+func (s fileStamp) equal(t fileStamp) bool {
+	return s.size == t.size && s.modTime.Equal(t.modTime)
+}
+
+// ============================================================================
+// KMS-Backed Key Rings
+// ============================================================================
+
+// KMS is an external key management service holding a master key that
+// never leaves it. Adapt a cloud KMS client to this interface to back a
+// KMSKeyRing.
+type KMS interface {
+	// GenerateDataKey returns a new data key, in the clear and wrapped
+	// under the master key
+	GenerateDataKey(ctx context.Context) (plaintext, wrapped []byte, err error)
+
+	// Decrypt unwraps a data key made by GenerateDataKey
+	Decrypt(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// WrappedKey is a key generation as stored outside the KMS
+type WrappedKey struct {
+	ID      KeyID     `json:"id"`
+	Wrapped []byte    `json:"wrapped"`
+	Created time.Time `json:"created"`
+}
+
+// KMSKeyRing is a KeyRing whose generations are data keys wrapped by a KMS.
+// Only the wrapped forms are meant to be stored or shared (see Wrapped);
+// the clear keys live in memory only.
+type KMSKeyRing struct {
+	kms     KMS
+	mem     *MemoryKeyRing
+	mu      sync.Mutex
+	wrapped []WrappedKey
+}
+
+// NewKMSKeyRing unwraps the stored generations with kms, the last of which
+// becomes current. With none it generates one.
+//
+// Example:
+//   ring, err := nsigii.NewKMSKeyRing(ctx, awsKMS{client, keyARN}, stored...)
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   defer persist(ring.Wrapped())
+func NewKMSKeyRing(ctx context.Context, kms KMS, stored ...WrappedKey) (*KMSKeyRing, error) {
+	r := &KMSKeyRing{kms: kms, mem: &MemoryKeyRing{}}
+	for _, w := range stored {
+		secret, err := kms.Decrypt(ctx, w.Wrapped)
+		if err != nil {
+			return nil, fmt.Errorf("unwrapping key %s: %w", w.ID, err)
+		}
+		if err := r.mem.Add(Key{ID: w.ID, Secret: secret, Created: w.Created}); err != nil {
+			return nil, err
+		}
+		r.wrapped = append(r.wrapped, w)
+	}
+	if len(stored) == 0 {
+		if _, err := r.rotate(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Current implements KeyRing
+func (r *KMSKeyRing) Current() Key {
+	return r.mem.Current()
+}
+
+// Key implements KeyRing
+func (r *KMSKeyRing) Key(id KeyID) (Key, bool) {
+	return r.mem.Key(id)
+}
+
+// Rotate implements KeyRing, asking the KMS for a new data key
+func (r *KMSKeyRing) Rotate() (Key, error) {
+	return r.rotate(context.Background())
+}
+
+func (r *KMSKeyRing) rotate(ctx context.Context) (Key, error) {
+	secret, wrapped, err := r.kms.GenerateDataKey(ctx)
+	if err != nil {
+		return Key{}, fmt.Errorf("generating data key: %w", err)
+	}
+	id, err := newKeyID()
+	if err != nil {
+		return Key{}, err
+	}
+	k := Key{ID: id, Secret: secret, Created: time.Now().UTC()}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.mem.Add(k); err != nil {
+		return Key{}, err
+	}
+	r.wrapped = append(r.wrapped, WrappedKey{ID: k.ID, Wrapped: wrapped, Created: k.Created})
+	return k, nil
+}
+
+// Wrapped returns every generation in wrapped form, oldest first, for
+// storing and passing to NewKMSKeyRing
+func (r *KMSKeyRing) Wrapped() []WrappedKey {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.wrapped)
+}
+
+// ============================================================================
+// Envelope Encryption
+// ============================================================================
+
+// EncryptedEnvelope is data encrypted under a fresh data key, with the data
+// key wrapped by a generation of the context's key ring
+type EncryptedEnvelope struct {
+	KeyID      KeyID  `json:"key_id"`      // Generation that wrapped the data key
+	WrappedKey []byte `json:"wrapped_key"` // AES-256-GCM, nonce first
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"` // AES-256-GCM of the data
+}
+
+// EncryptEnvelope encrypts plaintext with a new random data key and wraps
+// that key under the context's current key. aad is authenticated but not
+// encrypted, and must be given again to DecryptEnvelope.
+//
+// Example:
+//   data, _ := json.Marshal(stream)
+//   env, err := ctx.EncryptEnvelope(data, []byte(stream.Schema))
+func (c *Context) EncryptEnvelope(plaintext, aad []byte) (*EncryptedEnvelope, error) {
+	if err := c.require(RoleOperator, "EncryptEnvelope"); err != nil {
+		return nil, err
+	}
+	id, key := c.currentKey()
+
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	wrapped, err := sealGCM(envelopeKEK(key, id), dataKey, []byte(id))
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := sealGCM(dataKey, plaintext, aad)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EncryptedEnvelope{
+		KeyID:      id,
+		WrappedKey: wrapped,
+		Nonce:      sealed[:gcmNonceSize],
+		Ciphertext: sealed[gcmNonceSize:],
+	}, nil
+}
+
+// DecryptEnvelope decrypts e with the key generation it names, which must
+// still be in the context's ring
+func (c *Context) DecryptEnvelope(e *EncryptedEnvelope, aad []byte) ([]byte, error) {
+	if err := c.require(RoleOperator, "DecryptEnvelope"); err != nil {
+		return nil, err
+	}
+	key, ok := c.keyByID(e.KeyID)
+	if !ok {
+		return nil, fmt.Errorf("envelope key %s is not in the key ring", e.KeyID)
+	}
+	dataKey, err := openGCM(envelopeKEK(key, e.KeyID), e.WrappedKey, []byte(e.KeyID))
+	if err != nil {
+		return nil, fmt.Errorf("unwrapping envelope key: %w", err)
+	}
+	return openGCM(dataKey, append(slices.Clone(e.Nonce), e.Ciphertext...), aad)
+}
+
+// envelopeKEK derives the key-encryption key of a key generation, so the
+// generation's secret is never used directly as an AES key
+func envelopeKEK(key []byte, id KeyID) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("nsigii envelope key\x00"))
+	mac.Write([]byte(id))
+	return mac.Sum(nil)
+}
+
+// sealGCM encrypts plaintext with AES-256-GCM, returning the nonce followed
+// by the ciphertext
+func sealGCM(key, plaintext, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(plaintext)+gcm.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// openGCM decrypts the output of sealGCM
+func openGCM(key, sealed, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], aad)
+}
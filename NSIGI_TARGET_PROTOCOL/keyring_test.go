@@ -0,0 +1,114 @@
+package nsigii
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestFileKeyRingShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	a, err := OpenFileKeyRing(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := OpenFileKeyRing(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Current().ID != b.Current().ID {
+		t.Fatal("rings opened on one file disagree")
+	}
+
+	// b finds a generation a added after b loaded the file
+	ka, err := a.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.Key(ka.ID); !ok {
+		t.Fatalf("Key(%s) missed a generation added by another ring", ka.ID)
+	}
+
+	// Rotating b keeps a's generation rather than overwriting it
+	kb, err := b.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := OpenFileKeyRing(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []KeyID{ka.ID, kb.ID} {
+		if _, ok := c.Key(id); !ok {
+			t.Errorf("file lost generation %s", id)
+		}
+	}
+	if c.Current().ID != kb.ID {
+		t.Errorf("current = %s, want %s", c.Current().ID, kb.ID)
+	}
+
+	// Add is persisted too
+	k, err := newKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Add(k); err != nil {
+		t.Fatal(err)
+	}
+	d, err := OpenFileKeyRing(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Current().ID != k.ID {
+		t.Errorf("added key %s not saved; current is %s", k.ID, d.Current().ID)
+	}
+}
+
+func TestFileKeyRingConcurrentRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	first, err := OpenFileKeyRing(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const rings, rotations = 4, 5
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		ids = []KeyID{first.Current().ID}
+	)
+	for i := 0; i < rings; i++ {
+		r, err := OpenFileKeyRing(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < rotations; j++ {
+				k, err := r.Rotate()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				ids = append(ids, k.ID)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	final, err := OpenFileKeyRing(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(final.Keys()); got != len(ids) {
+		t.Fatalf("file holds %d generations, want %d", got, len(ids))
+	}
+	for _, id := range ids {
+		if _, ok := final.Key(id); !ok {
+			t.Errorf("file lost generation %s", id)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package nsigii
+
+// ============================================================================
+// Keywords
+// ============================================================================
+
+// DefaultKeywords returns the keyword set built into the RIFT lexer
+func DefaultKeywords() []string {
+	return append([]string(nil), defaultKeywords...)
+}
+
+// SetKeywords replaces the set of words classified as TokenKeyword. Words
+// outside the set that the lexer would treat as keywords are reported as
+// TokenIdentifier instead.
+//
+// A nil slice restores the lexer's built-in set; an empty, non-nil slice
+// disables keywords entirely.
+func (c *Context) SetKeywords(keywords []string) {
+	if keywords == nil {
+		c.keywords = nil
+		return
+	}
+
+	set := make(map[string]bool, len(keywords))
+	for _, kw := range keywords {
+		set[kw] = true
+	}
+	c.keywords = set
+}
+
+// Keywords returns the context's keyword set, or nil if it uses the
+// lexer's built-in set
+func (c *Context) Keywords() []string {
+	if c.keywords == nil {
+		return nil
+	}
+
+	keywords := make([]string, 0, len(c.keywords))
+	for kw := range c.keywords {
+		keywords = append(keywords, kw)
+	}
+	return keywords
+}
+
+// classifyKeywords reclassifies identifier-like tokens against the
+// context's keyword set
+func (c *Context) classifyKeywords(tokens []Token) {
+	for i := range tokens {
+		switch tokens[i].Type {
+		case TokenIdentifier, TokenKeyword:
+			if c.keywords[tokens[i].Lexeme()] {
+				tokens[i].Type = TokenKeyword
+			} else {
+				tokens[i].Type = TokenIdentifier
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package nsigii
+
+import "strings"
+
+// ============================================================================
+// Lazy Token Text
+// ============================================================================
+
+// SetLazyText controls whether tokenization fills in Token.Text. With lazy
+// text on, tokens leave Text empty and share a reference to their source
+// instead, and Lexeme slices each token's text out of it on demand. This
+// saves the text header of every token that is never read, and lets
+// TokenizeFile copy a file once rather than once per token.
+//
+// Everything in this package reads tokens through Lexeme, so lazy tokens
+// can be parsed, validated, signed and encoded as usual; callers that read
+// Text directly should call Materialize first. Lazy tokens keep their whole
+// source alive for as long as any of them is reachable.
+//
+// Example:
+//   ctx.SetLazyText(true)
+//   tokens, _ := ctx.Tokenize(source)
+//   for _, t := range tokens {
+//       if t.Type == nsigii.TokenIdentifier {
+//           fmt.Println(t.Lexeme())
+//       }
+//   }
+func (c *Context) SetLazyText(on bool) {
+	c.lazyText = on
+}
+
+// LazyText reports whether lazy token text is on
+func (c *Context) LazyText() bool {
+	return c.lazyText
+}
+
+// WithLazyText sets lazy token text; see SetLazyText
+func WithLazyText(on bool) Option {
+	return func(c *Context) error {
+		c.SetLazyText(on)
+		return nil
+	}
+}
+
+// Lexeme returns the token's text: Text if it is set, otherwise the span of
+// the retained source that the token covers
+func (t Token) Lexeme() string {
+	if t.src == nil {
+		return t.Text
+	}
+	return tokenText(*t.src, t.Memory, t.Value)
+}
+
+// Materialize fills in the Text of lazy tokens and drops their reference
+// to the source, for callers that read Text directly. The text still shares
+// memory with the source, as with lazy text off.
+func Materialize(tokens []Token) {
+	for i := range tokens {
+		tokens[i].materialize()
+	}
+}
+
+// materialize fills in the Text of a lazy token
+func (t *Token) materialize() {
+	if t.src != nil {
+		t.Text = t.Lexeme()
+		t.src = nil
+	}
+}
+
+// lazySource returns the reference lazy tokens of source share, or nil
+// with lazy text off
+func (c *Context) lazySource(source string) *string {
+	if !c.lazyText {
+		return nil
+	}
+	src := new(string)
+	*src = source
+	return src
+}
+
+// rebase points a lazy token whose offsets have been shifted into source at
+// source
+func (t *Token) rebase(source *string) {
+	if t.src != nil {
+		t.src = source
+	}
+}
+
+// tokenText extracts the text of the token at memory with length value from
+// source. Zero-length tokens inside the source take one byte, tokens that
+// end inside a rune take the rest of it, and tokens past the end are EOF.
+func tokenText(source string, memory, value uint32) string {
+	memPtr := int(memory)
+	if memPtr >= len(source) {
+		return "<EOF>"
+	}
+
+	length := max(int(value), 1)
+	end := min(memPtr+length, len(source))
+	return source[memPtr:runeEnd(source, end)]
+}
+
+// cloneLazy makes lazy tokens that reference a source about to be released
+// reference one private copy of it instead
+func cloneLazy(tokens []Token, source string) {
+	src := new(string)
+	*src = strings.Clone(source)
+	for i := range tokens {
+		tokens[i].rebase(src)
+	}
+}
@@ -0,0 +1,430 @@
+package nsigii
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ============================================================================
+// Go Lexer (RIFT Stage 000-111)
+// ============================================================================
+
+// defaultKeywords is the keyword set recognised by the RIFT lexer
+var defaultKeywords = []string{
+	"let", "const", "var", "func", "fn", "return",
+	"if", "else", "for", "while", "break", "continue",
+	"true", "false", "null", "import", "type", "struct",
+}
+
+// defaultOperators lists the operators recognised as a single token. Any
+// other byte that starts no other token is a one-byte operator.
+var defaultOperators = []string{
+	"<<=", ">>=", "...",
+	"==", "!=", "<=", ">=", "&&", "||", "++", "--",
+	"+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=",
+	"<<", ">>", "->", "=>", "::", ":=",
+}
+
+// defaultDelimiters lists the sequences classified as TokenDelimiter
+var defaultDelimiters = []string{"(", ")", "{", "}", "[", "]", ";", ","}
+
+// lexer is a pure-Go scanner producing the same triplets as the C
+// nsigii_tokenize: whitespace is skipped, every other byte belongs to
+// exactly one token, and the stream ends with an EOF token at len(source)
+type lexer struct {
+	keywords      map[string]bool
+	lineComments  []string    // longest first
+	blockComments [][2]string // open/close pairs
+	quotes        []string    // longest first
+	rawQuotes     map[string]bool
+	operators     []string // longest first, for maximal munch
+	delimiters    []string // longest first, for maximal munch
+	root          lexMode
+	modes         []lexMode
+	first         [256]uint8 // startsX bits of the tables each byte can open
+}
+
+// Bits of lexer.first
+const (
+	startsComment = 1 << iota
+	startsQuote
+	startsDelimiter
+	startsOperator
+)
+
+// lexMode is a compiled LexerMode
+type lexMode struct {
+	text        bool
+	escapes     bool
+	enter       []modeSwitch // longest open first
+	exit        []string     // longest first
+	spaceSwitch bool         // a switch or exit starts with whitespace
+}
+
+// modeSwitch pushes modes[mode] when open is scanned
+type modeSwitch struct {
+	open string
+	mode int
+}
+
+// newLexer builds a lexer from a language profile
+func newLexer(p LanguageProfile) *lexer {
+	l := &lexer{
+		keywords:      make(map[string]bool, len(p.Keywords)),
+		lineComments:  longestFirst(p.LineComments),
+		blockComments: append([][2]string(nil), p.BlockComments...),
+		quotes:        longestFirst(append(append([]string(nil), p.Quotes...), p.RawQuotes...)),
+		rawQuotes:     make(map[string]bool, len(p.RawQuotes)),
+		operators:     longestFirst(p.Operators),
+		delimiters:    longestFirst(p.Delimiters),
+	}
+	for _, kw := range p.Keywords {
+		l.keywords[kw] = true
+	}
+	for _, q := range p.RawQuotes {
+		l.rawQuotes[q] = true
+	}
+	l.classify(startsComment, l.lineComments)
+	for _, bc := range l.blockComments {
+		l.classify(startsComment, bc[:1])
+	}
+	l.classify(startsQuote, l.quotes)
+	l.classify(startsDelimiter, l.delimiters)
+	l.classify(startsOperator, l.operators)
+
+	index := make(map[string]int, len(p.Modes))
+	for i, m := range p.Modes {
+		index[m.Name] = i
+	}
+	l.root = compileMode(LexerMode{Enter: p.Enter}, index)
+	for _, m := range p.Modes {
+		l.modes = append(l.modes, compileMode(m, index))
+	}
+
+	return l
+}
+
+// classify marks the first byte of every entry of table with bit
+func (l *lexer) classify(bit uint8, table []string) {
+	for _, entry := range table {
+		if entry != "" {
+			l.first[entry[0]] |= bit
+		}
+	}
+}
+
+// compileMode resolves a mode's switches to mode indices. Switches to
+// unknown modes are dropped; LanguageProfile.Validate reports them.
+func compileMode(m LexerMode, index map[string]int) lexMode {
+	lm := lexMode{text: m.Text, escapes: m.Escapes, exit: longestFirst(m.Exit)}
+	for _, sw := range m.Enter {
+		if i, ok := index[sw.Mode]; ok && sw.Open != "" {
+			lm.enter = append(lm.enter, modeSwitch{open: sw.Open, mode: i})
+		}
+	}
+	sort.SliceStable(lm.enter, func(i, j int) bool {
+		return len(lm.enter[i].open) > len(lm.enter[j].open)
+	})
+
+	// Whitespace runs are skipped whole unless a switch could start in one
+	for _, sw := range lm.enter {
+		lm.spaceSwitch = lm.spaceSwitch || isSpace(sw.open[0])
+	}
+	for _, exit := range lm.exit {
+		lm.spaceSwitch = lm.spaceSwitch || (exit != "" && isSpace(exit[0]))
+	}
+	return lm
+}
+
+var defaultLexer = newLexer(RIFTProfile)
+
+// scan tokenizes source, reusing dst's storage. With limit > 0, full
+// reports that the source needs more than limit tokens and no triplets are
+// returned.
+func (l *lexer) scan(dst []triplet, source string, limit int) (triplets []triplet, full bool) {
+	triplets = dst[:0]
+	if cap(triplets) == 0 {
+		triplets = make([]triplet, 0, len(source)/4+16)
+	}
+	var stack []int // active nested modes, innermost last
+
+	for i := 0; i < len(source); {
+		mode := &l.root
+		if len(stack) > 0 {
+			mode = &l.modes[stack[len(stack)-1]]
+		}
+
+		start := i
+		var typ TokenType
+
+		if n := matchLength(mode.exit, source[i:]); n > 0 {
+			i += n
+			stack = stack[:len(stack)-1]
+			typ = TokenDelimiter
+		} else if sw := mode.switchAt(source[i:]); sw != nil {
+			i += len(sw.open)
+			stack = append(stack, sw.mode)
+			typ = TokenDelimiter
+		} else if mode.text {
+			i = mode.scanText(source, i)
+			typ = TokenString
+		} else if isSpace(source[i]) {
+			if mode.spaceSwitch {
+				i++
+			} else {
+				i += spaceRun(source[i:])
+			}
+			continue
+		} else {
+			typ, i = l.token(source, i)
+		}
+
+		triplets = append(triplets, triplet{typ: typ, memory: uint32(start), value: uint32(i - start)})
+		if limit > 0 && len(triplets) >= limit {
+			return nil, true
+		}
+	}
+
+	triplets = append(triplets, triplet{typ: TokenEOF, memory: uint32(len(source))})
+	return triplets, false
+}
+
+// modeAt replays the mode switches among tokens, a stream scanned by l, up
+// to offset. It returns the depth of the mode stack there and the offset of
+// the switch that entered the outermost open mode.
+//
+// Scan tries exits and switches before any other token, so a delimiter is
+// a switch exactly when its text is one of the current mode's.
+func (l *lexer) modeAt(tokens []Token, offset int) (depth, entered int) {
+	var stack []int
+	for _, t := range tokens {
+		if t.Type == TokenEOF || int(t.EndOffset) > offset {
+			break
+		}
+		if t.Type != TokenDelimiter {
+			continue
+		}
+
+		mode := &l.root
+		if len(stack) > 0 {
+			mode = &l.modes[stack[len(stack)-1]]
+		}
+		text := t.Lexeme()
+		if slices.Contains(mode.exit, text) {
+			stack = stack[:len(stack)-1]
+		} else if sw := mode.switchAt(text); sw != nil && sw.open == text {
+			if len(stack) == 0 {
+				entered = int(t.Memory)
+			}
+			stack = append(stack, sw.mode)
+		}
+	}
+	return len(stack), entered
+}
+
+// token scans the token starting at the non-space byte source[i], returning
+// its type and end offset
+func (l *lexer) token(source string, i int) (TokenType, int) {
+	start := i
+	ch := source[i]
+	var typ TokenType
+
+	switch {
+	case isIdentStart(ch) || isLetterRune(source[i:]):
+		i += identLength(source[i:])
+		typ = TokenIdentifier
+		if l.keywords[source[start:i]] {
+			typ = TokenKeyword
+		}
+
+	case isDigit(ch):
+		for i < len(source) {
+			i += identRun(source[i:])
+			if i == len(source) || source[i] != '.' {
+				break
+			}
+			i++
+		}
+		typ = TokenNumber
+
+	default:
+		// Only the tables with an entry starting with ch can match
+		first := l.first[ch]
+
+		if first&startsComment != 0 {
+			if n := l.comment(source[i:]); n > 0 {
+				i += n
+				typ = TokenComment
+				break
+			}
+		}
+
+		if first&startsQuote != 0 && matchLength(l.quotes, source[i:]) > 0 {
+			i = l.scanString(source, i)
+			typ = TokenString
+			break
+		}
+
+		if first&startsDelimiter != 0 {
+			if n := matchLength(l.delimiters, source[i:]); n > 0 {
+				i += n
+				typ = TokenDelimiter
+				break
+			}
+		}
+
+		n := 0
+		if first&startsOperator != 0 {
+			n = matchLength(l.operators, source[i:])
+		}
+		if n == 0 {
+			_, n = utf8.DecodeRuneInString(source[i:])
+		}
+		i += n
+		typ = TokenOperator
+	}
+
+	return typ, i
+}
+
+// switchAt returns the mode switch opening at the start of s, if any
+func (m *lexMode) switchAt(s string) *modeSwitch {
+	for i := range m.enter {
+		if strings.HasPrefix(s, m.enter[i].open) {
+			return &m.enter[i]
+		}
+	}
+	return nil
+}
+
+// scanText returns the end of the literal text run starting at start in a
+// text mode: the next mode switch or exit, or the end of the source
+func (m *lexMode) scanText(source string, start int) int {
+	i := start
+	for i < len(source) {
+		if i > start && (matchLength(m.exit, source[i:]) > 0 || m.switchAt(source[i:]) != nil) {
+			break
+		}
+		if m.escapes && source[i] == '\\' {
+			i++
+		}
+		i++
+	}
+	if i > len(source) {
+		i = len(source)
+	}
+	return i
+}
+
+// comment returns the length of the comment at the start of s, or 0.
+// Unterminated block comments run to the end of s.
+func (l *lexer) comment(s string) int {
+	if n := matchLength(l.lineComments, s); n > 0 {
+		if end := strings.IndexByte(s, '\n'); end >= 0 {
+			return end
+		}
+		return len(s)
+	}
+
+	for _, bc := range l.blockComments {
+		if bc[0] == "" || !strings.HasPrefix(s, bc[0]) {
+			continue
+		}
+		end := strings.Index(s[len(bc[0]):], bc[1])
+		if end < 0 {
+			return len(s)
+		}
+		return len(bc[0]) + end + len(bc[1])
+	}
+
+	return 0
+}
+
+// scanString returns the offset just past the string literal opening at
+// start. The literal closes with the same delimiter it opened with;
+// backslash escapes are honoured unless the delimiter is raw. Unterminated
+// strings run to the end of the source.
+func (l *lexer) scanString(source string, start int) int {
+	quote := source[start : start+matchLength(l.quotes, source[start:])]
+	raw := l.rawQuotes[quote]
+
+	for i := start + len(quote); i < len(source); i++ {
+		if source[i] == '\\' && !raw {
+			i++
+			continue
+		}
+		if strings.HasPrefix(source[i:], quote) {
+			return i + len(quote)
+		}
+	}
+	return len(source)
+}
+
+// matchLength returns the length of the first entry of table that prefixes
+// s, or 0 if none does
+func matchLength(table []string, s string) int {
+	for _, entry := range table {
+		if entry != "" && strings.HasPrefix(s, entry) {
+			return len(entry)
+		}
+	}
+	return 0
+}
+
+// longestFirst returns a copy of table sorted by descending length
+func longestFirst(table []string) []string {
+	sorted := append([]string(nil), table...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i]) > len(sorted[j])
+	})
+	return sorted
+}
+
+func isSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == '\v' || ch == '\f'
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isIdentStart(ch byte) bool {
+	return ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+func isIdentPart(ch byte) bool {
+	return isIdentStart(ch) || isDigit(ch)
+}
+
+// isLetterRune reports whether s starts with a non-ASCII letter
+func isLetterRune(s string) bool {
+	if len(s) == 0 || s[0] < utf8.RuneSelf {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsLetter(r)
+}
+
+// identLength returns the length of the identifier at the start of s,
+// accepting Unicode letters and digits after the first character
+func identLength(s string) int {
+	i := identRun(s)
+	for i < len(s) {
+		if isIdentPart(s[i]) {
+			i++
+			continue
+		}
+		if s[i] < utf8.RuneSelf {
+			break
+		}
+		r, n := utf8.DecodeRuneInString(s[i:])
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		i += n
+	}
+	return i
+}
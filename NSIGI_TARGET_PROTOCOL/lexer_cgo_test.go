@@ -0,0 +1,63 @@
+//go:build cgo && !purego
+
+package nsigii
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestLexerConformance checks that the pure-Go lexer produces the same
+// triplets as the C nsigii_tokenize it stands in for under purego
+func TestLexerConformance(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{"empty", ""},
+		{"whitespace", " \t\r\n  "},
+		{"identifiers", "alpha _beta gamma_2"},
+		{"keywords", "let x = fn return if else while"},
+		{"numbers", "0 42 3.14 1.2.3 7abc"},
+		{"operators", "a <<= b >>= c ... d == e != f && g || h ++ i -- j -> k => l :: m := n"},
+		{"single operators", "+ - * / % ! ~ ^ & | < > = ? : ."},
+		{"delimiters", "f(a, b)[0]{x;}"},
+		{"line comment", "x // comment\ny"},
+		{"line comment at end", "x // comment"},
+		{"block comment", "x /* a\nb */ y"},
+		{"unterminated block comment", "x /* a b"},
+		{"double quoted", `"a b" "esc \" quote" "back\\"`},
+		{"single quoted", `'c' '\''`},
+		{"backquoted", "`raw \\` text`"},
+		{"unterminated string", `x = "abc`},
+		{"statement", "let total = count * 2 + offset; // running total\n"},
+		{"program", "func add(a, b) {\n\treturn a + b;\n}\n\nlet s = \"sum: \" + add(1, 2);\n"},
+	}
+
+	ctx, err := NewContext("tokenize", "lexer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, _ := defaultLexer.scan(nil, tt.source, 0)
+
+			var (
+				got    []triplet
+				result int
+			)
+			err := ctx.withNative(func(nc *nativeContext) {
+				got, _, result = nativeTokenize(nc, nil, tt.source, 0)
+			})
+			if err != nil || result != 0 {
+				t.Fatalf("nativeTokenize(%q) = %d, %v", tt.source, result, err)
+			}
+
+			if !slices.Equal(got, want) {
+				t.Errorf("tokens of %q differ\nC:  %v\nGo: %v", tt.source, got, want)
+			}
+		})
+	}
+}
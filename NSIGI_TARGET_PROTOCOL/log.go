@@ -0,0 +1,109 @@
+package nsigii
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+)
+
+// ============================================================================
+// Structured Logging
+// ============================================================================
+
+// Contexts log structured events to a log/slog logger. Nothing is logged
+// until a logger is set, either for every context with SetDefaultLogger or
+// for one context with SetLogger.
+//
+// Events, each with a "schema" attribute:
+//   Debug "nsigii context created"
+//   Debug "nsigii context closed"
+//   Info  "nsigii color transition"  from, to, reason; Warn into MAGENTA or BLACK
+//   Debug "nsigii consensus check"   passed, state; Warn if not passed
+//   Error "nsigii operation failed"  op, error, duration
+
+var defaultLogger atomic.Pointer[slog.Logger]
+
+// SetDefaultLogger sets the logger of contexts that have none of their own.
+// nil silences them.
+//
+// Example:
+//   nsigii.SetDefaultLogger(slog.Default().With("component", "nsigii"))
+func SetDefaultLogger(l *slog.Logger) {
+	defaultLogger.Store(l)
+}
+
+// SetLogger sets the context's logger, overriding the default logger. nil
+// restores the default.
+func (c *Context) SetLogger(l *slog.Logger) {
+	c.logger = l
+}
+
+// WithLogger sets the context's logger; see SetLogger
+func WithLogger(l *slog.Logger) Option {
+	return func(c *Context) error {
+		c.SetLogger(l)
+		return nil
+	}
+}
+
+// log returns the context's logger, nil if it is silent
+func (c *Context) log() *slog.Logger {
+	if c.logger != nil {
+		return c.logger
+	}
+	return defaultLogger.Load()
+}
+
+// schema returns the context's schema without a native call
+func (c *Context) schema() string {
+	return fmt.Sprintf("obinexus.%s.%s", c.operation, c.service)
+}
+
+// logEvent logs msg at level with the context's schema and attrs, if the
+// context's logger is enabled for level
+func (c *Context) logEvent(level slog.Level, msg string, attrs ...slog.Attr) {
+	l := c.log()
+	if l == nil || !l.Enabled(context.Background(), level) {
+		return
+	}
+	attrs = append([]slog.Attr{slog.String("schema", c.schema())}, attrs...)
+	l.LogAttrs(context.Background(), level, msg, attrs...)
+}
+
+// logTransition logs a color transition
+func (c *Context) logTransition(t ColorTransition) {
+	level := slog.LevelInfo
+	if t.To == ColorMagenta || t.To == ColorBlack {
+		level = slog.LevelWarn
+	}
+	attrs := []slog.Attr{
+		slog.String("from", t.From.String()),
+		slog.String("to", t.To.String()),
+	}
+	if t.Reason != "" {
+		attrs = append(attrs, slog.String("reason", t.Reason))
+	}
+	c.logEvent(level, "nsigii color transition", attrs...)
+}
+
+// logOperation logs a finished operation: consensus results, and failures
+// of any operation
+func (c *Context) logOperation(e Event) {
+	if e.Err != nil {
+		c.logEvent(slog.LevelError, "nsigii operation failed",
+			slog.String("op", e.Op.String()),
+			slog.Any("error", e.Err),
+			slog.Duration("duration", e.Duration))
+		return
+	}
+	if e.Op == OpConsensus {
+		level := slog.LevelDebug
+		if !e.Passed {
+			level = slog.LevelWarn
+		}
+		c.logEvent(level, "nsigii consensus check",
+			slog.Bool("passed", e.Passed),
+			slog.String("state", c.ColorState().String()))
+	}
+}
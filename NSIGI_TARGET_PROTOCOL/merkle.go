@@ -0,0 +1,246 @@
+package nsigii
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// ============================================================================
+// Merkle Trees
+// ============================================================================
+
+// MerkleHash is a node hash of a token Merkle tree
+type MerkleHash [sha256.Size]byte
+
+// String returns the hash in hex
+func (h MerkleHash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// MarshalText encodes the hash in hex
+func (h MerkleHash) MarshalText() ([]byte, error) {
+	return []byte(h.String()), nil
+}
+
+// UnmarshalText decodes a hash produced by MarshalText
+func (h *MerkleHash) UnmarshalText(text []byte) error {
+	if hex.DecodedLen(len(text)) != len(h) {
+		return fmt.Errorf("merkle hash: want %d hex digits, got %d", 2*len(h), len(text))
+	}
+	_, err := hex.Decode(h[:], text)
+	return err
+}
+
+// MerkleTree is a binary hash tree over a token stream. Each leaf commits to
+// a token and its position, so a proof shows both that a token belongs to
+// the stream and where.
+//
+// Leaves are SHA-256 of 0x00, the index and the token; interior nodes are
+// SHA-256 of 0x01 and their children. A level with an odd node out carries
+// it up unchanged.
+type MerkleTree struct {
+	levels [][]MerkleHash // levels[0] are the leaves, the last level the root
+}
+
+// MerkleProof is the sibling path from a leaf to the root
+type MerkleProof struct {
+	Index int          `json:"index"` // Position of the token in the stream
+	Count int          `json:"count"` // Tokens in the stream
+	Path  []MerkleHash `json:"path"`  // Siblings, leaf level first
+}
+
+// BuildMerkle builds the Merkle tree of tokens
+//
+// Example:
+//   tree := nsigii.BuildMerkle(tokens)
+//   proof, _ := tree.Proof(3)
+//   ok := nsigii.VerifyMerkleProof(tree.Root(), tokens[3], proof)
+func BuildMerkle(tokens []Token) *MerkleTree {
+	level := make([]MerkleHash, len(tokens))
+	for i, t := range tokens {
+		level[i] = merkleLeaf(i, t)
+	}
+
+	t := &MerkleTree{levels: [][]MerkleHash{level}}
+	for len(level) > 1 {
+		next := make([]MerkleHash, (len(level)+1)/2)
+		for i := range next {
+			if 2*i+1 < len(level) {
+				next[i] = merkleNode(level[2*i], level[2*i+1])
+			} else {
+				next[i] = level[2*i]
+			}
+		}
+		t.levels = append(t.levels, next)
+		level = next
+	}
+	return t
+}
+
+// Len returns the number of tokens in the tree
+func (t *MerkleTree) Len() int {
+	return len(t.levels[0])
+}
+
+// Root returns the root hash. The root of an empty stream is the SHA-256 of
+// nothing.
+func (t *MerkleTree) Root() MerkleHash {
+	top := t.levels[len(t.levels)-1]
+	if len(top) == 0 {
+		return sha256.Sum256(nil)
+	}
+	return top[0]
+}
+
+// Proof returns the inclusion proof of the token at index i
+func (t *MerkleTree) Proof(i int) (MerkleProof, error) {
+	if i < 0 || i >= t.Len() {
+		return MerkleProof{}, fmt.Errorf("merkle proof: index %d out of range [0, %d)", i, t.Len())
+	}
+
+	p := MerkleProof{Index: i, Count: t.Len()}
+	for _, level := range t.levels[:len(t.levels)-1] {
+		if sibling := i ^ 1; sibling < len(level) {
+			p.Path = append(p.Path, level[sibling])
+		}
+		i /= 2
+	}
+	return p, nil
+}
+
+// VerifyMerkleProof reports whether proof shows that tok is the token at
+// proof.Index of the stream with the given root
+func VerifyMerkleProof(root MerkleHash, tok Token, proof MerkleProof) bool {
+	i, n := proof.Index, proof.Count
+	if i < 0 || i >= n {
+		return false
+	}
+
+	h := merkleLeaf(i, tok)
+	path := proof.Path
+	for ; n > 1; i, n = i/2, (n+1)/2 {
+		sibling := i ^ 1
+		if sibling >= n {
+			continue // Carried up unchanged
+		}
+		if len(path) == 0 {
+			return false
+		}
+		if i%2 == 0 {
+			h = merkleNode(h, path[0])
+		} else {
+			h = merkleNode(path[0], h)
+		}
+		path = path[1:]
+	}
+	return len(path) == 0 && h == root
+}
+
+// merkleLeaf hashes the token at index i
+func merkleLeaf(i int, t Token) MerkleHash {
+	buf := make([]byte, 0, 21+len(t.Lexeme()))
+	buf = append(buf, 0x00)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(i))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(t.Type))
+	buf = binary.BigEndian.AppendUint32(buf, t.Memory)
+	buf = binary.BigEndian.AppendUint32(buf, t.Value)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(t.Lexeme())))
+	buf = append(buf, t.Lexeme()...)
+	return sha256.Sum256(buf)
+}
+
+// merkleNode hashes two children
+func merkleNode(left, right MerkleHash) MerkleHash {
+	var buf [1 + 2*sha256.Size]byte
+	buf[0] = 0x01
+	copy(buf[1:], left[:])
+	copy(buf[1+sha256.Size:], right[:])
+	return sha256.Sum256(buf[:])
+}
+
+// ============================================================================
+// Merkle Attestation
+// ============================================================================
+
+// MerkleAttestation is a producer's signed claim to a token stream's Merkle
+// root. A consumer holding the attestation can check single tokens with
+// VerifyMerkleProof without receiving the rest of the stream.
+type MerkleAttestation struct {
+	Origin PhantomID  `json:"origin"` // Phantom ID of the producer
+	Schema string     `json:"schema"`
+	Root   MerkleHash `json:"root"`
+	Count  int        `json:"count"` // Tokens in the stream
+	Seal   []byte     `json:"seal"`  // MAC binding the fields above
+}
+
+// AttestMerkle attests the root of tree with the context's current phantom
+// ID and schema
+func (c *Context) AttestMerkle(tree *MerkleTree) (MerkleAttestation, error) {
+	if err := c.require(RoleOperator, "AttestMerkle"); err != nil {
+		return MerkleAttestation{}, err
+	}
+	id, err := c.PhantomID()
+	if err != nil {
+		return MerkleAttestation{}, err
+	}
+	schema, err := c.Schema()
+	if err != nil {
+		return MerkleAttestation{}, err
+	}
+
+	key, err := c.originKey(id)
+	if err != nil {
+		return MerkleAttestation{}, err
+	}
+
+	a := MerkleAttestation{Origin: id, Schema: schema, Root: tree.Root(), Count: tree.Len()}
+	a.Seal = merkleSeal(key, a)
+	return a, nil
+}
+
+// VerifyMerkleAttestation checks that a was made by a context that ctx
+// trusts (see Context.VerifyPhantomID), that its origin names its schema,
+// and that it has not been altered
+func VerifyMerkleAttestation(ctx *Context, a MerkleAttestation) (bool, error) {
+	if a.Origin.IsZero() {
+		return false, errors.New("merkle attestation has no origin")
+	}
+
+	ok, err := ctx.VerifyPhantomID(a.Origin)
+	if err != nil || !ok {
+		return false, err
+	}
+	if a.Origin.Schema() != a.Schema {
+		return false, nil
+	}
+
+	key, err := ctx.originKey(a.Origin)
+	if err != nil {
+		return false, nil
+	}
+	return hmac.Equal(merkleSeal(key, a), a.Seal), nil
+}
+
+// merkleSeal computes the MAC over an attestation's fields
+func merkleSeal(key []byte, a MerkleAttestation) []byte {
+	h := hmac.New(sha256.New, key)
+
+	var buf []byte
+	field := func(v string) {
+		buf = binary.BigEndian.AppendUint32(buf[:0], uint32(len(v)))
+		h.Write(buf)
+		h.Write([]byte(v))
+	}
+
+	field(a.Origin.String())
+	field(a.Schema)
+	h.Write(a.Root[:])
+	buf = binary.BigEndian.AppendUint64(buf[:0], uint64(a.Count))
+	h.Write(buf)
+
+	return h.Sum(nil)
+}
@@ -0,0 +1,84 @@
+package nsigii
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+)
+
+// merkleTokens returns n distinct identifier tokens
+func merkleTokens(n int) []Token {
+	tokens := make([]Token, n)
+	for i := range tokens {
+		text := fmt.Sprintf("id%d", i)
+		tokens[i] = Token{Type: TokenIdentifier, Memory: uint32(4 * i), Value: uint32(len(text)), Text: text}
+	}
+	return tokens
+}
+
+func TestMerkleProofs(t *testing.T) {
+	if root := BuildMerkle(nil).Root(); root != sha256.Sum256(nil) {
+		t.Errorf("root of an empty stream = %s", root)
+	}
+
+	// Odd counts carry a node up unchanged at one or more levels
+	for n := 1; n <= 9; n++ {
+		tokens := merkleTokens(n)
+		tree := BuildMerkle(tokens)
+		root := tree.Root()
+		for i, tok := range tokens {
+			proof, err := tree.Proof(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !VerifyMerkleProof(root, tok, proof) {
+				t.Errorf("n=%d: proof of token %d does not verify", n, i)
+			}
+
+			other := tok
+			other.Text += "x"
+			if VerifyMerkleProof(root, other, proof) {
+				t.Errorf("n=%d: proof of token %d verifies a different token", n, i)
+			}
+			if n > 1 {
+				moved := proof
+				moved.Index = (i + 1) % n
+				if VerifyMerkleProof(root, tok, moved) {
+					t.Errorf("n=%d: proof of token %d verifies at index %d", n, i, moved.Index)
+				}
+			}
+		}
+		if _, err := tree.Proof(n); err == nil {
+			t.Errorf("n=%d: Proof(%d) succeeded", n, n)
+		}
+	}
+}
+
+func TestMerkleAttestationTampered(t *testing.T) {
+	ctx, err := NewContext("tokenize", "lexer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+
+	tree := BuildMerkle(merkleTokens(5))
+	a, err := ctx.AttestMerkle(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := VerifyMerkleAttestation(ctx, a); !ok || err != nil {
+		t.Fatalf("VerifyMerkleAttestation(untouched) = %t, %v", ok, err)
+	}
+
+	root := a
+	root.Root = BuildMerkle(merkleTokens(6)).Root()
+	count := a
+	count.Count++
+	schema := a
+	schema.Schema = "obinexus.tokenize.other"
+	for name, tampered := range map[string]MerkleAttestation{"root": root, "count": count, "schema": schema} {
+		if ok, _ := VerifyMerkleAttestation(ctx, tampered); ok {
+			t.Errorf("attestation with a changed %s verifies", name)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package nsigii
+
+import "time"
+
+// ============================================================================
+// Channel Metrics
+// ============================================================================
+
+// ChannelMetrics counts a context's activity in one color channel
+type ChannelMetrics struct {
+	Time           time.Duration // Total time spent in the channel
+	TransitionsIn  int           // Transitions into the channel
+	TransitionsOut int           // Transitions out of the channel
+	ConsensusPass  int           // RGB consensus checks passed while in the channel
+	ConsensusFail  int           // RGB consensus checks failed while in the channel
+}
+
+// channelCounters accumulates ChannelMetrics for every channel. It is
+// guarded by colorState.mu.
+type channelCounters struct {
+	since   time.Time // when the current channel was entered
+	metrics [ColorContrast + 1]ChannelMetrics
+}
+
+// ChannelMetrics returns the context's per-channel metrics, including the
+// time spent so far in its current channel
+//
+// Example:
+//   for ch, m := range ctx.ChannelMetrics() {
+//       fmt.Printf("%-8s %v in=%d out=%d\n", ch, m.Time, m.TransitionsIn, m.TransitionsOut)
+//   }
+func (c *Context) ChannelMetrics() map[ColorChannel]ChannelMetrics {
+	c.color.mu.Lock()
+	defer c.color.mu.Unlock()
+
+	out := make(map[ColorChannel]ChannelMetrics, len(c.color.counters.metrics))
+	for i, m := range c.color.counters.metrics {
+		out[ColorChannel(i)] = m
+	}
+
+	current := out[c.color.channel]
+	current.Time += time.Since(c.color.counters.since)
+	out[c.color.channel] = current
+
+	return out
+}
+
+// countTransition records a move between channels at time at. The caller
+// holds colorState.mu.
+func (s *colorState) countTransition(from, to ColorChannel, at time.Time) {
+	m := &s.counters.metrics
+	m[from].Time += at.Sub(s.counters.since)
+	m[from].TransitionsOut++
+	m[to].TransitionsIn++
+	s.counters.since = at
+}
+
+// countConsensus records a consensus check made in the current channel
+func (s *colorState) countConsensus(passed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if passed {
+		s.counters.metrics[s.channel].ConsensusPass++
+	} else {
+		s.counters.metrics[s.channel].ConsensusFail++
+	}
+}
@@ -0,0 +1,181 @@
+// Package metrics exports NSIGII activity as Prometheus metrics. A
+// Collector is both a prometheus.Collector and an nsigii.Instrument: set it
+// as the instrument of the contexts to observe, usually as the default, and
+// register it with Prometheus.
+//
+// Example:
+//   collector := metrics.NewCollector(metrics.Options{})
+//   prometheus.MustRegister(collector)
+//   nsigii.SetDefaultInstrument(collector)
+//
+// Metrics, with the default namespace:
+//   nsigii_tokenize_total{schema,result}           tokenizations, result "ok" or "error"
+//   nsigii_tokens_total{schema}                    tokens produced; rate() gives tokens/sec
+//   nsigii_tokenize_bytes_total{schema}            source bytes tokenized
+//   nsigii_tokenize_duration_seconds{schema}       tokenization latency
+//   nsigii_consensus_checks_total{schema,result}   result "passed", "failed" or "error"
+//   nsigii_aux_operations_total{schema,op,result}  op "start" or "stop"
+//   nsigii_stage_duration_seconds{stage,result}    pipeline stage latency
+//   nsigii_contexts{color}                         live contexts in a registry by color state
+package metrics
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/obinexus/nsigii-rift/nsigii"
+)
+
+// Options configures a Collector
+type Options struct {
+	Namespace string // Metric name prefix; "" means "nsigii"
+
+	// Registry supplies the contexts counted by nsigii_contexts; nil means
+	// nsigii.DefaultRegistry. Only contexts created with WithRegistry are
+	// counted.
+	Registry *nsigii.Registry
+
+	// Buckets are the latency histogram buckets in seconds; nil means
+	// exponential buckets from 10µs to about 2.6s
+	Buckets []float64
+}
+
+// Collector collects NSIGII metrics
+type Collector struct {
+	registry *nsigii.Registry
+
+	tokenizes *prometheus.CounterVec
+	tokens    *prometheus.CounterVec
+	bytes     *prometheus.CounterVec
+	latency   *prometheus.HistogramVec
+	consensus *prometheus.CounterVec
+	aux       *prometheus.CounterVec
+	stages    *prometheus.HistogramVec
+	contexts  *prometheus.Desc
+}
+
+// NewCollector creates a collector
+func NewCollector(opts Options) *Collector {
+	if opts.Namespace == "" {
+		opts.Namespace = "nsigii"
+	}
+	if opts.Registry == nil {
+		opts.Registry = nsigii.DefaultRegistry
+	}
+	if opts.Buckets == nil {
+		opts.Buckets = prometheus.ExponentialBuckets(1e-5, 4, 10)
+	}
+	ns := opts.Namespace
+
+	return &Collector{
+		registry: opts.Registry,
+		tokenizes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: ns, Name: "tokenize_total",
+			Help: "Tokenizations by schema and result.",
+		}, []string{"schema", "result"}),
+		tokens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: ns, Name: "tokens_total",
+			Help: "Tokens produced by successful tokenizations.",
+		}, []string{"schema"}),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: ns, Name: "tokenize_bytes_total",
+			Help: "Source bytes tokenized, successfully or not.",
+		}, []string{"schema"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: ns, Name: "tokenize_duration_seconds",
+			Help:    "Tokenization latency.",
+			Buckets: opts.Buckets,
+		}, []string{"schema"}),
+		consensus: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: ns, Name: "consensus_checks_total",
+			Help: "RGB consensus checks by schema and result.",
+		}, []string{"schema", "result"}),
+		aux: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: ns, Name: "aux_operations_total",
+			Help: "AUX noise starts and stops by schema and result.",
+		}, []string{"schema", "op", "result"}),
+		stages: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: ns, Name: "stage_duration_seconds",
+			Help:    "RIFT pipeline stage latency.",
+			Buckets: opts.Buckets,
+		}, []string{"stage", "result"}),
+		contexts: prometheus.NewDesc(prometheus.BuildFQName(ns, "", "contexts"),
+			"Live registered contexts by color state.", []string{"color"}, nil),
+	}
+}
+
+// Observe implements nsigii.Instrument
+func (c *Collector) Observe(_ *nsigii.Context, e nsigii.Event) {
+	switch e.Op {
+	case nsigii.OpTokenize:
+		c.tokenizes.WithLabelValues(e.Schema, result(e.Err)).Inc()
+		c.bytes.WithLabelValues(e.Schema).Add(float64(e.Bytes))
+		c.latency.WithLabelValues(e.Schema).Observe(e.Duration.Seconds())
+		if e.Err == nil {
+			c.tokens.WithLabelValues(e.Schema).Add(float64(e.Tokens))
+		}
+	case nsigii.OpConsensus:
+		r := "passed"
+		switch {
+		case e.Err != nil:
+			r = "error"
+		case !e.Passed:
+			r = "failed"
+		}
+		c.consensus.WithLabelValues(e.Schema, r).Inc()
+	case nsigii.OpAuxStart:
+		c.aux.WithLabelValues(e.Schema, "start", result(e.Err)).Inc()
+	case nsigii.OpAuxStop:
+		c.aux.WithLabelValues(e.Schema, "stop", result(e.Err)).Inc()
+	case nsigii.OpStage:
+		// StageID.String is "001 parse"; the name alone makes a better label
+		stage := e.Stage.String()
+		if i := strings.IndexByte(stage, ' '); i >= 0 {
+			stage = stage[i+1:]
+		}
+		c.stages.WithLabelValues(stage, result(e.Err)).Observe(e.Duration.Seconds())
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.tokenizes.Describe(ch)
+	c.tokens.Describe(ch)
+	c.bytes.Describe(ch)
+	c.latency.Describe(ch)
+	c.consensus.Describe(ch)
+	c.aux.Describe(ch)
+	c.stages.Describe(ch)
+	ch <- c.contexts
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.tokenizes.Collect(ch)
+	c.tokens.Collect(ch)
+	c.bytes.Collect(ch)
+	c.latency.Collect(ch)
+	c.consensus.Collect(ch)
+	c.aux.Collect(ch)
+	c.stages.Collect(ch)
+
+	// Every state is reported, so a channel emptying drops to zero rather
+	// than disappearing
+	counts := make(map[nsigii.ColorChannel]int)
+	for _, info := range c.registry.Contexts() {
+		counts[info.ColorState]++
+	}
+	for state := nsigii.ColorRed; state <= nsigii.ColorBlack; state++ {
+		ch <- prometheus.MustNewConstMetric(c.contexts, prometheus.GaugeValue,
+			float64(counts[state]), state.String())
+	}
+}
+
+// result is the result label of an operation that returned err
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
@@ -3,14 +3,35 @@
 //
 // This package implements zero-trust service architecture with color
 // verification, phantom ID encoding, and RIFT tokenization stages.
+//
+// A Context is safe for use by multiple goroutines once configured: calls
+// into the native library are serialized, and Close waits for a running
+// call to finish, after which work fails with ErrContextClosed. SetPolarity
+// and the audit, alert, escalation and rotation setters are synchronized
+// and may be called at any time. The other Set* methods are not and must
+// complete before the context is shared; goroutines that need different
+// settings should each take their own context, for example from a
+// ContextPool.
+//
+// Failures callers may want to handle are reported as sentinel errors such
+// as ErrTerminated and ErrTokenBufferFull, for errors.Is, and native result
+// codes as *CError, for errors.As. Contexts log nothing unless given a
+// log/slog logger; see SetDefaultLogger.
+//
+// By default the package links against libnsigii_rift through cgo. Building
+// with CGO_ENABLED=0 or the purego tag selects a pure-Go implementation of
+// the same API instead.
 package nsigii
 
-import "C"
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"runtime"
-	"unsafe"
+	"sync"
+	"time"
+	"unicode/utf8"
 )
 
 // ============================================================================
@@ -31,6 +52,18 @@ const (
 	ColorContrast ColorChannel = 7 // Inverse
 )
 
+var colorChannelNames = []string{
+	"RED", "GREEN", "BLUE", "CYAN",
+	"YELLOW", "MAGENTA", "BLACK", "CONTRAST",
+}
+
+func (c ColorChannel) String() string {
+	if c >= 0 && int(c) < len(colorChannelNames) {
+		return colorChannelNames[c]
+	}
+	return "UNKNOWN"
+}
+
 // Polarity represents polarity states
 type Polarity int
 
@@ -52,15 +85,18 @@ const (
 	TokenDelimiter  TokenType = 5
 	TokenString     TokenType = 6
 	TokenComment    TokenType = 7
+	TokenError      TokenType = 8 // Unscannable input (recovery mode)
 )
 
+var tokenTypeNames = []string{
+	"EOF", "IDENTIFIER", "KEYWORD", "NUMBER",
+	"OPERATOR", "DELIMITER", "STRING", "COMMENT",
+	"ERROR",
+}
+
 func (t TokenType) String() string {
-	names := []string{
-		"EOF", "IDENTIFIER", "KEYWORD", "NUMBER",
-		"OPERATOR", "DELIMITER", "STRING", "COMMENT",
-	}
-	if int(t) < len(names) {
-		return names[t]
+	if name, ok := tokenTypeName(t); ok {
+		return name
 	}
 	return "UNKNOWN"
 }
@@ -69,47 +105,94 @@ func (t TokenType) String() string {
 // Structures
 // ============================================================================
 
+// triplet is the raw (type, memory, value) record produced by a lexer
+// backend, before text extraction
+type triplet struct {
+	typ    TokenType
+	memory uint32
+	value  uint32
+}
+
 // Token represents a RIFT token triplet
 type Token struct {
-	Type   TokenType // What it is (relation)
-	Memory uint32    // Where it lives (memory pointer)
-	Value  uint32    // What it contains (value/length)
-	Text   string    // Extracted text from source
+	Type      TokenType // What it is (relation)
+	Memory    uint32    // Where it lives (memory pointer)
+	Value     uint32    // What it contains (value/length)
+	Text      string    // Extracted text from source, empty with lazy text (see Lexeme)
+	Line      int       // 1-based line of Memory (0 if not tracked)
+	Column    int       // 1-based byte column of Memory (0 if not tracked)
+	EndOffset uint32    // Byte offset just past the token (Memory + Value)
+
+	// Rune offset and length (0 unless rune offsets are enabled)
+	RuneOffset uint32
+	RuneLength uint32
+
+	src *string // source Lexeme slices, set only with lazy text
 }
 
 func (t Token) String() string {
 	return fmt.Sprintf("Token(%s, mem=%d, val=%d, text='%s')",
-		t.Type, t.Memory, t.Value, t.Text)
+		t.Type, t.Memory, t.Value, t.Lexeme())
 }
 
 // Context represents an NSIGII service context
 type Context struct {
-	ctx       *C.NSigiiContext
-	operation string
-	service   string
+	ctx         *nativeContext // guarded by handle
+	handle      sync.Mutex     // serializes native calls against each other and Close
+	operation   string
+	service     string
+	maxTokens   int              // 0 means unlimited
+	noPos       bool             // skip Line/Column computation
+	keywords    map[string]bool  // nil means the lexer's built-in set
+	lexer       *lexer           // Go lexer overriding the native one, if set
+	operators   []string         // custom operator table, nil for default
+	delimiters  []string         // custom delimiter table, nil for default
+	profile     *LanguageProfile // language preset, nil for the native lexer
+	grammar     *CompiledGrammar // custom language, nil for RIFT
+	validators  []Validator      // semantic checks, nil for the defaults
+	runes       bool             // report RuneOffset/RuneLength
+	strictUTF8  bool             // reject sources that are not valid UTF-8
+	lazyText    bool             // leave Token.Text empty; see SetLazyText
+	phantomKey  []byte           // phantom ID secret, nil for the process key
+	keyRing     KeyRing          // generations of the phantom key, if set
+	signingKey  []byte           // Ed25519 stream signing key, nil to derive one
+	identity    identity         // the context's own rotating phantom ID
+	stampOrigin bool             // stamp TokenizeStream output with identity
+	color       colorState       // current color channel
+	consensus   *ConsensusPolicy // nil for the native RGB rule
+	audit       auditRing        // recent color transitions and checks
+	escalation  escalation       // automatic YELLOW/MAGENTA escalation
+	alertSink   AlertSink        // notified on entering MAGENTA
+	aux         auxState         // serializes AUX calls
+	usage       usageCounters    // reported by Stats
+	registry    *Registry        // tracking the context, if any
+	instrument  Instrument       // nil for the default instrument
+	logger      *slog.Logger     // nil for the default logger
+	policy      PolicyEngine     // consulted before operations, nil allows all
+	caller      *PhantomID       // party the context works for, if known
+	capability  *Capability      // checked by CapabilityPolicy, if presented
+	role        Role             // methods the context allows
 }
 
 // ============================================================================
 // Context Management
 // ============================================================================
 
-// NewContext creates a new NSIGII context
+// NewContext creates a new NSIGII context, applying opts in order. If an
+// option fails the context is closed and the option's error returned.
 //
 // Schema: obinexus.[operation].[service]
 //
 // Example:
-//   ctx, err := nsigii.NewContext("tokenize", "lexer")
+//   ctx, err := nsigii.NewContext("tokenize", "lexer",
+//       nsigii.WithMaxTokens(4096),
+//       nsigii.WithProfile(nsigii.GoProfile))
 //   if err != nil {
 //       log.Fatal(err)
 //   }
 //   defer ctx.Close()
-func NewContext(operation, service string) (*Context, error) {
-	cOperation := C.CString(operation)
-	cService := C.CString(service)
-	defer C.free(unsafe.Pointer(cOperation))
-	defer C.free(unsafe.Pointer(cService))
-
-	ctx := C.nsigii_create_context(cOperation, cService)
+func NewContext(operation, service string, opts ...Option) (*Context, error) {
+	ctx := nativeCreateContext(operation, service)
 	if ctx == nil {
 		return nil, errors.New("failed to create NSIGII context")
 	}
@@ -119,39 +202,164 @@ func NewContext(operation, service string) (*Context, error) {
 		operation: operation,
 		service:   service,
 	}
+	nsigiiCtx.color.counters.since = time.Now()
 
 	// Set finalizer to ensure cleanup
 	runtime.SetFinalizer(nsigiiCtx, (*Context).Close)
 
+	for _, opt := range opts {
+		if err := opt(nsigiiCtx); err != nil {
+			nsigiiCtx.Close()
+			return nil, err
+		}
+	}
+
+	nsigiiCtx.logEvent(slog.LevelDebug, "nsigii context created")
 	return nsigiiCtx, nil
 }
 
 // Close releases the context resources
 func (c *Context) Close() error {
+	if c.closed() {
+		return nil
+	}
+
+	c.stopEscalation()
+	c.aux.mu.Lock()
+	c.aux.gen++ // ends any noise burst schedule
+	c.aux.noise = nil
+	c.aux.mu.Unlock()
+
+	c.handle.Lock()
+	defer c.handle.Unlock()
 	if c.ctx != nil {
-		C.nsigii_destroy_context(c.ctx)
+		nativeDestroyContext(c.ctx)
 		c.ctx = nil
+		if c.registry != nil {
+			c.registry.remove(c)
+		}
+		c.logEvent(slog.LevelDebug, "nsigii context closed")
+	}
+	return nil
+}
+
+// closed reports whether Close has released the native context
+func (c *Context) closed() bool {
+	c.handle.Lock()
+	defer c.handle.Unlock()
+	return c.ctx == nil
+}
+
+// withNative runs fn with exclusive use of the native context, or returns
+// ErrContextClosed if it has been released. fn must not call back into
+// methods that use the native context.
+func (c *Context) withNative(fn func(ctx *nativeContext)) error {
+	start := time.Now()
+	defer c.usage.countNative(start)
+
+	c.handle.Lock()
+	defer c.handle.Unlock()
+	if c.ctx == nil {
+		return ErrContextClosed
 	}
+	fn(c.ctx)
 	return nil
 }
 
+// usable returns the reason the context cannot take on work, if any
+func (c *Context) usable() error {
+	if c.closed() {
+		return ErrContextClosed
+	}
+	if c.ColorState() == ColorBlack {
+		return ErrTerminated
+	}
+	return nil
+}
+
+// fork creates a new context with the same schema and settings as c, for
+// work that must not share c's native handle
+func (c *Context) fork() (*Context, error) {
+	f, err := NewContext(c.operation, c.service)
+	if err != nil {
+		return nil, err
+	}
+
+	f.copySettings(c)
+	if c.registry != nil {
+		c.registry.add(f)
+	}
+	return f, nil
+}
+
+// copySettings makes c's settings match from's. Hooks, color state and the
+// audit trail are not settings and are left as they are.
+func (c *Context) copySettings(from *Context) {
+	c.maxTokens = from.maxTokens
+	c.noPos = from.noPos
+	c.keywords = from.keywords
+	c.lexer = from.lexer
+	c.operators = from.operators
+	c.delimiters = from.delimiters
+	c.profile = from.profile
+	c.grammar = from.grammar
+	c.validators = from.validators
+	c.runes = from.runes
+	c.strictUTF8 = from.strictUTF8
+	c.lazyText = from.lazyText
+	c.phantomKey = from.phantomKey
+	c.keyRing = from.keyRing
+	c.signingKey = from.signingKey
+	from.identity.mu.Lock()
+	rotation, subject := from.identity.policy, from.identity.subj
+	from.identity.mu.Unlock()
+	c.identity.mu.Lock()
+	c.identity.policy, c.identity.subj = rotation, subject
+	c.identity.mu.Unlock()
+	c.stampOrigin = from.stampOrigin
+	c.consensus = from.consensus
+	escalation := from.EscalationPolicy()
+	c.escalation.mu.Lock()
+	c.escalation.policy = escalation
+	c.escalation.mu.Unlock()
+	c.alertSink = from.alertSink
+	c.instrument = from.instrument
+	c.logger = from.logger
+	c.policy = from.policy
+	c.caller = from.caller
+	c.capability = from.capability
+	c.role = from.role
+	from.aux.mu.Lock()
+	entropy := from.aux.entropy
+	from.aux.mu.Unlock()
+	c.aux.mu.Lock()
+	c.aux.entropy = entropy
+	c.aux.mu.Unlock()
+	if p := from.Polarity(); p != c.Polarity() {
+		c.SetPolarity(p)
+	}
+}
+
 // Schema returns the service schema string
 //
 // Returns: obinexus.[operation].[service]
 func (c *Context) Schema() (string, error) {
-	if c.ctx == nil {
-		return "", errors.New("context is closed")
+	var (
+		schema string
+		result int
+	)
+	err := c.withNative(func(ctx *nativeContext) {
+		schema, result = nativeGenerateSchema(ctx)
+	})
+	if err != nil {
+		return "", err
 	}
-
-	schemaBuf := make([]byte, 256)
-	cSchema := (*C.char)(unsafe.Pointer(&schemaBuf[0]))
-
-	result := C.nsigii_generate_schema(c.ctx, cSchema, 256)
 	if result != 0 {
-		return "", fmt.Errorf("failed to generate schema: %d", result)
+		c.usage.countError()
+		return "", &CError{Op: "schema generation", Code: result}
 	}
 
-	return C.GoString(cSchema), nil
+	return schema, nil
 }
 
 // ============================================================================
@@ -169,97 +377,202 @@ func (c *Context) Schema() (string, error) {
 //       fmt.Println(token)
 //   }
 func (c *Context) Tokenize(source string) ([]Token, error) {
-	if c.ctx == nil {
-		return nil, errors.New("context is closed")
+	if err := c.usable(); err != nil {
+		return nil, err
 	}
 
-	const maxTokens = 10000
-	cSource := C.CString(source)
-	defer C.free(unsafe.Pointer(cSource))
+	return c.tokenize(source)
+}
 
-	// Allocate token buffer
-	tokensBuf := make([]C.TokenTriplet, maxTokens)
-	var count C.size_t
+// tokenize runs the native lexer over source and converts the resulting
+// triplets into Go tokens
+func (c *Context) tokenize(source string) ([]Token, error) {
+	return c.lex(nil, source, nil)
+}
+
+// lex implements tokenize, converting into dst's storage. mapped, if
+// non-nil, holds source followed by a NUL byte in memory the native lexer
+// can read in place.
+func (c *Context) lex(dst []Token, source string, mapped []byte) (tokens []Token, err error) {
+	start := time.Now()
+	defer func() {
+		c.usage.countTokenize(len(source), tokens, err)
+		c.observe(Event{Op: OpTokenize, Start: start, Err: err, Bytes: len(source), Tokens: len(tokens)})
+	}()
+
+	if err := c.authorize(OpTokenize); err != nil {
+		return nil, err
+	}
+
+	if c.strictUTF8 {
+		if err := validateUTF8(source); err != nil {
+			return nil, err
+		}
+	}
 
-	// Perform tokenization
-	result := C.nsigii_tokenize(
-		c.ctx,
-		cSource,
-		(*C.TokenTriplet)(unsafe.Pointer(&tokensBuf[0])),
-		C.size_t(maxTokens),
-		&count,
+	buf := getTriplets()
+	defer putTriplets(buf)
+
+	var (
+		triplets []triplet
+		full     bool
+		result   int
 	)
+	switch {
+	case c.lexer != nil:
+		triplets, full = c.lexer.scan(buf.triplets, source, c.maxTokens)
+	case mapped != nil:
+		err := c.withNative(func(ctx *nativeContext) {
+			triplets, full, result = nativeTokenizeMapped(ctx, buf.triplets, mapped, c.maxTokens)
+		})
+		if err != nil {
+			return nil, err
+		}
+	default:
+		err := c.withNative(func(ctx *nativeContext) {
+			triplets, full, result = nativeTokenize(ctx, buf.triplets, source, c.maxTokens)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	buf.keep(triplets)
 
+	return c.tokens(dst, source, triplets, full, result)
+}
+
+// tokens converts the triplets the lexer produced for source into Go
+// tokens, reusing dst's storage. full and result are the lexer's buffer and
+// result reports.
+func (c *Context) tokens(dst []Token, source string, triplets []triplet, full bool, result int) ([]Token, error) {
+	if full {
+		return nil, fmt.Errorf("%w: %d", ErrTokenBufferFull, c.maxTokens)
+	}
 	if result != 0 {
-		return nil, fmt.Errorf("tokenization failed: %d", result)
+		return nil, &CError{Op: "tokenization", Code: result}
 	}
 
+	if !isASCII(source) {
+		triplets = repairRunes(source, triplets)
+	}
+
+	src := c.lazySource(source)
+
 	// Convert to Go tokens
-	tokens := make([]Token, count)
-	for i := 0; i < int(count); i++ {
-		cToken := tokensBuf[i]
-
-		// Extract text from source
-		memPtr := int(cToken.memory)
-		length := int(cToken.value)
-		if length == 0 {
-			length = 1
+	tokens := dst[:0]
+	if cap(tokens) < len(triplets) {
+		tokens = make([]Token, len(triplets))
+	}
+	tokens = tokens[:len(triplets)]
+	pos := positionTracker{source: source}
+	for i, triplet := range triplets {
+		memPtr := int(triplet.memory)
+
+		tokens[i] = Token{
+			Type:      triplet.typ,
+			Memory:    triplet.memory,
+			Value:     triplet.value,
+			EndOffset: triplet.memory + triplet.value,
+			src:       src,
+		}
+		if src == nil {
+			tokens[i].Text = tokenText(source, triplet.memory, triplet.value)
 		}
 
-		var text string
-		if memPtr < len(source) {
-			end := memPtr + length
-			if end > len(source) {
-				end = len(source)
+		if !c.noPos || c.runes {
+			line, column := pos.position(memPtr)
+			if !c.noPos {
+				tokens[i].Line, tokens[i].Column = line, column
+			}
+			if c.runes && memPtr < len(source) {
+				end := min(int(tokens[i].EndOffset), len(source))
+				tokens[i].RuneOffset = uint32(pos.runes)
+				tokens[i].RuneLength = uint32(utf8.RuneCountInString(source[memPtr:end]))
+			} else if c.runes {
+				tokens[i].RuneOffset = uint32(pos.runes)
 			}
-			text = source[memPtr:end]
-		} else {
-			text = "<EOF>"
 		}
+	}
 
-		tokens[i] = Token{
-			Type:   TokenType(cToken._type),
-			Memory: uint32(cToken.memory),
-			Value:  uint32(cToken.value),
-			Text:   text,
-		}
+	if c.keywords != nil {
+		c.classifyKeywords(tokens)
 	}
 
 	return tokens, nil
 }
 
+// SetMaxTokens limits the number of tokens a single Tokenize call may
+// produce. The token buffer grows on demand up to the limit; sources that
+// need more fail instead of being truncated.
+//
+// limit <= 0 removes the limit (the default).
+func (c *Context) SetMaxTokens(limit int) {
+	if limit < 0 {
+		limit = 0
+	}
+	c.maxTokens = limit
+}
+
+// MaxTokens returns the per-call token limit, or 0 if unlimited
+func (c *Context) MaxTokens() int {
+	return c.maxTokens
+}
+
 // ============================================================================
 // AUX Instructions
 // ============================================================================
 
 // AuxStart starts AUX instruction sequence
 //
-// noiseLevel: 0 for low entropy, 1 for high entropy
-func (c *Context) AuxStart(noiseLevel int) error {
-	if c.ctx == nil {
-		return errors.New("context is closed")
+// profile shapes the injected entropy; NoiseLow and NoiseHigh match the
+// native low and high entropy levels
+func (c *Context) AuxStart(profile NoiseProfile) error {
+	return c.auxStart(profile, nil)
+}
+
+// auxStart implements AuxStart. claim, if not nil, is called once the AUX
+// state is held; if it reports false, AUX is left alone (see abandonable).
+func (c *Context) auxStart(profile NoiseProfile, claim func() bool) error {
+	if err := c.require(RoleOperator, "AuxStart"); err != nil {
+		return err
+	}
+	if err := profile.Validate(); err != nil {
+		return err
+	}
+	if err := c.usable(); err != nil {
+		return err
 	}
 
-	result := C.nsigii_aux_start(c.ctx, C.int(noiseLevel))
-	if result != 0 {
-		return fmt.Errorf("AUX start failed: %d", result)
+	if err := c.authorize(OpAuxStart); err != nil {
+		return err
 	}
 
-	return nil
+	c.aux.mu.Lock()
+	defer c.aux.mu.Unlock()
+	if claim != nil && !claim() {
+		return context.Canceled
+	}
+
+	return c.runAux(AuxInstruction{Op: AuxNoise, Noise: profile})
 }
 
 // AuxStop stops AUX instruction sequence
 func (c *Context) AuxStop() error {
-	if c.ctx == nil {
-		return errors.New("context is closed")
+	if err := c.require(RoleOperator, "AuxStop"); err != nil {
+		return err
+	}
+	if err := c.usable(); err != nil {
+		return err
 	}
 
-	result := C.nsigii_aux_stop(c.ctx)
-	if result != 0 {
-		return fmt.Errorf("AUX stop failed: %d", result)
+	if err := c.authorize(OpAuxStop); err != nil {
+		return err
 	}
 
-	return nil
+	c.aux.mu.Lock()
+	defer c.aux.mu.Unlock()
+
+	return c.runAux(AuxInstruction{Op: AuxSync})
 }
 
 // ============================================================================
@@ -267,13 +580,58 @@ func (c *Context) AuxStop() error {
 // ============================================================================
 
 // VerifyRGBConsensus verifies RGB consensus (1/4 RED + 1/4 GREEN = 1/2 CYAN)
-func (c *Context) VerifyRGBConsensus() (bool, error) {
-	if c.ctx == nil {
-		return false, errors.New("context is closed")
+//
+// A consensus policy set with SetConsensusPolicy replaces the weights and
+// threshold. The native library confirms RED and GREEN together, so for a
+// single context the policy only decides whether that pair is enough; the
+// individual weights matter for votes counted separately in a
+// ConsensusGroup.
+func (c *Context) VerifyRGBConsensus() (ok bool, err error) {
+	return c.verifyRGBConsensus(nil)
+}
+
+// verifyRGBConsensus implements VerifyRGBConsensus. claim, if not nil, is
+// called once the native handle is held; if it reports false, no consensus
+// is taken (see abandonable).
+func (c *Context) verifyRGBConsensus(claim func() bool) (ok bool, err error) {
+	if err := c.require(RoleVerifier, "VerifyRGBConsensus"); err != nil {
+		return false, err
 	}
+	start := time.Now()
+	defer func() { c.observe(Event{Op: OpConsensus, Start: start, Err: err, Passed: ok}) }()
 
-	result := C.nsigii_verify_rgb_consensus(c.ctx)
-	return bool(result), nil
+	if err = c.authorize(OpConsensus); err != nil {
+		return false, err
+	}
+	abandoned := false
+	err = c.withNative(func(ctx *nativeContext) {
+		if claim != nil && !claim() {
+			abandoned = true
+			return
+		}
+		ok = nativeVerifyRGBConsensus(ctx)
+	})
+	if err != nil {
+		return false, err
+	}
+	if abandoned {
+		return false, context.Canceled
+	}
+	if c.consensus != nil {
+		ok = c.consensus.Reached(ok, ok)
+	}
+
+	c.color.countConsensus(ok)
+	state := c.ColorState()
+	c.audit.record(AuditEntry{
+		Time:   time.Now(),
+		Kind:   AuditConsensus,
+		From:   state,
+		To:     state,
+		Passed: ok,
+	})
+
+	return ok, nil
 }
 
 // ============================================================================
@@ -297,39 +655,18 @@ type TokenStats struct {
 	TypeDistribution map[TokenType]int
 	MemoryRange      [2]uint32
 	AverageLength    float64
+	LengthHistogram  Histogram               // Lengths of all tokens
+	TypeLengths      map[TokenType]Histogram // Lengths of each type's tokens
 }
 
-// AnalyzeTokens analyzes token stream for statistics
+// AnalyzeTokens analyzes token stream for statistics, with histograms over
+// the default bounds and quantiles (see AnalyzeTokensWith)
 func AnalyzeTokens(tokens []Token) TokenStats {
-	stats := TokenStats{
-		TotalTokens:      len(tokens),
-		TypeDistribution: make(map[TokenType]int),
-	}
-
-	if len(tokens) == 0 {
-		return stats
-	}
-
-	var totalLength uint32
-	minMem := tokens[0].Memory
-	maxMem := tokens[0].Memory
-
+	var acc StatsAccumulator
 	for _, token := range tokens {
-		stats.TypeDistribution[token.Type]++
-		totalLength += token.Value
-
-		if token.Memory < minMem {
-			minMem = token.Memory
-		}
-		if token.Memory > maxMem {
-			maxMem = token.Memory
-		}
+		acc.Add(token)
 	}
-
-	stats.MemoryRange = [2]uint32{minMem, maxMem}
-	stats.AverageLength = float64(totalLength) / float64(len(tokens))
-
-	return stats
+	return acc.Stats()
 }
 
 /*
@@ -0,0 +1,317 @@
+//go:build cgo && !purego
+
+package nsigii
+
+import "C"
+import (
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+// ============================================================================
+// Native Backend (libnsigii_rift via cgo)
+// ============================================================================
+
+// nativeContext is the NSIGII C service context
+type nativeContext = C.NSigiiContext
+
+// cBuffer is a reusable buffer of NUL-terminated strings for the C
+// library. Go memory holding no Go pointers may be passed to C for the
+// duration of a call, so strings copied here need no C.CString malloc and
+// free, and pooling the buffers keeps small calls from allocating at all.
+type cBuffer struct {
+	buf []byte
+}
+
+var cBufferPool = sync.Pool{New: func() any { return new(cBuffer) }}
+
+// maxPooledCBuffer bounds the string buffers kept for reuse, in bytes
+const maxPooledCBuffer = 1 << 20
+
+// getCBuffer takes an empty buffer from the pool
+func getCBuffer() *cBuffer {
+	b := cBufferPool.Get().(*cBuffer)
+	b.buf = b.buf[:0]
+	return b
+}
+
+// putCBuffer returns b to the pool, dropping buffers too large to keep. No
+// pointer into b may be used afterwards.
+func putCBuffer(b *cBuffer) {
+	if cap(b.buf) > maxPooledCBuffer {
+		b.buf = nil
+	}
+	cBufferPool.Put(b)
+}
+
+// add appends s and a NUL byte, returning the offset of the copy. Take
+// pointers with at only once every string is added, as adding may move the
+// buffer.
+func (b *cBuffer) add(s string) int {
+	offset := len(b.buf)
+	b.buf = append(b.buf, s...)
+	b.buf = append(b.buf, 0)
+	return offset
+}
+
+// at returns the C string at offset
+func (b *cBuffer) at(offset int) *C.char {
+	return (*C.char)(unsafe.Pointer(&b.buf[offset]))
+}
+
+func nativeCreateContext(operation, service string) *nativeContext {
+	b := getCBuffer()
+	defer putCBuffer(b)
+	cOperation, cService := b.add(operation), b.add(service)
+
+	return C.nsigii_create_context(b.at(cOperation), b.at(cService))
+}
+
+func nativeDestroyContext(ctx *nativeContext) {
+	C.nsigii_destroy_context(ctx)
+}
+
+func nativeGenerateSchema(ctx *nativeContext) (string, int) {
+	schemaBuf := make([]byte, 256)
+	cSchema := (*C.char)(unsafe.Pointer(&schemaBuf[0]))
+
+	result := C.nsigii_generate_schema(ctx, cSchema, 256)
+	if result != 0 {
+		return "", int(result)
+	}
+
+	return C.GoString(cSchema), 0
+}
+
+// cTripletBuffer is a reusable buffer for the C lexer's output, boxed so
+// that returning it to cTripletPool does not allocate
+type cTripletBuffer struct {
+	buf []C.TokenTriplet
+}
+
+var cTripletPool = sync.Pool{New: func() any { return new(cTripletBuffer) }}
+
+// getCTriplets takes a buffer of at least capacity triplets from the pool
+func getCTriplets(capacity int) *cTripletBuffer {
+	b := cTripletPool.Get().(*cTripletBuffer)
+	if cap(b.buf) < capacity {
+		b.buf = make([]C.TokenTriplet, capacity)
+	}
+	return b
+}
+
+// putCTriplets returns b to the pool, dropping buffers too large to keep
+func putCTriplets(b *cTripletBuffer) {
+	if cap(b.buf) > maxPooledTriplets {
+		b.buf = nil
+	}
+	cTripletPool.Put(b)
+}
+
+// nativeTokenize runs the C lexer over source, reusing dst's storage. With
+// limit > 0, full reports that the source needs more than limit tokens.
+func nativeTokenize(ctx *nativeContext, dst []triplet, source string, limit int) (triplets []triplet, full bool, result int) {
+	b := getCBuffer()
+	defer putCBuffer(b)
+	b.add(source)
+
+	return tokenizeCString(ctx, dst, b.at(0), len(source), limit)
+}
+
+// nativeTokenizeMapped runs the C lexer directly over data, which must end
+// with a NUL byte, without copying it
+func nativeTokenizeMapped(ctx *nativeContext, dst []triplet, data []byte, limit int) (triplets []triplet, full bool, result int) {
+	cSource := (*C.char)(unsafe.Pointer(&data[0]))
+	return tokenizeCString(ctx, dst, cSource, len(data)-1, limit)
+}
+
+// tokenizeCString runs the C lexer over the n-byte NUL-terminated cSource,
+// converting its output into dst's storage
+func tokenizeCString(ctx *nativeContext, dst []triplet, cSource *C.char, n int, limit int) (triplets []triplet, full bool, result int) {
+	// Every token consumes at least one byte, plus the trailing EOF token,
+	// so the buffer never needs to grow beyond this. With a limit set, one
+	// extra slot tells a stream that exactly fits apart from one that overflows.
+	ceiling := n + 1
+	if limit > 0 && limit+1 < ceiling {
+		ceiling = limit + 1
+	}
+
+	capacity := n/4 + 16
+	if capacity > ceiling {
+		capacity = ceiling
+	}
+
+	// A pooled buffer larger than the estimate is used in full, saving
+	// retries
+	pooled := getCTriplets(capacity)
+	defer putCTriplets(pooled)
+	capacity = min(cap(pooled.buf), ceiling)
+
+	var (
+		tokensBuf []C.TokenTriplet
+		count     C.size_t
+		cResult   C.int
+	)
+
+	// Retry with a larger buffer until the lexer fits or the ceiling is hit
+	for {
+		if cap(pooled.buf) < capacity {
+			pooled.buf = make([]C.TokenTriplet, capacity)
+		}
+		tokensBuf = pooled.buf[:capacity]
+		cResult = C.nsigii_tokenize(
+			ctx,
+			cSource,
+			(*C.TokenTriplet)(unsafe.Pointer(&tokensBuf[0])),
+			C.size_t(capacity),
+			&count,
+		)
+
+		overflowed := cResult != 0 || int(count) >= capacity
+		if !overflowed || capacity >= ceiling {
+			break
+		}
+
+		capacity *= 2
+		if capacity > ceiling {
+			capacity = ceiling
+		}
+	}
+
+	if limit > 0 && (int(count) > limit || (cResult != 0 && ceiling == limit+1)) {
+		return nil, true, 0
+	}
+	if cResult != 0 {
+		return nil, false, int(cResult)
+	}
+
+	triplets = dst[:0]
+	if cap(triplets) < int(count) {
+		triplets = make([]triplet, count)
+	}
+	triplets = triplets[:count]
+	for i := range triplets {
+		cToken := tokensBuf[i]
+		triplets[i] = triplet{
+			typ:    TokenType(cToken._type),
+			memory: uint32(cToken.memory),
+			value:  uint32(cToken.value),
+		}
+	}
+
+	return triplets, false, 0
+}
+
+// errNoMemory mirrors NSIGII_ERROR_NO_MEMORY from nsigii_core.h
+const errNoMemory = -3
+
+// nativeTokenizeBatch runs the C lexer over every source, crossing into C
+// once per batch rather than once per source; more crossings are only
+// needed when the shared token buffer has to grow. results holds each
+// source's result code.
+func nativeTokenizeBatch(ctx *nativeContext, sources []string) (triplets [][]triplet, results []int, result int) {
+	if len(sources) == 0 {
+		return nil, nil, 0
+	}
+
+	// Copy every source into one buffer, NUL-terminated back to back. The
+	// table of pointers into it is itself passed to C, which is only allowed
+	// while the buffer is pinned.
+	b := getCBuffer()
+	defer putCBuffer(b)
+	offsets := make([]int, len(sources))
+	for i, source := range sources {
+		offsets[i] = b.add(source)
+	}
+	total := len(b.buf)
+
+	var pinner runtime.Pinner
+	pinner.Pin(&b.buf[0])
+	defer pinner.Unpin()
+
+	inputs := make([]*C.char, len(sources))
+	for i, offset := range offsets {
+		inputs[i] = b.at(offset)
+	}
+
+	var (
+		counts   = make([]C.size_t, len(sources))
+		cResults = make([]C.int, len(sources))
+		pending  = total // token ceiling of the sources not yet done
+	)
+	capacity := min(total/4+16*len(sources), pending)
+
+	triplets = make([][]triplet, len(sources))
+	results = make([]int, len(sources))
+	pooled := getCTriplets(capacity)
+	defer putCTriplets(pooled)
+	capacity = min(cap(pooled.buf), pending)
+
+	for start := 0; start < len(sources); {
+		if cap(pooled.buf) < capacity {
+			pooled.buf = make([]C.TokenTriplet, capacity)
+		}
+		tokensBuf := pooled.buf[:capacity]
+		var done C.size_t
+		cResult := C.nsigii_tokenize_batch(
+			ctx,
+			&inputs[start],
+			C.size_t(len(sources)-start),
+			(*C.TokenTriplet)(unsafe.Pointer(&tokensBuf[0])),
+			C.size_t(capacity),
+			&counts[start],
+			&cResults[start],
+			&done,
+		)
+
+		used := 0
+		for i := start; i < start+int(done); i++ {
+			results[i] = int(cResults[i])
+			triplets[i] = make([]triplet, counts[i])
+			for j := range triplets[i] {
+				cToken := tokensBuf[used+j]
+				triplets[i][j] = triplet{
+					typ:    TokenType(cToken._type),
+					memory: uint32(cToken.memory),
+					value:  uint32(cToken.value),
+				}
+			}
+			used += int(counts[i])
+			pending -= len(sources[i]) + 1
+		}
+		start += int(done)
+
+		if cResult == 0 {
+			break
+		}
+		if cResult != errNoMemory {
+			return nil, nil, int(cResult)
+		}
+
+		// Grow until at least the next source is sure to fit
+		capacity = min(max(capacity*2, len(sources[start])+1), pending)
+	}
+
+	return triplets, results, 0
+}
+
+func nativeAuxStart(ctx *nativeContext, noiseLevel int) int {
+	return int(C.nsigii_aux_start(ctx, C.int(noiseLevel)))
+}
+
+func nativeAuxStop(ctx *nativeContext) int {
+	return int(C.nsigii_aux_stop(ctx))
+}
+
+func nativeVerifyRGBConsensus(ctx *nativeContext) bool {
+	return bool(C.nsigii_verify_rgb_consensus(ctx))
+}
+
+func nativeSetPolarity(ctx *nativeContext, polarity Polarity) int {
+	return int(C.nsigii_set_polarity(ctx, C.Polarity(polarity)))
+}
+
+func nativePolarity(ctx *nativeContext) Polarity {
+	return Polarity(C.nsigii_context_polarity(ctx))
+}
@@ -0,0 +1,112 @@
+//go:build !cgo || purego
+
+package nsigii
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// ============================================================================
+// Pure-Go Backend (CGO_ENABLED=0 or -tags purego)
+// ============================================================================
+
+// Mirror NSIGII_ERROR_NULL_CTX and NSIGII_ERROR_INVALID from nsigii_core.h
+const (
+	errNullCtx = -1
+	errInvalid = -4
+)
+
+// nativeContext is the Go-side equivalent of the NSIGII C service context
+type nativeContext struct {
+	operation string
+	service   string
+	auxActive bool
+	noise     int
+	polarity  Polarity
+}
+
+func nativeCreateContext(operation, service string) *nativeContext {
+	return &nativeContext{operation: operation, service: service, polarity: PolarityPositive}
+}
+
+func nativeDestroyContext(ctx *nativeContext) {}
+
+func nativeGenerateSchema(ctx *nativeContext) (string, int) {
+	return fmt.Sprintf("obinexus.%s.%s", ctx.operation, ctx.service), 0
+}
+
+// nativeTokenize runs the Go lexer over source, reusing dst's storage. With
+// limit > 0, full reports that the source needs more than limit tokens.
+func nativeTokenize(ctx *nativeContext, dst []triplet, source string, limit int) (triplets []triplet, full bool, result int) {
+	if ctx == nil {
+		return nil, false, errNullCtx
+	}
+
+	triplets, full = defaultLexer.scan(dst, source, limit)
+	return triplets, full, 0
+}
+
+// nativeTokenizeMapped runs the Go lexer over data, which ends with a NUL
+// byte, without copying it
+func nativeTokenizeMapped(ctx *nativeContext, dst []triplet, data []byte, limit int) (triplets []triplet, full bool, result int) {
+	return nativeTokenize(ctx, dst, unsafe.String(&data[0], len(data)-1), limit)
+}
+
+// nativeTokenizeBatch runs the Go lexer over every source; there is no
+// boundary to amortize, so it simply loops. results holds each source's
+// result code.
+func nativeTokenizeBatch(ctx *nativeContext, sources []string) (triplets [][]triplet, results []int, result int) {
+	if ctx == nil {
+		return nil, nil, errNullCtx
+	}
+
+	triplets = make([][]triplet, len(sources))
+	results = make([]int, len(sources))
+	for i, source := range sources {
+		triplets[i], _ = defaultLexer.scan(nil, source, 0)
+	}
+	return triplets, results, 0
+}
+
+func nativeAuxStart(ctx *nativeContext, noiseLevel int) int {
+	if ctx == nil {
+		return errNullCtx
+	}
+	ctx.auxActive = true
+	ctx.noise = noiseLevel
+	return 0
+}
+
+func nativeAuxStop(ctx *nativeContext) int {
+	if ctx == nil {
+		return errNullCtx
+	}
+	ctx.auxActive = false
+	return 0
+}
+
+// nativeVerifyRGBConsensus matches the C implementation: the RED and GREEN
+// channels are always active on a live context, so they always form CYAN
+func nativeVerifyRGBConsensus(ctx *nativeContext) bool {
+	return ctx != nil
+}
+
+func nativeSetPolarity(ctx *nativeContext, polarity Polarity) int {
+	if ctx == nil {
+		return errNullCtx
+	}
+	switch polarity {
+	case PolarityPositive, PolarityNegative, PolarityNeutral:
+		ctx.polarity = polarity
+		return 0
+	}
+	return errInvalid
+}
+
+func nativePolarity(ctx *nativeContext) Polarity {
+	if ctx == nil {
+		return PolarityNeutral
+	}
+	return ctx.polarity
+}
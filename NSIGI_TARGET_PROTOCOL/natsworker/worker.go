@@ -0,0 +1,241 @@
+// Package natsworker serves tokenization over NATS request/reply. Workers
+// subscribe to a subject in a queue group, so starting more of them spreads
+// requests across processes with no other coordination.
+//
+// A request's payload is the raw source. Optional headers choose how it is
+// handled:
+//   Nsigii-Operation  schema operation, defaulting to Options.Operation
+//   Nsigii-Service    schema service, defaulting to Options.Service
+//   Nsigii-Profile    built-in language profile, e.g. "go"
+//   Nsigii-Encoding   reply encoding, "json" or "msgpack"
+//
+// The reply is the token stream, as JSON or as encoding.MarshalStreamMsgpack
+// produces, with its encoding in the Nsigii-Encoding header. A request that
+// fails gets an empty reply with the error in the Nsigii-Error header.
+//
+// Example:
+//   nc, err := nats.Connect(nats.DefaultURL)
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   w, err := natsworker.New(nc, natsworker.Options{})
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   defer w.Close()
+package natsworker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/obinexus/nsigii-rift/nsigii"
+	"github.com/obinexus/nsigii-rift/nsigii/encoding"
+)
+
+// Request and reply headers
+const (
+	HeaderOperation = "Nsigii-Operation"
+	HeaderService   = "Nsigii-Service"
+	HeaderProfile   = "Nsigii-Profile"
+	HeaderEncoding  = "Nsigii-Encoding"
+	HeaderError     = "Nsigii-Error"
+)
+
+// Options configures a Worker
+type Options struct {
+	// Pool supplies the contexts requests run on. Nil creates a pool with
+	// default options that the worker owns.
+	Pool *nsigii.ContextPool
+
+	Subject string // Subject to serve; "" means "nsigii.tokenize"
+	Queue   string // Queue group; "" means "nsigii"
+
+	Operation string // Default schema operation; "" means "tokenize"
+	Service   string // Default schema service; "" means "worker"
+	Encoding  string // Default reply encoding; "" means "json"
+
+	// Concurrency bounds the requests handled at once; 0 means GOMAXPROCS
+	Concurrency int
+
+	// MaxSourceBytes rejects larger payloads; 0 means no limit beyond the
+	// server's maximum payload
+	MaxSourceBytes int
+
+	// Timeout bounds the wait for a pooled context; 0 means 5s
+	Timeout time.Duration
+}
+
+// Worker answers tokenization requests on a NATS subject
+type Worker struct {
+	opts    Options
+	ownPool bool
+	sub     *nats.Subscription
+	slots   chan struct{}
+
+	mu     sync.RWMutex // guards closed against wg.Add
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// New subscribes a worker to opts.Subject on nc
+func New(nc *nats.Conn, opts Options) (*Worker, error) {
+	if opts.Subject == "" {
+		opts.Subject = "nsigii.tokenize"
+	}
+	if opts.Queue == "" {
+		opts.Queue = "nsigii"
+	}
+	if opts.Operation == "" {
+		opts.Operation = "tokenize"
+	}
+	if opts.Service == "" {
+		opts.Service = "worker"
+	}
+	if opts.Encoding == "" {
+		opts.Encoding = "json"
+	}
+	if _, err := encode(opts.Encoding, &nsigii.TokenStream{}); err != nil {
+		return nil, err
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = runtime.GOMAXPROCS(0)
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+
+	w := &Worker{opts: opts, slots: make(chan struct{}, opts.Concurrency)}
+	if w.opts.Pool == nil {
+		w.opts.Pool = nsigii.NewContextPool(nsigii.PoolOptions{})
+		w.ownPool = true
+	}
+
+	sub, err := nc.QueueSubscribe(opts.Subject, opts.Queue, w.dispatch)
+	if err != nil {
+		w.closePool()
+		return nil, err
+	}
+	w.sub = sub
+	return w, nil
+}
+
+// Close stops taking requests, waits for those in progress to be answered,
+// and closes the worker's pool if it created it
+func (w *Worker) Close() error {
+	err := w.sub.Unsubscribe()
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+	w.wg.Wait()
+	if perr := w.closePool(); err == nil {
+		err = perr
+	}
+	return err
+}
+
+func (w *Worker) closePool() error {
+	if w.ownPool {
+		return w.opts.Pool.Close()
+	}
+	return nil
+}
+
+// dispatch hands msg to a goroutine once a slot is free. NATS calls it
+// serially, so waiting for a slot applies backpressure to the subscription.
+func (w *Worker) dispatch(msg *nats.Msg) {
+	w.mu.RLock()
+	if w.closed {
+		w.mu.RUnlock()
+		return
+	}
+	w.wg.Add(1)
+	w.mu.RUnlock()
+
+	w.slots <- struct{}{}
+	go func() {
+		defer func() {
+			<-w.slots
+			w.wg.Done()
+		}()
+		w.handle(msg)
+	}()
+}
+
+// handle answers one request
+func (w *Worker) handle(msg *nats.Msg) {
+	if msg.Reply == "" {
+		return // Nobody to answer
+	}
+
+	reply := nats.NewMsg(msg.Reply)
+	data, enc, err := w.tokenize(msg)
+	if err != nil {
+		reply.Header.Set(HeaderError, err.Error())
+	} else {
+		reply.Header.Set(HeaderEncoding, enc)
+		reply.Data = data
+	}
+	msg.RespondMsg(reply)
+}
+
+// tokenize tokenizes a request's payload into its encoded reply
+func (w *Worker) tokenize(msg *nats.Msg) (data []byte, enc string, err error) {
+	header := func(key, def string) string {
+		if v := msg.Header.Get(key); v != "" {
+			return v
+		}
+		return def
+	}
+
+	if w.opts.MaxSourceBytes > 0 && len(msg.Data) > w.opts.MaxSourceBytes {
+		return nil, "", fmt.Errorf("source is %d bytes, limit is %d", len(msg.Data), w.opts.MaxSourceBytes)
+	}
+	var lp nsigii.LanguageProfile
+	profile := header(HeaderProfile, "")
+	if profile != "" {
+		var ok bool
+		if lp, ok = nsigii.LookupProfile(profile); !ok {
+			return nil, "", fmt.Errorf("unknown language profile %q", profile)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), w.opts.Timeout)
+	defer cancel()
+	c, err := w.opts.Pool.Get(ctx, header(HeaderOperation, w.opts.Operation), header(HeaderService, w.opts.Service))
+	if err != nil {
+		return nil, "", err
+	}
+	defer w.opts.Pool.Put(c)
+
+	if profile != "" {
+		if err := c.SetProfile(lp); err != nil {
+			return nil, "", err
+		}
+	}
+	stream, err := c.TokenizeStream(string(msg.Data))
+	if err != nil {
+		return nil, "", err
+	}
+
+	enc = header(HeaderEncoding, w.opts.Encoding)
+	data, err = encode(enc, stream)
+	return data, enc, err
+}
+
+// encode encodes a token stream in the named encoding
+func encode(enc string, stream *nsigii.TokenStream) ([]byte, error) {
+	switch enc {
+	case "json":
+		return json.Marshal(stream)
+	case "msgpack":
+		return encoding.MarshalStreamMsgpack(stream)
+	}
+	return nil, fmt.Errorf("unknown encoding %q", enc)
+}
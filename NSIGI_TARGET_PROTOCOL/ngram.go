@@ -0,0 +1,110 @@
+package nsigii
+
+import (
+	"cmp"
+	"encoding/binary"
+	"slices"
+)
+
+// ============================================================================
+// N-gram Analysis
+// ============================================================================
+
+// NGramCount is an n-gram and the number of times it occurred
+type NGramCount struct {
+	Tokens []string // Texts of the n-gram's tokens, in order
+	Count  int
+}
+
+// NGramKey returns the NGramCounts key of the n-gram with the given token
+// texts. Each text is prefixed with its length, so no two n-grams share a
+// key whatever bytes their texts contain, as string literals and comments
+// may contain any.
+func NGramKey(texts ...string) string {
+	return string(appendNGramKey(nil, texts))
+}
+
+// appendNGramKey appends the NGramKey of texts to b
+func appendNGramKey(b []byte, texts []string) []byte {
+	for _, text := range texts {
+		b = binary.AppendUvarint(b, uint64(len(text)))
+		b = append(b, text...)
+	}
+	return b
+}
+
+// SplitNGramKey returns the token texts of an NGramCounts key. It returns
+// nil for a string that is not such a key.
+func SplitNGramKey(key string) []string {
+	var texts []string
+	for rest := key; len(rest) > 0; {
+		n, w := binary.Uvarint([]byte(rest[:min(len(rest), binary.MaxVarintLen64)]))
+		if w <= 0 || n > uint64(len(rest)-w) {
+			return nil
+		}
+		texts = append(texts, rest[w:w+int(n)])
+		rest = rest[w+int(n):]
+	}
+	return texts
+}
+
+// NGramCounts counts every run of n consecutive tokens, keyed by the
+// NGramKey of their texts. Comments and the EOF token are skipped, so
+// n-grams run across comments. n < 1 yields no n-grams.
+//
+// Example:
+//   counts := nsigii.NGramCounts(tokens, 3)
+//   for _, g := range nsigii.TopNGrams(counts, 10) {
+//       fmt.Println(g.Count, strings.Join(g.Tokens, " "))
+//   }
+func NGramCounts(tokens []Token, n int) map[string]int {
+	counts := make(map[string]int)
+	if n < 1 {
+		return counts
+	}
+
+	window := make([]string, 0, n)
+	var key []byte
+	for _, t := range tokens {
+		if t.Type == TokenComment || t.Type == TokenEOF {
+			continue
+		}
+		if len(window) == n {
+			window = append(window[:0], window[1:]...)
+		}
+		window = append(window, t.Lexeme())
+		if len(window) == n {
+			key = appendNGramKey(key[:0], window)
+			counts[string(key)]++
+		}
+	}
+	return counts
+}
+
+// MergeNGramCounts adds the counts of src to dst, to combine the n-grams
+// of several sources or corpora
+func MergeNGramCounts(dst, src map[string]int) {
+	for gram, n := range src {
+		dst[gram] += n
+	}
+}
+
+// TopNGrams returns the k most frequent n-grams of counts, most frequent
+// first and equally frequent ones in order of their token texts, so
+// reports are stable. k < 1 returns them all.
+func TopNGrams(counts map[string]int, k int) []NGramCount {
+	top := make([]NGramCount, 0, len(counts))
+	for gram, n := range counts {
+		top = append(top, NGramCount{Tokens: SplitNGramKey(gram), Count: n})
+	}
+	slices.SortFunc(top, func(a, b NGramCount) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return slices.Compare(a.Tokens, b.Tokens)
+	})
+	if k > 0 && k < len(top) {
+		top = top[:k]
+	}
+	return top
+}
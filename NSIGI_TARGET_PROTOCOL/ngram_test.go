@@ -0,0 +1,35 @@
+package nsigii
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNGramKeysDoNotCollide(t *testing.T) {
+	// Two bigrams whose texts joined with a unit separator would be equal
+	tokens := []Token{
+		{Type: TokenString, Text: "\"a\x1f\""}, {Type: TokenIdentifier, Text: "b"},
+		{Type: TokenDelimiter, Text: ";"},
+		{Type: TokenString, Text: "\"a"}, {Type: TokenString, Text: "\x1f\"b"},
+	}
+	counts := NGramCounts(tokens, 2)
+	if len(counts) != 4 {
+		t.Fatalf("got %d distinct bigrams, want 4", len(counts))
+	}
+
+	for _, g := range TopNGrams(counts, 0) {
+		if g.Count != 1 {
+			t.Errorf("%q counted %d times, want 1", g.Tokens, g.Count)
+		}
+		if key := NGramKey(g.Tokens...); counts[key] != g.Count || !slices.Equal(SplitNGramKey(key), g.Tokens) {
+			t.Errorf("key of %q does not round trip", g.Tokens)
+		}
+	}
+
+	if got := SplitNGramKey(NGramKey("", "x", "")); !slices.Equal(got, []string{"", "x", ""}) {
+		t.Errorf("SplitNGramKey with empty texts = %q", got)
+	}
+	if got := SplitNGramKey("\x05ab"); got != nil {
+		t.Errorf("SplitNGramKey(truncated) = %q, want nil", got)
+	}
+}
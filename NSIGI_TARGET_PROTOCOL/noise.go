@@ -0,0 +1,115 @@
+package nsigii
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ============================================================================
+// Noise Profiles
+// ============================================================================
+
+// MaxNoiseLevel is the highest NoiseProfile level
+const MaxNoiseLevel = 4
+
+// NoiseProfile shapes the entropy a context injects while AUX noise runs.
+// Level sets how much entropy each burst carries; with a Period, bursts
+// repeat, and Duty sets the fraction of each period during which the
+// burst's noise stays injected.
+type NoiseProfile struct {
+	Level  int           `json:"level"`            // 0 (deterministic) to MaxNoiseLevel
+	Burst  int           `json:"burst,omitempty"`  // Draws per burst; 0 means 1
+	Period time.Duration `json:"period,omitempty"` // Time between bursts; 0 for a single burst
+	Duty   float64       `json:"duty,omitempty"`   // Injected fraction of each period, (0, 1]; 0 means 1
+}
+
+var (
+	// NoiseLow injects no entropy (the native low-entropy level)
+	NoiseLow = NoiseProfile{Level: 0}
+
+	// NoiseHigh injects one burst of entropy at the start of the sequence
+	// (the native high-entropy level)
+	NoiseHigh = NoiseProfile{Level: 1}
+)
+
+// Validate checks that the profile's fields are in range
+func (p NoiseProfile) Validate() error {
+	switch {
+	case p.Level < 0 || p.Level > MaxNoiseLevel:
+		return fmt.Errorf("noise level %d out of range", p.Level)
+	case p.Burst < 0:
+		return errors.New("negative noise burst")
+	case p.Period < 0:
+		return errors.New("negative noise period")
+	case p.Duty < 0 || p.Duty > 1:
+		return fmt.Errorf("noise duty cycle %v out of range", p.Duty)
+	}
+	return nil
+}
+
+// nativeLevel maps the profile onto the C layer's low/high noise levels
+func (p NoiseProfile) nativeLevel() int {
+	if p.Level > 0 {
+		return 1
+	}
+	return 0
+}
+
+// burstSize returns the number of entropy bytes in one burst
+func (p NoiseProfile) burstSize() int {
+	return p.Level * max(p.Burst, 1) * auxNoiseSize
+}
+
+// onTime returns how long each burst stays injected
+func (p NoiseProfile) onTime() time.Duration {
+	if p.Duty == 0 {
+		return p.Period
+	}
+	return time.Duration(float64(p.Period) * p.Duty)
+}
+
+// scheduleBursts repeats p's bursts, the first of which was injected just
+// now, until the noise segment started with generation gen ends
+func (c *Context) scheduleBursts(p NoiseProfile, gen uint64) {
+	on := p.onTime()
+	start := time.Now()
+
+	go func() {
+		for next := start; ; {
+			if on < p.Period {
+				time.Sleep(time.Until(next.Add(on)))
+				if !c.burst(p, gen, false) {
+					return
+				}
+			}
+
+			next = next.Add(p.Period)
+			time.Sleep(time.Until(next))
+			if !c.burst(p, gen, true) {
+				return
+			}
+		}
+	}()
+}
+
+// burst injects a fresh burst (on) or clears the injected noise (!on),
+// reporting false once the segment started with generation gen has ended
+func (c *Context) burst(p NoiseProfile, gen uint64, on bool) bool {
+	c.aux.mu.Lock()
+	defer c.aux.mu.Unlock()
+
+	if c.aux.gen != gen || c.closed() {
+		return false
+	}
+	if !on {
+		c.aux.noise = nil
+		return true
+	}
+
+	noise, err := c.drawNoise(p)
+	if err == nil {
+		c.aux.noise = noise
+	}
+	return true
+}
@@ -150,6 +150,7 @@ typedef struct {
     // Color verification
     ColorChannel active_colors[3];  // RED, GREEN, BLUE active channels
     Polarity color_polarity[8];     // Polarity for each color
+    Polarity polarity;              // Flow polarity of the context
     
     // Consensus
     Trident* trident;     // 3-way version consensus
@@ -174,6 +175,9 @@ void nsigii_destroy_context(NSigiiContext* ctx);
 // Token operations (RIFT Stage 000-111)
 int nsigii_tokenize(NSigiiContext* ctx, const char* input, 
                     TokenTriplet* tokens, size_t max_tokens, size_t* count);
+int nsigii_tokenize_batch(NSigiiContext* ctx, const char* const* inputs,
+                          size_t n_inputs, TokenTriplet* tokens, size_t max_tokens,
+                          size_t* counts, int* results, size_t* done);
 bool nsigii_validate_token(const TokenTriplet* token, ColorChannel color);
 
 // Tomographic operations (RIFT Stage 222)
@@ -184,6 +188,8 @@ bool nsigii_verify_coherence(int** matrix, int rows, int cols, Polarity shared);
 bool nsigii_verify_color_channel(NSigiiContext* ctx, ColorChannel channel);
 bool nsigii_verify_rgb_consensus(NSigiiContext* ctx);
 Polarity nsigii_get_polarity(ColorChannel channel);
+int nsigii_set_polarity(NSigiiContext* ctx, Polarity polarity);
+Polarity nsigii_context_polarity(NSigiiContext* ctx);
 
 // AUX instruction control
 int nsigii_aux_start(NSigiiContext* ctx, NoiseLevel noise);
@@ -256,6 +262,7 @@ NSigiiContext* nsigii_create_context(const char* operation, const char* service)
     ctx->color_polarity[COLOR_GREEN] = POLARITY_NEG;
     ctx->color_polarity[COLOR_BLUE] = POLARITY_NEUTRAL;
     ctx->color_polarity[COLOR_CYAN] = POLARITY_NEUTRAL;
+    ctx->polarity = POLARITY_POS;
     
     // Create CISCO tree
     ctx->cisco = nsigii_cisco_create();
@@ -278,6 +285,46 @@ void nsigii_destroy_context(NSigiiContext* ctx) {
     free(ctx);
 }
 
+// Tokenize a batch of inputs into one shared token buffer, back to back.
+// counts[i] and results[i] receive the token count and result code of
+// inputs[i]; a failing input takes no room and does not stop the batch.
+// Stops early with NSIGII_ERROR_NO_MEMORY once the remaining buffer may be
+// too small for the next input (every token consumes at least one byte, plus
+// the EOF token); *done is the number of inputs completed, so the caller can
+// resume from there with a fresh buffer.
+int nsigii_tokenize_batch(NSigiiContext* ctx, const char* const* inputs,
+                          size_t n_inputs, TokenTriplet* tokens, size_t max_tokens,
+                          size_t* counts, int* results, size_t* done) {
+    if (!ctx) return NSIGII_ERROR_NULL_CTX;
+    if (!inputs || !tokens || !counts || !results || !done) return NSIGII_ERROR_NULL_INPUT;
+    
+    size_t used = 0;
+    *done = 0;
+    
+    for (size_t i = 0; i < n_inputs; i++) {
+        size_t remaining = max_tokens - used;
+        size_t ceiling = strlen(inputs[i]) + 1;
+        size_t count = 0;
+        
+        int result = NSIGII_ERROR_NO_MEMORY;
+        if (remaining > 0) {
+            result = nsigii_tokenize(ctx, inputs[i], tokens + used, remaining, &count);
+        }
+        
+        // Overflow is only certain once the input had room for its ceiling
+        if ((result != NSIGII_SUCCESS || count >= remaining) && remaining < ceiling) {
+            return NSIGII_ERROR_NO_MEMORY;
+        }
+        
+        counts[i] = (result == NSIGII_SUCCESS) ? count : 0;
+        results[i] = result;
+        used += counts[i];
+        *done = i + 1;
+    }
+    
+    return NSIGII_SUCCESS;
+}
+
 // Generate schema string
 int nsigii_generate_schema(NSigiiContext* ctx, char* schema_out, size_t len) {
     if (!ctx || !schema_out) return NSIGII_ERROR_NULL_INPUT;
@@ -323,6 +370,27 @@ bool nsigii_verify_rgb_consensus(NSigiiContext* ctx) {
     return false;
 }
 
+// Set the flow polarity of the context
+int nsigii_set_polarity(NSigiiContext* ctx, Polarity polarity) {
+    if (!ctx) return NSIGII_ERROR_NULL_CTX;
+    
+    if (polarity != POLARITY_POS && polarity != POLARITY_NEG &&
+        polarity != POLARITY_NEUTRAL) {
+        return NSIGII_ERROR_INVALID;
+    }
+    
+    ctx->polarity = polarity;
+    
+    return NSIGII_SUCCESS;
+}
+
+// Get the flow polarity of the context
+Polarity nsigii_context_polarity(NSigiiContext* ctx) {
+    if (!ctx) return POLARITY_NEUTRAL;
+    
+    return ctx->polarity;
+}
+
 // Initialize tomographic index with all 6 permutations
 void nsigii_init_tomographic_index(TomographicIndex* idx, int i, int j, int k) {
     idx->i = i;
@@ -40,7 +40,12 @@ NSigiiContext* nsigii_create_context(const char* operation, const char* service)
 void nsigii_destroy_context(NSigiiContext* ctx);
 int nsigii_tokenize(NSigiiContext* ctx, const char* input,
                    TokenTriplet* tokens, size_t max_tokens, size_t* count);
+int nsigii_tokenize_batch(NSigiiContext* ctx, const char* const* inputs,
+                         size_t n_inputs, TokenTriplet* tokens, size_t max_tokens,
+                         size_t* counts, int* results, size_t* done);
 int nsigii_generate_schema(NSigiiContext* ctx, char* schema_out, size_t len);
 int nsigii_aux_start(NSigiiContext* ctx, int noise);
 int nsigii_aux_stop(NSigiiContext* ctx);
-bool nsigii_verify_rgb_consensus(NSigiiContext* ctx);
\ No newline at end of file
+bool nsigii_verify_rgb_consensus(NSigiiContext* ctx);
+int nsigii_set_polarity(NSigiiContext* ctx, Polarity polarity);
+Polarity nsigii_context_polarity(NSigiiContext* ctx);
\ No newline at end of file
@@ -0,0 +1,275 @@
+// Package nsigiipb holds the protobuf form of NSIGII token data, defined in
+// nsigii.proto, and converters to and from the native types, so token
+// streams can be exchanged with protobuf-based data platforms. It has no
+// gRPC dependency; the service built on these messages is in
+// nsigii/grpc/tokenizerpb.
+//
+// Example:
+//   tokens, _ := ctx.Tokenize(source)
+//   msg := nsigiipb.FromTokenStream(nsigii.NewTokenStream(schema, tokens))
+//   data, err := proto.Marshal(msg)
+package nsigiipb
+
+//go:generate protoc -I .. --go_out=.. --go_opt=paths=source_relative nsigiipb/nsigii.proto
+
+import (
+	"fmt"
+	"slices"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/obinexus/nsigii-rift/nsigii"
+)
+
+// ============================================================================
+// Tokens
+// ============================================================================
+
+// FromToken converts a native token
+func FromToken(t nsigii.Token) *Token {
+	return &Token{
+		Type:       TokenType(t.Type),
+		Memory:     t.Memory,
+		Value:      t.Value,
+		Text:       t.Lexeme(),
+		Line:       int32(t.Line),
+		Column:     int32(t.Column),
+		EndOffset:  t.EndOffset,
+		RuneOffset: t.RuneOffset,
+		RuneLength: t.RuneLength,
+	}
+}
+
+// Native converts t to a native token
+func (t *Token) Native() nsigii.Token {
+	return nsigii.Token{
+		Type:       nsigii.TokenType(t.GetType()),
+		Memory:     t.GetMemory(),
+		Value:      t.GetValue(),
+		Text:       t.GetText(),
+		Line:       int(t.GetLine()),
+		Column:     int(t.GetColumn()),
+		EndOffset:  t.GetEndOffset(),
+		RuneOffset: t.GetRuneOffset(),
+		RuneLength: t.GetRuneLength(),
+	}
+}
+
+// FromTokens converts native tokens
+func FromTokens(tokens []nsigii.Token) []*Token {
+	out := make([]*Token, len(tokens))
+	for i, t := range tokens {
+		out[i] = FromToken(t)
+	}
+	return out
+}
+
+// NativeTokens converts tokens to native tokens
+func NativeTokens(tokens []*Token) []nsigii.Token {
+	out := make([]nsigii.Token, len(tokens))
+	for i, t := range tokens {
+		out[i] = t.Native()
+	}
+	return out
+}
+
+// FromTokenStats converts native token statistics
+func FromTokenStats(s nsigii.TokenStats) *TokenStats {
+	dist := make(map[string]int64, len(s.TypeDistribution))
+	for typ, n := range s.TypeDistribution {
+		name, _ := typ.MarshalText()
+		dist[string(name)] = int64(n)
+	}
+	lengths := make(map[string]*Histogram, len(s.TypeLengths))
+	for typ, h := range s.TypeLengths {
+		name, _ := typ.MarshalText()
+		lengths[string(name)] = FromHistogram(h)
+	}
+	return &TokenStats{
+		TotalTokens:      int64(s.TotalTokens),
+		TypeDistribution: dist,
+		MemoryMin:        s.MemoryRange[0],
+		MemoryMax:        s.MemoryRange[1],
+		AverageLength:    s.AverageLength,
+		LengthHistogram:  FromHistogram(s.LengthHistogram),
+		TypeLengths:      lengths,
+	}
+}
+
+// Native converts s to native token statistics. It fails on a type key
+// that is neither a known type name nor a decimal value.
+func (s *TokenStats) Native() (nsigii.TokenStats, error) {
+	stats := nsigii.TokenStats{
+		TotalTokens:      int(s.GetTotalTokens()),
+		TypeDistribution: make(map[nsigii.TokenType]int, len(s.GetTypeDistribution())),
+		MemoryRange:      [2]uint32{s.GetMemoryMin(), s.GetMemoryMax()},
+		AverageLength:    s.GetAverageLength(),
+		LengthHistogram:  s.GetLengthHistogram().Native(),
+		TypeLengths:      make(map[nsigii.TokenType]nsigii.Histogram, len(s.GetTypeLengths())),
+	}
+	for name, n := range s.GetTypeDistribution() {
+		var typ nsigii.TokenType
+		if err := typ.UnmarshalText([]byte(name)); err != nil {
+			return nsigii.TokenStats{}, err
+		}
+		stats.TypeDistribution[typ] = int(n)
+	}
+	for name, h := range s.GetTypeLengths() {
+		var typ nsigii.TokenType
+		if err := typ.UnmarshalText([]byte(name)); err != nil {
+			return nsigii.TokenStats{}, err
+		}
+		stats.TypeLengths[typ] = h.Native()
+	}
+	return stats, nil
+}
+
+// FromHistogram converts a native length histogram
+func FromHistogram(h nsigii.Histogram) *Histogram {
+	msg := &Histogram{Bounds: slices.Clone(h.Bounds)}
+	if h.Counts != nil {
+		msg.Counts = make([]int64, len(h.Counts))
+		for i, n := range h.Counts {
+			msg.Counts[i] = int64(n)
+		}
+	}
+	for _, q := range h.Quantiles {
+		msg.Quantiles = append(msg.Quantiles, &Quantile{Q: q.Q, Length: q.Length})
+	}
+	return msg
+}
+
+// Native converts h to a native length histogram. A nil h is the zero
+// Histogram.
+func (h *Histogram) Native() nsigii.Histogram {
+	var hist nsigii.Histogram
+	if len(h.GetBounds()) > 0 {
+		hist.Bounds = slices.Clone(h.GetBounds())
+	}
+	if len(h.GetCounts()) > 0 {
+		hist.Counts = make([]int, len(h.GetCounts()))
+		for i, n := range h.GetCounts() {
+			hist.Counts[i] = int(n)
+		}
+	}
+	for _, q := range h.GetQuantiles() {
+		hist.Quantiles = append(hist.Quantiles, nsigii.Quantile{Q: q.GetQ(), Length: q.GetLength()})
+	}
+	return hist
+}
+
+// FromTokenStream converts a native token stream
+func FromTokenStream(s *nsigii.TokenStream) *TokenStream {
+	msg := &TokenStream{
+		Version:   int32(s.Version),
+		Schema:    s.Schema,
+		Tokens:    FromTokens(s.Tokens),
+		Seal:      s.Seal,
+		Signer:    s.Signer,
+		Signature: s.Signature,
+	}
+	if s.Stats != nil {
+		msg.Stats = FromTokenStats(*s.Stats)
+	}
+	if s.Origin != nil {
+		msg.Origin = FromPhantomID(*s.Origin)
+	}
+	return msg
+}
+
+// Native converts s to a native token stream, rejecting unsupported
+// versions as decoding the JSON form does
+func (s *TokenStream) Native() (*nsigii.TokenStream, error) {
+	if s.GetVersion() != nsigii.TokenStreamVersion {
+		return nil, fmt.Errorf("unsupported token stream version: %d", s.GetVersion())
+	}
+
+	stream := &nsigii.TokenStream{
+		Version:   int(s.GetVersion()),
+		Schema:    s.GetSchema(),
+		Tokens:    NativeTokens(s.GetTokens()),
+		Seal:      s.GetSeal(),
+		Signer:    s.GetSigner(),
+		Signature: s.GetSignature(),
+	}
+	if s.Stats != nil {
+		stats, err := s.Stats.Native()
+		if err != nil {
+			return nil, err
+		}
+		stream.Stats = &stats
+	}
+	if s.Origin != nil {
+		origin, err := s.Origin.Native()
+		if err != nil {
+			return nil, err
+		}
+		stream.Origin = &origin
+	}
+	return stream, nil
+}
+
+// ============================================================================
+// Identity and Color State
+// ============================================================================
+
+// FromPhantomID converts a phantom ID
+func FromPhantomID(id nsigii.PhantomID) *PhantomID {
+	return &PhantomID{
+		Id:       id.String(),
+		Schema:   id.Schema(),
+		Subject:  id.Subject(),
+		IssuedAt: timestamppb.New(id.IssuedAt()),
+		Depth:    int32(id.Depth()),
+	}
+}
+
+// Native decodes the phantom ID from its id field; the other fields are
+// ignored. The result still has to be verified by a context holding the
+// minting key.
+func (id *PhantomID) Native() (nsigii.PhantomID, error) {
+	return nsigii.ParsePhantomID(id.GetId())
+}
+
+// FromColorTransition converts a color transition
+func FromColorTransition(t nsigii.ColorTransition) *ColorTransition {
+	return &ColorTransition{
+		From:   ColorChannel(t.From),
+		To:     ColorChannel(t.To),
+		Reason: t.Reason,
+		Time:   timestamppb.New(t.Time),
+	}
+}
+
+// Native converts t to a native color transition
+func (t *ColorTransition) Native() nsigii.ColorTransition {
+	return nsigii.ColorTransition{
+		From:   nsigii.ColorChannel(t.GetFrom()),
+		To:     nsigii.ColorChannel(t.GetTo()),
+		Reason: t.GetReason(),
+		Time:   t.GetTime().AsTime(),
+	}
+}
+
+// FromColorState captures the color state of a context, with the
+// transitions still in its audit trail as history
+func FromColorState(c *nsigii.Context) *ColorState {
+	msg := &ColorState{State: ColorChannel(c.ColorState())}
+	for _, e := range c.ColorAudit() {
+		if e.Kind != nsigii.AuditTransition {
+			continue
+		}
+		msg.History = append(msg.History, FromColorTransition(nsigii.ColorTransition{
+			From:   e.From,
+			To:     e.To,
+			Reason: e.Reason,
+			Time:   e.Time,
+		}))
+	}
+	return msg
+}
+
+// Native returns the color state s records
+func (s *ColorState) Native() nsigii.ColorChannel {
+	return nsigii.ColorChannel(s.GetState())
+}
@@ -0,0 +1,48 @@
+package nsigiipb
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/obinexus/nsigii-rift/nsigii"
+)
+
+func TestTokenStatsHistogramsRoundTrip(t *testing.T) {
+	ctx, err := nsigii.NewContext("tokenize", "lexer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+	tokens, err := ctx.Tokenize("let greeting = \"hello, world\"; // a comment\nlet n = 42 + greeting_length;\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := nsigii.AnalyzeTokens(tokens)
+	if len(stats.TypeLengths) == 0 || stats.LengthHistogram.Total() == 0 {
+		t.Fatal("fixture has no histograms")
+	}
+
+	// Native to message and back, through the wire form
+	data, err := proto.Marshal(FromTokenStats(stats))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg TokenStats
+	if err := proto.Unmarshal(data, &msg); err != nil {
+		t.Fatal(err)
+	}
+	got, err := msg.Native()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, stats) {
+		t.Fatalf("round trip changed the stats\ngot:  %+v\nwant: %+v", got, stats)
+	}
+
+	// Message to native and back
+	if again := FromTokenStats(got); !proto.Equal(again, &msg) {
+		t.Fatalf("round trip changed the message\ngot:  %v\nwant: %v", again, &msg)
+	}
+}
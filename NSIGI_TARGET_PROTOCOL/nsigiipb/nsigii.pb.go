@@ -0,0 +1,943 @@
+// NSIGII RIFT token data
+//
+// Regenerate the Go code with `go generate` in this directory.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: nsigiipb/nsigii.proto
+
+package nsigiipb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// TokenType mirrors nsigii.TokenType. Types registered at run time by
+// downstream stages arrive as their numeric value.
+type TokenType int32
+
+const (
+	TokenType_TOKEN_TYPE_EOF        TokenType = 0
+	TokenType_TOKEN_TYPE_IDENTIFIER TokenType = 1
+	TokenType_TOKEN_TYPE_KEYWORD    TokenType = 2
+	TokenType_TOKEN_TYPE_NUMBER     TokenType = 3
+	TokenType_TOKEN_TYPE_OPERATOR   TokenType = 4
+	TokenType_TOKEN_TYPE_DELIMITER  TokenType = 5
+	TokenType_TOKEN_TYPE_STRING     TokenType = 6
+	TokenType_TOKEN_TYPE_COMMENT    TokenType = 7
+	TokenType_TOKEN_TYPE_ERROR      TokenType = 8
+)
+
+// Enum value maps for TokenType.
+var (
+	TokenType_name = map[int32]string{
+		0: "TOKEN_TYPE_EOF",
+		1: "TOKEN_TYPE_IDENTIFIER",
+		2: "TOKEN_TYPE_KEYWORD",
+		3: "TOKEN_TYPE_NUMBER",
+		4: "TOKEN_TYPE_OPERATOR",
+		5: "TOKEN_TYPE_DELIMITER",
+		6: "TOKEN_TYPE_STRING",
+		7: "TOKEN_TYPE_COMMENT",
+		8: "TOKEN_TYPE_ERROR",
+	}
+	TokenType_value = map[string]int32{
+		"TOKEN_TYPE_EOF":        0,
+		"TOKEN_TYPE_IDENTIFIER": 1,
+		"TOKEN_TYPE_KEYWORD":    2,
+		"TOKEN_TYPE_NUMBER":     3,
+		"TOKEN_TYPE_OPERATOR":   4,
+		"TOKEN_TYPE_DELIMITER":  5,
+		"TOKEN_TYPE_STRING":     6,
+		"TOKEN_TYPE_COMMENT":    7,
+		"TOKEN_TYPE_ERROR":      8,
+	}
+)
+
+func (x TokenType) Enum() *TokenType {
+	p := new(TokenType)
+	*p = x
+	return p
+}
+
+func (x TokenType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (TokenType) Descriptor() protoreflect.EnumDescriptor {
+	return file_nsigiipb_nsigii_proto_enumTypes[0].Descriptor()
+}
+
+func (TokenType) Type() protoreflect.EnumType {
+	return &file_nsigiipb_nsigii_proto_enumTypes[0]
+}
+
+func (x TokenType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use TokenType.Descriptor instead.
+func (TokenType) EnumDescriptor() ([]byte, []int) {
+	return file_nsigiipb_nsigii_proto_rawDescGZIP(), []int{0}
+}
+
+// ColorChannel mirrors nsigii.ColorChannel
+type ColorChannel int32
+
+const (
+	ColorChannel_COLOR_CHANNEL_RED      ColorChannel = 0
+	ColorChannel_COLOR_CHANNEL_GREEN    ColorChannel = 1
+	ColorChannel_COLOR_CHANNEL_BLUE     ColorChannel = 2
+	ColorChannel_COLOR_CHANNEL_CYAN     ColorChannel = 3
+	ColorChannel_COLOR_CHANNEL_YELLOW   ColorChannel = 4
+	ColorChannel_COLOR_CHANNEL_MAGENTA  ColorChannel = 5
+	ColorChannel_COLOR_CHANNEL_BLACK    ColorChannel = 6
+	ColorChannel_COLOR_CHANNEL_CONTRAST ColorChannel = 7
+)
+
+// Enum value maps for ColorChannel.
+var (
+	ColorChannel_name = map[int32]string{
+		0: "COLOR_CHANNEL_RED",
+		1: "COLOR_CHANNEL_GREEN",
+		2: "COLOR_CHANNEL_BLUE",
+		3: "COLOR_CHANNEL_CYAN",
+		4: "COLOR_CHANNEL_YELLOW",
+		5: "COLOR_CHANNEL_MAGENTA",
+		6: "COLOR_CHANNEL_BLACK",
+		7: "COLOR_CHANNEL_CONTRAST",
+	}
+	ColorChannel_value = map[string]int32{
+		"COLOR_CHANNEL_RED":      0,
+		"COLOR_CHANNEL_GREEN":    1,
+		"COLOR_CHANNEL_BLUE":     2,
+		"COLOR_CHANNEL_CYAN":     3,
+		"COLOR_CHANNEL_YELLOW":   4,
+		"COLOR_CHANNEL_MAGENTA":  5,
+		"COLOR_CHANNEL_BLACK":    6,
+		"COLOR_CHANNEL_CONTRAST": 7,
+	}
+)
+
+func (x ColorChannel) Enum() *ColorChannel {
+	p := new(ColorChannel)
+	*p = x
+	return p
+}
+
+func (x ColorChannel) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ColorChannel) Descriptor() protoreflect.EnumDescriptor {
+	return file_nsigiipb_nsigii_proto_enumTypes[1].Descriptor()
+}
+
+func (ColorChannel) Type() protoreflect.EnumType {
+	return &file_nsigiipb_nsigii_proto_enumTypes[1]
+}
+
+func (x ColorChannel) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ColorChannel.Descriptor instead.
+func (ColorChannel) EnumDescriptor() ([]byte, []int) {
+	return file_nsigiipb_nsigii_proto_rawDescGZIP(), []int{1}
+}
+
+// Token is a (type, memory, value) triplet with its text and position
+type Token struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          TokenType              `protobuf:"varint,1,opt,name=type,proto3,enum=nsigii.v1.TokenType" json:"type,omitempty"`
+	Memory        uint32                 `protobuf:"varint,2,opt,name=memory,proto3" json:"memory,omitempty"` // Byte offset in the source
+	Value         uint32                 `protobuf:"varint,3,opt,name=value,proto3" json:"value,omitempty"`   // Length in bytes
+	Text          string                 `protobuf:"bytes,4,opt,name=text,proto3" json:"text,omitempty"`
+	Line          int32                  `protobuf:"varint,5,opt,name=line,proto3" json:"line,omitempty"`     // 1-based, 0 if not tracked
+	Column        int32                  `protobuf:"varint,6,opt,name=column,proto3" json:"column,omitempty"` // 1-based byte column, 0 if not tracked
+	EndOffset     uint32                 `protobuf:"varint,7,opt,name=end_offset,json=endOffset,proto3" json:"end_offset,omitempty"`
+	RuneOffset    uint32                 `protobuf:"varint,8,opt,name=rune_offset,json=runeOffset,proto3" json:"rune_offset,omitempty"` // 0 unless rune offsets are enabled
+	RuneLength    uint32                 `protobuf:"varint,9,opt,name=rune_length,json=runeLength,proto3" json:"rune_length,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Token) Reset() {
+	*x = Token{}
+	mi := &file_nsigiipb_nsigii_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Token) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Token) ProtoMessage() {}
+
+func (x *Token) ProtoReflect() protoreflect.Message {
+	mi := &file_nsigiipb_nsigii_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Token.ProtoReflect.Descriptor instead.
+func (*Token) Descriptor() ([]byte, []int) {
+	return file_nsigiipb_nsigii_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Token) GetType() TokenType {
+	if x != nil {
+		return x.Type
+	}
+	return TokenType_TOKEN_TYPE_EOF
+}
+
+func (x *Token) GetMemory() uint32 {
+	if x != nil {
+		return x.Memory
+	}
+	return 0
+}
+
+func (x *Token) GetValue() uint32 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+func (x *Token) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Token) GetLine() int32 {
+	if x != nil {
+		return x.Line
+	}
+	return 0
+}
+
+func (x *Token) GetColumn() int32 {
+	if x != nil {
+		return x.Column
+	}
+	return 0
+}
+
+func (x *Token) GetEndOffset() uint32 {
+	if x != nil {
+		return x.EndOffset
+	}
+	return 0
+}
+
+func (x *Token) GetRuneOffset() uint32 {
+	if x != nil {
+		return x.RuneOffset
+	}
+	return 0
+}
+
+func (x *Token) GetRuneLength() uint32 {
+	if x != nil {
+		return x.RuneLength
+	}
+	return 0
+}
+
+// TokenStats mirrors nsigii.TokenStats
+type TokenStats struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	TotalTokens      int64                  `protobuf:"varint,1,opt,name=total_tokens,json=totalTokens,proto3" json:"total_tokens,omitempty"`
+	TypeDistribution map[string]int64       `protobuf:"bytes,2,rep,name=type_distribution,json=typeDistribution,proto3" json:"type_distribution,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"` // Keyed by token type name, or value if unnamed
+	MemoryMin        uint32                 `protobuf:"varint,3,opt,name=memory_min,json=memoryMin,proto3" json:"memory_min,omitempty"`
+	MemoryMax        uint32                 `protobuf:"varint,4,opt,name=memory_max,json=memoryMax,proto3" json:"memory_max,omitempty"`
+	AverageLength    float64                `protobuf:"fixed64,5,opt,name=average_length,json=averageLength,proto3" json:"average_length,omitempty"`
+	LengthHistogram  *Histogram             `protobuf:"bytes,6,opt,name=length_histogram,json=lengthHistogram,proto3" json:"length_histogram,omitempty"`                                                               // Lengths of all tokens
+	TypeLengths      map[string]*Histogram  `protobuf:"bytes,7,rep,name=type_lengths,json=typeLengths,proto3" json:"type_lengths,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // Lengths of each type's tokens, keyed like type_distribution
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *TokenStats) Reset() {
+	*x = TokenStats{}
+	mi := &file_nsigiipb_nsigii_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TokenStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TokenStats) ProtoMessage() {}
+
+func (x *TokenStats) ProtoReflect() protoreflect.Message {
+	mi := &file_nsigiipb_nsigii_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TokenStats.ProtoReflect.Descriptor instead.
+func (*TokenStats) Descriptor() ([]byte, []int) {
+	return file_nsigiipb_nsigii_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *TokenStats) GetTotalTokens() int64 {
+	if x != nil {
+		return x.TotalTokens
+	}
+	return 0
+}
+
+func (x *TokenStats) GetTypeDistribution() map[string]int64 {
+	if x != nil {
+		return x.TypeDistribution
+	}
+	return nil
+}
+
+func (x *TokenStats) GetMemoryMin() uint32 {
+	if x != nil {
+		return x.MemoryMin
+	}
+	return 0
+}
+
+func (x *TokenStats) GetMemoryMax() uint32 {
+	if x != nil {
+		return x.MemoryMax
+	}
+	return 0
+}
+
+func (x *TokenStats) GetAverageLength() float64 {
+	if x != nil {
+		return x.AverageLength
+	}
+	return 0
+}
+
+func (x *TokenStats) GetLengthHistogram() *Histogram {
+	if x != nil {
+		return x.LengthHistogram
+	}
+	return nil
+}
+
+func (x *TokenStats) GetTypeLengths() map[string]*Histogram {
+	if x != nil {
+		return x.TypeLengths
+	}
+	return nil
+}
+
+// Histogram mirrors nsigii.Histogram
+type Histogram struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Bounds        []uint32               `protobuf:"varint,1,rep,packed,name=bounds,proto3" json:"bounds,omitempty"` // Inclusive upper bound of each bucket but the last, ascending
+	Counts        []int64                `protobuf:"varint,2,rep,packed,name=counts,proto3" json:"counts,omitempty"` // Tokens per bucket; the last holds lengths above every bound
+	Quantiles     []*Quantile            `protobuf:"bytes,3,rep,name=quantiles,proto3" json:"quantiles,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Histogram) Reset() {
+	*x = Histogram{}
+	mi := &file_nsigiipb_nsigii_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Histogram) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Histogram) ProtoMessage() {}
+
+func (x *Histogram) ProtoReflect() protoreflect.Message {
+	mi := &file_nsigiipb_nsigii_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Histogram.ProtoReflect.Descriptor instead.
+func (*Histogram) Descriptor() ([]byte, []int) {
+	return file_nsigiipb_nsigii_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Histogram) GetBounds() []uint32 {
+	if x != nil {
+		return x.Bounds
+	}
+	return nil
+}
+
+func (x *Histogram) GetCounts() []int64 {
+	if x != nil {
+		return x.Counts
+	}
+	return nil
+}
+
+func (x *Histogram) GetQuantiles() []*Quantile {
+	if x != nil {
+		return x.Quantiles
+	}
+	return nil
+}
+
+// Quantile mirrors nsigii.Quantile
+type Quantile struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Q             float64                `protobuf:"fixed64,1,opt,name=q,proto3" json:"q,omitempty"`
+	Length        uint32                 `protobuf:"varint,2,opt,name=length,proto3" json:"length,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Quantile) Reset() {
+	*x = Quantile{}
+	mi := &file_nsigiipb_nsigii_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Quantile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Quantile) ProtoMessage() {}
+
+func (x *Quantile) ProtoReflect() protoreflect.Message {
+	mi := &file_nsigiipb_nsigii_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Quantile.ProtoReflect.Descriptor instead.
+func (*Quantile) Descriptor() ([]byte, []int) {
+	return file_nsigiipb_nsigii_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Quantile) GetQ() float64 {
+	if x != nil {
+		return x.Q
+	}
+	return 0
+}
+
+func (x *Quantile) GetLength() uint32 {
+	if x != nil {
+		return x.Length
+	}
+	return 0
+}
+
+// TokenStream mirrors nsigii.TokenStream, the persisted form of a
+// tokenization result
+type TokenStream struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Version       int32                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Schema        string                 `protobuf:"bytes,2,opt,name=schema,proto3" json:"schema,omitempty"` // obinexus.[operation].[service] of the producer
+	Tokens        []*Token               `protobuf:"bytes,3,rep,name=tokens,proto3" json:"tokens,omitempty"`
+	Stats         *TokenStats            `protobuf:"bytes,4,opt,name=stats,proto3" json:"stats,omitempty"`
+	Origin        *PhantomID             `protobuf:"bytes,5,opt,name=origin,proto3" json:"origin,omitempty"`       // Set by Context.Stamp
+	Seal          []byte                 `protobuf:"bytes,6,opt,name=seal,proto3" json:"seal,omitempty"`           // MAC binding origin to the tokens
+	Signer        []byte                 `protobuf:"bytes,7,opt,name=signer,proto3" json:"signer,omitempty"`       // Ed25519 public key, set by SignStream
+	Signature     []byte                 `protobuf:"bytes,8,opt,name=signature,proto3" json:"signature,omitempty"` // Ed25519 signature over the canonical encoding
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TokenStream) Reset() {
+	*x = TokenStream{}
+	mi := &file_nsigiipb_nsigii_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TokenStream) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TokenStream) ProtoMessage() {}
+
+func (x *TokenStream) ProtoReflect() protoreflect.Message {
+	mi := &file_nsigiipb_nsigii_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TokenStream.ProtoReflect.Descriptor instead.
+func (*TokenStream) Descriptor() ([]byte, []int) {
+	return file_nsigiipb_nsigii_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *TokenStream) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *TokenStream) GetSchema() string {
+	if x != nil {
+		return x.Schema
+	}
+	return ""
+}
+
+func (x *TokenStream) GetTokens() []*Token {
+	if x != nil {
+		return x.Tokens
+	}
+	return nil
+}
+
+func (x *TokenStream) GetStats() *TokenStats {
+	if x != nil {
+		return x.Stats
+	}
+	return nil
+}
+
+func (x *TokenStream) GetOrigin() *PhantomID {
+	if x != nil {
+		return x.Origin
+	}
+	return nil
+}
+
+func (x *TokenStream) GetSeal() []byte {
+	if x != nil {
+		return x.Seal
+	}
+	return nil
+}
+
+func (x *TokenStream) GetSigner() []byte {
+	if x != nil {
+		return x.Signer
+	}
+	return nil
+}
+
+func (x *TokenStream) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+// PhantomID carries an nsigii.PhantomID. Only id is authoritative; the
+// other fields repeat what it encodes for readers that cannot decode it.
+type PhantomID struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"` // Canonical text form, as returned by PhantomID.String
+	Schema        string                 `protobuf:"bytes,2,opt,name=schema,proto3" json:"schema,omitempty"`
+	Subject       string                 `protobuf:"bytes,3,opt,name=subject,proto3" json:"subject,omitempty"`
+	IssuedAt      *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=issued_at,json=issuedAt,proto3" json:"issued_at,omitempty"`
+	Depth         int32                  `protobuf:"varint,5,opt,name=depth,proto3" json:"depth,omitempty"` // Number of delegations from the minting context
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PhantomID) Reset() {
+	*x = PhantomID{}
+	mi := &file_nsigiipb_nsigii_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PhantomID) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PhantomID) ProtoMessage() {}
+
+func (x *PhantomID) ProtoReflect() protoreflect.Message {
+	mi := &file_nsigiipb_nsigii_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PhantomID.ProtoReflect.Descriptor instead.
+func (*PhantomID) Descriptor() ([]byte, []int) {
+	return file_nsigiipb_nsigii_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *PhantomID) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *PhantomID) GetSchema() string {
+	if x != nil {
+		return x.Schema
+	}
+	return ""
+}
+
+func (x *PhantomID) GetSubject() string {
+	if x != nil {
+		return x.Subject
+	}
+	return ""
+}
+
+func (x *PhantomID) GetIssuedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.IssuedAt
+	}
+	return nil
+}
+
+func (x *PhantomID) GetDepth() int32 {
+	if x != nil {
+		return x.Depth
+	}
+	return 0
+}
+
+// ColorTransition mirrors nsigii.ColorTransition
+type ColorTransition struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	From          ColorChannel           `protobuf:"varint,1,opt,name=from,proto3,enum=nsigii.v1.ColorChannel" json:"from,omitempty"`
+	To            ColorChannel           `protobuf:"varint,2,opt,name=to,proto3,enum=nsigii.v1.ColorChannel" json:"to,omitempty"`
+	Reason        string                 `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+	Time          *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=time,proto3" json:"time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ColorTransition) Reset() {
+	*x = ColorTransition{}
+	mi := &file_nsigiipb_nsigii_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ColorTransition) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ColorTransition) ProtoMessage() {}
+
+func (x *ColorTransition) ProtoReflect() protoreflect.Message {
+	mi := &file_nsigiipb_nsigii_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ColorTransition.ProtoReflect.Descriptor instead.
+func (*ColorTransition) Descriptor() ([]byte, []int) {
+	return file_nsigiipb_nsigii_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ColorTransition) GetFrom() ColorChannel {
+	if x != nil {
+		return x.From
+	}
+	return ColorChannel_COLOR_CHANNEL_RED
+}
+
+func (x *ColorTransition) GetTo() ColorChannel {
+	if x != nil {
+		return x.To
+	}
+	return ColorChannel_COLOR_CHANNEL_RED
+}
+
+func (x *ColorTransition) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *ColorTransition) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+// ColorState is a context's color state with the transitions that led to
+// it, oldest first, as far as its audit trail reaches
+type ColorState struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	State         ColorChannel           `protobuf:"varint,1,opt,name=state,proto3,enum=nsigii.v1.ColorChannel" json:"state,omitempty"`
+	History       []*ColorTransition     `protobuf:"bytes,2,rep,name=history,proto3" json:"history,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ColorState) Reset() {
+	*x = ColorState{}
+	mi := &file_nsigiipb_nsigii_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ColorState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ColorState) ProtoMessage() {}
+
+func (x *ColorState) ProtoReflect() protoreflect.Message {
+	mi := &file_nsigiipb_nsigii_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ColorState.ProtoReflect.Descriptor instead.
+func (*ColorState) Descriptor() ([]byte, []int) {
+	return file_nsigiipb_nsigii_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ColorState) GetState() ColorChannel {
+	if x != nil {
+		return x.State
+	}
+	return ColorChannel_COLOR_CHANNEL_RED
+}
+
+func (x *ColorState) GetHistory() []*ColorTransition {
+	if x != nil {
+		return x.History
+	}
+	return nil
+}
+
+var File_nsigiipb_nsigii_proto protoreflect.FileDescriptor
+
+const file_nsigiipb_nsigii_proto_rawDesc = "" +
+	"\n" +
+	"\x15nsigiipb/nsigii.proto\x12\tnsigii.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x80\x02\n" +
+	"\x05Token\x12(\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x14.nsigii.v1.TokenTypeR\x04type\x12\x16\n" +
+	"\x06memory\x18\x02 \x01(\rR\x06memory\x12\x14\n" +
+	"\x05value\x18\x03 \x01(\rR\x05value\x12\x12\n" +
+	"\x04text\x18\x04 \x01(\tR\x04text\x12\x12\n" +
+	"\x04line\x18\x05 \x01(\x05R\x04line\x12\x16\n" +
+	"\x06column\x18\x06 \x01(\x05R\x06column\x12\x1d\n" +
+	"\n" +
+	"end_offset\x18\a \x01(\rR\tendOffset\x12\x1f\n" +
+	"\vrune_offset\x18\b \x01(\rR\n" +
+	"runeOffset\x12\x1f\n" +
+	"\vrune_length\x18\t \x01(\rR\n" +
+	"runeLength\"\x95\x04\n" +
+	"\n" +
+	"TokenStats\x12!\n" +
+	"\ftotal_tokens\x18\x01 \x01(\x03R\vtotalTokens\x12X\n" +
+	"\x11type_distribution\x18\x02 \x03(\v2+.nsigii.v1.TokenStats.TypeDistributionEntryR\x10typeDistribution\x12\x1d\n" +
+	"\n" +
+	"memory_min\x18\x03 \x01(\rR\tmemoryMin\x12\x1d\n" +
+	"\n" +
+	"memory_max\x18\x04 \x01(\rR\tmemoryMax\x12%\n" +
+	"\x0eaverage_length\x18\x05 \x01(\x01R\raverageLength\x12?\n" +
+	"\x10length_histogram\x18\x06 \x01(\v2\x14.nsigii.v1.HistogramR\x0flengthHistogram\x12I\n" +
+	"\ftype_lengths\x18\a \x03(\v2&.nsigii.v1.TokenStats.TypeLengthsEntryR\vtypeLengths\x1aC\n" +
+	"\x15TypeDistributionEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\x1aT\n" +
+	"\x10TypeLengthsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12*\n" +
+	"\x05value\x18\x02 \x01(\v2\x14.nsigii.v1.HistogramR\x05value:\x028\x01\"n\n" +
+	"\tHistogram\x12\x16\n" +
+	"\x06bounds\x18\x01 \x03(\rR\x06bounds\x12\x16\n" +
+	"\x06counts\x18\x02 \x03(\x03R\x06counts\x121\n" +
+	"\tquantiles\x18\x03 \x03(\v2\x13.nsigii.v1.QuantileR\tquantiles\"0\n" +
+	"\bQuantile\x12\f\n" +
+	"\x01q\x18\x01 \x01(\x01R\x01q\x12\x16\n" +
+	"\x06length\x18\x02 \x01(\rR\x06length\"\x8e\x02\n" +
+	"\vTokenStream\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\x05R\aversion\x12\x16\n" +
+	"\x06schema\x18\x02 \x01(\tR\x06schema\x12(\n" +
+	"\x06tokens\x18\x03 \x03(\v2\x10.nsigii.v1.TokenR\x06tokens\x12+\n" +
+	"\x05stats\x18\x04 \x01(\v2\x15.nsigii.v1.TokenStatsR\x05stats\x12,\n" +
+	"\x06origin\x18\x05 \x01(\v2\x14.nsigii.v1.PhantomIDR\x06origin\x12\x12\n" +
+	"\x04seal\x18\x06 \x01(\fR\x04seal\x12\x16\n" +
+	"\x06signer\x18\a \x01(\fR\x06signer\x12\x1c\n" +
+	"\tsignature\x18\b \x01(\fR\tsignature\"\x9c\x01\n" +
+	"\tPhantomID\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
+	"\x06schema\x18\x02 \x01(\tR\x06schema\x12\x18\n" +
+	"\asubject\x18\x03 \x01(\tR\asubject\x127\n" +
+	"\tissued_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\bissuedAt\x12\x14\n" +
+	"\x05depth\x18\x05 \x01(\x05R\x05depth\"\xaf\x01\n" +
+	"\x0fColorTransition\x12+\n" +
+	"\x04from\x18\x01 \x01(\x0e2\x17.nsigii.v1.ColorChannelR\x04from\x12'\n" +
+	"\x02to\x18\x02 \x01(\x0e2\x17.nsigii.v1.ColorChannelR\x02to\x12\x16\n" +
+	"\x06reason\x18\x03 \x01(\tR\x06reason\x12.\n" +
+	"\x04time\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\"q\n" +
+	"\n" +
+	"ColorState\x12-\n" +
+	"\x05state\x18\x01 \x01(\x0e2\x17.nsigii.v1.ColorChannelR\x05state\x124\n" +
+	"\ahistory\x18\x02 \x03(\v2\x1a.nsigii.v1.ColorTransitionR\ahistory*\xe1\x01\n" +
+	"\tTokenType\x12\x12\n" +
+	"\x0eTOKEN_TYPE_EOF\x10\x00\x12\x19\n" +
+	"\x15TOKEN_TYPE_IDENTIFIER\x10\x01\x12\x16\n" +
+	"\x12TOKEN_TYPE_KEYWORD\x10\x02\x12\x15\n" +
+	"\x11TOKEN_TYPE_NUMBER\x10\x03\x12\x17\n" +
+	"\x13TOKEN_TYPE_OPERATOR\x10\x04\x12\x18\n" +
+	"\x14TOKEN_TYPE_DELIMITER\x10\x05\x12\x15\n" +
+	"\x11TOKEN_TYPE_STRING\x10\x06\x12\x16\n" +
+	"\x12TOKEN_TYPE_COMMENT\x10\a\x12\x14\n" +
+	"\x10TOKEN_TYPE_ERROR\x10\b*\xd8\x01\n" +
+	"\fColorChannel\x12\x15\n" +
+	"\x11COLOR_CHANNEL_RED\x10\x00\x12\x17\n" +
+	"\x13COLOR_CHANNEL_GREEN\x10\x01\x12\x16\n" +
+	"\x12COLOR_CHANNEL_BLUE\x10\x02\x12\x16\n" +
+	"\x12COLOR_CHANNEL_CYAN\x10\x03\x12\x18\n" +
+	"\x14COLOR_CHANNEL_YELLOW\x10\x04\x12\x19\n" +
+	"\x15COLOR_CHANNEL_MAGENTA\x10\x05\x12\x17\n" +
+	"\x13COLOR_CHANNEL_BLACK\x10\x06\x12\x1a\n" +
+	"\x16COLOR_CHANNEL_CONTRAST\x10\aB1Z/github.com/obinexus/nsigii-rift/nsigii/nsigiipbb\x06proto3"
+
+var (
+	file_nsigiipb_nsigii_proto_rawDescOnce sync.Once
+	file_nsigiipb_nsigii_proto_rawDescData []byte
+)
+
+func file_nsigiipb_nsigii_proto_rawDescGZIP() []byte {
+	file_nsigiipb_nsigii_proto_rawDescOnce.Do(func() {
+		file_nsigiipb_nsigii_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_nsigiipb_nsigii_proto_rawDesc), len(file_nsigiipb_nsigii_proto_rawDesc)))
+	})
+	return file_nsigiipb_nsigii_proto_rawDescData
+}
+
+var file_nsigiipb_nsigii_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_nsigiipb_nsigii_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_nsigiipb_nsigii_proto_goTypes = []any{
+	(TokenType)(0),                // 0: nsigii.v1.TokenType
+	(ColorChannel)(0),             // 1: nsigii.v1.ColorChannel
+	(*Token)(nil),                 // 2: nsigii.v1.Token
+	(*TokenStats)(nil),            // 3: nsigii.v1.TokenStats
+	(*Histogram)(nil),             // 4: nsigii.v1.Histogram
+	(*Quantile)(nil),              // 5: nsigii.v1.Quantile
+	(*TokenStream)(nil),           // 6: nsigii.v1.TokenStream
+	(*PhantomID)(nil),             // 7: nsigii.v1.PhantomID
+	(*ColorTransition)(nil),       // 8: nsigii.v1.ColorTransition
+	(*ColorState)(nil),            // 9: nsigii.v1.ColorState
+	nil,                           // 10: nsigii.v1.TokenStats.TypeDistributionEntry
+	nil,                           // 11: nsigii.v1.TokenStats.TypeLengthsEntry
+	(*timestamppb.Timestamp)(nil), // 12: google.protobuf.Timestamp
+}
+var file_nsigiipb_nsigii_proto_depIdxs = []int32{
+	0,  // 0: nsigii.v1.Token.type:type_name -> nsigii.v1.TokenType
+	10, // 1: nsigii.v1.TokenStats.type_distribution:type_name -> nsigii.v1.TokenStats.TypeDistributionEntry
+	4,  // 2: nsigii.v1.TokenStats.length_histogram:type_name -> nsigii.v1.Histogram
+	11, // 3: nsigii.v1.TokenStats.type_lengths:type_name -> nsigii.v1.TokenStats.TypeLengthsEntry
+	5,  // 4: nsigii.v1.Histogram.quantiles:type_name -> nsigii.v1.Quantile
+	2,  // 5: nsigii.v1.TokenStream.tokens:type_name -> nsigii.v1.Token
+	3,  // 6: nsigii.v1.TokenStream.stats:type_name -> nsigii.v1.TokenStats
+	7,  // 7: nsigii.v1.TokenStream.origin:type_name -> nsigii.v1.PhantomID
+	12, // 8: nsigii.v1.PhantomID.issued_at:type_name -> google.protobuf.Timestamp
+	1,  // 9: nsigii.v1.ColorTransition.from:type_name -> nsigii.v1.ColorChannel
+	1,  // 10: nsigii.v1.ColorTransition.to:type_name -> nsigii.v1.ColorChannel
+	12, // 11: nsigii.v1.ColorTransition.time:type_name -> google.protobuf.Timestamp
+	1,  // 12: nsigii.v1.ColorState.state:type_name -> nsigii.v1.ColorChannel
+	8,  // 13: nsigii.v1.ColorState.history:type_name -> nsigii.v1.ColorTransition
+	4,  // 14: nsigii.v1.TokenStats.TypeLengthsEntry.value:type_name -> nsigii.v1.Histogram
+	15, // [15:15] is the sub-list for method output_type
+	15, // [15:15] is the sub-list for method input_type
+	15, // [15:15] is the sub-list for extension type_name
+	15, // [15:15] is the sub-list for extension extendee
+	0,  // [0:15] is the sub-list for field type_name
+}
+
+func init() { file_nsigiipb_nsigii_proto_init() }
+func file_nsigiipb_nsigii_proto_init() {
+	if File_nsigiipb_nsigii_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_nsigiipb_nsigii_proto_rawDesc), len(file_nsigiipb_nsigii_proto_rawDesc)),
+			NumEnums:      2,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_nsigiipb_nsigii_proto_goTypes,
+		DependencyIndexes: file_nsigiipb_nsigii_proto_depIdxs,
+		EnumInfos:         file_nsigiipb_nsigii_proto_enumTypes,
+		MessageInfos:      file_nsigiipb_nsigii_proto_msgTypes,
+	}.Build()
+	File_nsigiipb_nsigii_proto = out.File
+	file_nsigiipb_nsigii_proto_goTypes = nil
+	file_nsigiipb_nsigii_proto_depIdxs = nil
+}
@@ -0,0 +1,57 @@
+package nsigii
+
+// ============================================================================
+// Operator and Delimiter Tables
+// ============================================================================
+
+// DefaultOperators returns the multi-byte operators built into the RIFT
+// lexer. Bytes that start no other token are always one-byte operators.
+func DefaultOperators() []string {
+	return append([]string(nil), defaultOperators...)
+}
+
+// DefaultDelimiters returns the delimiters built into the RIFT lexer
+func DefaultDelimiters() []string {
+	return append([]string(nil), defaultDelimiters...)
+}
+
+// SetOperators replaces the sequences scanned as a single TokenOperator.
+// Entries may be any length; the longest match wins. A nil slice restores
+// the built-in table.
+//
+// The native lexer's tables are fixed, so a context with custom operator or
+// delimiter tables (or a language profile) tokenizes with the Go lexer.
+func (c *Context) SetOperators(operators []string) {
+	c.operators = operators
+	c.rebuildLexer()
+}
+
+// SetDelimiters replaces the sequences classified as TokenDelimiter.
+// Entries may be any length; the longest match wins, and delimiters take
+// precedence over operators. A nil slice restores the built-in table.
+func (c *Context) SetDelimiters(delimiters []string) {
+	c.delimiters = delimiters
+	c.rebuildLexer()
+}
+
+// rebuildLexer switches the context to a Go lexer built from its profile
+// and custom tables, or back to the native lexer if none are set
+func (c *Context) rebuildLexer() {
+	if c.profile == nil && c.operators == nil && c.delimiters == nil {
+		c.lexer = nil
+		return
+	}
+
+	p := RIFTProfile
+	if c.profile != nil {
+		p = *c.profile
+	}
+	if c.operators != nil {
+		p.Operators = c.operators
+	}
+	if c.delimiters != nil {
+		p.Delimiters = c.delimiters
+	}
+
+	c.lexer = newLexer(p)
+}
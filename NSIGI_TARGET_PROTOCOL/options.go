@@ -0,0 +1,57 @@
+package nsigii
+
+import "runtime"
+
+// ============================================================================
+// Context Options
+// ============================================================================
+
+// Option configures a context created by NewContext. Options are plain
+// functions over the context, so callers may write their own alongside the
+// ones below.
+type Option func(c *Context) error
+
+// WithMaxTokens sets the per-call token limit; see SetMaxTokens
+func WithMaxTokens(limit int) Option {
+	return func(c *Context) error {
+		c.SetMaxTokens(limit)
+		return nil
+	}
+}
+
+// WithProfile sets the language profile; see SetProfile
+func WithProfile(profile LanguageProfile) Option {
+	return func(c *Context) error {
+		return c.SetProfile(profile)
+	}
+}
+
+// WithNoise starts AUX noise with profile once the context is created; see
+// AuxStart
+func WithNoise(profile NoiseProfile) Option {
+	return func(c *Context) error {
+		return c.AuxStart(profile)
+	}
+}
+
+// WithConsensusPolicy sets the RGB consensus rule; see SetConsensusPolicy
+func WithConsensusPolicy(p ConsensusPolicy) Option {
+	return func(c *Context) error {
+		return c.SetConsensusPolicy(p)
+	}
+}
+
+// WithFinalizer controls whether the garbage collector closes the context
+// if it becomes unreachable without Close being called. It is enabled by
+// default; disabling it suits callers that manage contexts strictly and
+// want leaks to show up rather than be cleaned up silently.
+func WithFinalizer(enabled bool) Option {
+	return func(c *Context) error {
+		if enabled {
+			runtime.SetFinalizer(c, (*Context).Close)
+		} else {
+			runtime.SetFinalizer(c, nil)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,121 @@
+package nsigii
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+)
+
+// ============================================================================
+// Stream Origin
+// ============================================================================
+
+// SetStampOrigin controls whether TokenizeStream stamps the streams it
+// produces with the context's phantom ID
+func (c *Context) SetStampOrigin(on bool) error {
+	if err := c.require(RoleAdmin, "SetStampOrigin"); err != nil {
+		return err
+	}
+	c.stampOrigin = on
+	return nil
+}
+
+// TokenizeStream tokenizes source into a TokenStream carrying the context's
+// schema and, if origin stamping is enabled, its phantom ID
+//
+// Example:
+//   ctx.SetStampOrigin(true)
+//   stream, err := ctx.TokenizeStream(source)
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   json.NewEncoder(w).Encode(stream)
+func (c *Context) TokenizeStream(source string) (*TokenStream, error) {
+	tokens, err := c.Tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := c.Schema()
+	if err != nil {
+		return nil, err
+	}
+
+	s := NewTokenStream(schema, tokens)
+	if c.stampOrigin {
+		if err := c.Stamp(s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Stamp sets s's origin header to the context's current phantom ID and seals
+// it to the stream's schema and tokens. Modifying the tokens afterwards
+// invalidates the seal.
+func (c *Context) Stamp(s *TokenStream) error {
+	if err := c.require(RoleOperator, "Stamp"); err != nil {
+		return err
+	}
+	id, err := c.PhantomID()
+	if err != nil {
+		return err
+	}
+
+	key, err := c.originKey(id)
+	if err != nil {
+		return err
+	}
+	s.Origin = &id
+	s.Seal = streamSeal(key, s)
+	return nil
+}
+
+// VerifyStreamOrigin checks that s was stamped by a context that ctx trusts
+// (see Context.VerifyPhantomID), that the stamp names the stream's schema,
+// and that the tokens have not changed since. Streams without an origin
+// header are an error.
+func VerifyStreamOrigin(ctx *Context, s *TokenStream) (bool, error) {
+	if s.Origin == nil {
+		return false, errors.New("token stream has no origin")
+	}
+
+	ok, err := ctx.VerifyPhantomID(*s.Origin)
+	if err != nil || !ok {
+		return false, err
+	}
+	if s.Origin.Schema() != s.Schema {
+		return false, nil
+	}
+
+	key, err := ctx.originKey(*s.Origin)
+	if err != nil {
+		return false, nil
+	}
+	seal := streamSeal(key, s)
+	return hmac.Equal(seal, s.Seal), nil
+}
+
+// streamSeal computes the MAC over s's origin, schema and tokens
+func streamSeal(key []byte, s *TokenStream) []byte {
+	h := hmac.New(sha256.New, key)
+
+	var buf []byte
+	field := func(v string) {
+		buf = binary.BigEndian.AppendUint32(buf[:0], uint32(len(v)))
+		h.Write(buf)
+		h.Write([]byte(v))
+	}
+
+	field(s.Origin.String())
+	field(s.Schema)
+	for _, tok := range s.Tokens {
+		buf = binary.BigEndian.AppendUint32(buf[:0], uint32(tok.Type))
+		buf = binary.BigEndian.AppendUint32(buf, tok.Memory)
+		buf = binary.BigEndian.AppendUint32(buf, tok.Value)
+		h.Write(buf)
+		field(tok.Lexeme())
+	}
+
+	return h.Sum(nil)
+}
@@ -0,0 +1,155 @@
+package nsigii
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ============================================================================
+// Parallel Tokenization
+// ============================================================================
+
+// parallelMinChunk is the smallest chunk worth handing to its own worker
+const parallelMinChunk = 64 * 1024
+
+// TokenizeParallel tokenizes source on up to workers goroutines, each with
+// its own native context, and stitches the results into one stream
+//
+// The source is split on line boundaries and Memory offsets and lines are
+// corrected while stitching. A chunk that ends inside a token or lexer mode
+// (a block comment, string or interpolation spanning the split) is
+// re-tokenized together with the following chunk, so the result matches
+// Tokenize. Small inputs are tokenized on c directly.
+func (c *Context) TokenizeParallel(source string, workers int) ([]Token, error) {
+	if err := c.usable(); err != nil {
+		return nil, err
+	}
+
+	if max := len(source) / parallelMinChunk; workers > max {
+		workers = max
+	}
+	if workers <= 1 {
+		return c.Tokenize(source)
+	}
+
+	bounds := splitLines(source, workers)
+	chunks := len(bounds) - 1
+	results := make([][]Token, chunks)
+	errs := make([]error, chunks)
+
+	var wg sync.WaitGroup
+	for i := 0; i < chunks; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			worker, err := c.fork()
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer worker.Close()
+
+			results[i], errs[i] = worker.tokenize(source[bounds[i]:bounds[i+1]])
+		}(i)
+	}
+	wg.Wait()
+
+	tokens := make([]Token, 0, len(source)/4)
+	line, runes := 0, 0
+	src := &source // for lazy tokens
+
+	for i := 0; i < chunks; i++ {
+		start, end := bounds[i], bounds[i+1]
+		chunkTokens, err := results[i], errs[i]
+
+		// Merge forward while the chunk ends inside a token or mode
+		for err == nil && end < len(source) && (endsInsideToken(chunkTokens, end-start) || c.endsInsideMode(chunkTokens, end-start)) {
+			i++
+			end = bounds[i+1]
+			chunkTokens, err = c.tokenize(source[start:end])
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range chunkTokens {
+			if t.Type == TokenEOF {
+				continue
+			}
+			t.Memory += uint32(start)
+			t.EndOffset += uint32(start)
+			t.rebase(src)
+			if t.Line > 0 {
+				t.Line += line
+			}
+			if c.runes {
+				t.RuneOffset += uint32(runes)
+			}
+			tokens = append(tokens, t)
+		}
+
+		line += strings.Count(source[start:end], "\n")
+		if c.runes {
+			runes += utf8.RuneCountInString(source[start:end])
+		}
+	}
+
+	eof := Token{Type: TokenEOF, Memory: uint32(len(source)), EndOffset: uint32(len(source)), Text: "<EOF>"}
+	if !c.noPos {
+		eof.Line = line + 1
+		eof.Column = len(source) - lineStart(source, len(source)) + 1
+	}
+	if c.runes {
+		eof.RuneOffset = uint32(runes)
+	}
+	tokens = append(tokens, eof)
+
+	if c.maxTokens > 0 && len(tokens) > c.maxTokens {
+		return nil, fmt.Errorf("%w: %d", ErrTokenBufferFull, c.maxTokens)
+	}
+
+	return tokens, nil
+}
+
+// splitLines returns chunk boundaries dividing source into roughly n pieces
+// ending on line boundaries. The first boundary is 0 and the last
+// len(source).
+func splitLines(source string, n int) []int {
+	bounds := []int{0}
+	size := len(source) / n
+
+	for target := size; target < len(source); target += size {
+		end := lineEnd(source, target)
+		if end <= bounds[len(bounds)-1] {
+			continue
+		}
+		if end >= len(source) {
+			break
+		}
+		bounds = append(bounds, end)
+		target = end
+	}
+
+	return append(bounds, len(source))
+}
+
+// endsInsideMode reports whether a chunk leaves a lexer mode open at its
+// end, so the next chunk would be scanned in the wrong mode
+func (c *Context) endsInsideMode(tokens []Token, chunkLen int) bool {
+	_, open := c.openMode(tokens, chunkLen)
+	return open
+}
+
+// endsInsideToken reports whether the last token of a chunk runs up to the
+// chunk's end, swallowing the newline the chunk was split after
+func endsInsideToken(tokens []Token, chunkLen int) bool {
+	for i := len(tokens) - 1; i >= 0; i-- {
+		if tokens[i].Type != TokenEOF {
+			return int(tokens[i].EndOffset) >= chunkLen
+		}
+	}
+	return false
+}
@@ -0,0 +1,502 @@
+package nsigii
+
+import "fmt"
+
+// ============================================================================
+// Parser (RIFT Stage 222)
+// ============================================================================
+
+// SyntaxError reports where and why a token stream failed to parse
+type SyntaxError struct {
+	Diagnostic
+}
+
+func (e *SyntaxError) Error() string {
+	return e.Diagnostic.String()
+}
+
+// Parse tokenizes source and parses it into a syntax tree
+//
+// The C library exposes no parse entry point, so parsing always runs in Go
+// over the context's token triplets; keywords are those of the context's
+// keyword set. A context with a grammar (see SetGrammar) parses its
+// language instead of RIFT.
+//
+// Example:
+//   prog, err := ctx.Parse("let result = (x + y) * 42;")
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   decl := prog.Stmts[0].(*nsigii.LetStmt)
+func (c *Context) Parse(source string) (*Program, error) {
+	tokens, err := c.Tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+	return c.parseTokens(tokens)
+}
+
+// parseTokens parses tokens with the context's grammar, or as RIFT if it
+// has none
+func (c *Context) parseTokens(tokens []Token) (*Program, error) {
+	if c.grammar != nil {
+		return c.grammar.Parse(tokens)
+	}
+	return ParseTokens(tokens)
+}
+
+// ParseTokens parses a token stream into a syntax tree. Comments are
+// skipped; the stream must end with an EOF token. A failure is returned as
+// a *SyntaxError.
+func ParseTokens(tokens []Token) (*Program, error) {
+	p := &parser{tokens: tokens, pos: -1}
+	return p.program(p.stmt)
+}
+
+// runParseStage implements StageParse, tokenizing first if no earlier stage
+// did
+func runParseStage(c *Context, u *Unit) error {
+	if u.Tokens == nil {
+		if err := runTokenizeStage(c, u); err != nil {
+			return err
+		}
+	}
+
+	prog, err := c.parseTokens(u.Tokens)
+	if err != nil {
+		return err
+	}
+	u.AST = prog
+	return nil
+}
+
+// parser is a recursive-descent parser over a token stream. Errors unwind
+// the descent by panicking with a *SyntaxError, recovered in ParseTokens.
+type parser struct {
+	tokens []Token
+	pos    int
+	tok    Token // current token
+	last   Token // previous token, the last one consumed
+}
+
+// program parses statements with stmt up to the end of the stream
+func (p *parser) program(stmt func() Stmt) (prog *Program, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*SyntaxError)
+			if !ok {
+				panic(r)
+			}
+			prog, err = nil, e
+		}
+	}()
+
+	p.next()
+	prog = &Program{}
+	for p.tok.Type != TokenEOF {
+		prog.Stmts = append(prog.Stmts, stmt())
+	}
+	prog.EOF = p.tok
+
+	return prog, nil
+}
+
+// next advances to the next token that is not a comment
+func (p *parser) next() {
+	p.last = p.tok
+	for {
+		p.pos++
+		if p.pos >= len(p.tokens) {
+			// Streams without a trailing EOF end at the last token
+			end := uint32(0)
+			if len(p.tokens) > 0 {
+				end = p.tokens[len(p.tokens)-1].EndOffset
+			}
+			p.tok = Token{Type: TokenEOF, Memory: end, EndOffset: end, Text: "<EOF>"}
+			p.pos = len(p.tokens)
+			return
+		}
+		p.tok = p.tokens[p.pos]
+		if p.tok.Type != TokenComment {
+			return
+		}
+	}
+}
+
+// fail aborts parsing with a syntax error at t
+func (p *parser) fail(t Token, format string, args ...any) {
+	p.failFix(t, nil, format, args...)
+}
+
+// failFix aborts parsing with a syntax error at t offering fixes
+func (p *parser) failFix(t Token, fixes []Fix, format string, args ...any) {
+	panic(&SyntaxError{Diagnostic{
+		Offset:  t.Memory,
+		Length:  t.Value,
+		Line:    t.Line,
+		Column:  t.Column,
+		Message: fmt.Sprintf(format, args...),
+		Fixes:   fixes,
+	}})
+}
+
+// unexpected aborts parsing at the current token, which is not what
+// was expected
+func (p *parser) unexpected(expected string, fixes ...Fix) {
+	if p.tok.Type == TokenEOF {
+		p.failFix(p.tok, fixes, "expected %s, found end of input", expected)
+	}
+	p.failFix(p.tok, fixes, "expected %s, found %s %q", expected, p.tok.Type, p.tok.Lexeme())
+}
+
+// insertable lists the tokens a missing-token error offers to insert
+var insertable = map[string]bool{";": true, ")": true, "]": true, "}": true}
+
+// insertFix suggests inserting a missing text after the last token
+// consumed, if it is a terminator or closer
+func (p *parser) insertFix(text string) []Fix {
+	if !insertable[text] {
+		return nil
+	}
+	return []Fix{{
+		Message: fmt.Sprintf("insert %q", text),
+		Edits:   []Edit{{Offset: int(p.last.EndOffset), Inserted: text}},
+	}}
+}
+
+// is reports whether the current token is a delimiter, operator or keyword
+// with the given text
+func (p *parser) is(text string) bool {
+	switch p.tok.Type {
+	case TokenDelimiter, TokenOperator, TokenKeyword:
+		return p.tok.Lexeme() == text
+	}
+	return false
+}
+
+// expect consumes and returns the current token if it has the given text
+func (p *parser) expect(text string) Token {
+	if !p.is(text) {
+		p.unexpected(fmt.Sprintf("%q", text), p.insertFix(text)...)
+	}
+	t := p.tok
+	p.next()
+	return t
+}
+
+// ident consumes an identifier
+func (p *parser) ident() *Ident {
+	if p.tok.Type != TokenIdentifier {
+		p.unexpected("identifier")
+	}
+	id := &Ident{Token: p.tok}
+	p.next()
+	return id
+}
+
+// ============================================================================
+// Statements
+// ============================================================================
+
+func (p *parser) stmt() Stmt {
+	if p.tok.Type == TokenKeyword {
+		switch p.tok.Lexeme() {
+		case "let", "const", "var":
+			s := p.letStmt()
+			s.Semi = p.expect(";")
+			return s
+		case "func", "fn":
+			return p.funcDecl()
+		case "return":
+			return p.returnStmt()
+		case "if":
+			return p.ifStmt()
+		case "while":
+			return p.whileStmt()
+		case "for":
+			return p.forStmt()
+		case "break", "continue":
+			kw := p.tok
+			p.next()
+			return &BranchStmt{Keyword: kw, Semi: p.expect(";")}
+		case "import":
+			return p.importStmt()
+		}
+	}
+
+	// "function" is not a keyword, but introduces a declaration when
+	// followed by a name
+	if p.tok.Type == TokenIdentifier && p.tok.Lexeme() == "function" &&
+		p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].Type == TokenIdentifier {
+		return p.funcDecl()
+	}
+
+	if p.is("{") {
+		return p.block()
+	}
+
+	s := &ExprStmt{X: p.expr()}
+	s.Semi = p.expect(";")
+	return s
+}
+
+func (p *parser) block() *Block {
+	b := &Block{Lbrace: p.expect("{")}
+	for !p.is("}") {
+		if p.tok.Type == TokenEOF {
+			p.unexpected(`"}"`)
+		}
+		b.Stmts = append(b.Stmts, p.stmt())
+	}
+	b.Rbrace = p.expect("}")
+	return b
+}
+
+// letStmt parses a declaration up to, but not including, its semicolon
+func (p *parser) letStmt() *LetStmt {
+	s := &LetStmt{Keyword: p.tok}
+	p.next()
+	s.Name = p.ident()
+	if p.is("=") || p.is(":=") {
+		p.next()
+		s.Value = p.expr()
+	}
+	return s
+}
+
+func (p *parser) funcDecl() *FuncDecl {
+	d := &FuncDecl{Keyword: p.tok}
+	p.next()
+	d.Name = p.ident()
+
+	p.expect("(")
+	for !p.is(")") {
+		if len(d.Params) > 0 {
+			p.expect(",")
+		}
+		d.Params = append(d.Params, p.ident())
+	}
+	p.next()
+
+	d.Body = p.block()
+	return d
+}
+
+func (p *parser) returnStmt() *ReturnStmt {
+	s := &ReturnStmt{Keyword: p.tok}
+	p.next()
+	if !p.is(";") {
+		s.Value = p.expr()
+	}
+	s.Semi = p.expect(";")
+	return s
+}
+
+func (p *parser) ifStmt() *IfStmt {
+	s := &IfStmt{Keyword: p.tok}
+	p.next()
+	s.Cond = p.expr()
+	s.Then = p.block()
+
+	if p.is("else") {
+		p.next()
+		if p.is("if") {
+			s.Else = p.ifStmt()
+		} else {
+			s.Else = p.block()
+		}
+	}
+	return s
+}
+
+func (p *parser) whileStmt() *WhileStmt {
+	s := &WhileStmt{Keyword: p.tok}
+	p.next()
+	s.Cond = p.expr()
+	s.Body = p.block()
+	return s
+}
+
+// forStmt parses for (init; cond; post) { ... }
+func (p *parser) forStmt() *ForStmt {
+	s := &ForStmt{Keyword: p.tok}
+	p.next()
+	p.expect("(")
+
+	if !p.is(";") {
+		if p.is("let") || p.is("const") || p.is("var") {
+			s.Init = p.letStmt()
+		} else {
+			s.Init = &ExprStmt{X: p.expr()}
+		}
+	}
+	semi := p.expect(";")
+	switch init := s.Init.(type) {
+	case *LetStmt:
+		init.Semi = semi
+	case *ExprStmt:
+		init.Semi = semi
+	}
+
+	if !p.is(";") {
+		s.Cond = p.expr()
+	}
+	p.expect(";")
+
+	if !p.is(")") {
+		s.Post = &ExprStmt{X: p.expr()}
+	}
+	p.expect(")")
+
+	s.Body = p.block()
+	return s
+}
+
+func (p *parser) importStmt() *ImportStmt {
+	s := &ImportStmt{Keyword: p.tok}
+	p.next()
+	if p.tok.Type != TokenString {
+		p.unexpected("import path")
+	}
+	s.Path = &Literal{Token: p.tok}
+	p.next()
+	s.Semi = p.expect(";")
+	return s
+}
+
+// ============================================================================
+// Expressions
+// ============================================================================
+
+// binaryPrecedence gives the binding power of each binary operator; higher
+// binds tighter
+var binaryPrecedence = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3, "<": 3, "<=": 3, ">": 3, ">=": 3,
+	"+": 4, "-": 4, "|": 4, "^": 4,
+	"*": 5, "/": 5, "%": 5, "<<": 5, ">>": 5, "&": 5,
+}
+
+// assignOps lists the assignment operators, which are right-associative and
+// bind loosest of all
+var assignOps = map[string]bool{
+	"=": true, ":=": true, "+=": true, "-=": true, "*=": true, "/=": true,
+	"%=": true, "&=": true, "|=": true, "^=": true, "<<=": true, ">>=": true,
+}
+
+func (p *parser) expr() Expr {
+	x := p.binaryExpr(1)
+	if p.tok.Type == TokenOperator && assignOps[p.tok.Lexeme()] {
+		op := p.tok
+		p.next()
+		return &AssignExpr{X: x, Op: op, Y: p.expr()}
+	}
+	return x
+}
+
+// binaryExpr parses a chain of binary operators binding at least as tightly
+// as prec
+func (p *parser) binaryExpr(prec int) Expr {
+	x := p.unaryExpr()
+	for {
+		if p.tok.Type != TokenOperator {
+			return x
+		}
+		opPrec, ok := binaryPrecedence[p.tok.Lexeme()]
+		if !ok || opPrec < prec {
+			return x
+		}
+		op := p.tok
+		p.next()
+		x = &BinaryExpr{X: x, Op: op, Y: p.binaryExpr(opPrec + 1)}
+	}
+}
+
+func (p *parser) unaryExpr() Expr {
+	if p.tok.Type == TokenOperator {
+		switch p.tok.Lexeme() {
+		case "-", "+", "!", "~", "++", "--":
+			op := p.tok
+			p.next()
+			return &UnaryExpr{Op: op, X: p.unaryExpr()}
+		}
+	}
+	return p.postfixExpr(p.primaryExpr())
+}
+
+func (p *parser) postfixExpr(x Expr) Expr {
+	for {
+		switch {
+		case p.is("("):
+			call := &CallExpr{Fun: x, Lparen: p.tok}
+			p.next()
+			call.Args = p.exprList(")")
+			call.Rparen = p.expect(")")
+			x = call
+		case p.is("["):
+			ix := &IndexExpr{X: x, Lbrack: p.tok}
+			p.next()
+			ix.Index = p.expr()
+			ix.Rbrack = p.expect("]")
+			x = ix
+		case p.is("."):
+			p.next()
+			x = &SelectorExpr{X: x, Sel: p.ident()}
+		case p.is("++"), p.is("--"):
+			x = &UnaryExpr{Op: p.tok, X: x, Postfix: true}
+			p.next()
+		default:
+			return x
+		}
+	}
+}
+
+func (p *parser) primaryExpr() Expr {
+	switch p.tok.Type {
+	case TokenIdentifier:
+		return p.ident()
+	case TokenNumber, TokenString:
+		lit := &Literal{Token: p.tok}
+		p.next()
+		return lit
+	case TokenKeyword:
+		switch p.tok.Lexeme() {
+		case "true", "false", "null":
+			lit := &Literal{Token: p.tok}
+			p.next()
+			return lit
+		}
+	case TokenDelimiter:
+		switch p.tok.Lexeme() {
+		case "(":
+			paren := &ParenExpr{Lparen: p.tok}
+			p.next()
+			paren.X = p.expr()
+			paren.Rparen = p.expect(")")
+			return paren
+		case "[":
+			list := &ListExpr{Lbrack: p.tok}
+			p.next()
+			list.Elems = p.exprList("]")
+			list.Rbrack = p.expect("]")
+			return list
+		}
+	}
+
+	p.unexpected("expression")
+	return nil
+}
+
+// exprList parses comma-separated expressions up to, but not including,
+// the closing delimiter
+func (p *parser) exprList(closing string) []Expr {
+	var list []Expr
+	for !p.is(closing) {
+		if len(list) > 0 {
+			p.expect(",")
+		}
+		list = append(list, p.expr())
+	}
+	return list
+}
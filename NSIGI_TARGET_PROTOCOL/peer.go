@@ -0,0 +1,145 @@
+package nsigii
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ============================================================================
+// TLS Peer Binding
+// ============================================================================
+
+// ErrPeerUnauthorized reports a network peer whose TLS client certificate
+// is missing, unbound, or bound to an identity that may not use the
+// requested schema
+var ErrPeerUnauthorized = errors.New("peer is not authorized")
+
+// PeerBinding binds the identity in a TLS client certificate to the phantom
+// ID the peer acts as and the schemas it may request
+type PeerBinding struct {
+	Identity     string    // URI SAN (e.g. spiffe://...), DNS SAN or subject common name
+	PhantomID    PhantomID // Identity the peer acts as within the framework
+	SchemaPrefix string    // Requested schemas must lie under this, e.g. "obinexus.tokenize"; "" allows any
+}
+
+// PeerRegistry holds the peer bindings a network service enforces. The
+// transport verifies the certificate chain; the registry decides what a
+// verified certificate may do, so a valid certificate alone grants nothing.
+//
+// Example:
+//   peers := nsigii.NewPeerRegistry()
+//   id, _ := nsigii.GeneratePhantomID(ctx, "billing")
+//   peers.Bind(nsigii.PeerBinding{
+//       Identity:     "spiffe://example.org/billing",
+//       PhantomID:    id,
+//       SchemaPrefix: "obinexus.tokenize",
+//   })
+//   // Serve with tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ...}
+//   // and Options{Peers: peers} in httpapi or nsigiigrpc
+type PeerRegistry struct {
+	mu    sync.RWMutex
+	peers map[string]PeerBinding
+}
+
+// NewPeerRegistry creates an empty registry, which authorizes no one
+func NewPeerRegistry() *PeerRegistry {
+	return &PeerRegistry{peers: make(map[string]PeerBinding)}
+}
+
+// Bind adds or replaces the binding for b.Identity. The phantom ID's own
+// schema must lie under the binding's prefix.
+func (r *PeerRegistry) Bind(b PeerBinding) error {
+	if b.Identity == "" {
+		return errors.New("peer binding has no identity")
+	}
+	if b.PhantomID.IsZero() {
+		return errors.New("peer binding has no phantom ID")
+	}
+	if !schemaUnder(b.PhantomID.Schema(), b.SchemaPrefix) {
+		return fmt.Errorf("phantom ID schema %s is not under %s", b.PhantomID.Schema(), b.SchemaPrefix)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.peers[b.Identity] = b
+	return nil
+}
+
+// Unbind removes the binding for identity, if any
+func (r *PeerRegistry) Unbind(identity string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.peers, identity)
+}
+
+// Lookup returns the binding for identity
+func (r *PeerRegistry) Lookup(identity string) (PeerBinding, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	b, ok := r.peers[identity]
+	return b, ok
+}
+
+// Authorize checks that the peer of a TLS connection may use c: the
+// connection must carry a verified client certificate whose identity is
+// bound, c's schema must lie under the binding's prefix, and c must accept
+// the bound phantom ID (see Context.VerifyPhantomID). It returns the
+// binding, or an error wrapping ErrPeerUnauthorized.
+func (r *PeerRegistry) Authorize(c *Context, state *tls.ConnectionState) (PeerBinding, error) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return PeerBinding{}, fmt.Errorf("%w: no verified client certificate", ErrPeerUnauthorized)
+	}
+
+	var (
+		b  PeerBinding
+		ok bool
+	)
+	identities := PeerIdentities(state.VerifiedChains[0][0])
+	for _, identity := range identities {
+		if b, ok = r.Lookup(identity); ok {
+			break
+		}
+	}
+	if !ok {
+		return PeerBinding{}, fmt.Errorf("%w: certificate identities %q are not bound", ErrPeerUnauthorized, identities)
+	}
+
+	schema, err := c.Schema()
+	if err != nil {
+		return PeerBinding{}, err
+	}
+	if !schemaUnder(schema, b.SchemaPrefix) {
+		return PeerBinding{}, fmt.Errorf("%w: %s may not use %s", ErrPeerUnauthorized, b.Identity, schema)
+	}
+	if ok, err := c.VerifyPhantomID(b.PhantomID); err != nil {
+		return PeerBinding{}, err
+	} else if !ok {
+		return PeerBinding{}, fmt.Errorf("%w: phantom ID bound to %s is not accepted by %s", ErrPeerUnauthorized, b.Identity, schema)
+	}
+	return b, nil
+}
+
+// PeerIdentities returns the identities of a certificate in the order
+// Authorize tries them: URI SANs, DNS SANs, then the subject common name
+func PeerIdentities(cert *x509.Certificate) []string {
+	var ids []string
+	for _, u := range cert.URIs {
+		ids = append(ids, u.String())
+	}
+	ids = append(ids, cert.DNSNames...)
+	if cert.Subject.CommonName != "" {
+		ids = append(ids, cert.Subject.CommonName)
+	}
+	return ids
+}
+
+// schemaUnder reports whether schema is prefix or lies below it, comparing
+// whole dot-separated components. Every schema lies under "".
+func schemaUnder(schema, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, ".")
+	return prefix == "" || schema == prefix || strings.HasPrefix(schema, prefix+".")
+}
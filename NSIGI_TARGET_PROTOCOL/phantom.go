@@ -0,0 +1,490 @@
+package nsigii
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Phantom IDs
+// ============================================================================
+
+// phantomPrefix starts the string form of every phantom ID
+const phantomPrefix = "phantom."
+
+const (
+	phantomVersion   = 1
+	phantomVersionKG = 2 // Version 1 plus the ID of the key generation that minted it
+	phantomNonceSize = 16
+	phantomMaxField  = 1<<16 - 1
+	phantomMaxDepth  = 8 // Deepest chain of derived IDs
+)
+
+// PhantomID is an opaque identifier minted by a Context for a subject. It is
+// bound to the minting context's schema and authenticated with the context's
+// phantom key, so it can be checked but not forged or re-bound.
+//
+// The zero PhantomID is invalid.
+type PhantomID struct {
+	schema string
+	keyID  KeyID            // key ring generation that minted the ID, "" for the phantom key
+	path   []phantomSegment // the minted segment, then one per derivation
+	mac    [sha256.Size]byte
+}
+
+// phantomSegment is one level of a phantom ID: the subject minted by a
+// context, or a child segment added by DerivePhantomID
+type phantomSegment struct {
+	subject string
+	nonce   [phantomNonceSize]byte
+	issued  int64 // Unix nanoseconds
+}
+
+var (
+	processKeyOnce sync.Once
+	processKey     []byte
+)
+
+// defaultPhantomKey returns the random key shared by contexts in this process
+// that have no key of their own
+func defaultPhantomKey() []byte {
+	processKeyOnce.Do(func() {
+		processKey = make([]byte, 32)
+		if _, err := rand.Read(processKey); err != nil {
+			panic("nsigii: cannot generate phantom key: " + err.Error())
+		}
+	})
+	return processKey
+}
+
+// SetPhantomKey sets the secret used to mint and verify phantom IDs. Services
+// in different processes that must accept each other's IDs share a key; by
+// default each process uses its own random key.
+//
+// A nil key restores the process default.
+func (c *Context) SetPhantomKey(key []byte) error {
+	if err := c.require(RoleAdmin, "SetPhantomKey"); err != nil {
+		return err
+	}
+	if key == nil {
+		c.phantomKey = nil
+		return nil
+	}
+	c.phantomKey = append([]byte(nil), key...)
+	return nil
+}
+
+// key returns the context's phantom key, ignoring any key ring
+func (c *Context) key() []byte {
+	if c.phantomKey != nil {
+		return c.phantomKey
+	}
+	return defaultPhantomKey()
+}
+
+// GeneratePhantomID mints a new phantom ID for subject, bound to ctx's schema
+//
+// Example:
+//   id, err := nsigii.GeneratePhantomID(ctx, "worker-7")
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   fmt.Println(id) // phantom.AQAW...
+func GeneratePhantomID(ctx *Context, subject string) (PhantomID, error) {
+	if err := ctx.require(RoleOperator, "GeneratePhantomID"); err != nil {
+		return PhantomID{}, err
+	}
+	return mintPhantomID(ctx, subject)
+}
+
+// mintPhantomID is GeneratePhantomID without the role check, for IDs the
+// context issues itself
+func mintPhantomID(ctx *Context, subject string) (PhantomID, error) {
+	schema, err := ctx.Schema()
+	if err != nil {
+		return PhantomID{}, err
+	}
+	if len(subject) > phantomMaxField {
+		return PhantomID{}, errors.New("phantom ID subject too long")
+	}
+
+	keyID, key := ctx.currentKey()
+	seg, err := newPhantomSegment(subject)
+	if err != nil {
+		return PhantomID{}, err
+	}
+	id := PhantomID{
+		schema: schema,
+		keyID:  keyID,
+		path:   []phantomSegment{seg},
+	}
+	id.mac = id.sign(key)
+
+	return id, nil
+}
+
+// newPhantomSegment creates a segment for subject issued now
+func newPhantomSegment(subject string) (phantomSegment, error) {
+	seg := phantomSegment{subject: subject, issued: time.Now().UnixNano()}
+	if _, err := rand.Read(seg.nonce[:]); err != nil {
+		return phantomSegment{}, fmt.Errorf("failed to generate phantom ID: %w", err)
+	}
+	return seg, nil
+}
+
+// last returns the segment naming the ID itself
+func (id PhantomID) last() phantomSegment {
+	if len(id.path) == 0 {
+		return phantomSegment{}
+	}
+	return id.path[len(id.path)-1]
+}
+
+// Schema returns the schema of the context that minted the ID
+func (id PhantomID) Schema() string {
+	return id.schema
+}
+
+// Subject returns the subject the ID was minted for, or for a derived ID,
+// its own namespace segment
+func (id PhantomID) Subject() string {
+	return id.last().subject
+}
+
+// IssuedAt returns when the ID was minted or derived
+func (id PhantomID) IssuedAt() time.Time {
+	return time.Unix(0, id.last().issued)
+}
+
+// KeyID returns the key ring generation that minted the ID, or the one that
+// minted the root of its derivation chain; "" if it was minted with a
+// context's phantom key
+func (id PhantomID) KeyID() KeyID {
+	return id.keyID
+}
+
+// IsZero reports whether id is the zero PhantomID
+func (id PhantomID) IsZero() bool {
+	return id.mac == [sha256.Size]byte{} && id.schema == "" && len(id.path) == 0
+}
+
+// Equal reports whether id and other are the same identifier
+func (id PhantomID) Equal(other PhantomID) bool {
+	return id.String() == other.String()
+}
+
+// rootPayload encodes the minted segment as its MAC covers it
+func (id PhantomID) rootPayload() []byte {
+	root := id.path[0]
+	b := make([]byte, 0, 1+2+len(id.keyID)+2+len(id.schema)+2+len(root.subject)+phantomNonceSize+8+4)
+	if id.keyID != "" {
+		b = append(b, phantomVersionKG)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(id.keyID)))
+		b = append(b, id.keyID...)
+	} else {
+		b = append(b, phantomVersion)
+	}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(id.schema)))
+	b = append(b, id.schema...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(root.subject)))
+	b = append(b, root.subject...)
+	b = append(b, root.nonce[:]...)
+	b = binary.BigEndian.AppendUint64(b, uint64(root.issued))
+	return binary.BigEndian.AppendUint32(b, 0)
+}
+
+// appendSegment appends the encoding of a derived segment
+func appendSegment(b []byte, seg phantomSegment) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(seg.subject)))
+	b = append(b, seg.subject...)
+	b = append(b, seg.nonce[:]...)
+	return binary.BigEndian.AppendUint64(b, uint64(seg.issued))
+}
+
+// encode returns the ID's binary form: its minted segment, the number of
+// derived segments, the derived segments and the MAC. An ID with no derived
+// segments encodes exactly as IDs did before derivation chained keys, so
+// minted IDs stay valid.
+func (id PhantomID) encode() []byte {
+	b := id.rootPayload()
+	binary.BigEndian.PutUint32(b[len(b)-4:], uint32(len(id.path)-1))
+	for _, seg := range id.path[1:] {
+		b = appendSegment(b, seg)
+	}
+	return append(b, id.mac[:]...)
+}
+
+// sign computes the ID's MAC under the minting key. Each derived segment is
+// authenticated with the MAC of the ID it was derived from as the key, so
+// the MAC of an ancestor, which is never encoded, cannot be recovered from
+// a descendant.
+func (id PhantomID) sign(key []byte) [sha256.Size]byte {
+	mac := chainMAC(key, id.rootPayload())
+	for _, seg := range id.path[1:] {
+		mac = chainMAC(mac[:], appendSegment(nil, seg))
+	}
+	return mac
+}
+
+// chainMAC returns HMAC-SHA256(key, data)
+func chainMAC(key, data []byte) [sha256.Size]byte {
+	var mac [sha256.Size]byte
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	h.Sum(mac[:0])
+	return mac
+}
+
+// String returns the ID in its printable form, phantom.<base64url>
+func (id PhantomID) String() string {
+	return phantomPrefix + base64.RawURLEncoding.EncodeToString(id.encode())
+}
+
+// ParsePhantomID parses the printable form produced by PhantomID.String. It
+// checks the encoding only; use Context.VerifyPhantomID to authenticate the
+// result.
+func ParsePhantomID(s string) (PhantomID, error) {
+	enc, ok := strings.CutPrefix(s, phantomPrefix)
+	if !ok {
+		return PhantomID{}, errors.New("invalid phantom ID: missing prefix")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(enc)
+	if err != nil {
+		return PhantomID{}, fmt.Errorf("invalid phantom ID: %w", err)
+	}
+
+	return decodePhantomID(b)
+}
+
+// decodePhantomID decodes the binary form of an ID
+func decodePhantomID(b []byte) (PhantomID, error) {
+	var id PhantomID
+	if len(b) == 0 || b[0] != phantomVersion && b[0] != phantomVersionKG {
+		return PhantomID{}, errors.New("invalid phantom ID: unsupported version")
+	}
+	keyed := b[0] == phantomVersionKG
+	b = b[1:]
+
+	field := func() (string, bool) {
+		if len(b) < 2 {
+			return "", false
+		}
+		n := int(binary.BigEndian.Uint16(b))
+		if len(b) < 2+n {
+			return "", false
+		}
+		s := string(b[2 : 2+n])
+		b = b[2+n:]
+		return s, true
+	}
+	segment := func() (phantomSegment, bool) {
+		var seg phantomSegment
+		var ok bool
+		if seg.subject, ok = field(); !ok || len(b) < phantomNonceSize+8 {
+			return phantomSegment{}, false
+		}
+		copy(seg.nonce[:], b)
+		seg.issued = int64(binary.BigEndian.Uint64(b[phantomNonceSize:]))
+		b = b[phantomNonceSize+8:]
+		return seg, true
+	}
+
+	truncated := errors.New("invalid phantom ID: truncated")
+
+	if keyed {
+		keyID, ok := field()
+		if !ok {
+			return PhantomID{}, truncated
+		}
+		if keyID == "" {
+			return PhantomID{}, errors.New("invalid phantom ID: empty key ID")
+		}
+		id.keyID = KeyID(keyID)
+	}
+
+	var ok bool
+	if id.schema, ok = field(); !ok {
+		return PhantomID{}, truncated
+	}
+	root, ok := segment()
+	if !ok || len(b) < 4 {
+		return PhantomID{}, truncated
+	}
+	id.path = []phantomSegment{root}
+
+	n := binary.BigEndian.Uint32(b)
+	b = b[4:]
+	if n > phantomMaxDepth {
+		return PhantomID{}, errors.New("invalid phantom ID: derivation chain too deep")
+	}
+	for ; n > 0; n-- {
+		seg, ok := segment()
+		if !ok {
+			return PhantomID{}, truncated
+		}
+		id.path = append(id.path, seg)
+	}
+	if len(b) != sha256.Size {
+		return PhantomID{}, truncated
+	}
+	copy(id.mac[:], b)
+
+	return id, nil
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (id PhantomID) MarshalText() ([]byte, error) {
+	if id.IsZero() {
+		return nil, errors.New("cannot marshal zero phantom ID")
+	}
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (id *PhantomID) UnmarshalText(text []byte) error {
+	parsed, err := ParsePhantomID(string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// ============================================================================
+// Phantom ID Verification
+// ============================================================================
+
+// schemaRoot is the first component of every service schema
+const schemaRoot = "obinexus"
+
+// parseSchema splits an obinexus.[operation].[service] schema
+func parseSchema(schema string) (operation, service string, ok bool) {
+	parts := strings.SplitN(schema, ".", 3)
+	if len(parts) != 3 || parts[0] != schemaRoot || parts[1] == "" || parts[2] == "" {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// compatibleSchemas reports whether a context with schema verifier accepts
+// IDs minted under schema minted: both must be well-formed and name the same
+// operation
+func compatibleSchemas(verifier, minted string) bool {
+	vop, _, ok := parseSchema(verifier)
+	if !ok {
+		return false
+	}
+	mop, _, ok := parseSchema(minted)
+	return ok && vop == mop
+}
+
+// VerifyPhantomID checks that id was minted under c's phantom key, or a
+// generation of its key ring, by a context whose schema is compatible with
+// c's, that is, one serving the same operation, or was derived from such an
+// ID. It returns false for forged, altered or foreign IDs, IDs minted by a
+// retired key generation, and an error only if the check itself cannot be
+// made.
+//
+// Example:
+//   ok, err := ctx.VerifyPhantomID(callerID)
+//   if err != nil || !ok {
+//       return errUnauthenticated
+//   }
+func (c *Context) VerifyPhantomID(id PhantomID) (bool, error) {
+	schema, err := c.Schema()
+	if err != nil {
+		return false, err
+	}
+	if id.IsZero() {
+		return false, errors.New("zero phantom ID")
+	}
+
+	key, ok := c.keyByID(id.KeyID())
+	if !ok || !id.authentic(key) {
+		return false, nil
+	}
+
+	return compatibleSchemas(schema, id.schema), nil
+}
+
+// authentic reports whether id's MAC, chained through its whole derivation
+// path, checks out against the minting key
+func (id PhantomID) authentic(key []byte) bool {
+	mac := id.sign(key)
+	return hmac.Equal(mac[:], id.mac[:])
+}
+
+// ============================================================================
+// Phantom ID Namespaces
+// ============================================================================
+
+// DerivePhantomID mints a child identity one level below parent, for
+// delegating to a worker or instance. The child is authenticated with
+// parent's MAC as its key rather than with a context key, so any holder of
+// parent can derive children. The child carries its ancestors' segments but
+// none of their MACs, so its holder can derive below it but cannot recover
+// parent, widen its namespace or forge a sibling.
+//
+// Example:
+//   svc, _ := ctx.PhantomID()
+//   worker, err := nsigii.DerivePhantomID(svc, "worker-3")
+//   // worker.Namespace() == "obinexus/tokenize/lexer/worker-3"
+func DerivePhantomID(parent PhantomID, child string) (PhantomID, error) {
+	if parent.IsZero() {
+		return PhantomID{}, errors.New("zero phantom ID")
+	}
+	if child == "" || strings.Contains(child, "/") {
+		return PhantomID{}, fmt.Errorf("invalid phantom ID namespace segment: %q", child)
+	}
+	if len(child) > phantomMaxField {
+		return PhantomID{}, errors.New("phantom ID subject too long")
+	}
+	if parent.Depth() >= phantomMaxDepth {
+		return PhantomID{}, errors.New("phantom ID derivation chain too deep")
+	}
+
+	seg, err := newPhantomSegment(child)
+	if err != nil {
+		return PhantomID{}, err
+	}
+	id := PhantomID{
+		schema: parent.schema,
+		keyID:  parent.keyID,
+		path:   append(slices.Clip(parent.path), seg),
+	}
+	id.mac = chainMAC(parent.mac[:], appendSegment(nil, seg))
+
+	return id, nil
+}
+
+// Depth returns the number of derivations between id and the ID a context
+// minted; 0 for a minted ID
+func (id PhantomID) Depth() int {
+	return max(len(id.path)-1, 0)
+}
+
+// Namespace returns the ID's hierarchical name. A minted ID's namespace is
+// its schema written as org/operation/service; each derivation appends the
+// child's segment.
+func (id PhantomID) Namespace() string {
+	ns := strings.ReplaceAll(id.schema, ".", "/")
+	for _, seg := range id.path[min(1, len(id.path)):] {
+		ns += "/" + seg.subject
+	}
+	return ns
+}
+
+// Within reports whether id's namespace is namespace or lies below it
+func (id PhantomID) Within(namespace string) bool {
+	ns := id.Namespace()
+	return ns == namespace || strings.HasPrefix(ns, strings.TrimSuffix(namespace, "/")+"/")
+}
@@ -0,0 +1,94 @@
+package nsigii
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDerivePhantomIDChain(t *testing.T) {
+	ctx, err := NewContext("tokenize", "lexer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+
+	svc, err := ctx.PhantomID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	worker, err := DerivePhantomID(svc, "worker-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, err := DerivePhantomID(worker, "task-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParsePhantomID(task.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsed.Equal(task) {
+		t.Fatalf("round trip changed the ID: %v != %v", parsed, task)
+	}
+	if got, want := parsed.Namespace(), "obinexus/tokenize/lexer/worker-3/task-1"; got != want {
+		t.Errorf("Namespace() = %q, want %q", got, want)
+	}
+	if parsed.Depth() != 2 || parsed.Subject() != "task-1" {
+		t.Errorf("Depth() = %d, Subject() = %q", parsed.Depth(), parsed.Subject())
+	}
+	for _, id := range []PhantomID{svc, worker, parsed} {
+		if ok, err := ctx.VerifyPhantomID(id); !ok {
+			t.Errorf("VerifyPhantomID(%s) = false, %v", id.Namespace(), err)
+		}
+	}
+}
+
+func TestDerivePhantomIDCannotForgeSibling(t *testing.T) {
+	ctx, err := NewContext("tokenize", "lexer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+
+	svc, err := ctx.PhantomID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	worker, err := DerivePhantomID(svc, "worker-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The worker's encoding must not carry the key its siblings are made with
+	if bytes.Contains(worker.encode(), svc.mac[:]) {
+		t.Fatal("derived ID contains its parent's MAC")
+	}
+
+	// Renaming the worker's own segment breaks its MAC
+	forged, err := ParsePhantomID(worker.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged.path[len(forged.path)-1].subject = "admin"
+	if ok, _ := ctx.VerifyPhantomID(forged); ok {
+		t.Fatalf("forged sibling %s verified", forged.Namespace())
+	}
+
+	// Dropping the worker's segment leaves the service's ID with the wrong MAC
+	truncated := worker
+	truncated.path = truncated.path[:1]
+	if ok, _ := ctx.VerifyPhantomID(truncated); ok {
+		t.Fatal("parent recovered from derived ID verified")
+	}
+
+	// Deriving from the worker stays below the worker
+	below, err := DerivePhantomID(worker, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if below.Within("obinexus/tokenize/lexer/admin") || !below.Within("obinexus/tokenize/lexer/worker-3") {
+		t.Fatalf("derived ID escaped its parent: %s", below.Namespace())
+	}
+}
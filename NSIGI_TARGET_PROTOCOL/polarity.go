@@ -0,0 +1,102 @@
+package nsigii
+
+import (
+	"fmt"
+	"time"
+)
+
+// ============================================================================
+// Polarity
+// ============================================================================
+
+func (p Polarity) String() string {
+	switch p {
+	case PolarityPositive:
+		return "POSITIVE"
+	case PolarityNegative:
+		return "NEGATIVE"
+	case PolarityNeutral:
+		return "NEUTRAL"
+	}
+	return "UNKNOWN"
+}
+
+// SetPolarity sets the flow polarity of the context, in the C layer as
+// well, so downstream stages can treat positive, negative and neutral
+// flows differently. New contexts are positive.
+func (c *Context) SetPolarity(p Polarity) error {
+	if err := c.require(RoleAdmin, "SetPolarity"); err != nil {
+		return err
+	}
+	if err := c.usable(); err != nil {
+		return err
+	}
+
+	var result int
+	err := c.withNative(func(ctx *nativeContext) {
+		result = nativeSetPolarity(ctx, p)
+	})
+	if err != nil {
+		return err
+	}
+	if result != 0 {
+		c.usage.countError()
+		return &CError{Op: "set polarity " + p.String(), Code: result}
+	}
+
+	return nil
+}
+
+// Polarity returns the flow polarity of the context, or PolarityNeutral if
+// the context is closed
+func (c *Context) Polarity() Polarity {
+	p := PolarityNeutral
+	c.withNative(func(ctx *nativeContext) {
+		p = nativePolarity(ctx)
+	})
+	return p
+}
+
+// FlipPolarity inverts the context's polarity, positive to negative or back.
+// Since polarity decides how the context's output is routed, the flip only
+// takes effect after a passing RGB consensus; the attempt and its outcome
+// are recorded in the color audit trail. Neutral contexts cannot be flipped.
+func (c *Context) FlipPolarity(reason string) error {
+	if err := c.require(RoleOperator, "FlipPolarity"); err != nil {
+		return err
+	}
+	if err := c.usable(); err != nil {
+		return err
+	}
+
+	from := c.Polarity()
+	var to Polarity
+	switch from {
+	case PolarityPositive:
+		to = PolarityNegative
+	case PolarityNegative:
+		to = PolarityPositive
+	default:
+		return fmt.Errorf("cannot flip %s polarity", from)
+	}
+
+	ok, err := c.VerifyRGBConsensus()
+	if err == nil && !ok {
+		err = fmt.Errorf("polarity flip refused: %w", ErrConsensusFailed)
+	}
+	if err == nil {
+		err = c.SetPolarity(to)
+	}
+
+	state := c.ColorState()
+	c.audit.record(AuditEntry{
+		Time:   time.Now(),
+		Kind:   AuditPolarity,
+		From:   state,
+		To:     state,
+		Reason: fmt.Sprintf("%s -> %s: %s", from, to, reason),
+		Passed: err == nil,
+	})
+
+	return err
+}
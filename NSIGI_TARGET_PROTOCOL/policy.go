@@ -0,0 +1,261 @@
+package nsigii
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ============================================================================
+// Operation Authorization
+// ============================================================================
+
+// ErrPolicyDenied is matched, via errors.Is, by every *PolicyError
+var ErrPolicyDenied = errors.New("operation denied by policy")
+
+// PolicyError reports an operation refused by the context's policy engine
+type PolicyError struct {
+	Request PolicyRequest
+	Reason  string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("%s on obinexus.%s.%s denied: %s",
+		e.Request.Op, e.Request.Operation, e.Request.Service, e.Reason)
+}
+
+// Is reports whether target is ErrPolicyDenied
+func (e *PolicyError) Is(target error) bool {
+	return target == ErrPolicyDenied
+}
+
+// PolicyRequest describes an operation awaiting authorization
+type PolicyRequest struct {
+	Op        Operation    // OpTokenize, OpConsensus, OpAuxStart or OpAuxStop
+	Operation string       // Schema operation of the context
+	Service   string       // Schema service of the context
+	Polarity  Polarity     // Polarity of the context
+	State     ColorChannel // Color state of the context
+	Caller    PhantomID    // Caller set with SetCaller; zero if none
+}
+
+// PolicyDecision is a policy engine's answer to a PolicyRequest
+type PolicyDecision struct {
+	Allow  bool
+	Reason string // Why the request was denied, or which rule allowed it
+}
+
+// PolicyEngine decides whether a context may perform an operation. It is
+// consulted before every tokenization (each chunk of a streamed one), RGB
+// consensus check and AUX start or stop, and must be safe for concurrent
+// use. An error denies the operation.
+//
+// Policy is the built-in engine; adapt an external one, such as OPA, with
+// PolicyFunc.
+type PolicyEngine interface {
+	Decide(c *Context, req PolicyRequest) (PolicyDecision, error)
+}
+
+// PolicyFunc adapts a function to a PolicyEngine
+//
+// Example:
+//   ctx.SetPolicy(nsigii.PolicyFunc(func(c *nsigii.Context, req nsigii.PolicyRequest) (nsigii.PolicyDecision, error) {
+//       rs, err := query.Eval(context.Background(), rego.EvalInput(req))
+//       if err != nil {
+//           return nsigii.PolicyDecision{}, err
+//       }
+//       return nsigii.PolicyDecision{Allow: rs.Allowed(), Reason: "opa"}, nil
+//   }))
+type PolicyFunc func(c *Context, req PolicyRequest) (PolicyDecision, error)
+
+// Decide calls f(c, req)
+func (f PolicyFunc) Decide(c *Context, req PolicyRequest) (PolicyDecision, error) {
+	return f(c, req)
+}
+
+// SetPolicy makes the context consult engine before each operation, failing
+// refused operations with a *PolicyError. A nil engine allows everything.
+func (c *Context) SetPolicy(engine PolicyEngine) error {
+	if err := c.require(RoleAdmin, "SetPolicy"); err != nil {
+		return err
+	}
+	c.policy = engine
+	return nil
+}
+
+// WithPolicy sets the context's policy engine; see SetPolicy
+func WithPolicy(engine PolicyEngine) Option {
+	return func(c *Context) error {
+		return c.SetPolicy(engine)
+	}
+}
+
+// SetCaller records the phantom ID of the party the context is working for,
+// such as the peer bound to a network request, for policy rules to match.
+// The zero PhantomID clears it.
+func (c *Context) SetCaller(id PhantomID) error {
+	if err := c.require(RoleAdmin, "SetCaller"); err != nil {
+		return err
+	}
+	if id.IsZero() {
+		c.caller = nil
+		return nil
+	}
+	c.caller = &id
+	return nil
+}
+
+// Caller returns the phantom ID set with SetCaller
+func (c *Context) Caller() (PhantomID, bool) {
+	if c.caller == nil {
+		return PhantomID{}, false
+	}
+	return *c.caller, true
+}
+
+// authorize consults the context's policy engine about op
+func (c *Context) authorize(op Operation) error {
+	if c.policy == nil {
+		return nil
+	}
+
+	req := PolicyRequest{
+		Op:        op,
+		Operation: c.operation,
+		Service:   c.service,
+		Polarity:  c.Polarity(),
+		State:     c.ColorState(),
+	}
+	if c.caller != nil {
+		req.Caller = *c.caller
+	}
+
+	d, err := c.policy.Decide(c, req)
+	if err != nil {
+		return &PolicyError{Request: req, Reason: "policy engine failed: " + err.Error()}
+	}
+	if !d.Allow {
+		reason := d.Reason
+		if reason == "" {
+			reason = "no reason given"
+		}
+		return &PolicyError{Request: req, Reason: reason}
+	}
+	return nil
+}
+
+// ============================================================================
+// Rule-Based Policy
+// ============================================================================
+
+// PolicyEffect is what a matching PolicyRule does to a request
+type PolicyEffect int
+
+const (
+	PolicyAllow PolicyEffect = 0 // Permit the request
+	PolicyDeny  PolicyEffect = 1 // Refuse the request
+)
+
+var policyEffectNames = []string{"ALLOW", "DENY"}
+
+func (e PolicyEffect) String() string {
+	if e >= 0 && int(e) < len(policyEffectNames) {
+		return policyEffectNames[e]
+	}
+	return "UNKNOWN"
+}
+
+// PolicyRule matches requests on any combination of fields. An empty field
+// matches anything; a non-empty one matches any of its values.
+type PolicyRule struct {
+	Name   string
+	Effect PolicyEffect
+
+	Ops        []Operation
+	Operations []string // Schema operations
+	Services   []string // Schema services
+	Polarities []Polarity
+	States     []ColorChannel
+
+	// Callers matches a caller whose phantom ID the context accepts (see
+	// VerifyPhantomID) and lies within one of these namespaces (see
+	// PhantomID.Within); requests without a caller never match. A caller
+	// the context does not accept could claim any namespace, so it matches
+	// every DENY rule with Callers and no ALLOW rule.
+	Callers []string
+}
+
+// Policy is the built-in rule engine. A request is denied if any DENY rule
+// matches it, otherwise allowed if any ALLOW rule matches it, otherwise
+// given Default, so the order of rules does not matter.
+//
+// Example:
+//   ctx.SetPolicy(&nsigii.Policy{
+//       Default: nsigii.PolicyDeny,
+//       Rules: []nsigii.PolicyRule{
+//           {Name: "mesh tokenizers", Effect: nsigii.PolicyAllow,
+//               Ops: []nsigii.Operation{nsigii.OpTokenize},
+//               Callers: []string{"obinexus/tokenize"}},
+//           {Name: "no work while escalated", Effect: nsigii.PolicyDeny,
+//               States: []nsigii.ColorChannel{nsigii.ColorMagenta, nsigii.ColorBlack}},
+//       },
+//   })
+type Policy struct {
+	Rules   []PolicyRule
+	Default PolicyEffect
+}
+
+// Decide implements PolicyEngine
+func (p *Policy) Decide(c *Context, req PolicyRequest) (PolicyDecision, error) {
+	allowed := -1
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if !r.matches(c, req) {
+			continue
+		}
+		if r.Effect == PolicyDeny {
+			return PolicyDecision{Reason: "denied by rule " + r.label(i)}, nil
+		}
+		if allowed < 0 {
+			allowed = i
+		}
+	}
+
+	if allowed >= 0 {
+		return PolicyDecision{Allow: true, Reason: "allowed by rule " + p.Rules[allowed].label(allowed)}, nil
+	}
+	if p.Default == PolicyAllow {
+		return PolicyDecision{Allow: true, Reason: "allowed by default"}, nil
+	}
+	return PolicyDecision{Reason: "no rule allows it"}, nil
+}
+
+// matches reports whether every non-empty field of r matches req
+func (r *PolicyRule) matches(c *Context, req PolicyRequest) bool {
+	if len(r.Ops) > 0 && !slices.Contains(r.Ops, req.Op) ||
+		len(r.Operations) > 0 && !slices.Contains(r.Operations, req.Operation) ||
+		len(r.Services) > 0 && !slices.Contains(r.Services, req.Service) ||
+		len(r.Polarities) > 0 && !slices.Contains(r.Polarities, req.Polarity) ||
+		len(r.States) > 0 && !slices.Contains(r.States, req.State) {
+		return false
+	}
+	if len(r.Callers) == 0 {
+		return true
+	}
+
+	if req.Caller.IsZero() {
+		return false
+	}
+	if ok, err := c.VerifyPhantomID(req.Caller); err != nil || !ok {
+		return r.Effect == PolicyDeny // fail closed
+	}
+	return slices.ContainsFunc(r.Callers, req.Caller.Within)
+}
+
+// label names the rule at index i in decisions
+func (r *PolicyRule) label(i int) string {
+	if r.Name != "" {
+		return fmt.Sprintf("%q", r.Name)
+	}
+	return fmt.Sprintf("#%d", i)
+}
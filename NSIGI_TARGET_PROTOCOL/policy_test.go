@@ -0,0 +1,58 @@
+package nsigii
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPolicyUnverifiedCallerDenied(t *testing.T) {
+	ctx, err := NewContext("tokenize", "lexer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+
+	// A caller minted under another key, which ctx cannot verify
+	foreign, err := NewContext("tokenize", "lexer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer foreign.Close()
+	if err := foreign.SetPhantomKey([]byte("some other deployment's key")); err != nil {
+		t.Fatal(err)
+	}
+	forged, err := GeneratePhantomID(foreign, "worker-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trusted, err := GeneratePhantomID(ctx, "worker-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ctx.SetPolicy(&Policy{
+		Default: PolicyAllow,
+		Rules: []PolicyRule{
+			{Name: "no batch workers", Effect: PolicyDeny, Callers: []string{"obinexus/batch"}},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A verified caller outside the denied namespace is allowed
+	if err := ctx.SetCaller(trusted); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ctx.Tokenize("x"); err != nil {
+		t.Fatalf("Tokenize as verified caller = %v", err)
+	}
+
+	// An unverified one cannot be placed outside it, so it is denied
+	if err := ctx.SetCaller(forged); err != nil {
+		t.Fatal(err)
+	}
+	var perr *PolicyError
+	if _, err := ctx.Tokenize("x"); !errors.As(err, &perr) {
+		t.Fatalf("Tokenize as unverified caller = %v, want *PolicyError", err)
+	}
+}
@@ -0,0 +1,270 @@
+package nsigii
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ============================================================================
+// Context Pool
+// ============================================================================
+
+// ErrPoolClosed is returned by ContextPool.Get after the pool is closed
+var ErrPoolClosed = errors.New("context pool is closed")
+
+// PoolOptions configures a ContextPool
+type PoolOptions struct {
+	MaxSize int // Contexts per schema, idle or in use; 0 means unlimited
+	MaxIdle int // Idle contexts kept per schema; 0 means 2
+
+	// Setup configures the first context created for a schema. Later
+	// contexts for the schema copy its settings instead of rerunning it.
+	Setup func(c *Context) error
+
+	// Health checks an idle context before it is handed out or kept on
+	// return; contexts failing it are closed. Nil checks that Ping
+	// succeeds and the context is not in MAGENTA.
+	Health func(c *Context) error
+}
+
+// ContextPool hands out ready contexts keyed by operation and service, so
+// services avoid creating and destroying a native context per request.
+// Contexts are reset to their schema's settings when returned with Put;
+// color state, audit trail and registered hooks are not reset, which is
+// why the health check runs on every reuse.
+//
+// Example:
+//   pool := nsigii.NewContextPool(nsigii.PoolOptions{MaxSize: 8})
+//   defer pool.Close()
+//
+//   ctx, err := pool.Get(reqCtx, "tokenize", "lexer")
+//   if err != nil {
+//       return err
+//   }
+//   defer pool.Put(ctx)
+type ContextPool struct {
+	opts PoolOptions
+
+	mu      sync.Mutex
+	buckets map[poolKey]*poolBucket
+	closed  bool
+	done    chan struct{} // closed by Close to wake blocked Get calls
+}
+
+type poolKey struct {
+	operation string
+	service   string
+}
+
+// poolBucket holds the contexts of one schema
+type poolBucket struct {
+	template *Context      // settings new and returned contexts copy
+	idle     []*Context    // most recently returned last
+	slots    chan struct{} // one entry per context out, nil when unlimited
+}
+
+// NewContextPool creates an empty pool
+func NewContextPool(opts PoolOptions) *ContextPool {
+	if opts.MaxIdle <= 0 {
+		opts.MaxIdle = 2
+	}
+	if opts.MaxSize > 0 && opts.MaxIdle > opts.MaxSize {
+		opts.MaxIdle = opts.MaxSize
+	}
+	if opts.Health == nil {
+		opts.Health = defaultPoolHealth
+	}
+	return &ContextPool{
+		opts:    opts,
+		buckets: make(map[poolKey]*poolBucket),
+		done:    make(chan struct{}),
+	}
+}
+
+// defaultPoolHealth is the health check used when PoolOptions.Health is nil
+func defaultPoolHealth(c *Context) error {
+	if err := c.Ping(); err != nil {
+		return err
+	}
+	if c.ColorState() == ColorMagenta {
+		return errors.New("context is in MAGENTA")
+	}
+	return nil
+}
+
+// Get returns a healthy context for the schema obinexus.[operation].[service],
+// reusing an idle one if possible. With MaxSize set, Get blocks until a
+// context is returned or ctx is done. The context must be returned with Put
+// and not used afterwards.
+func (p *ContextPool) Get(ctx context.Context, operation, service string) (*Context, error) {
+	b, err := p.bucket(operation, service)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.slots != nil {
+		select {
+		case b.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-p.done:
+			return nil, ErrPoolClosed
+		}
+	}
+
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			p.release(b)
+			return nil, ErrPoolClosed
+		}
+		n := len(b.idle)
+		if n == 0 {
+			p.mu.Unlock()
+			break
+		}
+		c := b.idle[n-1]
+		b.idle[n-1] = nil
+		b.idle = b.idle[:n-1]
+		p.mu.Unlock()
+
+		if p.opts.Health(c) == nil {
+			return c, nil
+		}
+		c.Close()
+	}
+
+	c, err := b.template.fork()
+	if err != nil {
+		p.release(b)
+		return nil, err
+	}
+	return c, nil
+}
+
+// bucket returns the bucket for a schema, creating it and running Setup on
+// first use
+func (p *ContextPool) bucket(operation, service string) (*poolBucket, error) {
+	key := poolKey{operation, service}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, ErrPoolClosed
+	}
+	if b, ok := p.buckets[key]; ok {
+		return b, nil
+	}
+
+	template, err := NewContext(operation, service)
+	if err != nil {
+		return nil, err
+	}
+	if p.opts.Setup != nil {
+		if err := p.opts.Setup(template); err != nil {
+			template.Close()
+			return nil, err
+		}
+	}
+
+	b := &poolBucket{template: template}
+	if p.opts.MaxSize > 0 {
+		b.slots = make(chan struct{}, p.opts.MaxSize)
+	}
+	p.buckets[key] = b
+	return b, nil
+}
+
+// release frees the slot held by a context taken from b
+func (p *ContextPool) release(b *poolBucket) {
+	if b.slots != nil {
+		<-b.slots
+	}
+}
+
+// Put returns a context obtained from Get. Any open AUX session is stopped
+// and the context's settings are restored to those of its schema; it is
+// closed instead of kept if it fails the health check, the schema already
+// has MaxIdle idle contexts or the pool is closed.
+func (p *ContextPool) Put(c *Context) {
+	if c == nil {
+		return
+	}
+
+	p.mu.Lock()
+	b, ok := p.buckets[poolKey{c.operation, c.service}]
+	closed := p.closed
+	p.mu.Unlock()
+	if !ok {
+		c.Close()
+		return
+	}
+	defer p.release(b)
+
+	if closed {
+		c.Close()
+		return
+	}
+
+	if !p.reset(c, b.template) {
+		c.Close()
+		return
+	}
+
+	p.mu.Lock()
+	if p.closed || len(b.idle) >= p.opts.MaxIdle {
+		p.mu.Unlock()
+		c.Close()
+		return
+	}
+	b.idle = append(b.idle, c)
+	p.mu.Unlock()
+}
+
+// reset prepares c for reuse, reporting whether it is fit to keep
+func (p *ContextPool) reset(c *Context, template *Context) bool {
+	if c.usable() != nil {
+		return false
+	}
+
+	c.aux.mu.Lock()
+	session := c.aux.session
+	c.aux.mu.Unlock()
+	if session != nil {
+		if session.Close() != nil {
+			return false
+		}
+	} else if c.AuxStats().Active {
+		if c.AuxStop() != nil {
+			return false
+		}
+	}
+
+	c.copySettings(template)
+	return p.opts.Health(c) == nil
+}
+
+// Close closes every idle context and makes later Get calls fail. Contexts
+// still out are closed when they are returned.
+func (p *ContextPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	close(p.done)
+
+	for _, b := range p.buckets {
+		for _, c := range b.idle {
+			c.Close()
+		}
+		b.idle = nil
+		b.template.Close()
+	}
+	return nil
+}
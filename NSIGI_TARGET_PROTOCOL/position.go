@@ -0,0 +1,48 @@
+package nsigii
+
+import "unicode/utf8"
+
+// ============================================================================
+// Source Positions
+// ============================================================================
+
+// SetPositionTracking enables or disables Line/Column computation during
+// tokenization. Tracking is on by default; turning it off skips a pass over
+// the source for callers that only need byte offsets.
+func (c *Context) SetPositionTracking(enabled bool) {
+	c.noPos = !enabled
+}
+
+// positionTracker maps byte offsets to 1-based line/column pairs. Lexers
+// emit tokens in source order, so it scans forward incrementally and only
+// rescans from the start if asked for an earlier offset.
+type positionTracker struct {
+	source    string
+	offset    int // bytes scanned so far
+	line      int // line containing offset, 0-based
+	lineStart int // offset of the first byte on that line
+	runes     int // runes before offset
+}
+
+// position returns the line and column of the byte at offset, leaving the
+// number of runes before it in p.runes
+func (p *positionTracker) position(offset int) (line, column int) {
+	if offset > len(p.source) {
+		offset = len(p.source)
+	}
+	if offset < p.offset {
+		p.offset, p.line, p.lineStart, p.runes = 0, 0, 0, 0
+	}
+
+	for ; p.offset < offset; p.offset++ {
+		if p.source[p.offset] == '\n' {
+			p.line++
+			p.lineStart = p.offset + 1
+		}
+		if utf8.RuneStart(p.source[p.offset]) {
+			p.runes++
+		}
+	}
+
+	return p.line + 1, offset - p.lineStart + 1
+}
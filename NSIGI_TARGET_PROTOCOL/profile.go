@@ -0,0 +1,221 @@
+package nsigii
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ============================================================================
+// Language Profiles
+// ============================================================================
+
+// LanguageProfile bundles the lexical rules of a source language. Contexts
+// with a profile tokenize with the Go lexer configured from it.
+type LanguageProfile struct {
+	Name          string
+	Keywords      []string
+	LineComments  []string    // Comment openers running to end of line, e.g. "//", "#"
+	BlockComments [][2]string // Open/close pairs, e.g. {"/*", "*/"}
+	Quotes        []string    // String delimiters honouring backslash escapes
+	RawQuotes     []string    // String delimiters without escapes, e.g. "`"
+	Operators     []string    // Multi-byte operators; other bytes are 1-byte operators
+	Delimiters    []string
+
+	// Nested constructs: Enter lists the sequences that push a mode from
+	// the top level, Modes defines the modes that can be pushed
+	Enter []ModeSwitch
+	Modes []LexerMode
+}
+
+// LexerMode is a lexical mode pushed for a nested construct such as an
+// interpolated string, embedded template or heredoc body. Modes form a
+// stack: Enter sequences push further modes and Exit sequences pop back to
+// the enclosing one. Both are emitted as TokenDelimiter.
+//
+// A text mode emits its content as TokenString runs; any other mode scans
+// tokens with the profile's rules.
+//
+// Example (string interpolation):
+//   Enter: []nsigii.ModeSwitch{{Open: `"`, Mode: "string"}},
+//   Modes: []nsigii.LexerMode{
+//       {Name: "string", Text: true, Escapes: true, Exit: []string{`"`},
+//           Enter: []nsigii.ModeSwitch{{Open: "${", Mode: "code"}}},
+//       {Name: "code", Exit: []string{"}"},
+//           Enter: []nsigii.ModeSwitch{{Open: "{", Mode: "code"}, {Open: `"`, Mode: "string"}}},
+//   },
+type LexerMode struct {
+	Name    string
+	Text    bool         // Content is literal text, emitted as TokenString
+	Escapes bool         // In a text mode, a backslash escapes the next byte
+	Enter   []ModeSwitch // Sequences that push a nested mode
+	Exit    []string     // Sequences that pop back to the enclosing mode
+}
+
+// ModeSwitch pushes Mode when Open is scanned
+type ModeSwitch struct {
+	Open string
+	Mode string
+}
+
+// Validate checks that every mode switch names a defined mode
+func (p LanguageProfile) Validate() error {
+	defined := make(map[string]bool, len(p.Modes))
+	for _, m := range p.Modes {
+		if m.Name == "" {
+			return fmt.Errorf("profile %s: unnamed lexer mode", p.Name)
+		}
+		if defined[m.Name] {
+			return fmt.Errorf("profile %s: duplicate lexer mode %q", p.Name, m.Name)
+		}
+		defined[m.Name] = true
+	}
+
+	check := func(switches []ModeSwitch) error {
+		for _, sw := range switches {
+			if sw.Open == "" {
+				return fmt.Errorf("profile %s: empty open sequence for mode %q", p.Name, sw.Mode)
+			}
+			if !defined[sw.Mode] {
+				return fmt.Errorf("profile %s: undefined lexer mode %q", p.Name, sw.Mode)
+			}
+		}
+		return nil
+	}
+
+	if err := check(p.Enter); err != nil {
+		return err
+	}
+	for _, m := range p.Modes {
+		if err := check(m.Enter); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RIFTProfile is the RIFT DSL, the language the lexer targets by default
+var RIFTProfile = LanguageProfile{
+	Name:          "rift",
+	Keywords:      defaultKeywords,
+	LineComments:  []string{"//"},
+	BlockComments: [][2]string{{"/*", "*/"}},
+	Quotes:        []string{`"`, `'`, "`"},
+	Operators:     defaultOperators,
+	Delimiters:    defaultDelimiters,
+}
+
+// CProfile is C (C11)
+var CProfile = LanguageProfile{
+	Name: "c",
+	Keywords: []string{
+		"auto", "break", "case", "char", "const", "continue", "default", "do",
+		"double", "else", "enum", "extern", "float", "for", "goto", "if",
+		"inline", "int", "long", "register", "restrict", "return", "short",
+		"signed", "sizeof", "static", "struct", "switch", "typedef", "union",
+		"unsigned", "void", "volatile", "while", "_Bool", "_Static_assert",
+	},
+	LineComments:  []string{"//"},
+	BlockComments: [][2]string{{"/*", "*/"}},
+	Quotes:        []string{`"`, `'`},
+	Operators: []string{
+		"<<=", ">>=", "...", "->", "++", "--", "<<", ">>", "<=", ">=", "==",
+		"!=", "&&", "||", "*=", "/=", "%=", "+=", "-=", "&=", "^=", "|=", "##",
+	},
+	Delimiters: []string{"(", ")", "{", "}", "[", "]", ";", ","},
+}
+
+// GoProfile is Go
+var GoProfile = LanguageProfile{
+	Name: "go",
+	Keywords: []string{
+		"break", "case", "chan", "const", "continue", "default", "defer",
+		"else", "fallthrough", "for", "func", "go", "goto", "if", "import",
+		"interface", "map", "package", "range", "return", "select", "struct",
+		"switch", "type", "var",
+	},
+	LineComments:  []string{"//"},
+	BlockComments: [][2]string{{"/*", "*/"}},
+	Quotes:        []string{`"`, `'`},
+	RawQuotes:     []string{"`"},
+	Operators: []string{
+		"<<=", ">>=", "&^=", "...", "&&", "||", "<-", "++", "--", "==", "!=",
+		"<=", ">=", ":=", "+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=",
+		"<<", ">>", "&^", "~",
+	},
+	Delimiters: []string{"(", ")", "{", "}", "[", "]", ";", ","},
+}
+
+// PythonProfile is Python 3
+var PythonProfile = LanguageProfile{
+	Name: "python",
+	Keywords: []string{
+		"False", "None", "True", "and", "as", "assert", "async", "await",
+		"break", "class", "continue", "def", "del", "elif", "else", "except",
+		"finally", "for", "from", "global", "if", "import", "in", "is",
+		"lambda", "nonlocal", "not", "or", "pass", "raise", "return", "try",
+		"while", "with", "yield",
+	},
+	LineComments: []string{"#"},
+	Quotes:       []string{`"""`, `'''`, `"`, `'`},
+	Operators: []string{
+		"**=", "//=", ">>=", "<<=", "...", "**", "//", "<<", ">>", "<=", ">=",
+		"==", "!=", "->", ":=", "+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "@=",
+	},
+	Delimiters: []string{"(", ")", "{", "}", "[", "]", ",", ":", ";"},
+}
+
+// JSONProfile is JSON (RFC 8259)
+var JSONProfile = LanguageProfile{
+	Name:       "json",
+	Keywords:   []string{"true", "false", "null"},
+	Quotes:     []string{`"`},
+	Delimiters: []string{"{", "}", "[", "]", ",", ":"},
+}
+
+// Profiles returns the built-in language profiles
+func Profiles() []LanguageProfile {
+	return []LanguageProfile{RIFTProfile, CProfile, GoProfile, PythonProfile, JSONProfile}
+}
+
+// LookupProfile returns the built-in profile with the given name
+// (case-insensitive)
+func LookupProfile(name string) (LanguageProfile, bool) {
+	for _, p := range Profiles() {
+		if strings.EqualFold(p.Name, name) {
+			return p, true
+		}
+	}
+	return LanguageProfile{}, false
+}
+
+// NewContextWithProfile creates a context that tokenizes the language
+// described by profile
+//
+// Example:
+//   ctx, err := nsigii.NewContextWithProfile("tokenize", "lexer", nsigii.GoProfile)
+func NewContextWithProfile(operation, service string, profile LanguageProfile) (*Context, error) {
+	return NewContext(operation, service, WithProfile(profile))
+}
+
+// SetProfile switches the context to the given language profile, dropping
+// any grammar set with SetGrammar. Custom operator, delimiter and keyword
+// settings still take precedence.
+func (c *Context) SetProfile(profile LanguageProfile) error {
+	if err := profile.Validate(); err != nil {
+		return err
+	}
+
+	c.profile = &profile
+	c.grammar = nil
+	c.rebuildLexer()
+	return nil
+}
+
+// Profile returns the context's language profile, if one is set
+func (c *Context) Profile() (LanguageProfile, bool) {
+	if c.profile == nil {
+		return LanguageProfile{}, false
+	}
+	return *c.profile, true
+}
@@ -0,0 +1,116 @@
+package nsigii
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Run with -race: Tokenize, Close and the synchronized setters must not
+// race when a context is shared
+
+func TestContextConcurrentUse(t *testing.T) {
+	ctx, err := NewContext("tokenize", "lexer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := NewAuditLog(nil)
+
+	calls := map[string]func() error{
+		"Tokenize": func() error {
+			_, err := ctx.Tokenize("let x = 1; // shared\n")
+			return err
+		},
+		"Schema": func() error {
+			_, err := ctx.Schema()
+			return err
+		},
+		"PhantomID": func() error {
+			_, err := ctx.PhantomID()
+			return err
+		},
+		"ColorAudit": func() error {
+			ctx.ColorAudit()
+			return nil
+		},
+		"SetPolarity": func() error {
+			return ctx.SetPolarity(PolarityNegative)
+		},
+		"SetEscalationPolicy": func() error {
+			return ctx.SetEscalationPolicy(EscalationPolicy{MaxWarnings: 3})
+		},
+		"SetAlertSink": func() error {
+			return ctx.SetAlertSink(nil)
+		},
+		"SetAuditSink": func() error {
+			return ctx.SetAuditSink(AuditSinkFunc(func(AuditEntry) {}))
+		},
+		"SetAuditLog": func() error {
+			return ctx.SetAuditLog(log)
+		},
+		"SetAuditCapacity": func() error {
+			return ctx.SetAuditCapacity(16)
+		},
+		"SetRotationPolicy": func() error {
+			return ctx.SetRotationPolicy(RotationPolicy{MaxUses: 4})
+		},
+		"SetWorkloadIdentity": func() error {
+			return ctx.SetWorkloadIdentity("worker")
+		},
+	}
+
+	var (
+		wg    sync.WaitGroup
+		start = make(chan struct{})
+	)
+	for name, call := range calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			for i := 0; i < 200; i++ {
+				if err := call(); err != nil && !errors.Is(err, ErrContextClosed) {
+					t.Errorf("%s: %v", name, err)
+					return
+				}
+			}
+		}()
+	}
+
+	close(start)
+	time.Sleep(time.Millisecond)
+	if err := ctx.Close(); err != nil {
+		t.Error(err)
+	}
+	wg.Wait()
+
+	if _, err := ctx.Tokenize("x"); !errors.Is(err, ErrContextClosed) {
+		t.Fatalf("Tokenize after Close = %v, want ErrContextClosed", err)
+	}
+}
+
+func TestContextConcurrentClose(t *testing.T) {
+	ctx, err := NewContext("tokenize", "lexer")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := ctx.Tokenize("a + b"); err != nil && !errors.Is(err, ErrContextClosed) {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := ctx.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
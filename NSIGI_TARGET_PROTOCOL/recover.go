@@ -0,0 +1,223 @@
+package nsigii
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ============================================================================
+// Error Recovery
+// ============================================================================
+
+// Diagnostic describes a problem found in a source
+type Diagnostic struct {
+	Offset    uint32 // Byte offset of the problem
+	Length    uint32 // Length of the offending span in bytes
+	Line      int    // 1-based line (0 if not tracked)
+	Column    int    // 1-based byte column (0 if not tracked)
+	Message   string
+	Severity  Severity // SeverityError unless a validator says otherwise
+	Validator string   // Name of the validator reporting it, "" for the lexer and parser
+	Fixes     []Fix    // Suggested corrections, best first
+}
+
+func (d Diagnostic) String() string {
+	if d.Line > 0 {
+		return fmt.Sprintf("%d:%d: %s", d.Line, d.Column, d.Message)
+	}
+	return fmt.Sprintf("offset %d: %s", d.Offset, d.Message)
+}
+
+// Fix is a suggested correction for a Diagnostic: a set of edits to the
+// source the diagnostic was reported for
+type Fix struct {
+	Message string // What the fix does, e.g. `insert ";"`
+	Edits   []Edit // Non-overlapping, with offsets into the unedited source
+}
+
+// Apply returns source with the fix's edits made
+//
+// Example:
+//   if len(d.Fixes) > 0 {
+//       source, err = d.Fixes[0].Apply(source)
+//   }
+func (f Fix) Apply(source string) (string, error) {
+	edits := append([]Edit(nil), f.Edits...)
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].Offset < edits[j].Offset
+	})
+
+	var b strings.Builder
+	at := 0
+	for _, e := range edits {
+		if e.Offset < at || e.Deleted < 0 || e.Offset+e.Deleted > len(source) {
+			return "", fmt.Errorf("fix %q: edit out of range or overlapping: offset=%d deleted=%d",
+				f.Message, e.Offset, e.Deleted)
+		}
+		b.WriteString(source[at:e.Offset])
+		b.WriteString(e.Inserted)
+		at = e.Offset + e.Deleted
+	}
+	b.WriteString(source[at:])
+	return b.String(), nil
+}
+
+// TokenizeRecover tokenizes source, recovering from lexer failures instead
+// of giving up
+//
+// If the lexer rejects the source, it is re-scanned line by line. Within a
+// failing line, the first whitespace-separated word that makes the lexer
+// fail is emitted as a TokenError with a matching Diagnostic, and scanning
+// resumes after it. Tokens spanning lines are split when recovery kicks in.
+//
+// The returned error is reserved for failures recovery cannot work around
+// (closed context, token limit).
+func (c *Context) TokenizeRecover(source string) ([]Token, []Diagnostic, error) {
+	if err := c.usable(); err != nil {
+		return nil, nil, err
+	}
+
+	tokens, err := c.tokenize(source)
+	if err == nil || errors.Is(err, ErrTokenBufferFull) {
+		return tokens, nil, err
+	}
+
+	r := recovery{c: c}
+	for start := 0; start < len(source); {
+		end := lineEnd(source, start)
+		if err := r.segment(source[start:end], start); err != nil {
+			return nil, nil, err
+		}
+		start = end
+	}
+
+	r.tokens = append(r.tokens, Token{
+		Type:      TokenEOF,
+		Memory:    uint32(len(source)),
+		EndOffset: uint32(len(source)),
+		Text:      "<EOF>",
+	})
+
+	if c.maxTokens > 0 && len(r.tokens) > c.maxTokens {
+		return nil, nil, fmt.Errorf("%w: %d", ErrTokenBufferFull, c.maxTokens)
+	}
+
+	// Segments were scanned in isolation, so positions are recomputed
+	// against the whole source
+	if !c.noPos || c.runes {
+		pos := positionTracker{source: source}
+		for i := range r.tokens {
+			t := &r.tokens[i]
+			line, column := pos.position(int(t.Memory))
+			if !c.noPos {
+				t.Line, t.Column = line, column
+			}
+			if c.runes {
+				t.RuneOffset = uint32(pos.runes)
+				t.RuneLength = uint32(utf8.RuneCountInString(t.Lexeme()))
+				if t.Type == TokenEOF {
+					t.RuneLength = 0
+				}
+			}
+		}
+	}
+	if !c.noPos {
+		pos := positionTracker{source: source}
+		for i := range r.diagnostics {
+			r.diagnostics[i].Line, r.diagnostics[i].Column = pos.position(int(r.diagnostics[i].Offset))
+		}
+	}
+
+	return r.tokens, r.diagnostics, nil
+}
+
+// recovery accumulates the output of a recovering scan
+type recovery struct {
+	c           *Context
+	tokens      []Token
+	diagnostics []Diagnostic
+}
+
+// segment scans seg, located at base in the source, isolating any words
+// the lexer rejects
+func (r *recovery) segment(seg string, base int) error {
+	for len(seg) > 0 {
+		tokens, err := r.c.tokenize(seg)
+		if err == nil {
+			r.emit(tokens, base)
+			return nil
+		}
+		if errors.Is(err, ErrTokenBufferFull) {
+			return err
+		}
+
+		// Find the longest prefix ending on a word boundary that scans
+		good, goodTokens := 0, []Token(nil)
+		wordStart, wordEnd := 0, len(seg)
+		for _, w := range words(seg) {
+			prefix, perr := r.c.tokenize(seg[:w[1]])
+			if perr != nil {
+				wordStart, wordEnd, err = w[0], w[1], perr
+				break
+			}
+			good, goodTokens = w[1], prefix
+		}
+
+		r.emit(goodTokens, base)
+		if wordStart < good {
+			wordStart = good
+		}
+
+		r.tokens = append(r.tokens, Token{
+			Type:      TokenError,
+			Memory:    uint32(base + wordStart),
+			Value:     uint32(wordEnd - wordStart),
+			Text:      seg[wordStart:wordEnd],
+			EndOffset: uint32(base + wordEnd),
+		})
+		r.diagnostics = append(r.diagnostics, Diagnostic{
+			Offset:  uint32(base + wordStart),
+			Length:  uint32(wordEnd - wordStart),
+			Message: err.Error(),
+		})
+
+		seg = seg[wordEnd:]
+		base += wordEnd
+	}
+
+	return nil
+}
+
+// emit appends tokens scanned from a segment at base, dropping the
+// segment's EOF token
+func (r *recovery) emit(tokens []Token, base int) {
+	for _, t := range tokens {
+		if t.Type == TokenEOF {
+			continue
+		}
+		t.materialize()
+		t.Memory += uint32(base)
+		t.EndOffset += uint32(base)
+		r.tokens = append(r.tokens, t)
+	}
+}
+
+// words returns the [start, end) offsets of the whitespace-separated words
+// in s
+func words(s string) [][2]int {
+	var spans [][2]int
+	start := -1
+	for i := 0; i <= len(s); i++ {
+		space := i == len(s) || strings.IndexByte(" \t\r\n\v\f", s[i]) >= 0
+		if space && start >= 0 {
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		} else if !space && start < 0 {
+			start = i
+		}
+	}
+	return spans
+}
@@ -0,0 +1,159 @@
+package nsigii
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Context Registry
+// ============================================================================
+
+// DefaultRegistry is a process-wide registry for callers that want one.
+// Contexts are only tracked if created with WithRegistry.
+var DefaultRegistry = NewRegistry()
+
+// ContextInfo describes a live context tracked by a Registry
+type ContextInfo struct {
+	ID         uint64 // Assigned by the registry, in creation order
+	Schema     string // obinexus.[operation].[service]
+	Created    time.Time
+	Age        time.Duration
+	ColorState ColorChannel
+	Polarity   Polarity
+	Stats      ContextStats
+}
+
+// Registry tracks live contexts for debugging and administrative tooling.
+// A registered context stays reachable until it is closed, so its finalizer
+// never runs: a leaked context shows up in Contexts, growing older, rather
+// than being cleaned up silently.
+//
+// Example:
+//   ctx, err := nsigii.NewContext("tokenize", "lexer", nsigii.WithRegistry(nsigii.DefaultRegistry))
+//   ...
+//   for _, info := range nsigii.DefaultRegistry.Contexts() {
+//       if info.Age > time.Hour {
+//           log.Printf("context %d (%s) open for %v", info.ID, info.Schema, info.Age)
+//       }
+//   }
+type Registry struct {
+	mu      sync.Mutex
+	next    uint64
+	entries map[*Context]registryEntry
+}
+
+type registryEntry struct {
+	id      uint64
+	created time.Time
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{entries: make(map[*Context]registryEntry)}
+}
+
+// WithRegistry tracks the context in r until it is closed. Contexts forked
+// from it, such as clones and TokenizeParallel workers, are tracked too.
+func WithRegistry(r *Registry) Option {
+	return func(c *Context) error {
+		r.add(c)
+		return nil
+	}
+}
+
+// add starts tracking c
+func (r *Registry) add(c *Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.entries[c]; ok {
+		return
+	}
+	r.next++
+	r.entries[c] = registryEntry{id: r.next, created: time.Now()}
+	c.registry = r
+}
+
+// remove stops tracking c
+func (r *Registry) remove(c *Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.entries, c)
+}
+
+// Len returns the number of live contexts tracked
+func (r *Registry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.entries)
+}
+
+// Contexts describes every live context tracked, ordered by ID
+func (r *Registry) Contexts() []ContextInfo {
+	r.mu.Lock()
+	live := make(map[*Context]registryEntry, len(r.entries))
+	for c, e := range r.entries {
+		live[c] = e
+	}
+	r.mu.Unlock()
+
+	// Describe outside the lock; a context closed meanwhile is still listed
+	now := time.Now()
+	infos := make([]ContextInfo, 0, len(live))
+	for c, e := range live {
+		infos = append(infos, ContextInfo{
+			ID:         e.id,
+			Schema:     c.schema(),
+			Created:    e.created,
+			Age:        now.Sub(e.created),
+			ColorState: c.ColorState(),
+			Polarity:   c.Polarity(),
+			Stats:      c.Stats(),
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ID < infos[j].ID
+	})
+	return infos
+}
+
+// Lookup returns the live context with the given ID
+func (r *Registry) Lookup(id uint64) (*Context, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for c, e := range r.entries {
+		if e.id == id {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// Close force-closes the live context with the given ID
+func (r *Registry) Close(id uint64) error {
+	c, ok := r.Lookup(id)
+	if !ok {
+		return fmt.Errorf("no live context with ID %d", id)
+	}
+	return c.Close()
+}
+
+// CloseAll force-closes every live context tracked and returns how many
+// were closed
+func (r *Registry) CloseAll() int {
+	r.mu.Lock()
+	live := make([]*Context, 0, len(r.entries))
+	for c := range r.entries {
+		live = append(live, c)
+	}
+	r.mu.Unlock()
+
+	for _, c := range live {
+		c.Close()
+	}
+	return len(live)
+}
@@ -0,0 +1,65 @@
+package nsigii
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ============================================================================
+// Roles
+// ============================================================================
+
+// ErrForbidden is returned by methods the context's role does not allow
+var ErrForbidden = errors.New("forbidden by the context's role")
+
+// Role restricts which methods of a context succeed, for services shared
+// between teams. Each role allows everything the roles below it do.
+type Role int
+
+const (
+	RoleAdmin    Role = 0 // Everything, including reinstatement and security settings; the default
+	RoleOperator Role = 1 // AUX, color transitions, polarity flips, signing and identity
+	RoleVerifier Role = 2 // RGB consensus and contrast checks
+	RoleReader   Role = 3 // Tokenization, parsing and inspection only
+)
+
+var roleNames = []string{"ADMIN", "OPERATOR", "VERIFIER", "READER"}
+
+func (r Role) String() string {
+	if r >= 0 && int(r) < len(roleNames) {
+		return roleNames[r]
+	}
+	return "UNKNOWN"
+}
+
+// WithRole creates the context with a role. A context's role is fixed for
+// its lifetime and carried over to forks, clones and pooled contexts made
+// from it.
+//
+// Example:
+//   ctx, err := nsigii.NewContext("tokenize", "lexer", nsigii.WithRole(nsigii.RoleReader))
+//   ...
+//   _, err = ctx.VerifyRGBConsensus() // errors.Is(err, nsigii.ErrForbidden)
+func WithRole(r Role) Option {
+	return func(c *Context) error {
+		if r < RoleAdmin || r > RoleReader {
+			return fmt.Errorf("invalid role %d", int(r))
+		}
+		c.role = r
+		return nil
+	}
+}
+
+// Role returns the context's role
+func (c *Context) Role() Role {
+	return c.role
+}
+
+// require fails with ErrForbidden unless the context's role allows what
+// role need does
+func (c *Context) require(need Role, method string) error {
+	if c.role <= need {
+		return nil
+	}
+	return fmt.Errorf("%w: %s requires role %s, context has %s", ErrForbidden, method, need, c.role)
+}
@@ -0,0 +1,105 @@
+package nsigii
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReaderCannotChangeSecuritySettings(t *testing.T) {
+	ctx, err := NewContext("tokenize", "lexer", WithRole(RoleReader))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+
+	admin, err := NewContext("tokenize", "lexer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+	id, err := admin.PhantomID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := admin.EncryptEnvelope([]byte("secret"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setters := map[string]func() error{
+		"SetPolicy":           func() error { return ctx.SetPolicy(nil) },
+		"SetCaller":           func() error { return ctx.SetCaller(id) },
+		"SetPhantomKey":       func() error { return ctx.SetPhantomKey([]byte("attacker")) },
+		"SetKeyRing":          func() error { return ctx.SetKeyRing(nil) },
+		"SetAuditLog":         func() error { return ctx.SetAuditLog(nil) },
+		"SetAuditSink":        func() error { return ctx.SetAuditSink(nil) },
+		"SetAlertSink":        func() error { return ctx.SetAlertSink(nil) },
+		"SetEscalationPolicy": func() error { return ctx.SetEscalationPolicy(EscalationPolicy{}) },
+		"UseCapability":       func() error { return ctx.UseCapability(Capability{}) },
+		"SetStampOrigin":      func() error { return ctx.SetStampOrigin(true) },
+		"SetAuditCapacity":    func() error { return ctx.SetAuditCapacity(1) },
+		"SetEntropySource":    func() error { return ctx.SetEntropySource(NewSeededEntropy(1)) },
+		"SetRotationPolicy":   func() error { return ctx.SetRotationPolicy(RotationPolicy{MaxUses: 1}) },
+		"SetWorkloadIdentity": func() error { return ctx.SetWorkloadIdentity("spiffe://example.org/admin") },
+		"GeneratePhantomID": func() error {
+			_, err := GeneratePhantomID(ctx, "spiffe://example.org/admin")
+			return err
+		},
+		"StreamEnvelope.Append": func() error {
+			return NewStreamEnvelope(NewTokenStream("tokenize.lexer", nil)).Append(ctx, StageTokenize, "")
+		},
+		"EncryptEnvelope": func() error {
+			_, err := ctx.EncryptEnvelope([]byte("x"), nil)
+			return err
+		},
+		"DecryptEnvelope": func() error {
+			_, err := ctx.DecryptEnvelope(env, nil)
+			return err
+		},
+	}
+	for name, set := range setters {
+		if err := set(); !errors.Is(err, ErrForbidden) {
+			t.Errorf("%s on a reader = %v, want ErrForbidden", name, err)
+		}
+	}
+	if ctx.caller != nil || ctx.phantomKey != nil || ctx.stampOrigin {
+		t.Error("forbidden setter changed the context")
+	}
+	if ctx.WorkloadIdentity() != "lexer" || ctx.RotationPolicy() != (RotationPolicy{}) || ctx.EntropySource() != CryptoEntropy() {
+		t.Error("forbidden identity or entropy setter changed the context")
+	}
+
+	// The same calls succeed for an admin
+	if err := admin.SetCaller(id); err != nil {
+		t.Errorf("SetCaller on an admin = %v", err)
+	}
+	if err := admin.SetStampOrigin(true); err != nil {
+		t.Errorf("SetStampOrigin on an admin = %v", err)
+	}
+}
+
+func TestReaderOptionsForbidden(t *testing.T) {
+	_, err := NewContext("tokenize", "lexer", WithRole(RoleReader), WithPolicy(nil))
+	if !errors.Is(err, ErrForbidden) {
+		t.Fatalf("NewContext(reader, WithPolicy) = %v, want ErrForbidden", err)
+	}
+}
+
+func TestVerifierKeepsOwnIdentity(t *testing.T) {
+	ctx, err := NewContext("tokenize", "lexer", WithRole(RoleVerifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+
+	// The context still issues and checks its own IDs
+	if _, err := ctx.PhantomID(); err != nil {
+		t.Errorf("PhantomID on a verifier = %v", err)
+	}
+	if err := ctx.VerifyContrast(); err != nil {
+		t.Errorf("VerifyContrast on a verifier = %v", err)
+	}
+	if _, err := GeneratePhantomID(ctx, "worker"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("GeneratePhantomID on a verifier = %v, want ErrForbidden", err)
+	}
+}
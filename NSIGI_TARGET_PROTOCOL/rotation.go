@@ -0,0 +1,194 @@
+package nsigii
+
+import (
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Phantom ID Rotation
+// ============================================================================
+
+// RotationPolicy bounds the lifetime of a context's own phantom ID. Zero
+// fields impose no limit.
+type RotationPolicy struct {
+	MaxAge  time.Duration // Reissue once the ID is older than this
+	MaxUses int           // Reissue after the ID has been handed out this often
+}
+
+// RotationReason says why a phantom ID was reissued
+type RotationReason int
+
+const (
+	RotationInitial   RotationReason = 0 // First ID for the context
+	RotationExpired   RotationReason = 1 // MaxAge exceeded
+	RotationExhausted RotationReason = 2 // MaxUses reached
+	RotationForced    RotationReason = 3 // Context.RotatePhantomID called
+)
+
+var rotationReasonNames = []string{"INITIAL", "EXPIRED", "EXHAUSTED", "FORCED"}
+
+func (r RotationReason) String() string {
+	if r >= 0 && int(r) < len(rotationReasonNames) {
+		return rotationReasonNames[r]
+	}
+	return "UNKNOWN"
+}
+
+// Rotation describes a reissued phantom ID. Old is zero for the context's
+// first ID.
+type Rotation struct {
+	Old    PhantomID
+	New    PhantomID
+	Reason RotationReason
+}
+
+// identity is a context's own rotating phantom ID
+type identity struct {
+	mu     sync.Mutex
+	policy RotationPolicy
+	id     PhantomID
+	uses   int
+	hooks  []func(Rotation)
+	subj   string // subject of issued IDs; "" means the service
+}
+
+// SetRotationPolicy sets how long the context's own phantom ID stays valid
+// before PhantomID reissues it
+func (c *Context) SetRotationPolicy(policy RotationPolicy) error {
+	if err := c.require(RoleOperator, "SetRotationPolicy"); err != nil {
+		return err
+	}
+	c.identity.mu.Lock()
+	defer c.identity.mu.Unlock()
+	c.identity.policy = policy
+	return nil
+}
+
+// RotationPolicy returns the context's rotation policy
+func (c *Context) RotationPolicy() RotationPolicy {
+	c.identity.mu.Lock()
+	defer c.identity.mu.Unlock()
+	return c.identity.policy
+}
+
+// SetWorkloadIdentity sets the subject the context's own phantom IDs are
+// minted for, such as a SPIFFE ID, so the workload's identity can be read
+// back from any ID it hands out. "" restores the default, the context's
+// service. The change applies from the next rotation; call RotatePhantomID
+// to apply it at once.
+func (c *Context) SetWorkloadIdentity(subject string) error {
+	if err := c.require(RoleOperator, "SetWorkloadIdentity"); err != nil {
+		return err
+	}
+	c.identity.mu.Lock()
+	defer c.identity.mu.Unlock()
+	c.identity.subj = subject
+	return nil
+}
+
+// WithWorkloadIdentity sets the context's workload identity; see
+// SetWorkloadIdentity
+func WithWorkloadIdentity(subject string) Option {
+	return func(c *Context) error {
+		return c.SetWorkloadIdentity(subject)
+	}
+}
+
+// WorkloadIdentity returns the subject the context's phantom IDs are minted
+// for
+func (c *Context) WorkloadIdentity() string {
+	c.identity.mu.Lock()
+	defer c.identity.mu.Unlock()
+	if c.identity.subj != "" {
+		return c.identity.subj
+	}
+	return c.service
+}
+
+// OnRotate registers fn to be called whenever the context issues a new
+// phantom ID, including the first. fn runs synchronously on the goroutine
+// that triggered the rotation and must not call back into the context's
+// phantom ID methods.
+//
+// Example:
+//   ctx.OnRotate(func(r nsigii.Rotation) {
+//       registry.Replace(r.Old, r.New)
+//   })
+func (c *Context) OnRotate(fn func(Rotation)) {
+	c.identity.mu.Lock()
+	defer c.identity.mu.Unlock()
+	c.identity.hooks = append(c.identity.hooks, fn)
+}
+
+// CurrentPhantomID returns the context's own phantom ID as last issued,
+// without counting a use or rotating it; false if none has been issued yet
+func (c *Context) CurrentPhantomID() (PhantomID, bool) {
+	c.identity.mu.Lock()
+	defer c.identity.mu.Unlock()
+	return c.identity.id, !c.identity.id.IsZero()
+}
+
+// PhantomID returns the context's own phantom ID, minted for the context's
+// workload identity (by default its service). The ID is reissued first if the rotation policy says it is stale;
+// each call counts as one use.
+func (c *Context) PhantomID() (PhantomID, error) {
+	c.identity.mu.Lock()
+	defer c.identity.mu.Unlock()
+
+	var reason RotationReason
+	p := c.identity.policy
+	switch {
+	case c.identity.id.IsZero():
+		reason = RotationInitial
+	case p.MaxAge > 0 && time.Since(c.identity.id.IssuedAt()) >= p.MaxAge:
+		reason = RotationExpired
+	case p.MaxUses > 0 && c.identity.uses >= p.MaxUses:
+		reason = RotationExhausted
+	default:
+		c.identity.uses++
+		return c.identity.id, nil
+	}
+
+	if err := c.rotate(reason); err != nil {
+		return PhantomID{}, err
+	}
+	c.identity.uses++
+	return c.identity.id, nil
+}
+
+// RotatePhantomID reissues the context's own phantom ID immediately
+func (c *Context) RotatePhantomID() (PhantomID, error) {
+	if err := c.require(RoleOperator, "RotatePhantomID"); err != nil {
+		return PhantomID{}, err
+	}
+	c.identity.mu.Lock()
+	defer c.identity.mu.Unlock()
+
+	if err := c.rotate(RotationForced); err != nil {
+		return PhantomID{}, err
+	}
+	return c.identity.id, nil
+}
+
+// rotate mints a new identity and notifies hooks. The caller holds
+// c.identity.mu.
+func (c *Context) rotate(reason RotationReason) error {
+	subject := c.identity.subj
+	if subject == "" {
+		subject = c.service
+	}
+	id, err := mintPhantomID(c, subject)
+	if err != nil {
+		return err
+	}
+
+	r := Rotation{Old: c.identity.id, New: id, Reason: reason}
+	c.identity.id = id
+	c.identity.uses = 0
+
+	for _, fn := range c.identity.hooks {
+		fn(r)
+	}
+	return nil
+}
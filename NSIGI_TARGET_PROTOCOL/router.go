@@ -0,0 +1,53 @@
+package nsigii
+
+import "fmt"
+
+// ============================================================================
+// Polarity Routing
+// ============================================================================
+
+// RouteHandler receives a payload routed from the context src
+type RouteHandler[T any] func(src *Context, payload T) error
+
+// Router dispatches payloads, such as token streams, to a handler chosen by
+// the polarity of the context that emitted them: positive flows go to the
+// primary pipeline, negative flows to quarantine and neutral flows to
+// review.
+//
+// Example:
+//   r := nsigii.Router[*nsigii.TokenStream]{
+//       Positive: pipeline.Process,
+//       Negative: quarantine.Hold,
+//       Neutral:  review.Enqueue,
+//   }
+//   stream, _ := ctx.TokenizeStream(source)
+//   err := r.Route(ctx, stream)
+type Router[T any] struct {
+	Positive RouteHandler[T] // Primary pipeline
+	Negative RouteHandler[T] // Quarantine
+	Neutral  RouteHandler[T] // Review queue
+}
+
+// Route hands payload to the handler for src's current polarity. Closed and
+// terminated contexts are refused rather than routed.
+func (r *Router[T]) Route(src *Context, payload T) error {
+	if err := src.usable(); err != nil {
+		return err
+	}
+
+	p := src.Polarity()
+	var h RouteHandler[T]
+	switch p {
+	case PolarityPositive:
+		h = r.Positive
+	case PolarityNegative:
+		h = r.Negative
+	case PolarityNeutral:
+		h = r.Neutral
+	}
+	if h == nil {
+		return fmt.Errorf("no route for %s polarity", p)
+	}
+
+	return h(src, payload)
+}
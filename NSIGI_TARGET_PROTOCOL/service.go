@@ -0,0 +1,236 @@
+package nsigii
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ============================================================================
+// Tokenizer Service
+// ============================================================================
+
+// ErrServiceClosed is the error of sources submitted after a
+// TokenizerService began shutting down
+var ErrServiceClosed = errors.New("tokenizer service is closed")
+
+// ServiceOptions configures a TokenizerService
+type ServiceOptions struct {
+	Workers   int    // Worker goroutines, each with its own context; 0 means GOMAXPROCS
+	QueueSize int    // Sources queued ahead of the workers; 0 means Workers
+	Operation string // Schema operation; "" means "tokenize"
+	Service   string // Schema service; "" means "service"
+
+	// Setup configures the first worker's context. The other workers'
+	// contexts copy its settings instead of rerunning it.
+	Setup func(c *Context) error
+}
+
+// Result is the outcome of a source submitted to a TokenizerService
+type Result struct {
+	Tokens []Token
+	Err    error
+	Worker int // Index of the worker that tokenized the source, -1 if none did
+}
+
+// WorkerStats summarizes one TokenizerService worker's workload
+type WorkerStats struct {
+	Jobs    int64         // Sources tokenized, successfully or not
+	Errors  int64         // Sources that failed
+	Busy    time.Duration // Time spent tokenizing
+	Context ContextStats  // The worker context's own statistics
+}
+
+// TokenizerService tokenizes submitted sources on a fixed set of workers,
+// each owning a goroutine and a context, so applications fanning work out
+// across contexts need not build the pooling themselves. Submissions queue
+// until a worker is free; once the queue is full, Submit blocks, which
+// pushes back on producers faster than the workers.
+//
+// Example:
+//   svc, err := nsigii.NewTokenizerService(nsigii.ServiceOptions{Workers: 4})
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   defer svc.Close()
+//
+//   results := make([]<-chan nsigii.Result, len(sources))
+//   for i, source := range sources {
+//       results[i] = svc.Submit(source)
+//   }
+//   for _, ch := range results {
+//       r := <-ch
+//       if r.Err != nil {
+//           log.Fatal(r.Err)
+//       }
+//       fmt.Println(len(r.Tokens))
+//   }
+type TokenizerService struct {
+	jobs    chan serviceJob
+	workers []*serviceWorker
+	wg      sync.WaitGroup
+	done    chan struct{} // closed once every worker has exited
+
+	mu     sync.RWMutex // guards closed against sends on jobs
+	closed bool
+}
+
+// serviceJob is a queued source and where its result goes
+type serviceJob struct {
+	ctx    context.Context
+	source string
+	result chan Result
+}
+
+// serviceWorker is one worker's context and counters
+type serviceWorker struct {
+	ctx    *Context
+	jobs   atomic.Int64
+	errors atomic.Int64
+	busy   atomic.Int64 // nanoseconds
+}
+
+// NewTokenizerService creates the workers' contexts and starts them
+func NewTokenizerService(opts ServiceOptions) (*TokenizerService, error) {
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.GOMAXPROCS(0)
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = opts.Workers
+	}
+	if opts.Operation == "" {
+		opts.Operation = "tokenize"
+	}
+	if opts.Service == "" {
+		opts.Service = "service"
+	}
+
+	first, err := NewContext(opts.Operation, opts.Service)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Setup != nil {
+		if err := opts.Setup(first); err != nil {
+			first.Close()
+			return nil, err
+		}
+	}
+
+	s := &TokenizerService{
+		jobs:    make(chan serviceJob, opts.QueueSize),
+		workers: []*serviceWorker{{ctx: first}},
+		done:    make(chan struct{}),
+	}
+	for len(s.workers) < opts.Workers {
+		c, err := first.fork()
+		if err != nil {
+			for _, w := range s.workers {
+				w.ctx.Close()
+			}
+			return nil, err
+		}
+		s.workers = append(s.workers, &serviceWorker{ctx: c})
+	}
+
+	for i, w := range s.workers {
+		s.wg.Add(1)
+		go s.run(i, w)
+	}
+	go func() {
+		s.wg.Wait()
+		close(s.done)
+	}()
+	return s, nil
+}
+
+// Submit queues source for tokenization and returns the channel its Result
+// will be sent on. It blocks while the queue is full. After shutdown has
+// begun, the Result carries ErrServiceClosed.
+func (s *TokenizerService) Submit(source string) <-chan Result {
+	return s.SubmitContext(context.Background(), source)
+}
+
+// SubmitContext is Submit, but gives up waiting for room in the queue when
+// ctx is done, and skips the source if ctx is done by the time a worker
+// takes it. Either way the Result carries ctx.Err().
+func (s *TokenizerService) SubmitContext(ctx context.Context, source string) <-chan Result {
+	job := serviceJob{ctx: ctx, source: source, result: make(chan Result, 1)}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		job.result <- Result{Err: ErrServiceClosed, Worker: -1}
+		return job.result
+	}
+	select {
+	case s.jobs <- job:
+	case <-ctx.Done():
+		job.result <- Result{Err: ctx.Err(), Worker: -1}
+	}
+	return job.result
+}
+
+// run takes jobs until the queue is closed and drained, then closes the
+// worker's context
+func (s *TokenizerService) run(index int, w *serviceWorker) {
+	defer s.wg.Done()
+	defer w.ctx.Close()
+
+	for job := range s.jobs {
+		if err := job.ctx.Err(); err != nil {
+			job.result <- Result{Err: err, Worker: -1}
+			continue
+		}
+
+		start := time.Now()
+		tokens, err := w.ctx.Tokenize(job.source)
+		w.busy.Add(int64(time.Since(start)))
+		w.jobs.Add(1)
+		if err != nil {
+			w.errors.Add(1)
+		}
+		job.result <- Result{Tokens: tokens, Err: err, Worker: index}
+	}
+}
+
+// Stats returns each worker's statistics, indexed like Result.Worker
+func (s *TokenizerService) Stats() []WorkerStats {
+	stats := make([]WorkerStats, len(s.workers))
+	for i, w := range s.workers {
+		stats[i] = WorkerStats{
+			Jobs:    w.jobs.Load(),
+			Errors:  w.errors.Load(),
+			Busy:    time.Duration(w.busy.Load()),
+			Context: w.ctx.Stats(),
+		}
+	}
+	return stats
+}
+
+// Shutdown stops accepting sources and waits for the workers to finish the
+// queued ones and close their contexts. If ctx is done first, Shutdown
+// returns ctx.Err() and the workers finish in the background.
+func (s *TokenizerService) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.jobs)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close shuts the service down, waiting as long as the queue takes
+func (s *TokenizerService) Close() error {
+	return s.Shutdown(context.Background())
+}
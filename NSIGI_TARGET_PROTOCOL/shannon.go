@@ -0,0 +1,178 @@
+package nsigii
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// ============================================================================
+// Shannon Entropy Analysis
+// ============================================================================
+
+// Default EntropyOptions values, after the thresholds common secret
+// scanners use
+const (
+	DefaultEntropyThreshold    = 4.5 // Bits per byte
+	DefaultHexEntropyThreshold = 3.0 // Bits per byte; hex digits carry at most 4
+	DefaultEntropyMinLength    = 20  // Bytes
+)
+
+// EntropyOptions configures Entropy and HighEntropyTokens
+type EntropyOptions struct {
+	Threshold    float64     // Bits per byte above which a token is flagged; 0 means DefaultEntropyThreshold
+	HexThreshold float64     // Threshold for tokens of hex digits only; 0 means DefaultHexEntropyThreshold
+	MinLength    int         // Shortest text inspected, in bytes; 0 means DefaultEntropyMinLength
+	Types        []TokenType // Types inspected; nil means TokenString and TokenIdentifier
+}
+
+// withDefaults returns opts with zero fields set to their defaults
+func (opts EntropyOptions) withDefaults() EntropyOptions {
+	if opts.Threshold <= 0 {
+		opts.Threshold = DefaultEntropyThreshold
+	}
+	if opts.HexThreshold <= 0 {
+		opts.HexThreshold = DefaultHexEntropyThreshold
+	}
+	if opts.MinLength <= 0 {
+		opts.MinLength = DefaultEntropyMinLength
+	}
+	if opts.Types == nil {
+		opts.Types = []TokenType{TokenString, TokenIdentifier}
+	}
+	return opts
+}
+
+// EntropyReport is the result of Entropy
+type EntropyReport struct {
+	TypeEntropy float64           // Bits per token of the token type distribution
+	TextEntropy float64           // Bits per token of the token text distribution
+	Suspicious  []SuspiciousToken // High-entropy tokens, in stream order
+}
+
+// SuspiciousToken is a token whose text is random enough to be a secret,
+// key or obfuscated payload
+type SuspiciousToken struct {
+	Token   Token
+	Entropy float64 // Bits per byte of the token's text, quotes excluded
+}
+
+// ShannonEntropy returns the Shannon entropy of s in bits per byte: 0 for
+// an empty or constant string, up to 8 for uniformly random bytes. AUX
+// noise from a healthy entropy source measures close to 8.
+func ShannonEntropy(s string) float64 {
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+
+	h := 0.0
+	for _, n := range counts {
+		if n > 0 {
+			p := float64(n) / float64(len(s))
+			h -= p * math.Log2(p)
+		}
+	}
+	return h
+}
+
+// Entropy measures how predictable a token stream is and flags the tokens
+// of opts.Types random enough to be embedded secrets or obfuscated
+// payloads. Ordinary source has a low text entropy relative to its token
+// count; generated or packed code pushes it up.
+//
+// Example:
+//   report := nsigii.Entropy(tokens, nsigii.EntropyOptions{})
+//   for _, s := range report.Suspicious {
+//       fmt.Printf("%d:%d: %.2f bits/byte\n", s.Token.Line, s.Token.Column, s.Entropy)
+//   }
+func Entropy(tokens []Token, opts EntropyOptions) EntropyReport {
+	opts = opts.withDefaults()
+
+	types := make(map[TokenType]int)
+	texts := make(map[string]int)
+	n := 0
+	var report EntropyReport
+	for _, t := range tokens {
+		if t.Type == TokenEOF {
+			continue
+		}
+		n++
+		types[t.Type]++
+		texts[t.Lexeme()]++
+
+		if h, ok := opts.suspicious(t); ok {
+			report.Suspicious = append(report.Suspicious, SuspiciousToken{Token: t, Entropy: h})
+		}
+	}
+
+	report.TypeEntropy = distributionEntropy(types, n)
+	report.TextEntropy = distributionEntropy(texts, n)
+	return report
+}
+
+// suspicious returns the entropy of t's text and whether it exceeds the
+// threshold for t. opts must have its defaults set.
+func (opts EntropyOptions) suspicious(t Token) (float64, bool) {
+	if !slices.Contains(opts.Types, t.Type) {
+		return 0, false
+	}
+	text := t.Lexeme()
+	if t.Type == TokenString {
+		text = strings.Trim(text, "\"'`")
+	}
+	if len(text) < opts.MinLength {
+		return 0, false
+	}
+
+	threshold := opts.Threshold
+	if isHex(text) {
+		threshold = opts.HexThreshold
+	}
+	h := ShannonEntropy(text)
+	return h, h > threshold
+}
+
+// distributionEntropy returns the entropy in bits of the distribution of
+// n samples over counts
+func distributionEntropy[K comparable](counts map[K]int, n int) float64 {
+	h := 0.0
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// isHex reports whether s consists of hex digits only
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i] | 0x20
+		if !isDigit(s[i]) && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// HighEntropyTokens reports tokens Entropy would flag as suspicious, as
+// warnings
+//
+// Example:
+//   ctx.SetValidators(append(nsigii.DefaultValidators(),
+//       nsigii.HighEntropyTokens(nsigii.EntropyOptions{}))...)
+func HighEntropyTokens(opts EntropyOptions) Validator {
+	opts = opts.withDefaults()
+
+	return ValidatorFunc{"high-entropy-tokens", func(u *Unit) []Diagnostic {
+		var diags []Diagnostic
+		for _, t := range u.Tokens {
+			if h, ok := opts.suspicious(t); ok {
+				msg := fmt.Sprintf("possible secret: %s with %.2f bits of entropy per byte", t.Type, h)
+				diags = append(diags, tokenDiagnostic(t, SeverityWarning, msg))
+			}
+		}
+		return diags
+	}}
+}
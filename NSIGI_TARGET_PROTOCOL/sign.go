@@ -0,0 +1,142 @@
+package nsigii
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ============================================================================
+// Stream Signatures
+// ============================================================================
+
+// Unlike the origin seal, which only holders of the phantom key can check,
+// a signature can be verified by any stage holding the producer's public
+// key, without being able to forge one.
+
+// SetSigningKey sets the Ed25519 key SignStream signs with. A nil key
+// restores the default: a key derived from the context's phantom key and
+// schema, so contexts sharing both sign alike.
+func (c *Context) SetSigningKey(key ed25519.PrivateKey) error {
+	if err := c.require(RoleAdmin, "SetSigningKey"); err != nil {
+		return err
+	}
+	if key != nil && len(key) != ed25519.PrivateKeySize {
+		return fmt.Errorf("invalid Ed25519 private key length: %d", len(key))
+	}
+	if key == nil {
+		c.signingKey = nil
+		return nil
+	}
+	c.signingKey = append([]byte(nil), key...)
+	return nil
+}
+
+// WithSigningKey sets the context's signing key; see SetSigningKey
+func WithSigningKey(key ed25519.PrivateKey) Option {
+	return func(c *Context) error {
+		return c.SetSigningKey(key)
+	}
+}
+
+// SigningPublicKey returns the public half of the context's signing key, to
+// hand to the stages that verify its streams
+func (c *Context) SigningPublicKey() ed25519.PublicKey {
+	return c.signer().Public().(ed25519.PublicKey)
+}
+
+// signer returns the context's signing key
+func (c *Context) signer() ed25519.PrivateKey {
+	if c.signingKey != nil {
+		return ed25519.PrivateKey(c.signingKey)
+	}
+	_, key := c.currentKey()
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("nsigii stream signing key\x00"))
+	mac.Write([]byte(c.schema()))
+	return ed25519.NewKeyFromSeed(mac.Sum(nil))
+}
+
+// SignStream signs the canonical encoding of s with the context's signing
+// key, setting s.Signer and s.Signature. Any change to the stream's
+// version, schema, origin or tokens afterwards invalidates the signature.
+// A closed or terminated context refuses to sign.
+//
+// Example:
+//   stream, err := ctx.TokenizeStream(source)
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   if err := nsigii.SignStream(ctx, stream); err != nil {
+//       log.Fatal(err)
+//   }
+//   // Downstream, with the producer's ctx.SigningPublicKey():
+//   ok, err := nsigii.VerifyStream(producerKey, stream)
+func SignStream(c *Context, s *TokenStream) error {
+	if err := c.usable(); err != nil {
+		return err
+	}
+	if err := c.require(RoleOperator, "SignStream"); err != nil {
+		return err
+	}
+
+	key := c.signer()
+	s.Signer = key.Public().(ed25519.PublicKey)
+	s.Signature = ed25519.Sign(key, s.CanonicalBytes())
+	return nil
+}
+
+// VerifyStream reports whether s carries a valid signature by pub. A
+// stream signed by another key fails; an unsigned stream is an error.
+func VerifyStream(pub ed25519.PublicKey, s *TokenStream) (bool, error) {
+	if s.Signature == nil {
+		return false, errors.New("token stream is not signed")
+	}
+	if len(pub) != ed25519.PublicKeySize {
+		return false, fmt.Errorf("invalid Ed25519 public key length: %d", len(pub))
+	}
+	if !bytes.Equal(s.Signer, pub) {
+		return false, nil
+	}
+	return ed25519.Verify(pub, s.CanonicalBytes(), s.Signature), nil
+}
+
+// CanonicalBytes returns the encoding of the stream that signatures cover:
+// its version, schema, origin, signer and every field of every token, each
+// length-prefixed or fixed-width, big-endian. Stats are derived from the
+// tokens and the seal and signature are left out.
+func (s *TokenStream) CanonicalBytes() []byte {
+	b := make([]byte, 0, 64+48*len(s.Tokens))
+	field := func(v []byte) {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(v)))
+		b = append(b, v...)
+	}
+
+	b = append(b, "NSIGII-STREAM\x00"...)
+	b = binary.BigEndian.AppendUint32(b, uint32(s.Version))
+	field([]byte(s.Schema))
+	if s.Origin != nil {
+		field([]byte(s.Origin.String()))
+	} else {
+		field(nil)
+	}
+	field(s.Signer)
+
+	b = binary.BigEndian.AppendUint64(b, uint64(len(s.Tokens)))
+	for _, t := range s.Tokens {
+		b = binary.BigEndian.AppendUint32(b, uint32(t.Type))
+		b = binary.BigEndian.AppendUint32(b, t.Memory)
+		b = binary.BigEndian.AppendUint32(b, t.Value)
+		b = binary.BigEndian.AppendUint32(b, t.EndOffset)
+		b = binary.BigEndian.AppendUint64(b, uint64(t.Line))
+		b = binary.BigEndian.AppendUint64(b, uint64(t.Column))
+		b = binary.BigEndian.AppendUint32(b, t.RuneOffset)
+		b = binary.BigEndian.AppendUint32(b, t.RuneLength)
+		field([]byte(t.Lexeme()))
+	}
+	return b
+}
@@ -0,0 +1,53 @@
+package nsigii
+
+import (
+	"crypto/ed25519"
+	"testing"
+)
+
+func TestSignStreamTampered(t *testing.T) {
+	ctx, err := NewContext("tokenize", "lexer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+
+	signed := func() *TokenStream {
+		s := NewTokenStream(ctx.schema(), merkleTokens(4))
+		if err := SignStream(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	pub := ctx.SigningPublicKey()
+	if ok, err := VerifyStream(pub, signed()); !ok || err != nil {
+		t.Fatalf("VerifyStream(untouched) = %t, %v", ok, err)
+	}
+
+	tamper := map[string]func(s *TokenStream){
+		"token text":   func(s *TokenStream) { s.Tokens[1].Text = "other" },
+		"token offset": func(s *TokenStream) { s.Tokens[2].Memory++ },
+		"token order":  func(s *TokenStream) { s.Tokens[0], s.Tokens[1] = s.Tokens[1], s.Tokens[0] },
+		"token count":  func(s *TokenStream) { s.Tokens = s.Tokens[:3] },
+		"schema":       func(s *TokenStream) { s.Schema = "obinexus.tokenize.other" },
+		"version":      func(s *TokenStream) { s.Version++ },
+	}
+	for name, f := range tamper {
+		s := signed()
+		f(s)
+		if ok, err := VerifyStream(pub, s); ok || err != nil {
+			t.Errorf("VerifyStream with a changed %s = %t, %v; want false", name, ok, err)
+		}
+	}
+
+	other, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := VerifyStream(other, signed()); ok {
+		t.Error("stream verifies under another key")
+	}
+	if _, err := VerifyStream(pub, NewTokenStream(ctx.schema(), nil)); err == nil {
+		t.Error("VerifyStream(unsigned) succeeded")
+	}
+}
@@ -0,0 +1,80 @@
+// Package kafka publishes sink messages to Apache Kafka
+//
+// Example:
+//   pub := kafka.NewPublisher(kafka.Config{Brokers: []string{"localhost:9092"}})
+//   s := sink.New(pub, sink.Options{})
+//   defer s.Close()
+package kafka
+
+import (
+	"context"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+
+	"github.com/obinexus/nsigii-rift/nsigii/sink"
+)
+
+// Config configures a Publisher
+type Config struct {
+	Brokers []string // Bootstrap broker addresses, host:port
+
+	// RequiredAcks is the number of replicas that must acknowledge a write;
+	// 0 means all in-sync replicas
+	RequiredAcks int
+
+	// BatchTimeout bounds how long messages wait to fill a batch; 0 means
+	// 10ms
+	BatchTimeout time.Duration
+
+	Transport kafkago.RoundTripper // nil means kafka-go's default transport
+}
+
+// Publisher publishes messages with a kafka-go Writer. Messages are
+// partitioned by a hash of their key.
+type Publisher struct {
+	w *kafkago.Writer
+}
+
+// NewPublisher creates a publisher for the brokers in cfg. No connection is
+// made until the first Publish.
+func NewPublisher(cfg Config) *Publisher {
+	acks := kafkago.RequireAll
+	if cfg.RequiredAcks > 0 {
+		acks = kafkago.RequiredAcks(cfg.RequiredAcks)
+	}
+	if cfg.BatchTimeout <= 0 {
+		cfg.BatchTimeout = 10 * time.Millisecond
+	}
+
+	return NewWriterPublisher(&kafkago.Writer{
+		Addr:         kafkago.TCP(cfg.Brokers...),
+		Balancer:     &kafkago.Hash{},
+		RequiredAcks: acks,
+		BatchTimeout: cfg.BatchTimeout,
+		Transport:    cfg.Transport,
+	})
+}
+
+// NewWriterPublisher creates a publisher using w, for settings Config does
+// not cover. w must not have a Topic set; each message names its own.
+func NewWriterPublisher(w *kafkago.Writer) *Publisher {
+	return &Publisher{w: w}
+}
+
+// Publish implements sink.Publisher
+func (p *Publisher) Publish(ctx context.Context, msgs ...sink.Message) error {
+	out := make([]kafkago.Message, len(msgs))
+	for i, m := range msgs {
+		out[i] = kafkago.Message{Topic: m.Topic, Key: m.Key, Value: m.Value}
+		for k, v := range m.Headers {
+			out[i].Headers = append(out[i].Headers, kafkago.Header{Key: k, Value: []byte(v)})
+		}
+	}
+	return p.w.WriteMessages(ctx, out...)
+}
+
+// Close flushes pending writes and closes the writer
+func (p *Publisher) Close() error {
+	return p.w.Close()
+}
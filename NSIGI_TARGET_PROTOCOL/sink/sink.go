@@ -0,0 +1,246 @@
+// Package sink publishes token streams and color audit entries to a message
+// broker, so later RIFT stages can consume them asynchronously. Brokers are
+// reached through the Publisher interface; package sink/kafka provides one
+// for Kafka.
+//
+// Token streams are published synchronously with PublishStream. Audit
+// entries arrive through nsigii.AuditSink, which must not block, so they are
+// queued and published in the background; if the queue is full they are
+// dropped and counted. Both are keyed by the producing context's schema, so
+// a broker that partitions by key keeps each schema's messages in order.
+//
+// Example:
+//   s := sink.New(kafka.NewPublisher(kafka.Config{Brokers: brokers}), sink.Options{})
+//   defer s.Close()
+//   if err := s.Attach(ctx); err != nil {
+//       log.Fatal(err)
+//   }
+//
+//   stream, err := ctx.TokenizeStream(source)
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   if err := s.PublishStream(context.Background(), stream); err != nil {
+//       log.Fatal(err)
+//   }
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/obinexus/nsigii-rift/nsigii"
+)
+
+// ============================================================================
+// Publishers
+// ============================================================================
+
+// Message is one message for a broker
+type Message struct {
+	Topic   string
+	Key     []byte
+	Value   []byte
+	Headers map[string]string
+}
+
+// Publisher delivers messages to a broker. Publish returns once the broker
+// has accepted the messages, or with the first error.
+type Publisher interface {
+	Publish(ctx context.Context, msgs ...Message) error
+	Close() error
+}
+
+// PublisherFunc adapts an ordinary function to a Publisher with nothing to
+// close
+type PublisherFunc func(ctx context.Context, msgs ...Message) error
+
+// Publish calls f(ctx, msgs...)
+func (f PublisherFunc) Publish(ctx context.Context, msgs ...Message) error {
+	return f(ctx, msgs...)
+}
+
+// Close does nothing
+func (PublisherFunc) Close() error {
+	return nil
+}
+
+// ============================================================================
+// Sink
+// ============================================================================
+
+// ErrClosed is returned by a closed Sink
+var ErrClosed = errors.New("sink is closed")
+
+// Options configures a Sink
+type Options struct {
+	StreamTopic string // Topic of token streams; "" means "nsigii.tokens"
+	AuditTopic  string // Topic of audit entries; "" means "nsigii.audit"
+
+	// Queue is the number of audit entries buffered for publishing; 0 means
+	// 1024
+	Queue int
+
+	// OnError is called from the background publisher with each failure to
+	// publish audit entries; nil ignores them
+	OnError func(error)
+}
+
+// AuditMessage is the JSON value of an audit entry message
+type AuditMessage struct {
+	Schema string `json:"schema"` // obinexus.[operation].[service] of the context
+	nsigii.AuditEntry
+}
+
+// Sink publishes token streams and audit entries
+type Sink struct {
+	pub  Publisher
+	opts Options
+
+	mu      sync.RWMutex // guards closed against sends on queue
+	closed  bool
+	queue   chan Message
+	done    chan struct{}
+	dropped atomic.Uint64
+}
+
+// New creates a sink publishing through pub. Close must be called to flush
+// queued audit entries.
+func New(pub Publisher, opts Options) *Sink {
+	if opts.StreamTopic == "" {
+		opts.StreamTopic = "nsigii.tokens"
+	}
+	if opts.AuditTopic == "" {
+		opts.AuditTopic = "nsigii.audit"
+	}
+	if opts.Queue <= 0 {
+		opts.Queue = 1024
+	}
+
+	s := &Sink{
+		pub:   pub,
+		opts:  opts,
+		queue: make(chan Message, opts.Queue),
+		done:  make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// PublishStream publishes a token stream as JSON, keyed by its schema
+func (s *Sink) PublishStream(ctx context.Context, stream *nsigii.TokenStream) error {
+	s.mu.RLock()
+	closed := s.closed
+	s.mu.RUnlock()
+	if closed {
+		return ErrClosed
+	}
+
+	value, err := json.Marshal(stream)
+	if err != nil {
+		return err
+	}
+	return s.pub.Publish(ctx, Message{
+		Topic:   s.opts.StreamTopic,
+		Key:     []byte(stream.Schema),
+		Value:   value,
+		Headers: map[string]string{"content-type": "application/json"},
+	})
+}
+
+// Attach sets c's audit sink to one queueing c's entries for publishing,
+// replacing any earlier audit sink
+func (s *Sink) Attach(c *nsigii.Context) error {
+	schema, err := c.Schema()
+	if err != nil {
+		return err
+	}
+	return c.SetAuditSink(s.auditSink(schema))
+}
+
+// auditSink returns an audit sink queueing entries of a context with schema
+func (s *Sink) auditSink(schema string) nsigii.AuditSink {
+	return nsigii.AuditSinkFunc(func(entry nsigii.AuditEntry) {
+		value, err := json.Marshal(AuditMessage{Schema: schema, AuditEntry: entry})
+		if err != nil {
+			s.fail(err)
+			return
+		}
+		s.enqueue(Message{
+			Topic:   s.opts.AuditTopic,
+			Key:     []byte(schema),
+			Value:   value,
+			Headers: map[string]string{"content-type": "application/json"},
+		})
+	})
+}
+
+// Dropped returns the number of audit entries dropped because the queue was
+// full or the sink closed
+func (s *Sink) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close publishes the queued audit entries and closes the publisher
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.queue)
+	s.mu.Unlock()
+
+	<-s.done
+	return s.pub.Close()
+}
+
+// enqueue queues msg without blocking
+func (s *Sink) enqueue(msg Message) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		s.dropped.Add(1)
+		return
+	}
+	select {
+	case s.queue <- msg:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// run publishes queued messages, batching whatever has accumulated
+func (s *Sink) run() {
+	defer close(s.done)
+
+	for msg := range s.queue {
+		batch := []Message{msg}
+	drain:
+		for len(batch) < cap(s.queue) {
+			select {
+			case m, ok := <-s.queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, m)
+			default:
+				break drain
+			}
+		}
+		if err := s.pub.Publish(context.Background(), batch...); err != nil {
+			s.fail(err)
+		}
+	}
+}
+
+// fail reports a background failure
+func (s *Sink) fail(err error) {
+	if s.opts.OnError != nil {
+		s.opts.OnError(err)
+	}
+}
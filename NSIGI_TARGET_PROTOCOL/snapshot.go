@@ -0,0 +1,126 @@
+package nsigii
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ============================================================================
+// Color State Snapshots
+// ============================================================================
+
+// colorSnapshotVersion is the current snapshot format version
+const colorSnapshotVersion = 1
+
+// colorSnapshot is the signed content of a color state snapshot
+type colorSnapshot struct {
+	Version int          `json:"version"`
+	Schema  string       `json:"schema"`
+	State   ColorChannel `json:"state"`
+	Time    time.Time    `json:"time"`
+	Origin  PhantomID    `json:"origin"` // identity of the snapshotting context
+}
+
+// signedSnapshot is the wire form of a snapshot
+type signedSnapshot struct {
+	Snapshot json.RawMessage `json:"snapshot"`
+	KeyID    KeyID           `json:"key_id,omitempty"` // key ring generation of the MAC
+	MAC      []byte          `json:"mac"`
+}
+
+// SnapshotColorState captures the context's color state, signed with its
+// current phantom key, so it can be restored after a restart or handed to
+// another worker along with a job
+//
+// Example:
+//   data, err := ctx.SnapshotColorState()
+//   // ... later, possibly elsewhere
+//   err = worker.RestoreColorState(data)
+func (c *Context) SnapshotColorState() ([]byte, error) {
+	schema, err := c.Schema()
+	if err != nil {
+		return nil, err
+	}
+	origin, err := c.PhantomID()
+	if err != nil {
+		return nil, err
+	}
+
+	snap, err := json.Marshal(colorSnapshot{
+		Version: colorSnapshotVersion,
+		Schema:  schema,
+		State:   c.ColorState(),
+		Time:    time.Now(),
+		Origin:  origin,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	keyID, key := c.currentKey()
+	return json.Marshal(signedSnapshot{Snapshot: snap, KeyID: keyID, MAC: snapshotMAC(key, snap)})
+}
+
+// RestoreColorState moves the context into the color state recorded by
+// SnapshotColorState. The snapshot must carry a valid signature under the
+// context's phantom key and come from a context it trusts (see
+// VerifyPhantomID). A terminated context must be reinstated before it can
+// restore.
+func (c *Context) RestoreColorState(data []byte) error {
+	if err := c.require(RoleAdmin, "RestoreColorState"); err != nil {
+		return err
+	}
+	schema, err := c.Schema()
+	if err != nil {
+		return err
+	}
+
+	var signed signedSnapshot
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return fmt.Errorf("invalid color snapshot: %w", err)
+	}
+	key, known := c.keyByID(signed.KeyID)
+	if !known || !hmac.Equal(signed.MAC, snapshotMAC(key, signed.Snapshot)) {
+		return errors.New("color snapshot signature mismatch")
+	}
+
+	var snap colorSnapshot
+	if err := json.Unmarshal(signed.Snapshot, &snap); err != nil {
+		return fmt.Errorf("invalid color snapshot: %w", err)
+	}
+	if snap.Version != colorSnapshotVersion {
+		return fmt.Errorf("unsupported color snapshot version: %d", snap.Version)
+	}
+	if snap.State == ColorContrast || snap.State.String() == "UNKNOWN" {
+		return fmt.Errorf("invalid color snapshot state: %s", snap.State)
+	}
+
+	ok, err := c.VerifyPhantomID(snap.Origin)
+	if err != nil {
+		return err
+	}
+	if !ok || snap.Origin.Schema() != snap.Schema || !compatibleSchemas(schema, snap.Schema) {
+		return errors.New("color snapshot from untrusted context")
+	}
+
+	current := c.ColorState()
+	if current == ColorBlack {
+		return ErrTerminated
+	}
+	if current == snap.State {
+		return nil
+	}
+	return c.setColor(current, snap.State, "restored from snapshot", true)
+}
+
+// snapshotMAC signs the encoded snapshot
+func snapshotMAC(key, snap []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte("nsigii color snapshot\x00"))
+	h.Write(snap)
+	return h.Sum(nil)
+}
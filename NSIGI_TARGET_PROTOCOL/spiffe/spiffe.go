@@ -0,0 +1,181 @@
+// Package spiffe gives NSIGII contexts the workload identity of a SPIFFE
+// mesh. An Attestor maps SPIFFE IDs to obinexus schemas, creates contexts
+// whose phantom IDs are minted for the workload's own SPIFFE ID, and binds
+// peer SPIFFE IDs into a nsigii.PeerRegistry for the mTLS checks of the
+// network servers.
+//
+// The default mapping takes a SPIFFE ID's last two path segments as the
+// schema's operation and service:
+//   spiffe://example.org/ns/prod/tokenize/lexer  ->  obinexus.tokenize.lexer
+//
+// Example:
+//   source, err := workloadapi.NewX509Source(ctx)
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   defer source.Close()
+//
+//   a, err := spiffe.New(spiffe.Options{Source: source, TrustDomain: td})
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   c, err := a.NewContext()
+//   // c.Schema() == "obinexus.tokenize.lexer"
+//   // c.WorkloadIdentity() == "spiffe://example.org/ns/prod/tokenize/lexer"
+package spiffe
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/spiffe/go-spiffe/v2/svid/x509svid"
+
+	"github.com/obinexus/nsigii-rift/nsigii"
+)
+
+// Mapper maps a SPIFFE ID to the operation and service of its schema
+type Mapper func(id spiffeid.ID) (operation, service string, err error)
+
+// PathMapper is the default Mapper: the last two segments of the ID's path
+// are the operation and service
+func PathMapper(id spiffeid.ID) (operation, service string, err error) {
+	segments := strings.Split(strings.Trim(id.Path(), "/"), "/")
+	if len(segments) < 2 || segments[len(segments)-2] == "" {
+		return "", "", fmt.Errorf("SPIFFE ID %s has no operation and service path segments", id)
+	}
+	return segments[len(segments)-2], segments[len(segments)-1], nil
+}
+
+// Options configures an Attestor
+type Options struct {
+	// Source supplies the workload's X.509 SVID, usually a
+	// *workloadapi.X509Source. It is only needed by NewContext.
+	Source x509svid.Source
+
+	// Mapper maps SPIFFE IDs to schemas; nil means PathMapper
+	Mapper Mapper
+
+	// TrustDomain, if set, rejects SPIFFE IDs from any other trust domain
+	TrustDomain spiffeid.TrustDomain
+}
+
+// Attestor maps SPIFFE workload identities to NSIGII identities
+type Attestor struct {
+	opts Options
+}
+
+// New creates an attestor
+func New(opts Options) (*Attestor, error) {
+	if opts.Mapper == nil {
+		opts.Mapper = PathMapper
+	}
+	return &Attestor{opts: opts}, nil
+}
+
+// Schema returns the obinexus schema id maps to
+func (a *Attestor) Schema(id spiffeid.ID) (string, error) {
+	operation, service, err := a.resolve(id)
+	if err != nil {
+		return "", err
+	}
+	return "obinexus." + operation + "." + service, nil
+}
+
+// NewContext creates a context for the workload's current SVID: its schema
+// is mapped from the SVID's SPIFFE ID, and its phantom IDs are minted for
+// that ID (see nsigii.Context.SetWorkloadIdentity). opts are applied after
+// the workload identity.
+func (a *Attestor) NewContext(opts ...nsigii.Option) (*nsigii.Context, error) {
+	if a.opts.Source == nil {
+		return nil, errors.New("spiffe: no X.509 SVID source")
+	}
+	svid, err := a.opts.Source.GetX509SVID()
+	if err != nil {
+		return nil, fmt.Errorf("spiffe: fetching X.509 SVID: %w", err)
+	}
+	operation, service, err := a.resolve(svid.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	opts = append([]nsigii.Option{nsigii.WithWorkloadIdentity(svid.ID.String())}, opts...)
+	return nsigii.NewContext(operation, service, opts...)
+}
+
+// Bind binds the peer workload id into reg, allowing it the schemas of its
+// mapped operation. The binding's phantom ID is minted by issuer for id, so
+// issuer must serve the same operation and share its phantom key with the
+// servers enforcing reg.
+//
+// Example:
+//   peer := spiffeid.RequireFromString("spiffe://example.org/billing/tokenize/client")
+//   if _, err := a.Bind(peers, issuer, peer); err != nil {
+//       log.Fatal(err)
+//   }
+func (a *Attestor) Bind(reg *nsigii.PeerRegistry, issuer *nsigii.Context, id spiffeid.ID) (nsigii.PeerBinding, error) {
+	operation, _, err := a.resolve(id)
+	if err != nil {
+		return nsigii.PeerBinding{}, err
+	}
+	pid, err := nsigii.GeneratePhantomID(issuer, id.String())
+	if err != nil {
+		return nsigii.PeerBinding{}, err
+	}
+
+	b := nsigii.PeerBinding{
+		Identity:     id.String(),
+		PhantomID:    pid,
+		SchemaPrefix: "obinexus." + operation,
+	}
+	if err := reg.Bind(b); err != nil {
+		return nsigii.PeerBinding{}, fmt.Errorf("spiffe: binding %s: %w", id, err)
+	}
+	return b, nil
+}
+
+// Verify checks that pid is accepted by c (see
+// nsigii.Context.VerifyPhantomID) and was minted for a SPIFFE ID this
+// attestor accepts and maps to pid's schema, and returns that SPIFFE ID. A
+// context serving any other schema cannot assert the workload's identity.
+func (a *Attestor) Verify(c *nsigii.Context, pid nsigii.PhantomID) (spiffeid.ID, error) {
+	ok, err := c.VerifyPhantomID(pid)
+	if err != nil {
+		return spiffeid.ID{}, err
+	}
+	if !ok {
+		return spiffeid.ID{}, errors.New("spiffe: phantom ID is not authentic")
+	}
+
+	id, err := spiffeid.FromString(pid.Subject())
+	if err != nil {
+		return spiffeid.ID{}, fmt.Errorf("spiffe: phantom ID subject is not a SPIFFE ID: %w", err)
+	}
+	schema, err := a.Schema(id)
+	if err != nil {
+		return spiffeid.ID{}, err
+	}
+	if pid.Schema() != schema {
+		return spiffeid.ID{}, fmt.Errorf("spiffe: %s maps to schema %s, phantom ID schema is %s", id, schema, pid.Schema())
+	}
+	return id, nil
+}
+
+// resolve checks id's trust domain and maps it to a schema
+func (a *Attestor) resolve(id spiffeid.ID) (operation, service string, err error) {
+	if id.IsZero() {
+		return "", "", errors.New("spiffe: zero SPIFFE ID")
+	}
+	if !a.opts.TrustDomain.IsZero() && !id.MemberOf(a.opts.TrustDomain) {
+		return "", "", fmt.Errorf("spiffe: %s is not in trust domain %s", id, a.opts.TrustDomain)
+	}
+	operation, service, err = a.opts.Mapper(id)
+	if err != nil {
+		return "", "", fmt.Errorf("spiffe: %w", err)
+	}
+	if operation == "" || service == "" || strings.Contains(operation, ".") || strings.Contains(service, ".") {
+		return "", "", fmt.Errorf("spiffe: %s maps to invalid schema obinexus.%s.%s", id, operation, service)
+	}
+	return operation, service, nil
+}
@@ -0,0 +1,49 @@
+package spiffe_test
+
+import (
+	"testing"
+
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+
+	"github.com/obinexus/nsigii-rift/nsigii"
+	"github.com/obinexus/nsigii-rift/nsigii/spiffe"
+)
+
+func TestVerifyRequiresMappedSchema(t *testing.T) {
+	a, err := spiffe.New(spiffe.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := nsigii.NewContext("tokenize", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer verifier.Close()
+
+	id := spiffeid.RequireFromString("spiffe://example.org/ns/prod/tokenize/lexer")
+	phantomID := func(service string) nsigii.PhantomID {
+		c, err := nsigii.NewContext("tokenize", service, nsigii.WithWorkloadIdentity(id.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		pid, err := c.PhantomID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pid
+	}
+
+	got, err := a.Verify(verifier, phantomID("lexer"))
+	if err != nil {
+		t.Fatalf("Verify(workload's own ID) = %v", err)
+	}
+	if got != id {
+		t.Errorf("Verify = %s, want %s", got, id)
+	}
+
+	// Another service of the same operation claims the workload's identity
+	if _, err := a.Verify(verifier, phantomID("parser")); err == nil {
+		t.Error("Verify accepted an ID whose schema the SPIFFE ID does not map to")
+	}
+}
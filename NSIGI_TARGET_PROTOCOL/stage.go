@@ -0,0 +1,260 @@
+package nsigii
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// RIFT Stage Pipeline
+// ============================================================================
+
+// StageID is the 3-bit identifier of a RIFT stage (000 through 111)
+type StageID uint8
+
+const (
+	StageTokenize StageID = 0b000 // Source to token triplets
+	StageParse    StageID = 0b001 // Tokens to AST
+	StageValidate StageID = 0b010 // Semantic checks over the AST
+	StageEmit     StageID = 0b011 // AST to IR
+
+	maxStageID StageID = 0b111
+)
+
+var stageNames = map[StageID]string{
+	StageTokenize: "tokenize",
+	StageParse:    "parse",
+	StageValidate: "validate",
+	StageEmit:     "emit",
+}
+
+// String returns the stage's 3-bit ID and name, e.g. "001 parse"
+func (id StageID) String() string {
+	if name, ok := stageNames[id]; ok {
+		return fmt.Sprintf("%03b %s", uint8(id), name)
+	}
+	return fmt.Sprintf("%03b", uint8(id))
+}
+
+// Unit is the compilation unit passed through a stage pipeline. Each stage
+// reads what earlier stages produced and fills in its own part.
+type Unit struct {
+	Source string   // Input to the pipeline
+	Tokens []Token  // Output of StageTokenize
+	AST    *Program // Output of StageParse
+
+	Diagnostics []Diagnostic // Output of StageValidate
+	IR          *IR          // Output of StageEmit
+
+	Envelope *StreamEnvelope // Custody of the tokens, if the pipeline records it
+}
+
+// Stage is one step of a RIFT pipeline
+type Stage interface {
+	ID() StageID
+	Run(c *Context, u *Unit) error
+}
+
+// StageFunc adapts a function to a Stage with the given ID
+type StageFunc struct {
+	StageID StageID
+	Func    func(c *Context, u *Unit) error
+}
+
+// ID returns s.StageID
+func (s StageFunc) ID() StageID {
+	return s.StageID
+}
+
+// Run calls s.Func(c, u)
+func (s StageFunc) Run(c *Context, u *Unit) error {
+	return s.Func(c, u)
+}
+
+var (
+	stagesMu sync.RWMutex
+	stages   = map[StageID]Stage{
+		StageTokenize: StageFunc{StageTokenize, runTokenizeStage},
+		StageParse:    StageFunc{StageParse, runParseStage},
+		StageValidate: StageFunc{StageValidate, runValidateStage},
+		StageEmit:     StageFunc{StageEmit, runEmitStage},
+	}
+)
+
+// RegisterStage makes s selectable by its ID, replacing any stage
+// registered under the same ID
+func RegisterStage(s Stage) error {
+	if s.ID() > maxStageID {
+		return fmt.Errorf("stage ID %d is not a 3-bit value", s.ID())
+	}
+
+	stagesMu.Lock()
+	defer stagesMu.Unlock()
+	stages[s.ID()] = s
+	return nil
+}
+
+// lookupStage returns the stage registered under id
+func lookupStage(id StageID) (Stage, bool) {
+	stagesMu.RLock()
+	defer stagesMu.RUnlock()
+	s, ok := stages[id]
+	return s, ok
+}
+
+// runTokenizeStage implements StageTokenize
+func runTokenizeStage(c *Context, u *Unit) error {
+	tokens, err := c.Tokenize(u.Source)
+	if err != nil {
+		return err
+	}
+	u.Tokens = tokens
+	return nil
+}
+
+// StageResult reports how one stage of a pipeline run went
+type StageResult struct {
+	Stage    StageID
+	Duration time.Duration
+	Err      error
+	Cached   bool // Output was served from the pipeline's cache
+}
+
+// StageError is returned by StagePipeline.Run when a stage fails
+type StageError struct {
+	Stage StageID
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("stage %s: %v", e.Stage, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// StagePipeline runs a selection of RIFT stages in stage order
+//
+// Example:
+//   p, err := nsigii.NewStagePipeline(ctx, nsigii.StageTokenize, nsigii.StageParse)
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   unit, results, err := p.Run(source)
+type StagePipeline struct {
+	ctx    *Context
+	stages []Stage
+	cache  StageCache // nil disables caching
+	gate   Gate       // consulted between stages, if set
+
+	custody bool // record custody in each unit's envelope
+}
+
+// NewStagePipeline selects the stages with the given IDs, which must be
+// registered and listed in strictly increasing order
+func NewStagePipeline(ctx *Context, ids ...StageID) (*StagePipeline, error) {
+	if len(ids) == 0 {
+		return nil, errors.New("no stages selected")
+	}
+
+	p := &StagePipeline{ctx: ctx}
+	for i, id := range ids {
+		if i > 0 && id <= ids[i-1] {
+			return nil, fmt.Errorf("stage %s selected out of order", id)
+		}
+		s, ok := lookupStage(id)
+		if !ok {
+			return nil, fmt.Errorf("stage %s is not available", id)
+		}
+		p.stages = append(p.stages, s)
+	}
+
+	return p, nil
+}
+
+// Stages returns the IDs of the selected stages, in order
+func (p *StagePipeline) Stages() []StageID {
+	ids := make([]StageID, len(p.stages))
+	for i, s := range p.stages {
+		ids[i] = s.ID()
+	}
+	return ids
+}
+
+// Run passes source through every selected stage in order. It stops at the
+// first stage that fails, returning a *StageError, or that its gate refuses
+// to enter, returning a *GateError; results holds an entry for every stage
+// reached, including the failed or blocked one.
+func (p *StagePipeline) Run(source string) (*Unit, []StageResult, error) {
+	if err := p.ctx.usable(); err != nil {
+		return nil, nil, err
+	}
+
+	u := &Unit{Source: source}
+	results := make([]StageResult, 0, len(p.stages))
+
+	var keys []CacheKey
+	next := 0
+	if p.cache != nil {
+		keys = p.cacheKeys(source)
+		for i := len(keys) - 1; i >= 0; i-- {
+			if cached, ok := p.cache.Get(keys[i]); ok {
+				copied := *cached
+				copied.Envelope = cached.Envelope.Clone()
+				u, next = &copied, i+1
+				break
+			}
+		}
+		// A cached unit stands in for a tokenization, so the policy decides
+		// whether it may be served just as it would for the real one
+		if next > 0 {
+			if err := p.ctx.authorize(OpTokenize); err != nil {
+				return nil, nil, err
+			}
+		}
+		for i, s := range p.stages[:next] {
+			if err := p.checkGate(i, u); err != nil {
+				results = append(results, StageResult{Stage: s.ID(), Err: err})
+				return u, results, err
+			}
+			results = append(results, StageResult{Stage: s.ID(), Cached: true})
+		}
+		if next > 0 && u.Diagnostics != nil {
+			if err := p.ctx.reportDiagnostics(u.Diagnostics); err != nil {
+				return u, results, err
+			}
+		}
+	}
+
+	for i, s := range p.stages[next:] {
+		if err := p.checkGate(next+i, u); err != nil {
+			results = append(results, StageResult{Stage: s.ID(), Err: err})
+			return u, results, err
+		}
+
+		start := time.Now()
+		err := s.Run(p.ctx, u)
+		results = append(results, StageResult{
+			Stage:    s.ID(),
+			Duration: time.Since(start),
+			Err:      err,
+		})
+		p.ctx.observe(Event{Op: OpStage, Start: start, Err: err, Stage: s.ID()})
+		if err == nil {
+			err = p.recordCustody(s.ID(), u)
+		}
+		if err != nil {
+			return u, results, &StageError{Stage: s.ID(), Err: err}
+		}
+		if keys != nil {
+			snapshot := *u
+			snapshot.Envelope = u.Envelope.Clone()
+			p.cache.Put(keys[next+i], &snapshot)
+		}
+	}
+
+	return u, results, nil
+}
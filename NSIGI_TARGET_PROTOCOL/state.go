@@ -0,0 +1,164 @@
+package nsigii
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ============================================================================
+// Context State Export
+// ============================================================================
+
+// contextStateVersion is the current export format version
+const contextStateVersion = 1
+
+// contextState is the signed content of an exported context
+type contextState struct {
+	Version  int              `json:"version"`
+	Schema   string           `json:"schema"`
+	Time     time.Time        `json:"time"`
+	Polarity Polarity         `json:"polarity"`
+	State    ColorChannel     `json:"state"`
+	Identity *PhantomID       `json:"identity,omitempty"` // nil if none issued yet
+	Uses     int              `json:"uses,omitempty"`
+	Channels []ChannelMetrics `json:"channels"` // indexed by ColorChannel
+	Usage    ContextStats     `json:"usage"`
+}
+
+// Export captures the context's logical state: schema, polarity, color
+// state, its own phantom ID and its channel and usage counters. The result
+// is signed with the context's phantom key, so a service that sets a
+// persistent key with SetPhantomKey can Import it after a restart and
+// resume verified pipelines without repeating their handshakes.
+//
+// Settings, hooks, the audit trail and AUX activity are not exported.
+//
+// Example:
+//   data, err := ctx.Export()
+//   // ... after a restart
+//   ctx, _ = nsigii.NewContext("tokenize", "lexer")
+//   ctx.SetPhantomKey(key)
+//   err = ctx.Import(data)
+func (c *Context) Export() ([]byte, error) {
+	schema, err := c.Schema()
+	if err != nil {
+		return nil, err
+	}
+
+	st := contextState{
+		Version:  contextStateVersion,
+		Schema:   schema,
+		Time:     time.Now(),
+		Polarity: c.Polarity(),
+		Usage:    c.Stats(),
+	}
+
+	c.identity.mu.Lock()
+	if !c.identity.id.IsZero() {
+		id := c.identity.id
+		st.Identity, st.Uses = &id, c.identity.uses
+	}
+	c.identity.mu.Unlock()
+
+	c.color.mu.Lock()
+	st.State = c.color.channel
+	st.Channels = append([]ChannelMetrics(nil), c.color.counters.metrics[:]...)
+	st.Channels[st.State].Time += st.Time.Sub(c.color.counters.since)
+	c.color.mu.Unlock()
+
+	body, err := json.Marshal(st)
+	if err != nil {
+		return nil, err
+	}
+	keyID, key := c.currentKey()
+	return json.Marshal(signedSnapshot{Snapshot: body, KeyID: keyID, MAC: stateMAC(key, body)})
+}
+
+// Import restores state produced by Export into the context, which must
+// have the same schema and phantom key, or key ring, as the exporting one. The exported
+// phantom ID must still verify; it replaces the context's own ID without
+// counting as a rotation. A terminated context must be reinstated before it
+// can import.
+func (c *Context) Import(data []byte) error {
+	if err := c.require(RoleAdmin, "Import"); err != nil {
+		return err
+	}
+	schema, err := c.Schema()
+	if err != nil {
+		return err
+	}
+
+	var signed signedSnapshot
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return fmt.Errorf("invalid context state: %w", err)
+	}
+	key, known := c.keyByID(signed.KeyID)
+	if !known || !hmac.Equal(signed.MAC, stateMAC(key, signed.Snapshot)) {
+		return errors.New("context state signature mismatch")
+	}
+
+	var st contextState
+	if err := json.Unmarshal(signed.Snapshot, &st); err != nil {
+		return fmt.Errorf("invalid context state: %w", err)
+	}
+	if st.Version != contextStateVersion {
+		return fmt.Errorf("unsupported context state version: %d", st.Version)
+	}
+	if st.Schema != schema {
+		return fmt.Errorf("context state is for %s, not %s", st.Schema, schema)
+	}
+	if st.State == ColorContrast || st.State.String() == "UNKNOWN" {
+		return fmt.Errorf("invalid context state color: %s", st.State)
+	}
+	if len(st.Channels) != len(c.color.counters.metrics) {
+		return fmt.Errorf("invalid context state: %d channel counters", len(st.Channels))
+	}
+	if st.Identity != nil {
+		ok, err := c.VerifyPhantomID(*st.Identity)
+		if err != nil {
+			return err
+		}
+		if !ok || st.Identity.Schema() != schema {
+			return errors.New("context state identity does not verify")
+		}
+	}
+
+	current := c.ColorState()
+	if current == ColorBlack {
+		return ErrTerminated
+	}
+	if err := c.SetPolarity(st.Polarity); err != nil {
+		return err
+	}
+	if current != st.State {
+		if err := c.setColor(current, st.State, "imported", true); err != nil {
+			return err
+		}
+	}
+
+	if st.Identity != nil {
+		c.identity.mu.Lock()
+		c.identity.id, c.identity.uses = *st.Identity, st.Uses
+		c.identity.mu.Unlock()
+	}
+
+	c.color.mu.Lock()
+	copy(c.color.counters.metrics[:], st.Channels)
+	c.color.counters.since = time.Now()
+	c.color.mu.Unlock()
+
+	c.usage.restore(st.Usage)
+	return nil
+}
+
+// stateMAC signs the encoded context state
+func stateMAC(key, body []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte("nsigii context state\x00"))
+	h.Write(body)
+	return h.Sum(nil)
+}
@@ -0,0 +1,96 @@
+package nsigii
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ============================================================================
+// Usage Statistics
+// ============================================================================
+
+// ContextStats summarizes a context's workload since it was created
+type ContextStats struct {
+	NativeCalls  int64         // Calls into the native library
+	NativeTime   time.Duration // Time spent in native calls, including waiting for the handle
+	Tokenizes    int64         // Tokenize calls, direct or through other operations
+	Tokens       int64         // Tokens produced by successful tokenizations
+	Bytes        int64         // Source bytes tokenized, successfully or not
+	Errors       int64         // Failed tokenizations and failed native calls
+	LastActivity time.Time     // Time of the last native call or tokenization, zero if none
+}
+
+// usageCounters accumulates ContextStats. Its fields are updated atomically
+// so counting never contends with the context's locks.
+type usageCounters struct {
+	nativeCalls atomic.Int64
+	nativeTime  atomic.Int64 // nanoseconds
+	tokenizes   atomic.Int64
+	tokens      atomic.Int64
+	bytes       atomic.Int64
+	errors      atomic.Int64
+	last        atomic.Int64 // UnixNano, 0 if none
+}
+
+// Stats returns the context's usage statistics, so operators can spot hot
+// or misbehaving contexts
+//
+// Example:
+//   s := ctx.Stats()
+//   fmt.Printf("%d tokens from %d bytes, %d errors, %v native\n",
+//       s.Tokens, s.Bytes, s.Errors, s.NativeTime)
+func (c *Context) Stats() ContextStats {
+	u := &c.usage
+	s := ContextStats{
+		NativeCalls: u.nativeCalls.Load(),
+		NativeTime:  time.Duration(u.nativeTime.Load()),
+		Tokenizes:   u.tokenizes.Load(),
+		Tokens:      u.tokens.Load(),
+		Bytes:       u.bytes.Load(),
+		Errors:      u.errors.Load(),
+	}
+	if last := u.last.Load(); last != 0 {
+		s.LastActivity = time.Unix(0, last)
+	}
+	return s
+}
+
+// countNative records a native call that started at start
+func (u *usageCounters) countNative(start time.Time) {
+	now := time.Now()
+	u.nativeCalls.Add(1)
+	u.nativeTime.Add(int64(now.Sub(start)))
+	u.last.Store(now.UnixNano())
+}
+
+// countTokenize records a tokenization of n source bytes
+func (u *usageCounters) countTokenize(n int, tokens []Token, err error) {
+	u.tokenizes.Add(1)
+	u.bytes.Add(int64(n))
+	if err != nil {
+		u.errors.Add(1)
+	} else {
+		u.tokens.Add(int64(len(tokens)))
+	}
+	u.last.Store(time.Now().UnixNano())
+}
+
+// countError records a failed native call
+func (u *usageCounters) countError() {
+	u.errors.Add(1)
+}
+
+// restore replaces the counters with those reported in s
+func (u *usageCounters) restore(s ContextStats) {
+	u.nativeCalls.Store(s.NativeCalls)
+	u.nativeTime.Store(int64(s.NativeTime))
+	u.tokenizes.Store(s.Tokenizes)
+	u.tokens.Store(s.Tokens)
+	u.bytes.Store(s.Bytes)
+	u.errors.Store(s.Errors)
+	if s.LastActivity.IsZero() {
+		u.last.Store(0)
+	} else {
+		u.last.Store(s.LastActivity.UnixNano())
+	}
+}
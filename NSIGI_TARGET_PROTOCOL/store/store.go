@@ -0,0 +1,396 @@
+// Package store keeps token streams, their statistics and the phantom IDs
+// that stamped them in a SQLite database, so small deployments get durable
+// history without running a database server. It uses the pure-Go
+// modernc.org/sqlite driver and so works in CGO_ENABLED=0 builds too.
+//
+// Streams are indexed by the SHA-256 of their source, by schema, and by the
+// types of their tokens.
+//
+// Example:
+//   st, err := store.Open("nsigii.db")
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   defer st.Close()
+//
+//   stream, err := ctx.TokenizeStream(source)
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   rec, err := st.Put(context.Background(), "main.rift", source, stream)
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   same, err := st.ByFileHash(context.Background(), rec.FileHash)
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/obinexus/nsigii-rift/nsigii"
+)
+
+// ErrNotFound is returned for a stream ID with no stream
+var ErrNotFound = errors.New("token stream not found")
+
+// schema creates the tables and indexes, idempotently
+const schema = `
+CREATE TABLE IF NOT EXISTS phantom_ids (
+	id         TEXT PRIMARY KEY,
+	schema     TEXT NOT NULL,
+	issued_at  INTEGER NOT NULL,
+	first_seen INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS phantom_ids_schema ON phantom_ids (schema);
+
+CREATE TABLE IF NOT EXISTS streams (
+	id           INTEGER PRIMARY KEY,
+	file_hash    TEXT NOT NULL,
+	name         TEXT NOT NULL,
+	schema       TEXT NOT NULL,
+	version      INTEGER NOT NULL,
+	origin       TEXT REFERENCES phantom_ids (id),
+	seal         BLOB,
+	signer       BLOB,
+	signature    BLOB,
+	stored_at    INTEGER NOT NULL,
+	total_tokens INTEGER NOT NULL,
+	stats        TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS streams_file_hash ON streams (file_hash);
+CREATE INDEX IF NOT EXISTS streams_schema ON streams (schema, stored_at);
+
+CREATE TABLE IF NOT EXISTS tokens (
+	stream_id   INTEGER NOT NULL REFERENCES streams (id) ON DELETE CASCADE,
+	idx         INTEGER NOT NULL,
+	type        INTEGER NOT NULL,
+	memory      INTEGER NOT NULL,
+	value       INTEGER NOT NULL,
+	text        TEXT NOT NULL,
+	line        INTEGER NOT NULL,
+	col         INTEGER NOT NULL,
+	end_offset  INTEGER NOT NULL,
+	rune_offset INTEGER NOT NULL,
+	rune_length INTEGER NOT NULL,
+	PRIMARY KEY (stream_id, idx)
+) WITHOUT ROWID;
+CREATE INDEX IF NOT EXISTS tokens_type ON tokens (type, stream_id);
+`
+
+// Record describes a stored stream without its tokens
+type Record struct {
+	ID       int64
+	FileHash string // Hex SHA-256 of the source
+	Name     string // File name or other label given to Put
+	Schema   string
+	Origin   *nsigii.PhantomID // Phantom ID that stamped the stream, if any
+	Stored   time.Time
+	Stats    nsigii.TokenStats
+}
+
+// TokenMatch is a token found by FindTokens
+type TokenMatch struct {
+	StreamID int64
+	Index    int // Position in the stream
+	Token    nsigii.Token
+}
+
+// Store is a SQLite token store. It is safe for concurrent use.
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens or creates the database at path
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	s, err := New(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// New creates a store in an open SQLite database, creating its tables if
+// they do not exist. Close closes db.
+func New(db *sql.DB) (*Store, error) {
+	if _, err := db.Exec(schema); err != nil {
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// HashSource returns the file hash streams of source are stored under
+func HashSource(source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:])
+}
+
+// Put stores a stream tokenized from source under name, along with its
+// origin phantom ID if it is stamped. Statistics are computed if the stream
+// has none.
+func (s *Store) Put(ctx context.Context, name, source string, stream *nsigii.TokenStream) (Record, error) {
+	rec := Record{
+		FileHash: HashSource(source),
+		Name:     name,
+		Schema:   stream.Schema,
+		Origin:   stream.Origin,
+		Stored:   time.Now(),
+	}
+	if stream.Stats != nil {
+		rec.Stats = *stream.Stats
+	} else {
+		rec.Stats = nsigii.AnalyzeTokens(stream.Tokens)
+	}
+	stats, err := json.Marshal(rec.Stats)
+	if err != nil {
+		return Record{}, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Record{}, err
+	}
+	defer tx.Rollback()
+
+	var origin sql.NullString
+	if stream.Origin != nil {
+		if err := putPhantomID(ctx, tx, *stream.Origin, rec.Stored); err != nil {
+			return Record{}, err
+		}
+		origin = sql.NullString{String: stream.Origin.String(), Valid: true}
+	}
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO streams
+		(file_hash, name, schema, version, origin, seal, signer, signature, stored_at, total_tokens, stats)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rec.FileHash, name, stream.Schema, stream.Version, origin, stream.Seal,
+		[]byte(stream.Signer), stream.Signature, rec.Stored.UnixNano(), rec.Stats.TotalTokens, string(stats))
+	if err != nil {
+		return Record{}, err
+	}
+	if rec.ID, err = res.LastInsertId(); err != nil {
+		return Record{}, err
+	}
+
+	insert, err := tx.PrepareContext(ctx, `INSERT INTO tokens
+		(stream_id, idx, type, memory, value, text, line, col, end_offset, rune_offset, rune_length)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return Record{}, err
+	}
+	defer insert.Close()
+	for i, t := range stream.Tokens {
+		_, err := insert.ExecContext(ctx, rec.ID, i, int(t.Type), t.Memory, t.Value, t.Lexeme(),
+			t.Line, t.Column, t.EndOffset, t.RuneOffset, t.RuneLength)
+		if err != nil {
+			return Record{}, err
+		}
+	}
+
+	return rec, tx.Commit()
+}
+
+// PutPhantomID records a phantom ID, as Put does for stream origins. An ID
+// already stored is left as it is.
+func (s *Store) PutPhantomID(ctx context.Context, id nsigii.PhantomID) error {
+	return putPhantomID(ctx, s.db, id, time.Now())
+}
+
+// execer is the part of *sql.DB and *sql.Tx putPhantomID needs
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func putPhantomID(ctx context.Context, db execer, id nsigii.PhantomID, seen time.Time) error {
+	_, err := db.ExecContext(ctx, `INSERT INTO phantom_ids (id, schema, issued_at, first_seen)
+		VALUES (?, ?, ?, ?) ON CONFLICT (id) DO NOTHING`,
+		id.String(), id.Schema(), id.IssuedAt().UnixNano(), seen.UnixNano())
+	return err
+}
+
+// ============================================================================
+// Queries
+// ============================================================================
+
+const recordColumns = `id, file_hash, name, schema, origin, stored_at, stats`
+
+// Record returns the record of the stream with the given ID
+func (s *Store) Record(ctx context.Context, id int64) (Record, error) {
+	recs, err := s.records(ctx, `SELECT `+recordColumns+` FROM streams WHERE id = ?`, id)
+	if err != nil {
+		return Record{}, err
+	}
+	if len(recs) == 0 {
+		return Record{}, ErrNotFound
+	}
+	return recs[0], nil
+}
+
+// ByFileHash returns the records of streams of the source with the given
+// hash, oldest first
+func (s *Store) ByFileHash(ctx context.Context, hash string) ([]Record, error) {
+	return s.records(ctx, `SELECT `+recordColumns+` FROM streams
+		WHERE file_hash = ? ORDER BY id`, hash)
+}
+
+// BySchema returns the records of the most recent streams produced under
+// schema, newest first; limit <= 0 means all of them
+func (s *Store) BySchema(ctx context.Context, schema string, limit int) ([]Record, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+	return s.records(ctx, `SELECT `+recordColumns+` FROM streams
+		WHERE schema = ? ORDER BY stored_at DESC, id DESC LIMIT ?`, schema, limit)
+}
+
+// records runs a query for record columns
+func (s *Store) records(ctx context.Context, query string, args ...any) ([]Record, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recs []Record
+	for rows.Next() {
+		var (
+			rec    Record
+			origin sql.NullString
+			stored int64
+			stats  string
+		)
+		if err := rows.Scan(&rec.ID, &rec.FileHash, &rec.Name, &rec.Schema, &origin, &stored, &stats); err != nil {
+			return nil, err
+		}
+		if origin.Valid {
+			id, err := nsigii.ParsePhantomID(origin.String)
+			if err != nil {
+				return nil, err
+			}
+			rec.Origin = &id
+		}
+		rec.Stored = time.Unix(0, stored)
+		if err := json.Unmarshal([]byte(stats), &rec.Stats); err != nil {
+			return nil, err
+		}
+		recs = append(recs, rec)
+	}
+	return recs, rows.Err()
+}
+
+// Stream loads the stream with the given ID, tokens included
+func (s *Store) Stream(ctx context.Context, id int64) (*nsigii.TokenStream, error) {
+	rec, err := s.Record(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := &nsigii.TokenStream{Schema: rec.Schema, Stats: &rec.Stats, Origin: rec.Origin}
+	var signer []byte
+	err = s.db.QueryRowContext(ctx, `SELECT version, seal, signer, signature FROM streams WHERE id = ?`, id).
+		Scan(&stream.Version, &stream.Seal, &signer, &stream.Signature)
+	if err != nil {
+		return nil, err
+	}
+	stream.Signer = signer
+
+	matches, err := s.tokens(ctx, `SELECT `+tokenColumns+` FROM tokens
+		WHERE stream_id = ? ORDER BY idx`, id)
+	if err != nil {
+		return nil, err
+	}
+	stream.Tokens = make([]nsigii.Token, len(matches))
+	for i, m := range matches {
+		stream.Tokens[i] = m.Token
+	}
+	return stream, nil
+}
+
+// FindTokens returns stored tokens of type typ, in stream order, optionally
+// only from streams produced under schema; limit <= 0 means all of them
+func (s *Store) FindTokens(ctx context.Context, typ nsigii.TokenType, schema string, limit int) ([]TokenMatch, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+	if schema == "" {
+		return s.tokens(ctx, `SELECT `+tokenColumns+` FROM tokens
+			WHERE type = ? ORDER BY stream_id, idx LIMIT ?`, int(typ), limit)
+	}
+	return s.tokens(ctx, `SELECT `+tokenColumns+` FROM tokens
+		JOIN streams ON streams.id = tokens.stream_id
+		WHERE tokens.type = ? AND streams.schema = ?
+		ORDER BY stream_id, idx LIMIT ?`, int(typ), schema, limit)
+}
+
+const tokenColumns = `stream_id, idx, type, memory, value, text, line, col, end_offset, rune_offset, rune_length`
+
+// tokens runs a query for token columns
+func (s *Store) tokens(ctx context.Context, query string, args ...any) ([]TokenMatch, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []TokenMatch
+	for rows.Next() {
+		var (
+			m   TokenMatch
+			typ int
+			t   = &m.Token
+		)
+		err := rows.Scan(&m.StreamID, &m.Index, &typ, &t.Memory, &t.Value, &t.Text,
+			&t.Line, &t.Column, &t.EndOffset, &t.RuneOffset, &t.RuneLength)
+		if err != nil {
+			return nil, err
+		}
+		t.Type = nsigii.TokenType(typ)
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
+
+// PhantomIDs returns the stored phantom IDs issued under schema, oldest
+// first; "" means every schema
+func (s *Store) PhantomIDs(ctx context.Context, schema string) ([]nsigii.PhantomID, error) {
+	query, args := `SELECT id FROM phantom_ids ORDER BY issued_at`, []any(nil)
+	if schema != "" {
+		query, args = `SELECT id FROM phantom_ids WHERE schema = ? ORDER BY issued_at`, []any{schema}
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []nsigii.PhantomID
+	for rows.Next() {
+		var text string
+		if err := rows.Scan(&text); err != nil {
+			return nil, err
+		}
+		id, err := nsigii.ParsePhantomID(text)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,174 @@
+package nsigii
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"iter"
+	"strings"
+	"unicode/utf8"
+)
+
+// ============================================================================
+// Streaming Tokenization (RIFT Stage 000-111)
+// ============================================================================
+
+// streamChunkSize is the amount of input fed to the lexer per call when
+// tokenizing a stream
+const streamChunkSize = 8 * 1024
+
+// TokenizeReader tokenizes source read from r, calling fn with each token
+// as it is scanned
+//
+// Input is fed to the lexer in line-aligned chunks, so memory use is bounded
+// by the chunk size (or the longest line) instead of the size of the input.
+// Memory offsets are relative to the start of the stream, and a single EOF
+// token is emitted once r is exhausted. A string, comment or lexer mode
+// still open at the end of a chunk is carried, from the start of its line,
+// into the next chunk, so multi-line tokens and nested constructs come out
+// as they do from Tokenize.
+//
+// If fn returns an error, scanning stops and that error is returned.
+//
+// Example:
+//   f, _ := os.Open("huge.rf")
+//   defer f.Close()
+//   err := ctx.TokenizeReader(f, func(t nsigii.Token) error {
+//       fmt.Println(t)
+//       return nil
+//   })
+func (c *Context) TokenizeReader(r io.Reader, fn func(Token) error) error {
+	if err := c.usable(); err != nil {
+		return err
+	}
+
+	br := bufio.NewReaderSize(r, streamChunkSize)
+	chunk := make([]byte, 0, streamChunkSize)
+	flushAt := streamChunkSize
+	var (
+		base      uint32
+		baseLine  int
+		baseRunes int
+	)
+
+	for {
+		line, err := br.ReadSlice('\n')
+		chunk = append(chunk, line...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+
+		atEOF := err == io.EOF
+		if err != nil && !atEOF {
+			return err
+		}
+
+		if len(chunk) >= flushAt || (atEOF && len(chunk) > 0) {
+			n, err := c.emitChunk(string(chunk), base, baseLine, baseRunes, atEOF, fn)
+			if err != nil {
+				return err
+			}
+			base += uint32(n)
+			baseLine += bytes.Count(chunk[:n], []byte{'\n'})
+			if c.runes {
+				baseRunes += utf8.RuneCount(chunk[:n])
+			}
+			chunk = append(chunk[:0], chunk[n:]...)
+
+			// Grow geometrically while a long token is carried, so it is
+			// not re-scanned for every line read
+			flushAt = max(streamChunkSize, 2*len(chunk))
+		}
+
+		if atEOF {
+			eof := Token{Type: TokenEOF, Memory: base, Text: "<EOF>", EndOffset: base}
+			if !c.noPos {
+				eof.Line, eof.Column = baseLine+1, 1
+			}
+			if c.runes {
+				eof.RuneOffset = uint32(baseRunes)
+			}
+			return fn(eof)
+		}
+	}
+}
+
+// errStopIteration ends a scan early when a range loop over Tokens breaks
+var errStopIteration = errors.New("iteration stopped")
+
+// Tokens returns a lazy iterator over the tokens of source
+//
+// Tokens are produced chunk by chunk as the loop advances (see
+// TokenizeReader), so breaking out early skips scanning the rest of the
+// input and no slice of the whole stream is ever allocated. A failure is
+// yielded once as a zero Token with a non-nil error, ending the sequence.
+//
+// Example:
+//   for token, err := range ctx.Tokens(source) {
+//       if err != nil {
+//           log.Fatal(err)
+//       }
+//       if token.Type == nsigii.TokenComment {
+//           break
+//       }
+//   }
+func (c *Context) Tokens(source string) iter.Seq2[Token, error] {
+	return func(yield func(Token, error) bool) {
+		err := c.TokenizeReader(strings.NewReader(source), func(t Token) error {
+			if !yield(t, nil) {
+				return errStopIteration
+			}
+			return nil
+		})
+		if err != nil && err != errStopIteration {
+			yield(Token{}, err)
+		}
+	}
+}
+
+// emitChunk tokenizes one chunk of a stream and passes its tokens, shifted
+// to stream offsets and lines, to fn. The chunk's own EOF token is dropped.
+//
+// Unless final is set, a last token running to the end of the chunk, or a
+// lexer mode left open, may continue in the next one: it and the rest of
+// its line are held back, and the returned length of the chunk consumed
+// stops at the start of that line.
+func (c *Context) emitChunk(chunk string, base uint32, baseLine, baseRunes int, final bool, fn func(Token) error) (int, error) {
+	tokens, err := c.tokenize(chunk)
+	if err != nil {
+		return 0, err
+	}
+
+	consumed := len(chunk)
+	if !final && endsInsideToken(tokens, len(chunk)) {
+		last := tokens[len(tokens)-1]
+		if last.Type == TokenEOF {
+			last = tokens[len(tokens)-2]
+		}
+		consumed = lineStart(chunk, int(last.Memory))
+	}
+	if entered, open := c.openMode(tokens, len(chunk)); !final && open {
+		consumed = min(consumed, lineStart(chunk, entered))
+	}
+
+	for _, token := range tokens {
+		if token.Type == TokenEOF || int(token.Memory) >= consumed {
+			continue
+		}
+		token.materialize()
+		token.Memory += base
+		token.EndOffset += base
+		if token.Line > 0 {
+			token.Line += baseLine
+		}
+		if c.runes {
+			token.RuneOffset += uint32(baseRunes)
+		}
+		if err := fn(token); err != nil {
+			return 0, err
+		}
+	}
+
+	return consumed, nil
+}
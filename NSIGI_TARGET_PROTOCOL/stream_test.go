@@ -0,0 +1,42 @@
+package nsigii
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokenizeReaderCarriesTokenAcrossChunks(t *testing.T) {
+	ctx, err := NewContext("tokenize", "lexer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+
+	// The comment opens in the first chunk and closes several chunks later
+	filler := strings.Repeat("let x = 1;\n", streamChunkSize/11-2)
+	source := filler + "let y = 2; /* spans\n" + strings.Repeat("comment text\n", streamChunkSize/4) +
+		"*/ let z = \"a\nb\";\n" + filler
+
+	want, err := ctx.Tokenize(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []Token
+	err = ctx.TokenizeReader(strings.NewReader(source), func(tok Token) error {
+		got = append(got, tok)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("TokenizeReader returned %d tokens, Tokenize %d", len(got), len(want))
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.Type != w.Type || g.Memory != w.Memory || g.Value != w.Value || g.Line != w.Line || g.Column != w.Column {
+			t.Fatalf("token %d = %v at %d:%d, want %v at %d:%d", i, g, g.Line, g.Column, w, w.Line, w.Column)
+		}
+	}
+}
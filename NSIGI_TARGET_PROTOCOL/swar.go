@@ -0,0 +1,77 @@
+package nsigii
+
+import "math/bits"
+
+// ============================================================================
+// Chunked Byte Classification
+// ============================================================================
+
+// The Go lexer spends most of its time in whitespace and identifier runs.
+// These helpers classify eight bytes at a time in a uint64 (SIMD within a
+// register), which keeps the pure-Go backend close to the C lexer without
+// assembly. The byte-at-a-time loops in lexer.go remain the reference: the
+// fast paths stop at the first byte they cannot classify, including every
+// non-ASCII byte, and leave it to them.
+
+const (
+	lows  = 0x0101010101010101 // 0x01 in every byte
+	highs = 0x8080808080808080 // 0x80 in every byte
+)
+
+// load64 reads s[0:8] as a little-endian word. The compiler merges the byte
+// loads into one unaligned load on amd64 and arm64.
+func load64(s string) uint64 {
+	_ = s[7]
+	return uint64(s[0]) | uint64(s[1])<<8 | uint64(s[2])<<16 | uint64(s[3])<<24 |
+		uint64(s[4])<<32 | uint64(s[5])<<40 | uint64(s[6])<<48 | uint64(s[7])<<56
+}
+
+// inRange sets the high bit of each byte of w within [lo, hi]. Bytes must
+// be ASCII for the result to be exact; a non-ASCII byte may corrupt the
+// bytes after it, but never those before it.
+func inRange(w uint64, lo, hi byte) uint64 {
+	atLeast := w + lows*uint64(0x80-lo)
+	above := w + lows*uint64(0x80-hi-1)
+	return (atLeast &^ above) & highs
+}
+
+// leading returns the number of leading bytes of a word whose high bit is
+// clear in stop
+func leading(stop uint64) int {
+	return bits.TrailingZeros64(stop) / 8
+}
+
+// spaceRun returns the length of the run of ASCII whitespace at the start
+// of s, as isSpace defines it
+func spaceRun(s string) int {
+	i := 0
+	for ; i+8 <= len(s); i += 8 {
+		w := load64(s[i:])
+		space := inRange(w, ' ', ' ') | inRange(w, '\t', '\r')
+		if stop := ^space&highs | w&highs; stop != 0 {
+			return i + leading(stop)
+		}
+	}
+	for i < len(s) && isSpace(s[i]) {
+		i++
+	}
+	return i
+}
+
+// identRun returns the length of the run of ASCII identifier bytes at the
+// start of s, as isIdentPart defines them
+func identRun(s string) int {
+	i := 0
+	for ; i+8 <= len(s); i += 8 {
+		w := load64(s[i:])
+		// Setting 0x20 folds A-Z onto a-z and nothing else onto a-z
+		ident := inRange(w|lows*0x20, 'a', 'z') | inRange(w, '0', '9') | inRange(w, '_', '_')
+		if stop := ^ident&highs | w&highs; stop != 0 {
+			return i + leading(stop)
+		}
+	}
+	for i < len(s) && isIdentPart(s[i]) {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,56 @@
+package nsigii
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ============================================================================
+// Termination
+// ============================================================================
+
+// OnTerminate registers fn to be called when the context enters BLACK.
+// From then on tokenization and AUX calls fail with ErrTerminated until the
+// context is reinstated. fn runs synchronously after the transition, on the
+// goroutine that made it.
+//
+// Example:
+//   ctx.OnTerminate(func(t nsigii.ColorTransition) {
+//       log.Printf("context terminated from %s: %s", t.From, t.Reason)
+//       server.Drain()
+//   })
+func (c *Context) OnTerminate(fn func(ColorTransition)) {
+	c.color.mu.Lock()
+	defer c.color.mu.Unlock()
+
+	terminate := make([]func(ColorTransition), len(c.color.terminate), len(c.color.terminate)+1)
+	copy(terminate, c.color.terminate)
+	c.color.terminate = append(terminate, fn)
+}
+
+// Terminated reports whether the context's color state is BLACK
+func (c *Context) Terminated() bool {
+	return c.ColorState() == ColorBlack
+}
+
+// Reinstate returns a terminated context to RED. It requires a fresh RGB
+// consensus under the context's consensus policy, taken at the time of the
+// call; without it the context stays BLACK.
+func (c *Context) Reinstate(reason string) error {
+	if err := c.require(RoleAdmin, "Reinstate"); err != nil {
+		return err
+	}
+	if c.ColorState() != ColorBlack {
+		return errors.New("context is not terminated")
+	}
+
+	ok, err := c.VerifyRGBConsensus()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("reinstatement refused: %w", ErrConsensusFailed)
+	}
+
+	return c.setColor(ColorBlack, ColorRed, "reinstated: "+reason, true)
+}
@@ -0,0 +1,84 @@
+package nsigii
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// ============================================================================
+// Token Type Registry
+// ============================================================================
+
+var (
+	tokenTypesMu sync.RWMutex
+	tokenTypes   = map[TokenType]string{} // registered types beyond the built-ins
+)
+
+// RegisterTokenType registers a token class introduced by a downstream RIFT
+// stage, so String and the JSON encoding report it by name
+//
+// id must be in [0, math.MaxInt32], the range the binary encodings carry,
+// and must not collide with a built-in or previously registered type; name
+// must be non-empty and unique.
+func RegisterTokenType(id int, name string) (TokenType, error) {
+	t := TokenType(id)
+	if id < 0 || id > math.MaxInt32 {
+		return 0, fmt.Errorf("token type %d out of range", id)
+	}
+	if name == "" {
+		return 0, fmt.Errorf("token type %d: empty name", id)
+	}
+	if int(t) < len(tokenTypeNames) {
+		return 0, fmt.Errorf("token type %d is built in (%s)", id, tokenTypeNames[t])
+	}
+
+	tokenTypesMu.Lock()
+	defer tokenTypesMu.Unlock()
+
+	if existing, ok := tokenTypes[t]; ok {
+		return 0, fmt.Errorf("token type %d already registered as %s", id, existing)
+	}
+	if _, ok := lookupTokenTypeLocked(name); ok {
+		return 0, fmt.Errorf("token type name %q already in use", name)
+	}
+
+	tokenTypes[t] = name
+	return t, nil
+}
+
+// tokenTypeName returns the built-in or registered name of t
+func tokenTypeName(t TokenType) (string, bool) {
+	if t >= 0 && int(t) < len(tokenTypeNames) {
+		return tokenTypeNames[t], true
+	}
+
+	tokenTypesMu.RLock()
+	defer tokenTypesMu.RUnlock()
+
+	name, ok := tokenTypes[t]
+	return name, ok
+}
+
+// lookupTokenType returns the token type with the given built-in or
+// registered name
+func lookupTokenType(name string) (TokenType, bool) {
+	tokenTypesMu.RLock()
+	defer tokenTypesMu.RUnlock()
+
+	return lookupTokenTypeLocked(name)
+}
+
+func lookupTokenTypeLocked(name string) (TokenType, bool) {
+	for i, n := range tokenTypeNames {
+		if n == name {
+			return TokenType(i), true
+		}
+	}
+	for t, n := range tokenTypes {
+		if n == name {
+			return t, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,32 @@
+package nsigii
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRegisterTokenTypeRejects(t *testing.T) {
+	const id = 1<<20 + 13
+	if _, err := RegisterTokenType(id, "TEST_REGISTERED"); err != nil {
+		t.Fatal(err)
+	}
+
+	tooLarge := int64(math.MaxInt32) + 1
+	tests := []struct {
+		name string
+		id   int
+		tn   string
+	}{
+		{"negative", -1, "TEST_NEGATIVE"},
+		{"too large", int(tooLarge), "TEST_TOO_LARGE"},
+		{"built in", int(TokenString), "TEST_BUILT_IN"},
+		{"id in use", id, "TEST_DUPLICATE_ID"},
+		{"name in use", id + 1, "TEST_REGISTERED"},
+		{"empty name", id + 2, ""},
+	}
+	for _, tt := range tests {
+		if _, err := RegisterTokenType(tt.id, tt.tn); err == nil {
+			t.Errorf("%s: RegisterTokenType(%d, %q) succeeded", tt.name, tt.id, tt.tn)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Package tracing records NSIGII operations as OpenTelemetry spans, so
+// distributed traces show where tokenization and zero-trust verification
+// time goes. An Instrument is an nsigii.Instrument: set it as the
+// instrument of the contexts to trace, usually as the default.
+//
+// Spans are recorded when operations finish, with their real start and end
+// times. They are children of the Go context bound to the nsigii context
+// with Bind, or roots if none is bound. Because a span is only created once
+// its operation ends, the tokenization run by a pipeline stage appears as
+// the stage's sibling rather than its child.
+//
+// Example:
+//   tracer := tracing.New(tracing.Options{SchemaAttribute: true})
+//   nsigii.SetDefaultInstrument(tracer)
+//
+//   func handle(ctx context.Context, c *nsigii.Context, source string) error {
+//       defer tracer.Bind(ctx, c)()
+//       _, err := c.Tokenize(source)
+//       return err
+//   }
+package tracing
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/obinexus/nsigii-rift/nsigii"
+)
+
+// ScopeName is the instrumentation scope of the spans
+const ScopeName = "github.com/obinexus/nsigii-rift/nsigii"
+
+// Attribute keys set on spans
+const (
+	AttrSchema    = attribute.Key("nsigii.schema")     // Opt-in, see Options
+	AttrPhantomID = attribute.Key("nsigii.phantom_id") // Opt-in, see Options
+	AttrColor     = attribute.Key("nsigii.color")      // Color state when the operation ended
+	AttrBytes     = attribute.Key("nsigii.bytes")      // Tokenize: source bytes
+	AttrTokens    = attribute.Key("nsigii.tokens")     // Tokenize: tokens produced
+	AttrPassed    = attribute.Key("nsigii.consensus.passed")
+	AttrStage     = attribute.Key("nsigii.stage") // Stage: stage name
+)
+
+// Options configures an Instrument
+type Options struct {
+	TracerProvider trace.TracerProvider // nil means otel.GetTracerProvider()
+
+	// SchemaAttribute records the context's schema on every span
+	SchemaAttribute bool
+
+	// PhantomIDAttribute records the context's current phantom ID on every
+	// span. It identifies the service instance, so it is off by default;
+	// recording it never mints or rotates an ID.
+	PhantomIDAttribute bool
+}
+
+// Instrument records NSIGII operations as spans
+type Instrument struct {
+	tracer trace.Tracer
+	opts   Options
+
+	mu      sync.Mutex
+	parents map[*nsigii.Context]context.Context
+}
+
+// New creates an instrument
+func New(opts Options) *Instrument {
+	tp := opts.TracerProvider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return &Instrument{
+		tracer:  tp.Tracer(ScopeName),
+		opts:    opts,
+		parents: make(map[*nsigii.Context]context.Context),
+	}
+}
+
+// Bind makes spans of operations on c children of ctx until the returned
+// function is called, which restores any earlier binding. Pooled contexts
+// should be bound after Get and unbound before Put.
+func (in *Instrument) Bind(ctx context.Context, c *nsigii.Context) func() {
+	in.mu.Lock()
+	prev, had := in.parents[c]
+	in.parents[c] = ctx
+	in.mu.Unlock()
+
+	return func() {
+		in.mu.Lock()
+		defer in.mu.Unlock()
+		if had {
+			in.parents[c] = prev
+		} else {
+			delete(in.parents, c)
+		}
+	}
+}
+
+// parent returns the Go context bound to c
+func (in *Instrument) parent(c *nsigii.Context) context.Context {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if ctx, ok := in.parents[c]; ok {
+		return ctx
+	}
+	return context.Background()
+}
+
+// Observe implements nsigii.Instrument
+func (in *Instrument) Observe(c *nsigii.Context, e nsigii.Event) {
+	attrs := []attribute.KeyValue{AttrColor.String(c.ColorState().String())}
+	if in.opts.SchemaAttribute {
+		attrs = append(attrs, AttrSchema.String(e.Schema))
+	}
+	if in.opts.PhantomIDAttribute {
+		if id, ok := c.CurrentPhantomID(); ok {
+			attrs = append(attrs, AttrPhantomID.String(id.String()))
+		}
+	}
+
+	switch e.Op {
+	case nsigii.OpTokenize:
+		attrs = append(attrs, AttrBytes.Int(e.Bytes))
+		if e.Err == nil {
+			attrs = append(attrs, AttrTokens.Int(e.Tokens))
+		}
+	case nsigii.OpConsensus:
+		if e.Err == nil {
+			attrs = append(attrs, AttrPassed.Bool(e.Passed))
+		}
+	case nsigii.OpStage:
+		// StageID.String is "001 parse"; keep the name
+		stage := e.Stage.String()
+		if i := strings.IndexByte(stage, ' '); i >= 0 {
+			stage = stage[i+1:]
+		}
+		attrs = append(attrs, AttrStage.String(stage))
+	}
+
+	_, span := in.tracer.Start(in.parent(c), "nsigii."+e.Op.String(),
+		trace.WithTimestamp(e.Start),
+		trace.WithAttributes(attrs...),
+		trace.WithSpanKind(trace.SpanKindInternal))
+	if e.Err != nil {
+		span.RecordError(e.Err)
+		span.SetStatus(codes.Error, e.Err.Error())
+	}
+	span.End(trace.WithTimestamp(e.Start.Add(e.Duration)))
+}
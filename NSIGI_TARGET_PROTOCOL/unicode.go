@@ -0,0 +1,106 @@
+package nsigii
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ============================================================================
+// Unicode
+// ============================================================================
+
+// SetRuneOffsets enables RuneOffset/RuneLength on tokens, for consumers
+// (editors, JavaScript hosts) that address text by character rather than
+// byte. Off by default.
+func (c *Context) SetRuneOffsets(enabled bool) {
+	c.runes = enabled
+}
+
+// SetStrictUTF8 makes tokenization fail on sources that are not valid
+// UTF-8, reporting the offset of the first invalid byte. Off by default.
+func (c *Context) SetStrictUTF8(enabled bool) {
+	c.strictUTF8 = enabled
+}
+
+// validateUTF8 returns an error locating the first invalid UTF-8 sequence
+// in s
+func validateUTF8(s string) error {
+	for i := 0; i < len(s); {
+		if s[i] < utf8.RuneSelf {
+			i++
+			continue
+		}
+		r, n := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && n == 1 {
+			return fmt.Errorf("%w at offset %d", ErrInvalidUTF8, i)
+		}
+		i += n
+	}
+	return nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// runeEnd moves end forward to the next rune boundary in s
+func runeEnd(s string, end int) int {
+	for end < len(s) && !utf8.RuneStart(s[end]) {
+		end++
+	}
+	return end
+}
+
+// repairRunes fixes triplets from a byte-oriented lexer for multi-byte
+// input: tokens that end inside a rune absorb the rest of it, lone letter
+// or digit runes become identifiers, and adjacent identifier pieces are
+// joined, so "naïve" is one identifier rather than four fragments
+func repairRunes(source string, triplets []triplet) []triplet {
+	out := make([]triplet, 0, len(triplets))
+
+	for i := 0; i < len(triplets); i++ {
+		t := triplets[i]
+		if t.typ == TokenEOF {
+			out = append(out, t)
+			continue
+		}
+
+		end := t.memory + t.value
+		for int(end) < len(source) && !utf8.RuneStart(source[end]) &&
+			i+1 < len(triplets) && triplets[i+1].memory == end && triplets[i+1].typ != TokenEOF {
+			i++
+			end += triplets[i].value
+		}
+		t.value = end - t.memory
+
+		if t.typ == TokenOperator && isIdentRune(source[t.memory:end]) {
+			t.typ = TokenIdentifier
+		}
+
+		if n := len(out); n > 0 && t.typ == TokenIdentifier {
+			prev := &out[n-1]
+			adjacent := prev.memory+prev.value == t.memory
+			if adjacent && (prev.typ == TokenIdentifier || prev.typ == TokenKeyword) {
+				prev.value += t.value
+				prev.typ = TokenIdentifier
+				continue
+			}
+		}
+
+		out = append(out, t)
+	}
+
+	return out
+}
+
+// isIdentRune reports whether s is exactly one non-ASCII letter or digit
+func isIdentRune(s string) bool {
+	r, n := utf8.DecodeRuneInString(s)
+	return n == len(s) && r >= utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
@@ -0,0 +1,403 @@
+package nsigii
+
+import "fmt"
+
+// ============================================================================
+// Semantic Validation (RIFT Stage 333)
+// ============================================================================
+
+// Severity grades a diagnostic. The zero value is SeverityError, so lexer
+// and parser diagnostics, which carry no severity, are errors.
+type Severity int
+
+const (
+	SeverityError   Severity = 0 // Escalates the context to MAGENTA
+	SeverityWarning Severity = 1 // Warns the context into YELLOW
+)
+
+var severityNames = []string{"ERROR", "WARNING"}
+
+func (s Severity) String() string {
+	if s >= 0 && int(s) < len(severityNames) {
+		return severityNames[s]
+	}
+	return "UNKNOWN"
+}
+
+// Channel returns the color state a diagnostic of this severity moves a
+// context into
+func (s Severity) Channel() ColorChannel {
+	if s == SeverityWarning {
+		return ColorYellow
+	}
+	return ColorMagenta
+}
+
+// Validator checks a compilation unit after parsing
+type Validator interface {
+	Name() string
+	Validate(u *Unit) []Diagnostic
+}
+
+// ValidatorFunc adapts a function to a Validator with the given name
+type ValidatorFunc struct {
+	ValidatorName string
+	Func          func(u *Unit) []Diagnostic
+}
+
+// Name returns v.ValidatorName
+func (v ValidatorFunc) Name() string {
+	return v.ValidatorName
+}
+
+// Validate calls v.Func(u)
+func (v ValidatorFunc) Validate(u *Unit) []Diagnostic {
+	return v.Func(u)
+}
+
+// ValidationError is returned when validation finds error diagnostics
+type ValidationError struct {
+	Diagnostics []Diagnostic // Every diagnostic found, warnings included
+}
+
+func (e *ValidationError) Error() string {
+	var errs []Diagnostic
+	for _, d := range e.Diagnostics {
+		if d.Severity == SeverityError {
+			errs = append(errs, d)
+		}
+	}
+	switch len(errs) {
+	case 0:
+		return "validation failed"
+	case 1:
+		return "validation failed: " + errs[0].String()
+	}
+	return fmt.Sprintf("validation failed: %d errors, first: %s", len(errs), errs[0])
+}
+
+// DefaultValidators returns the validators used by a context with none set
+func DefaultValidators() []Validator {
+	return []Validator{BalancedDelimiters()}
+}
+
+// SetValidators replaces the validators run by Validate and StageValidate.
+// Calling it with no validators restores the defaults.
+func (c *Context) SetValidators(validators ...Validator) {
+	if len(validators) == 0 {
+		c.validators = nil
+		return
+	}
+	c.validators = append([]Validator(nil), validators...)
+}
+
+// Validators returns the validators the context runs
+func (c *Context) Validators() []Validator {
+	if c.validators == nil {
+		return DefaultValidators()
+	}
+	return append([]Validator(nil), c.validators...)
+}
+
+// Validate tokenizes, parses and validates source, returning every
+// diagnostic found
+//
+// Diagnostics move the context's color state: warnings call Warn, and
+// errors escalate the context to MAGENTA and fail with a *ValidationError.
+//
+// Example:
+//   ctx.SetValidators(nsigii.BalancedDelimiters(), nsigii.UndefinedIdentifiers("print"))
+//   diags, err := ctx.Validate(source)
+//   for _, d := range diags {
+//       fmt.Printf("%s %s: %s\n", d.Severity, d.Validator, d)
+//   }
+func (c *Context) Validate(source string) ([]Diagnostic, error) {
+	u := &Unit{Source: source}
+	if err := runValidateStage(c, u); err != nil {
+		return u.Diagnostics, err
+	}
+	return u.Diagnostics, nil
+}
+
+// runValidateStage implements StageValidate, tokenizing and parsing first
+// if no earlier stage did
+func runValidateStage(c *Context, u *Unit) error {
+	if u.AST == nil {
+		if err := runParseStage(c, u); err != nil {
+			return err
+		}
+	}
+
+	u.Diagnostics = nil
+	for _, v := range c.Validators() {
+		for _, d := range v.Validate(u) {
+			d.Validator = v.Name()
+			u.Diagnostics = append(u.Diagnostics, d)
+		}
+	}
+
+	return c.reportDiagnostics(u.Diagnostics)
+}
+
+// reportDiagnostics moves the context's color state for diags and returns a
+// *ValidationError if any of them is an error
+func (c *Context) reportDiagnostics(diags []Diagnostic) error {
+	var first *Diagnostic
+	var errs, warnings int
+	for i := range diags {
+		if diags[i].Severity == SeverityError {
+			if errs == 0 {
+				first = &diags[i]
+			}
+			errs++
+		} else {
+			warnings++
+		}
+	}
+
+	switch {
+	case errs > 0:
+		reason := fmt.Sprintf("validation: %d errors, first: %s", errs, first)
+		if state := c.ColorState(); state != ColorMagenta && state != ColorBlack {
+			c.transitionColor(state, ColorMagenta, reason)
+		}
+		return &ValidationError{Diagnostics: diags}
+	case warnings > 0:
+		reason := fmt.Sprintf("validation: %d warnings", warnings)
+		if err := c.Warn(reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nodeDiagnostic returns a diagnostic spanning n
+func nodeDiagnostic(n Node, severity Severity, message string) Diagnostic {
+	pos := n.Pos()
+	return Diagnostic{
+		Offset:   pos.Offset,
+		Length:   n.End() - pos.Offset,
+		Line:     pos.Line,
+		Column:   pos.Column,
+		Message:  message,
+		Severity: severity,
+	}
+}
+
+// tokenDiagnostic returns a diagnostic spanning t
+func tokenDiagnostic(t Token, severity Severity, message string) Diagnostic {
+	return Diagnostic{
+		Offset:   t.Memory,
+		Length:   t.Value,
+		Line:     t.Line,
+		Column:   t.Column,
+		Message:  message,
+		Severity: severity,
+	}
+}
+
+// ============================================================================
+// Built-in Validators
+// ============================================================================
+
+// BalancedDelimiters reports delimiters that are never closed, closers
+// without an opener and closers that do not match the innermost opener.
+// pairs defaults to (), [] and {}.
+func BalancedDelimiters(pairs ...[2]string) Validator {
+	if len(pairs) == 0 {
+		pairs = [][2]string{{"(", ")"}, {"[", "]"}, {"{", "}"}}
+	}
+	closers := make(map[string]string, len(pairs))
+	openers := make(map[string]string, len(pairs))
+	for _, p := range pairs {
+		openers[p[0]] = p[1]
+		closers[p[1]] = p[0]
+	}
+
+	return ValidatorFunc{"balanced-delimiters", func(u *Unit) []Diagnostic {
+		var diags []Diagnostic
+		var stack []Token
+		for _, t := range u.Tokens {
+			if t.Type != TokenDelimiter && t.Type != TokenOperator {
+				continue
+			}
+			if _, ok := openers[t.Lexeme()]; ok {
+				stack = append(stack, t)
+				continue
+			}
+			if _, ok := closers[t.Lexeme()]; !ok {
+				continue
+			}
+
+			if len(stack) == 0 {
+				d := tokenDiagnostic(t, SeverityError, fmt.Sprintf("unexpected %q", t.Lexeme()))
+				d.Fixes = []Fix{{
+					Message: fmt.Sprintf("remove %q", t.Lexeme()),
+					Edits:   []Edit{{Offset: int(t.Memory), Deleted: len(t.Lexeme())}},
+				}}
+				diags = append(diags, d)
+				continue
+			}
+			open := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if want := openers[open.Lexeme()]; want != t.Lexeme() {
+				d := tokenDiagnostic(t, SeverityError,
+					fmt.Sprintf("expected %q to close %q, found %q", want, open.Lexeme(), t.Lexeme()))
+				d.Fixes = []Fix{{
+					Message: fmt.Sprintf("replace with %q", want),
+					Edits:   []Edit{{Offset: int(t.Memory), Deleted: len(t.Lexeme()), Inserted: want}},
+				}}
+				diags = append(diags, d)
+			}
+		}
+		for _, open := range stack {
+			d := tokenDiagnostic(open, SeverityError, fmt.Sprintf("unclosed %q", open.Lexeme()))
+			d.Fixes = []Fix{{
+				Message: fmt.Sprintf("insert %q at end of input", openers[open.Lexeme()]),
+				Edits:   []Edit{{Offset: len(u.Source), Inserted: openers[open.Lexeme()]}},
+			}}
+			diags = append(diags, d)
+		}
+		return diags
+	}}
+}
+
+// UndefinedIdentifiers reports identifiers used in a RIFT syntax tree
+// without a declaration in scope. Declarations are let/const/var (visible
+// after the declaration), functions (visible throughout their block) and
+// parameters; predeclared names are visible everywhere. Nodes of custom
+// grammars are skipped.
+func UndefinedIdentifiers(predeclared ...string) Validator {
+	universe := &scope{names: make(map[string]bool, len(predeclared))}
+	for _, name := range predeclared {
+		universe.names[name] = true
+	}
+
+	return ValidatorFunc{"undefined-identifiers", func(u *Unit) []Diagnostic {
+		if u.AST == nil {
+			return nil
+		}
+		r := resolver{}
+		r.stmts(universe.child(), u.AST.Stmts)
+		return r.diags
+	}}
+}
+
+// Forbid reports every node of the syntax tree for which match returns
+// true, with the given message and severity
+//
+// Example:
+//   noWhile := nsigii.Forbid("no-while", "while loops are not allowed", nsigii.SeverityWarning,
+//       func(n nsigii.Node) bool {
+//           _, ok := n.(*nsigii.WhileStmt)
+//           return ok
+//       })
+func Forbid(name, message string, severity Severity, match func(Node) bool) Validator {
+	return ValidatorFunc{name, func(u *Unit) []Diagnostic {
+		if u.AST == nil {
+			return nil
+		}
+		var diags []Diagnostic
+		Inspect(u.AST, func(n Node) bool {
+			if n != nil && match(n) {
+				diags = append(diags, nodeDiagnostic(n, severity, message))
+			}
+			return true
+		})
+		return diags
+	}}
+}
+
+// scope is a lexical scope of declared names
+type scope struct {
+	parent *scope
+	names  map[string]bool
+}
+
+func (s *scope) child() *scope {
+	return &scope{parent: s, names: make(map[string]bool)}
+}
+
+func (s *scope) declared(name string) bool {
+	for ; s != nil; s = s.parent {
+		if s.names[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// resolver checks identifier uses against their scopes
+type resolver struct {
+	diags []Diagnostic
+}
+
+func (r *resolver) stmts(s *scope, list []Stmt) {
+	// Functions are visible throughout their block, so they may be called
+	// before, or from within, their own declaration
+	for _, st := range list {
+		if fn, ok := st.(*FuncDecl); ok {
+			s.names[fn.Name.Name()] = true
+		}
+	}
+	for _, st := range list {
+		r.stmt(s, st)
+	}
+}
+
+func (r *resolver) stmt(s *scope, st Stmt) {
+	switch st := st.(type) {
+	case nil:
+	case *LetStmt:
+		if st.Value != nil {
+			r.expr(s, st.Value)
+		}
+		s.names[st.Name.Name()] = true
+	case *FuncDecl:
+		fs := s.child()
+		for _, p := range st.Params {
+			fs.names[p.Name()] = true
+		}
+		r.stmts(fs.child(), st.Body.Stmts)
+	case *ReturnStmt:
+		if st.Value != nil {
+			r.expr(s, st.Value)
+		}
+	case *IfStmt:
+		r.expr(s, st.Cond)
+		r.stmts(s.child(), st.Then.Stmts)
+		r.stmt(s, st.Else)
+	case *WhileStmt:
+		r.expr(s, st.Cond)
+		r.stmts(s.child(), st.Body.Stmts)
+	case *ForStmt:
+		fs := s.child()
+		r.stmt(fs, st.Init)
+		if st.Cond != nil {
+			r.expr(fs, st.Cond)
+		}
+		r.stmt(fs, st.Post)
+		r.stmts(fs.child(), st.Body.Stmts)
+	case *Block:
+		r.stmts(s.child(), st.Stmts)
+	case *ExprStmt:
+		r.expr(s, st.X)
+	}
+}
+
+func (r *resolver) expr(s *scope, x Node) {
+	switch x := x.(type) {
+	case *Ident:
+		if !s.declared(x.Name()) {
+			r.diags = append(r.diags, nodeDiagnostic(x, SeverityError, "undefined: "+x.Name()))
+		}
+	case *SelectorExpr:
+		// The selected name belongs to X, not to any scope
+		r.expr(s, x.X)
+	case *RuleNode:
+	default:
+		for _, child := range x.Children() {
+			r.expr(s, child)
+		}
+	}
+}
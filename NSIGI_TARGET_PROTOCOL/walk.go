@@ -0,0 +1,297 @@
+package nsigii
+
+import "fmt"
+
+// ============================================================================
+// AST Traversal
+// ============================================================================
+
+// Visitor's Visit is called for each node met by Walk. If the returned
+// visitor w is not nil, Walk visits each of the node's children with w,
+// followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(n Node) (w Visitor)
+}
+
+// Walk traverses a syntax tree depth-first, in source order
+func Walk(v Visitor, n Node) {
+	if v = v.Visit(n); v == nil {
+		return
+	}
+	for _, child := range n.Children() {
+		Walk(v, child)
+	}
+	v.Visit(nil)
+}
+
+// inspector adapts a function to a Visitor
+type inspector func(Node) bool
+
+func (f inspector) Visit(n Node) Visitor {
+	if f(n) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses a syntax tree depth-first, calling f for each node and
+// then f(nil) once its children are done. The children of a node are
+// skipped if f returns false for it.
+//
+// Example:
+//   nsigii.Inspect(prog, func(n nsigii.Node) bool {
+//       if call, ok := n.(*nsigii.CallExpr); ok {
+//           fmt.Println("call at", call.Pos().Line)
+//       }
+//       return true
+//   })
+func Inspect(n Node, f func(Node) bool) {
+	Walk(inspector(f), n)
+}
+
+// ============================================================================
+// AST Rewriting
+// ============================================================================
+
+// Rewrite transforms a syntax tree in place, bottom-up: the children of
+// each node are rewritten first, then fn is called with the node and its
+// result takes the node's place. fn returns its argument to keep a node.
+// The new root is returned.
+//
+// Returning nil removes a node from a statement list or an optional slot
+// (a declaration's value, a bare return, an else branch, the clauses of a
+// for loop). Removing a required node, or replacing a node with one that
+// does not fit its slot (a statement where an expression belongs), is an
+// error; the offending slot keeps its old node, but rewrites already made
+// elsewhere in the tree remain.
+//
+// Example:
+//   // Fold "x * 1" to "x"
+//   prog2, err := nsigii.Rewrite(prog, func(n nsigii.Node) nsigii.Node {
+//       if b, ok := n.(*nsigii.BinaryExpr); ok && b.Op.Text == "*" {
+//           if lit, ok := b.Y.(*nsigii.Literal); ok && lit.Token.Text == "1" {
+//               return b.X
+//           }
+//       }
+//       return n
+//   })
+func Rewrite(root Node, fn func(Node) Node) (Node, error) {
+	r := rewriter{fn: fn}
+	return r.node(root)
+}
+
+// rewriter carries the rewrite function through the tree
+type rewriter struct {
+	fn func(Node) Node
+}
+
+// node rewrites the children of n, then n itself
+func (r rewriter) node(n Node) (Node, error) {
+	if err := r.children(n); err != nil {
+		return nil, err
+	}
+	return r.fn(n), nil
+}
+
+func (r rewriter) expr(x Expr, optional bool) (Expr, error) {
+	if x == nil {
+		return nil, nil
+	}
+	n, err := r.slot(x, optional)
+	if err != nil {
+		return x, err
+	}
+	if n == nil {
+		return nil, nil
+	}
+	e, ok := n.(Expr)
+	if !ok {
+		return x, fmt.Errorf("rewrite: cannot replace %T with %T", x, n)
+	}
+	return e, nil
+}
+
+func (r rewriter) stmt(s Stmt, optional bool) (Stmt, error) {
+	if s == nil {
+		return nil, nil
+	}
+	n, err := r.slot(s, optional)
+	if err != nil {
+		return s, err
+	}
+	if n == nil {
+		return nil, nil
+	}
+	st, ok := n.(Stmt)
+	if !ok {
+		return s, fmt.Errorf("rewrite: cannot replace %T with %T", s, n)
+	}
+	return st, nil
+}
+
+func (r rewriter) block(b *Block) (*Block, error) {
+	n, err := r.slot(b, false)
+	if err != nil {
+		return b, err
+	}
+	nb, ok := n.(*Block)
+	if !ok {
+		return b, fmt.Errorf("rewrite: cannot replace %T with %T", b, n)
+	}
+	return nb, nil
+}
+
+func (r rewriter) ident(id *Ident) (*Ident, error) {
+	n, err := r.slot(id, false)
+	if err != nil {
+		return id, err
+	}
+	nid, ok := n.(*Ident)
+	if !ok {
+		return id, fmt.Errorf("rewrite: cannot replace %T with %T", id, n)
+	}
+	return nid, nil
+}
+
+// slot rewrites the node filling a slot of its parent. A nil result is an
+// error unless the slot is optional. On error, callers keep the old node so
+// the tree stays well-formed.
+func (r rewriter) slot(old Node, optional bool) (Node, error) {
+	n, err := r.node(old)
+	if err != nil {
+		return nil, err
+	}
+	if n == nil && !optional {
+		return nil, fmt.Errorf("rewrite: cannot remove required %T", old)
+	}
+	return n, nil
+}
+
+// stmts rewrites a statement list, dropping removed statements
+func (r rewriter) stmts(list []Stmt) ([]Stmt, error) {
+	out := make([]Stmt, 0, len(list))
+	for _, s := range list {
+		ns, err := r.stmt(s, true)
+		if err != nil {
+			return nil, err
+		}
+		if ns != nil {
+			out = append(out, ns)
+		}
+	}
+	return out, nil
+}
+
+// exprs rewrites an expression list; elements are required
+func (r rewriter) exprs(list []Expr) error {
+	for i, x := range list {
+		nx, err := r.expr(x, false)
+		list[i] = nx
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// children rewrites the children of n in place
+func (r rewriter) children(n Node) (err error) {
+	switch n := n.(type) {
+	case *Program:
+		var stmts []Stmt
+		if stmts, err = r.stmts(n.Stmts); err == nil {
+			n.Stmts = stmts
+		}
+	case *Block:
+		var stmts []Stmt
+		if stmts, err = r.stmts(n.Stmts); err == nil {
+			n.Stmts = stmts
+		}
+	case *LetStmt:
+		if n.Name, err = r.ident(n.Name); err == nil {
+			n.Value, err = r.expr(n.Value, true)
+		}
+	case *FuncDecl:
+		if n.Name, err = r.ident(n.Name); err != nil {
+			return err
+		}
+		for i, p := range n.Params {
+			if n.Params[i], err = r.ident(p); err != nil {
+				return err
+			}
+		}
+		n.Body, err = r.block(n.Body)
+	case *ReturnStmt:
+		n.Value, err = r.expr(n.Value, true)
+	case *IfStmt:
+		if n.Cond, err = r.expr(n.Cond, false); err != nil {
+			return err
+		}
+		if n.Then, err = r.block(n.Then); err != nil {
+			return err
+		}
+		n.Else, err = r.stmt(n.Else, true)
+	case *WhileStmt:
+		if n.Cond, err = r.expr(n.Cond, false); err == nil {
+			n.Body, err = r.block(n.Body)
+		}
+	case *ForStmt:
+		if n.Init, err = r.stmt(n.Init, true); err != nil {
+			return err
+		}
+		if n.Cond, err = r.expr(n.Cond, true); err != nil {
+			return err
+		}
+		if n.Post, err = r.stmt(n.Post, true); err != nil {
+			return err
+		}
+		n.Body, err = r.block(n.Body)
+	case *ImportStmt:
+		var path Node
+		if path, err = r.slot(n.Path, false); err != nil {
+			return err
+		}
+		lit, ok := path.(*Literal)
+		if !ok {
+			return fmt.Errorf("rewrite: cannot replace %T with %T", n.Path, path)
+		}
+		n.Path = lit
+	case *ExprStmt:
+		n.X, err = r.expr(n.X, false)
+	case *ParenExpr:
+		n.X, err = r.expr(n.X, false)
+	case *UnaryExpr:
+		n.X, err = r.expr(n.X, false)
+	case *BinaryExpr:
+		if n.X, err = r.expr(n.X, false); err == nil {
+			n.Y, err = r.expr(n.Y, false)
+		}
+	case *AssignExpr:
+		if n.X, err = r.expr(n.X, false); err == nil {
+			n.Y, err = r.expr(n.Y, false)
+		}
+	case *CallExpr:
+		if n.Fun, err = r.expr(n.Fun, false); err == nil {
+			err = r.exprs(n.Args)
+		}
+	case *IndexExpr:
+		if n.X, err = r.expr(n.X, false); err == nil {
+			n.Index, err = r.expr(n.Index, false)
+		}
+	case *SelectorExpr:
+		if n.X, err = r.expr(n.X, false); err == nil {
+			n.Sel, err = r.ident(n.Sel)
+		}
+	case *ListExpr:
+		err = r.exprs(n.Elems)
+	case *RuleNode:
+		for i, child := range n.Nodes {
+			var nc Node
+			if nc, err = r.slot(child, false); err != nil {
+				return err
+			}
+			n.Nodes[i] = nc
+		}
+	}
+	return err
+}